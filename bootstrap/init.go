@@ -3,12 +3,15 @@ package bootstrap
 import (
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/aria2"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/auth"
 	"github.com/HFO4/cloudreve/pkg/cache"
 	"github.com/HFO4/cloudreve/pkg/conf"
 	"github.com/HFO4/cloudreve/pkg/crontab"
 	"github.com/HFO4/cloudreve/pkg/email"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/task"
+	"github.com/HFO4/cloudreve/pkg/task/slavetask"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,11 +26,15 @@ func Init(path string) {
 	cache.Init()
 	if conf.SystemConfig.Mode == "master" {
 		model.Init()
+		audit.Init()
 		task.Init()
 		aria2.Init(false)
 		email.Init()
+		filesystem.InitSearchIndex()
 		crontab.Init()
 		InitStatic()
+	} else {
+		slavetask.Init()
 	}
 	auth.Init()
 }
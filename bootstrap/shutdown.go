@@ -0,0 +1,29 @@
+package bootstrap
+
+import (
+	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchShutdown 收到退出信号后保存需持久化的数据再退出
+func WatchShutdown() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		util.Log().Info("收到信号 %s，正在退出...", sig)
+		Shutdown()
+		os.Exit(0)
+	}()
+}
+
+// Shutdown 保存需持久化的数据
+func Shutdown() {
+	if conf.SystemConfig.Mode == "master" {
+		search.Flush()
+	}
+}
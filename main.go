@@ -28,6 +28,7 @@ func main() {
 	}
 
 	api := routers.InitRouter()
+	bootstrap.WatchShutdown()
 
 	// 如果启用了SSL
 	if conf.SSLConfig.CertPath != "" {
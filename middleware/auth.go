@@ -6,7 +6,9 @@ import (
 	"crypto/md5"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	model "github.com/HFO4/cloudreve/models"
@@ -15,6 +17,8 @@ import (
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/onedrive"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/oss"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/upyun"
+	"github.com/HFO4/cloudreve/pkg/ldap"
+	"github.com/HFO4/cloudreve/pkg/lockout"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-contrib/sessions"
@@ -44,9 +48,15 @@ func SignRequired() gin.HandlerFunc {
 	}
 }
 
-// CurrentUser 获取登录用户
+// CurrentUser 获取登录用户，请求携带 API 令牌时使用令牌认证
 func CurrentUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			tokenUser(c, strings.TrimPrefix(header, "Bearer "))
+			c.Next()
+			return
+		}
+
 		session := sessions.Default(c)
 		uid := session.Get("user_id")
 		if uid != nil {
@@ -59,6 +69,53 @@ func CurrentUser() gin.HandlerFunc {
 	}
 }
 
+// tokenUser 使用 API 令牌认证用户，令牌无效、过期或所有者不可用时视为未登录
+func tokenUser(c *gin.Context, plain string) {
+	if !strings.HasPrefix(plain, model.APITokenPrefix) {
+		return
+	}
+
+	token, err := model.GetAPITokenByPlain(plain)
+	if err != nil || token.IsExpired() {
+		return
+	}
+
+	user, err := model.GetActiveUserByID(token.UserID)
+	if err != nil {
+		return
+	}
+
+	if err := token.Touch(); err != nil {
+		util.Log().Warning("无法更新 API 令牌最后使用时间, %s", err)
+	}
+	c.Set("user", &user)
+	c.Set("api_token", token)
+}
+
+// TokenScope 通过 API 令牌访问时，要求令牌拥有 scope 权限；通过会话登录时不做限制
+func TokenScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, ok := c.Get("api_token"); ok && !token.(*model.APIToken).HasScope(scope) {
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "API 令牌无权访问此接口", nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionRequired 只允许通过会话登录访问，禁止使用 API 令牌
+func SessionRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_token"); ok {
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "此接口不允许使用 API 令牌访问", nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // AuthRequired 需要登录
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -98,33 +155,43 @@ func WebDAVAuth() gin.HandlerFunc {
 		} else {
 			expectedUser, err = model.GetUserByNick(username)
 		}
+
+		// 登录失败次数过多时拒绝登录，不再校验密码
+		ip := c.ClientIP()
+		if remain := lockout.Check(expectedUser.ID, ip); remain > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(remain.Seconds()))))
+			c.Status(http.StatusTooManyRequests)
+			c.Abort()
+			return
+		}
+
 		if err != nil {
+			lockout.Fail(0, ip)
 			c.Status(http.StatusUnauthorized)
 			c.Abort()
 			return
 		}
 
-		// 密码正确？
-		if authOK, _ := expectedUser.CheckPassword(password); !authOK {
+		// 使用应用密码登录，用户的登录密码不可用于 WebDAV
+		webdav, err := model.GetWebdavByPassword(password, expectedUser.ID)
+		if err != nil {
+			lockout.Fail(expectedUser.ID, ip)
 			c.Status(http.StatusUnauthorized)
 			c.Abort()
 			return
 		}
-		webdav := &model.Webdav{
-			Name:     "",
-			Password: "",
-			UserID:   expectedUser.ID,
-			Root:     "/",
+		lockout.Success(expectedUser.ID)
+
+		// 已从 LDAP 目录中移除的用户不可继续使用 WebDAV
+		if ldap.Enabled() {
+			if err := ldap.Check(&expectedUser); err != nil {
+				util.Log().Warning("无法验证 LDAP 用户[%s], %s", expectedUser.Email, err)
+				c.Status(http.StatusUnauthorized)
+				c.Abort()
+				return
+			}
 		}
 
-		//// 密码正确？
-		//webdav, err := model.GetWebdavByPassword(password, expectedUser.ID)
-		//if err != nil {
-		//	c.Status(http.StatusUnauthorized)
-		//	c.Abort()
-		//	return
-		//}
-
 		// 用户组已启用WebDAV？
 		if !expectedUser.Group.WebDAVEnabled {
 			c.Status(http.StatusForbidden)
@@ -18,6 +18,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 var mock sqlmock.Sqlmock
@@ -62,6 +63,121 @@ func TestCurrentUser(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestCurrentUser_Token(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	sessionFunc := Session("233")
+
+	// 格式不符的令牌
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer 123")
+		sessionFunc(c)
+		CurrentUser()(c)
+		user, _ := c.Get("user")
+		asserts.Nil(user)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 令牌不存在
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer crt_123")
+		sessionFunc(c)
+		mock.ExpectQuery("SELECT(.+)api_tokens(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		CurrentUser()(c)
+		user, _ := c.Get("user")
+		asserts.Nil(user)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 令牌已过期
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer crt_123")
+		sessionFunc(c)
+		mock.ExpectQuery("SELECT(.+)api_tokens(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "expires_at"}).AddRow(1, 1, time.Now().Add(-time.Hour)))
+		CurrentUser()(c)
+		user, _ := c.Get("user")
+		asserts.Nil(user)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功，优先于会话
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		c.Request.Header.Set("Authorization", "Bearer crt_123")
+		sessionFunc(c)
+		util.SetSession(c, map[string]interface{}{"user_id": 2})
+		mock.ExpectQuery("SELECT(.+)api_tokens(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "scopes"}).AddRow(1, 1, "file:read"))
+		mock.ExpectQuery("SELECT(.+)users(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "deleted_at", "email", "options"}).AddRow(1, nil, "admin@cloudreve.org", "{}"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)api_tokens(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		CurrentUser()(c)
+		user, _ := c.Get("user")
+		asserts.EqualValues(1, user.(*model.User).ID)
+		token, _ := c.Get("api_token")
+		asserts.True(token.(*model.APIToken).HasScope(model.ScopeFileRead))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestTokenScope(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+
+	// 会话登录，不做限制
+	{
+		c, _ := gin.CreateTestContext(rec)
+		TokenScope(model.ScopeAdmin)(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 令牌拥有权限
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("api_token", &model.APIToken{Scopes: "share,file:write"})
+		TokenScope(model.ScopeFileRead)(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 令牌无权限
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("api_token", &model.APIToken{Scopes: "file:read"})
+		TokenScope(model.ScopeFileWrite)(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
+func TestSessionRequired(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+
+	// 会话登录
+	{
+		c, _ := gin.CreateTestContext(rec)
+		SessionRequired()(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 使用令牌
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("api_token", &model.APIToken{Scopes: "admin"})
+		SessionRequired()(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
 func TestAuthRequired(t *testing.T) {
 	asserts := assert.New(t)
 	rec := httptest.NewRecorder()
@@ -42,9 +42,14 @@ func CSRFInit() gin.HandlerFunc {
 	}
 }
 
-// CSRFCheck 检查CSRF标记
+// CSRFCheck 检查CSRF标记，使用 API 令牌的请求无需检查
 func CSRFCheck() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if _, ok := c.Get("api_token"); ok {
+			c.Next()
+			return
+		}
+
 		if check, ok := util.GetSession(c, "CSRF").(bool); ok && check {
 			c.Next()
 			return
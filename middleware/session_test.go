@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/conf"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-gonic/gin"
@@ -69,4 +70,14 @@ func TestCSRFCheck(t *testing.T) {
 		CSRFCheck()(c)
 		asserts.True(c.IsAborted())
 	}
+
+	// 使用 API 令牌，无需检查
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		sessionFunc(c)
+		c.Set("api_token", &model.APIToken{})
+		CSRFCheck()(c)
+		asserts.False(c.IsAborted())
+	}
 }
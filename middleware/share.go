@@ -73,6 +73,38 @@ func ShareCanPreview() gin.HandlerFunc {
 	}
 }
 
+// ShareCanBrowse 检查分享内容是否可被浏览，文件收集只能上传，不能浏览或下载其中的内容
+func ShareCanBrowse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if !share.(*model.Share).FileRequest {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "此分享仅用于收集文件", nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
+// ShareIsFileRequest 检查分享是否为文件收集
+func ShareIsFileRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if share.(*model.Share).FileRequest {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "此分享不接收上传", nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
 // CheckShareUnlocked 检查分享是否已解锁
 func CheckShareUnlocked() gin.HandlerFunc {
 	return func(c *gin.Context) {
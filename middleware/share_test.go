@@ -79,6 +79,57 @@ func TestShareCanPreview(t *testing.T) {
 	}
 }
 
+func TestShareCanBrowse(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareCanBrowse()
+
+	// 无分享上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 普通分享
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 文件收集
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{FileRequest: true})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
+func TestShareIsFileRequest(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareIsFileRequest()
+
+	// 普通分享
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 文件收集
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{FileRequest: true})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+}
+
 func TestCheckShareUnlocked(t *testing.T) {
 	asserts := assert.New(t)
 	rec := httptest.NewRecorder()
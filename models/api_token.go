@@ -0,0 +1,127 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// API 令牌的权限范围
+const (
+	// ScopeFileRead 列出、预览、下载文件
+	ScopeFileRead = "file:read"
+	// ScopeFileWrite 上传、修改、移动、删除文件，包含 ScopeFileRead
+	ScopeFileWrite = "file:write"
+	// ScopeShare 管理分享
+	ScopeShare = "share"
+	// ScopeAdmin 访问管理面板接口，仍要求令牌所有者为管理员
+	ScopeAdmin = "admin"
+)
+
+// APITokenPrefix 令牌明文的前缀，便于识别
+const APITokenPrefix = "crt_"
+
+// apiTokenTouchInterval 更新令牌最后使用时间的最小间隔
+const apiTokenTouchInterval = time.Minute
+
+// APIToken 个人访问令牌
+type APIToken struct {
+	gorm.Model
+	UserID uint   `gorm:"index:api_token_user_id"`
+	Name   string // 令牌名称
+	Token  string `json:"-" gorm:"unique_index:api_token_hash"` // 令牌明文的 SHA-256 摘要
+	Scopes string // 以逗号分隔的权限范围
+	// 过期时间，为空表示永不过期
+	ExpiresAt *time.Time
+	// 最后使用时间
+	LastUsedAt *time.Time
+}
+
+// IsValidScope 返回 scope 是否为合法的权限范围
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeFileRead, ScopeFileWrite, ScopeShare, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// Generate 生成新的令牌明文，并将其摘要保存在 token 中
+func (token *APIToken) Generate() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	plain := APITokenPrefix + hex.EncodeToString(buf)
+	token.Token = hashAPIToken(plain)
+	return plain, nil
+}
+
+// hashAPIToken 计算令牌明文的摘要，数据库中只保存摘要
+func hashAPIToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create 创建令牌
+func (token *APIToken) Create() (uint, error) {
+	if err := DB.Create(token).Error; err != nil {
+		return 0, err
+	}
+	return token.ID, nil
+}
+
+// ScopeList 返回令牌的权限范围列表
+func (token *APIToken) ScopeList() []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
+// HasScope 返回令牌是否拥有 scope 权限
+func (token *APIToken) HasScope(scope string) bool {
+	for _, s := range token.ScopeList() {
+		if s == scope || (s == ScopeFileWrite && scope == ScopeFileRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired 返回令牌是否已过期
+func (token *APIToken) IsExpired() bool {
+	return token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)
+}
+
+// Touch 记录令牌的最后使用时间，距上次记录不足 apiTokenTouchInterval 时忽略
+func (token *APIToken) Touch() error {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < apiTokenTouchInterval {
+		return nil
+	}
+	return DB.Model(token).UpdateColumn("last_used_at", now).Error
+}
+
+// GetAPITokenByPlain 根据令牌明文查找令牌
+func GetAPITokenByPlain(plain string) (*APIToken, error) {
+	token := &APIToken{}
+	res := DB.Where("token = ?", hashAPIToken(plain)).First(token)
+	return token, res.Error
+}
+
+// ListAPITokens 列出用户的所有令牌
+func ListAPITokens(uid uint) []APIToken {
+	var tokens []APIToken
+	DB.Where("user_id = ?", uid).Order("created_at desc").Find(&tokens)
+	return tokens
+}
+
+// DeleteAPITokenByID 根据令牌ID和UID删除令牌
+func DeleteAPITokenByID(id, uid uint) {
+	DB.Unscoped().Where("user_id = ? and id = ?", uid, id).Delete(&APIToken{})
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIToken_Generate(t *testing.T) {
+	asserts := assert.New(t)
+	token := APIToken{}
+	plain, err := token.Generate()
+	asserts.NoError(err)
+	asserts.True(strings.HasPrefix(plain, APITokenPrefix))
+	asserts.Equal(hashAPIToken(plain), token.Token)
+	asserts.NotEqual(plain, token.Token)
+
+	other := APIToken{}
+	otherPlain, _ := other.Generate()
+	asserts.NotEqual(plain, otherPlain)
+}
+
+func TestAPIToken_Create(t *testing.T) {
+	asserts := assert.New(t)
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		token := APIToken{}
+		id, err := token.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		token := APIToken{}
+		id, err := token.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestAPIToken_HasScope(t *testing.T) {
+	asserts := assert.New(t)
+	token := APIToken{Scopes: "file:write,share"}
+	asserts.Equal([]string{"file:write", "share"}, token.ScopeList())
+	asserts.True(token.HasScope(ScopeFileRead))
+	asserts.True(token.HasScope(ScopeFileWrite))
+	asserts.True(token.HasScope(ScopeShare))
+	asserts.False(token.HasScope(ScopeAdmin))
+
+	token.Scopes = ""
+	asserts.Empty(token.ScopeList())
+	asserts.False(token.HasScope(ScopeFileRead))
+}
+
+func TestAPIToken_IsExpired(t *testing.T) {
+	asserts := assert.New(t)
+	token := APIToken{}
+	asserts.False(token.IsExpired())
+
+	expires := time.Now().Add(time.Hour)
+	token.ExpiresAt = &expires
+	asserts.False(token.IsExpired())
+
+	expires = time.Now().Add(-time.Hour)
+	asserts.True(token.IsExpired())
+}
+
+func TestAPIToken_Touch(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 距上次使用不足间隔，忽略
+	{
+		recent := time.Now()
+		token := APIToken{LastUsedAt: &recent}
+		asserts.NoError(token.Touch())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新最后使用时间
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)last_used_at(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		token := APIToken{}
+		token.ID = 1
+		asserts.NoError(token.Touch())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetAPITokenByPlain(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WithArgs(hashAPIToken("crt_123")).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err := GetAPITokenByPlain("crt_123")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Error(err)
+}
+
+func TestListAPITokens(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	res := ListAPITokens(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Len(res, 1)
+}
+
+func TestDeleteAPITokenByID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	DeleteAPITokenByID(1, 1)
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// AuditLog 审计日志，记录用户及管理员对文件、分享、账户等的操作
+type AuditLog struct {
+	gorm.Model
+	UserID    uint   `gorm:"index:audit_user_id"` // 操作者ID，未登录时为0
+	Actor     string // 操作者邮箱或登录时使用的用户名
+	IP        string
+	UserAgent string `gorm:"type:text"`
+	Action    string `gorm:"index:audit_action"` // 操作类型
+	Target    string `gorm:"type:text"`          // 操作对象
+	Result    string // 操作结果
+	Detail    string `gorm:"type:text"` // 失败原因等附加信息
+}
+
+const (
+	// AuditSuccess 操作成功
+	AuditSuccess = "success"
+	// AuditFailed 操作失败
+	AuditFailed = "failed"
+)
+
+// Create 创建审计日志
+func (log *AuditLog) Create() error {
+	return DB.Create(log).Error
+}
+
+// DeleteAuditLogsBefore 删除 before 之前创建的审计日志，返回删除的条数
+func DeleteAuditLogsBefore(before time.Time) (int64, error) {
+	result := DB.Unscoped().Where("created_at < ?", before).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
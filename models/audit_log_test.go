@@ -0,0 +1,47 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_Create(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)audit_logs(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		log := AuditLog{UserID: 1, Action: "login", Result: AuditSuccess}
+		asserts.NoError(log.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, log.ID)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)audit_logs(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		log := AuditLog{UserID: 1, Action: "login", Result: AuditSuccess}
+		asserts.Error(log.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestDeleteAuditLogsBefore(t *testing.T) {
+	asserts := assert.New(t)
+	before := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)audit_logs(.+)created_at(.+)").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	deleted, err := DeleteAuditLogsBefore(before)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(3, deleted)
+}
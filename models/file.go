@@ -24,6 +24,8 @@ type File struct {
 	FolderID   uint `gorm:"index:folder_id;unique_index:idx_only_one"`
 	PolicyID   uint
 	AccessDate time.Time
+	Hash       string `gorm:"size:64;index:hash"` // 文件内容的 SHA-256 摘要
+	Encrypted  bool   // 物理文件是否加密存储
 
 	// 关联模型
 	Policy Policy `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -135,6 +137,57 @@ func GetFilesByKeywords(uid uint, keywords ...interface{}) ([]File, error) {
 	return files, result.Error
 }
 
+// GetFilesAfterID 按ID顺序列出ID大于id的至多limit个文件，用于分批遍历所有文件
+func GetFilesAfterID(id uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.Where("id > ?", id).Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// GetFilesToMigrate 按ID顺序列出ID大于afterID、尚未位于存储策略dstPolicy的至多limit个文件，
+// 包括回收站中的文件。scope 为 user、group 或 policy，targetID 为对应的用户、用户组或存储策略ID
+func GetFilesToMigrate(scope string, targetID, dstPolicy, afterID uint, limit int) ([]File, error) {
+	var files []File
+	tx := DB.Unscoped().Where("id > ? AND policy_id <> ?", afterID, dstPolicy)
+	switch scope {
+	case StorageScopeUser:
+		tx = tx.Where("user_id = ?", targetID)
+	case StorageScopeGroup:
+		tx = tx.Where("user_id in (?)", DB.Model(&User{}).Select("id").Where("group_id = ?", targetID).QueryExpr())
+	case StorageScopePolicy:
+		tx = tx.Where("policy_id = ?", targetID)
+	default:
+		return files, fmt.Errorf("未知的迁移范围 %q", scope)
+	}
+	result := tx.Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// MigrateFileSource 将使用存储策略srcPolicy下物理文件srcSource的所有文件、历史版本及副本记录
+// 指向存储策略dstPolicy下的物理文件dstSource，软链接及回收站中的文件会一同更新。
+// encrypted 为新物理文件是否加密存储，副本的加密状态不受影响
+func MigrateFileSource(srcPolicy uint, srcSource string, dstPolicy uint, dstSource string, encrypted bool) error {
+	tx := DB.Begin()
+	updates := map[string]interface{}{"policy_id": dstPolicy, "source_name": dstSource}
+	fileUpdates := map[string]interface{}{"policy_id": dstPolicy, "source_name": dstSource, "encrypted": encrypted}
+	if err := tx.Unscoped().Model(&File{}).Where("policy_id = ? AND source_name = ?", srcPolicy, srcSource).
+		UpdateColumns(fileUpdates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Model(&FileVersion{}).Where("policy_id = ? AND source_name = ?", srcPolicy, srcSource).
+		UpdateColumns(fileUpdates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&FileReplica{}).Where("policy_id = ? AND source_name = ?", srcPolicy, srcSource).
+		UpdateColumns(updates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
 // GetChildFilesOfFolders 批量检索目录子文件
 func GetChildFilesOfFolders(folders *[]Folder) ([]File, error) {
 	// 将所有待删除目录ID抽离，以便检索文件
@@ -157,14 +210,15 @@ func (file *File) GetPolicy() *Policy {
 	return &file.Policy
 }
 
-// RemoveFilesWithSoftLinks 去除给定的文件列表中有软链接的文件
+// RemoveFilesWithSoftLinks 去除给定的文件列表中有软链接的文件，
+// 开启上传去重后内容相同的文件同样以软链接的形式共用物理文件
 func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 	// 结果值
 	filteredFiles := make([]File, 0)
 
-	// 查询软链接的文件
+	// 查询软链接的文件，回收站中的文件同样引用着物理文件
 	var filesWithSoftLinks []File
-	tx := DB
+	tx := DB.Unscoped()
 	for _, value := range files {
 		tx = tx.Or("source_name = ? and policy_id = ? and id != ?", value.SourceName, value.PolicyID, value.ID)
 	}
@@ -229,6 +283,34 @@ func (file *File) UpdateSize(value uint64) error {
 	return DB.Model(&file).Update("size", value).Error
 }
 
+// UpdateHash 更新文件的内容摘要
+func (file *File) UpdateHash(value string) error {
+	return DB.Model(&file).Update("hash", value).Error
+}
+
+// UpdateSource 更新文件的源文件名及其是否加密存储
+func (file *File) UpdateSource(sourceName string, encrypted bool) error {
+	return DB.Model(&file).Updates(map[string]interface{}{
+		"source_name": sourceName,
+		"encrypted":   encrypted,
+	}).Error
+}
+
+// UpdateEncrypted 更新物理文件是否加密存储
+func (file *File) UpdateEncrypted(value bool) error {
+	return DB.Model(&file).Update("encrypted", value).Error
+}
+
+// GetFileByHash 查找同一存储策略下内容相同且物理文件不同的文件，
+// 回收站中的文件同样持有物理文件
+func GetFileByHash(hash string, size uint64, policyID uint, sourceName string) (*File, error) {
+	var file File
+	result := DB.Unscoped().
+		Where("hash = ? AND size = ? AND policy_id = ? AND source_name <> ?", hash, size, policyID, sourceName).
+		First(&file)
+	return &file, result.Error
+}
+
 // UpdateSourceName 更新文件的源文件名
 func (file *File) UpdateSourceName(value string) error {
 	return DB.Model(&file).Update("source_name", value).Error
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// FileReplica 物理文件在副本存储策略下的副本，以原存储策略ID及路径标识，
+// 共用同一物理文件的软链接共享副本
+type FileReplica struct {
+	gorm.Model
+	PolicyID        uint   `gorm:"index:replica_policy_id"`
+	SourceName      string `gorm:"type:text"`
+	ReplicaPolicyID uint
+	ReplicaSource   string `gorm:"type:text"`
+	Size            uint64
+	Encrypted       bool // 副本是否加密存储
+}
+
+// SaveFileReplica 保存副本记录，同一物理文件在同一副本存储策略下已有记录时更新副本路径
+func SaveFileReplica(replica *FileReplica) error {
+	return DB.Where(FileReplica{
+		PolicyID:        replica.PolicyID,
+		SourceName:      replica.SourceName,
+		ReplicaPolicyID: replica.ReplicaPolicyID,
+	}).Assign(map[string]interface{}{
+		"replica_source": replica.ReplicaSource,
+		"size":           replica.Size,
+		"encrypted":      replica.Encrypted,
+	}).FirstOrCreate(replica).Error
+}
+
+// GetFileReplicas 列出存储策略policyID下物理文件sourceName的所有副本
+func GetFileReplicas(policyID uint, sourceName string) ([]FileReplica, error) {
+	var replicas []FileReplica
+	result := DB.Where("policy_id = ? AND source_name = ?", policyID, sourceName).Find(&replicas)
+	return replicas, result.Error
+}
+
+// GetReplicasBySources 列出存储策略policyID下多个物理文件的副本
+func GetReplicasBySources(policyID uint, sources []string) ([]FileReplica, error) {
+	var replicas []FileReplica
+	result := DB.Where("policy_id = ? AND source_name in (?)", policyID, sources).Find(&replicas)
+	return replicas, result.Error
+}
+
+// DeleteFileReplicaByIDs 根据ID批量删除副本记录
+func DeleteFileReplicaByIDs(ids []uint) error {
+	return DB.Where("id in (?)", ids).Delete(&FileReplica{}).Error
+}
+
+// AsFile 将副本包装为以 file 为基础的文件对象，用于复用文件的下载处理流程
+func (replica *FileReplica) AsFile(file *File) File {
+	replicaFile := *file
+	replicaFile.SourceName = replica.ReplicaSource
+	replicaFile.PolicyID = replica.ReplicaPolicyID
+	replicaFile.Encrypted = replica.Encrypted
+	replicaFile.Policy = Policy{}
+	return replicaFile
+}
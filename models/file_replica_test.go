@@ -0,0 +1,93 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSaveFileReplica(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 新建
+	{
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		replica := &FileReplica{PolicyID: 1, SourceName: "a.txt", ReplicaPolicyID: 2, ReplicaSource: "b.txt", Size: 10}
+		asserts.NoError(SaveFileReplica(replica))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, replica.ID)
+	}
+
+	// 已存在，更新副本路径
+	{
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_source", "encrypted"}).AddRow(3, "old.txt", true))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)file_replicas(.+)encrypted(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		replica := &FileReplica{PolicyID: 1, SourceName: "a.txt", ReplicaPolicyID: 2, ReplicaSource: "b.txt", Size: 10}
+		asserts.NoError(SaveFileReplica(replica))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(3, replica.ID)
+		asserts.Equal("b.txt", replica.ReplicaSource)
+		asserts.False(replica.Encrypted)
+	}
+
+	// 失败
+	{
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WillReturnError(errors.New("error"))
+		asserts.Error(SaveFileReplica(&FileReplica{}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetFileReplicas(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WithArgs(1, "a.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id"}).AddRow(1, 2))
+	replicas, err := GetFileReplicas(1, "a.txt")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(replicas, 1)
+
+	mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WithArgs(1, "a.txt", "b.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	replicas, err = GetReplicasBySources(1, []string{"a.txt", "b.txt"})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(replicas, 2)
+}
+
+func TestDeleteFileReplicaByIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)file_replicas(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteFileReplicaByIDs([]uint{1, 2}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestFileReplica_AsFile(t *testing.T) {
+	asserts := assert.New(t)
+	file := &File{
+		Model:      gorm.Model{ID: 1},
+		Name:       "a.txt",
+		SourceName: "a.txt",
+		PolicyID:   1,
+		Policy:     Policy{Model: gorm.Model{ID: 1}},
+	}
+	replica := FileReplica{ReplicaPolicyID: 2, ReplicaSource: "b.txt"}
+	replicaFile := replica.AsFile(file)
+	asserts.EqualValues(1, replicaFile.ID)
+	asserts.Equal("b.txt", replicaFile.SourceName)
+	asserts.EqualValues(2, replicaFile.PolicyID)
+	asserts.EqualValues(0, replicaFile.Policy.ID)
+	asserts.Equal("a.txt", file.SourceName)
+}
@@ -356,6 +356,41 @@ func TestFile_Updates(t *testing.T) {
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
 	}
+
+	// UpdateHash
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WithArgs("abc", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := file.UpdateHash("abc")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+}
+
+func TestGetFileByHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("abc", 10, 1, "new").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "old"))
+		file, err := GetFileByHash("abc", 10, 1, "new")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal("old", file.SourceName)
+	}
+
+	// 未找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("abc", 10, 1, "new").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}))
+		_, err := GetFileByHash("abc", 10, 1, "new")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
 }
 
 func TestFile_FileInfoInterface(t *testing.T) {
@@ -386,6 +421,19 @@ func TestFile_FileInfoInterface(t *testing.T) {
 	asserts.Equal("/test", file.GetPosition())
 }
 
+func TestGetFilesAfterID(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)id > (.+)ORDER BY id asc LIMIT 2").
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(5))
+	res, err := GetFilesAfterID(3, 2)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
+	asserts.EqualValues(4, res[0].ID)
+}
+
 func TestGetFilesByKeywords(t *testing.T) {
 	asserts := assert.New(t)
 
@@ -407,3 +455,57 @@ func TestGetFilesByKeywords(t *testing.T) {
 		asserts.Len(res, 1)
 	}
 }
+
+func TestGetFilesToMigrate(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 按用户
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id = \\?(.+)").WithArgs(10, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11).AddRow(12))
+		files, err := GetFilesToMigrate(StorageScopeUser, 1, 2, 10, 100)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 2)
+	}
+
+	// 按用户组
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id in \\(SELECT id FROM `users`(.+)").WithArgs(0, 2, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		files, err := GetFilesToMigrate(StorageScopeGroup, 3, 2, 0, 100)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 0)
+	}
+
+	// 未知范围
+	{
+		_, err := GetFilesToMigrate(StorageScopeSite, 0, 2, 0, 100)
+		asserts.Error(err)
+	}
+}
+
+func TestMigrateFileSource(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE `files`(.+)").WithArgs(true, 2, "new", 1, "old").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE `file_versions`(.+)").WithArgs(true, 2, "new", 1, "old").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE `file_replicas`(.+)").WithArgs(2, "new", 1, "old").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		asserts.NoError(MigrateFileSource(1, "old", 2, "new", true))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败时回滚
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE `files`(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(MigrateFileSource(1, "old", 2, "new", true))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
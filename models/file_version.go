@@ -0,0 +1,222 @@
+package model
+
+import (
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// FileVersion 文件历史版本，文件内容被覆盖时记录原有内容
+type FileVersion struct {
+	gorm.Model
+	FileID     uint   `gorm:"index:file_id"`
+	UserID     uint   `gorm:"index:file_version_user_id"`
+	SourceName string `gorm:"type:text"`
+	Hash       string `gorm:"size:64"`
+	Size       uint64
+	PolicyID   uint
+	Encrypted  bool
+}
+
+// Create 创建历史版本记录
+func (version *FileVersion) Create() (uint, error) {
+	if err := DB.Create(version).Error; err != nil {
+		util.Log().Warning("无法插入历史版本记录, %s", err)
+		return 0, err
+	}
+	return version.ID, nil
+}
+
+// AsFile 将历史版本包装为以 file 为基础的文件对象，
+// 用于复用文件的下载、删除等处理流程
+func (version *FileVersion) AsFile(file *File) File {
+	versionFile := *file
+	versionFile.SourceName = version.SourceName
+	versionFile.Hash = version.Hash
+	versionFile.Size = version.Size
+	versionFile.PolicyID = version.PolicyID
+	versionFile.Encrypted = version.Encrypted
+	versionFile.Policy = Policy{}
+	versionFile.UpdatedAt = version.CreatedAt
+	return versionFile
+}
+
+// GetVersionsByFileID 列出文件的所有历史版本，按创建时间倒序排列
+func GetVersionsByFileID(fileID, uid uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id = ? AND user_id = ?", fileID, uid).Order("id desc").Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByFileIDs 批量列出文件的历史版本
+func GetVersionsByFileIDs(fileIDs []uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id in (?)", fileIDs).Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByIDs 根据ID查找文件的历史版本
+func GetVersionsByIDs(ids []uint, fileID, uid uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("id in (?) AND file_id = ? AND user_id = ?", ids, fileID, uid).Find(&versions)
+	return versions, result.Error
+}
+
+// DeleteVersionsByIDs 根据ID批量删除历史版本记录
+func DeleteVersionsByIDs(ids []uint) error {
+	return DB.Where("id in (?)", ids).Unscoped().Delete(&FileVersion{}).Error
+}
+
+// RemoveFilesWithVersions 去除给定的文件列表中物理文件仍被其他文件的历史版本引用的文件
+func RemoveFilesWithVersions(files []File) ([]File, error) {
+	if len(files) == 0 {
+		return files, nil
+	}
+
+	var versions []FileVersion
+	tx := DB
+	for _, value := range files {
+		tx = tx.Or("source_name = ? and policy_id = ? and file_id != ?", value.SourceName, value.PolicyID, value.ID)
+	}
+	if err := tx.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	filteredFiles := make([]File, 0, len(files))
+	for i := 0; i < len(files); i++ {
+		referenced := false
+		for _, value := range versions {
+			if value.PolicyID == files[i].PolicyID && value.SourceName == files[i].SourceName {
+				referenced = true
+				break
+			}
+		}
+		if !referenced {
+			filteredFiles = append(filteredFiles, files[i])
+		}
+	}
+
+	return filteredFiles, nil
+}
+
+// RemoveVersionsWithSoftLinks 去除给定的历史版本列表中物理文件仍被文件或其他版本引用的版本
+func RemoveVersionsWithSoftLinks(versions []FileVersion) ([]FileVersion, error) {
+	if len(versions) == 0 {
+		return versions, nil
+	}
+
+	// 查询引用相同物理文件的文件，包括回收站中的文件
+	var files []File
+	fileTx := DB.Unscoped()
+	for _, value := range versions {
+		fileTx = fileTx.Or("source_name = ? and policy_id = ?", value.SourceName, value.PolicyID)
+	}
+	if err := fileTx.Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	// 查询引用相同物理文件的其他版本
+	var others []FileVersion
+	versionTx := DB
+	for _, value := range versions {
+		versionTx = versionTx.Or("source_name = ? and policy_id = ? and id != ?", value.SourceName, value.PolicyID, value.ID)
+	}
+	if err := versionTx.Find(&others).Error; err != nil {
+		return nil, err
+	}
+
+	filtered := make([]FileVersion, 0, len(versions))
+	for i := 0; i < len(versions); i++ {
+		referenced := false
+		for _, value := range files {
+			if value.PolicyID == versions[i].PolicyID && value.SourceName == versions[i].SourceName {
+				referenced = true
+				break
+			}
+		}
+		for _, value := range others {
+			if referenced {
+				break
+			}
+			if value.PolicyID == versions[i].PolicyID && value.SourceName == versions[i].SourceName {
+				referenced = true
+			}
+		}
+		if !referenced {
+			filtered = append(filtered, versions[i])
+		}
+	}
+
+	return filtered, nil
+}
+
+// ReplaceContent 将文件的当前内容保存为历史版本，并将文件指向新的物理文件
+func (file *File) ReplaceContent(sourceName, hash string, size uint64, policyID uint, encrypted bool) error {
+	tx := DB.Begin()
+
+	version := FileVersion{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		SourceName: file.SourceName,
+		Hash:       file.Hash,
+		Size:       file.Size,
+		PolicyID:   file.PolicyID,
+		Encrypted:  file.Encrypted,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(file).Updates(map[string]interface{}{
+		"source_name": sourceName,
+		"hash":        hash,
+		"size":        size,
+		"policy_id":   policyID,
+		"encrypted":   encrypted,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// RestoreVersion 将文件的当前内容与历史版本 version 交换，
+// 原有内容将成为新的历史版本
+func (file *File) RestoreVersion(version *FileVersion) error {
+	tx := DB.Begin()
+
+	// 当前内容保存为历史版本
+	current := FileVersion{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		SourceName: file.SourceName,
+		Hash:       file.Hash,
+		Size:       file.Size,
+		PolicyID:   file.PolicyID,
+		Encrypted:  file.Encrypted,
+	}
+	if err := tx.Create(&current).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 历史版本成为当前内容
+	if err := tx.Model(file).Updates(map[string]interface{}{
+		"source_name": version.SourceName,
+		"hash":        version.Hash,
+		"size":        version.Size,
+		"policy_id":   version.PolicyID,
+		"encrypted":   version.Encrypted,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(version).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
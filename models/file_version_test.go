@@ -0,0 +1,129 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFileVersion_AsFile(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{Name: "1.txt", SourceName: "current", Size: 10, PolicyID: 1}
+	file.ID = 2
+	version := FileVersion{SourceName: "old", Size: 5, PolicyID: 3}
+
+	res := version.AsFile(&file)
+	asserts.Equal("1.txt", res.Name)
+	asserts.EqualValues(2, res.ID)
+	asserts.Equal("old", res.SourceName)
+	asserts.EqualValues(5, res.Size)
+	asserts.EqualValues(3, res.PolicyID)
+	asserts.Equal("current", file.SourceName)
+}
+
+func TestGetVersionsByFileID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+		WithArgs(1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+	res, err := GetVersionsByFileID(1, 2)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
+}
+
+func TestRemoveFilesWithVersions(t *testing.T) {
+	asserts := assert.New(t)
+	files := []File{
+		{SourceName: "1.txt", PolicyID: 1, Model: gorm.Model{ID: 1}},
+		{SourceName: "2.txt", PolicyID: 1, Model: gorm.Model{ID: 2}},
+	}
+
+	// 空列表
+	{
+		res, err := RemoveFilesWithVersions([]File{})
+		asserts.NoError(err)
+		asserts.Len(res, 0)
+	}
+
+	// 查询出错
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		res, err := RemoveFilesWithVersions(files)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+
+	// 部分被引用
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(1, "2.txt", 1))
+		res, err := RemoveFilesWithVersions(files)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]File{files[0]}, res)
+	}
+}
+
+func TestRemoveVersionsWithSoftLinks(t *testing.T) {
+	asserts := assert.New(t)
+	versions := []FileVersion{
+		{SourceName: "1.txt", PolicyID: 1, Model: gorm.Model{ID: 1}},
+		{SourceName: "2.txt", PolicyID: 1, Model: gorm.Model{ID: 2}},
+		{SourceName: "3.txt", PolicyID: 1, Model: gorm.Model{ID: 3}},
+	}
+
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(1, "1.txt", 1))
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(4, "2.txt", 1))
+	res, err := RemoveVersionsWithSoftLinks(versions)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Equal([]FileVersion{versions[2]}, res)
+}
+
+func TestFile_ReplaceContent(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{SourceName: "old", Size: 1, PolicyID: 1}
+	file.ID = 1
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(file.ReplaceContent("new", "", 2, 1, true))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(file.ReplaceContent("new", "", 2, 1, true))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFile_RestoreVersion(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{SourceName: "current", Size: 1, PolicyID: 1}
+	file.ID = 1
+	version := FileVersion{SourceName: "old", Size: 2, PolicyID: 1}
+	version.ID = 2
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+	asserts.NoError(file.RestoreVersion(&version))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -58,11 +58,16 @@ func (folder *Folder) GetChildFolder() ([]Folder, error) {
 
 // GetRecursiveChildFolder 查找所有递归子目录，包括自身
 func GetRecursiveChildFolder(dirs []uint, uid uint, includeSelf bool) ([]Folder, error) {
+	return getRecursiveChildFolder(DB, dirs, uid, includeSelf)
+}
+
+// getRecursiveChildFolder 在给定的查询作用域下查找所有递归子目录
+func getRecursiveChildFolder(db *gorm.DB, dirs []uint, uid uint, includeSelf bool) ([]Folder, error) {
 	folders := make([]Folder, 0, len(dirs))
 	var err error
 
 	var parFolders []Folder
-	result := DB.Where("owner_id = ? and id in (?)", uid, dirs).Find(&parFolders)
+	result := db.Where("owner_id = ? and id in (?)", uid, dirs).Find(&parFolders)
 	if result.Error != nil {
 		return folders, err
 	}
@@ -82,7 +87,7 @@ func GetRecursiveChildFolder(dirs []uint, uid uint, includeSelf bool) ([]Folder,
 	// 递归查询子目录,最大递归65535次
 	for i := 0; i < 65535; i++ {
 
-		result = DB.Where("owner_id = ? and parent_id in (?)", uid, parentIDs).Find(&parFolders)
+		result = db.Where("owner_id = ? and parent_id in (?)", uid, parentIDs).Find(&parFolders)
 
 		// 查询结束条件
 		if len(parFolders) == 0 {
@@ -136,7 +141,9 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 		}
 
 		// 复制文件记录
+		newIDs := make(map[uint]uint, len(originFiles))
 		for _, oldFile := range originFiles {
+			oldID := oldFile.ID
 			oldFile.Model = gorm.Model{}
 			oldFile.FolderID = dstFolder.ID
 			oldFile.UserID = dstFolder.OwnerID
@@ -145,9 +152,13 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 				return copiedSize, err
 			}
 
+			newIDs[oldID] = oldFile.ID
 			copiedSize += oldFile.Size
 		}
 
+		// 复制文件的WebDAV属性
+		copyWebdavPropertiesOrLog(newIDs, false)
+
 	} else {
 		// 更改顶级要移动文件的父目录指向
 		err := DB.Model(File{}).Where(
@@ -224,7 +235,9 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 	}
 
 	// 复制文件记录
+	var newFileIDs = make(map[uint]uint, len(originFiles))
 	for _, oldFile := range originFiles {
+		oldID := oldFile.ID
 		oldFile.Model = gorm.Model{}
 		oldFile.FolderID = newIDCache[oldFile.FolderID]
 		oldFile.UserID = dstFolder.OwnerID
@@ -232,9 +245,14 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 			return size, err
 		}
 
+		newFileIDs[oldID] = oldFile.ID
 		size += oldFile.Size
 	}
 
+	// 复制目录及文件的WebDAV属性
+	copyWebdavPropertiesOrLog(newIDCache, true)
+	copyWebdavPropertiesOrLog(newFileIDs, false)
+
 	return size, nil
 
 }
@@ -256,6 +274,25 @@ func (folder *Folder) MoveFolderTo(dirs []uint, dstFolder *Folder) error {
 
 }
 
+// TraceRoot 向上递归查找父目录，计算出目录所在的路径并存入Position
+func (folder *Folder) TraceRoot() error {
+	if folder.ParentID == nil {
+		return nil
+	}
+
+	var parentFolder Folder
+	err := DB.Where("id = ? AND owner_id = ?", *folder.ParentID, folder.OwnerID).First(&parentFolder).Error
+	if err != nil {
+		return err
+	}
+
+	if err := parentFolder.TraceRoot(); err != nil {
+		return err
+	}
+	folder.Position = path.Join(parentFolder.Position, parentFolder.Name)
+	return nil
+}
+
 // Rename 重命名目录
 func (folder *Folder) Rename(new string) error {
 	if err := DB.Model(&folder).Update("name", new).Error; err != nil {
@@ -224,6 +224,8 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
+		// 复制WebDAV属性
+		mock.ExpectQuery("SELECT(.+)webdav_properties(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		storage, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2},
 			&dstFolder,
@@ -372,6 +374,10 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(6, 1))
 		mock.ExpectCommit()
 
+		// 复制WebDAV属性
+		mock.ExpectQuery("SELECT(.+)webdav_properties(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)webdav_properties(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
 		size, err := parFolder.CopyFolderTo(2, &dstFolder)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
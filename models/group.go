@@ -31,6 +31,7 @@ type GroupOption struct {
 	ShareDownload   bool                   `json:"share_download,omitempty"`
 	Aria2           bool                   `json:"aria2,omitempty"`         // 离线下载
 	Aria2Options    map[string]interface{} `json:"aria2_options,omitempty"` // 离线下载用户组配置
+	MaxVersions     int                    `json:"max_versions,omitempty"`  // 覆盖文件时保留的历史版本数量，0 为不保留
 }
 
 // GetGroupByID 用ID获取用户组
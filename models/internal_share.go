@@ -0,0 +1,180 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// SharedFolderName 用户根目录下“与我共享”虚拟目录的名称，根目录下的文件和目录不能使用此名称
+const SharedFolderName = "与我共享"
+
+// InternalShare 站内共享，将目录共享给指定的用户或用户组
+type InternalShare struct {
+	gorm.Model
+	UserID   uint `gorm:"index:internal_share_user_id"`   // 目录所有者ID
+	FolderID uint `gorm:"index:internal_share_folder_id"` // 被共享的目录ID
+	// 共享给的用户ID，为 0 时表示共享给用户组
+	TargetUserID uint `gorm:"index:internal_share_target_user"`
+	// 共享给的用户组ID
+	TargetGroupID uint `gorm:"index:internal_share_target_group"`
+	// 是否允许写入，写入操作计入所有者的容量及存储策略
+	Writable bool
+}
+
+// Create 创建共享
+func (share *InternalShare) Create() (uint, error) {
+	if err := DB.Create(share).Error; err != nil {
+		return 0, err
+	}
+	return share.ID, nil
+}
+
+// Update 更新共享属性
+func (share *InternalShare) Update(val map[string]interface{}) error {
+	return DB.Model(share).Updates(val).Error
+}
+
+// Delete 删除共享
+func (share *InternalShare) Delete() error {
+	return DB.Delete(share).Error
+}
+
+// GetInternalShareByID 根据ID查找用户 uid 创建的共享
+func GetInternalShareByID(id, uid uint) (*InternalShare, error) {
+	share := &InternalShare{}
+	res := DB.Where("id = ? and user_id = ?", id, uid).First(share)
+	return share, res.Error
+}
+
+// ListInternalShares 列出用户 uid 创建的共享，folderID 不为 0 时只列出此目录的共享
+func ListInternalShares(uid, folderID uint) ([]InternalShare, error) {
+	var shares []InternalShare
+	dbChain := DB.Where("user_id = ?", uid)
+	if folderID != 0 {
+		dbChain = dbChain.Where("folder_id = ?", folderID)
+	}
+	res := dbChain.Order("id").Find(&shares)
+	return shares, res.Error
+}
+
+// ListInternalSharesTo 列出共享给用户或其所在用户组的记录，不包括用户自己创建的共享
+func ListInternalSharesTo(user *User) ([]InternalShare, error) {
+	var shares []InternalShare
+	res := DB.Where(
+		"user_id <> ? and (target_user_id = ? or (target_user_id = 0 and target_group_id = ?))",
+		user.ID, user.ID, user.GroupID,
+	).Order("id").Find(&shares)
+	return shares, res.Error
+}
+
+// GetInternalShareOfFolder 向上查找将 folderID 或其上级目录共享给用户的记录，
+// 存在多条记录时优先返回允许写入的
+func GetInternalShareOfFolder(user *User, folderID uint) (*InternalShare, error) {
+	shares, err := ListInternalSharesTo(user)
+	if err != nil {
+		return nil, err
+	}
+
+	byFolder := make(map[uint]*InternalShare, len(shares))
+	for i := 0; i < len(shares); i++ {
+		if existed, ok := byFolder[shares[i].FolderID]; !ok || (!existed.Writable && shares[i].Writable) {
+			byFolder[shares[i].FolderID] = &shares[i]
+		}
+	}
+
+	var found *InternalShare
+	for id := folderID; id != 0 && len(byFolder) > 0; {
+		if share, ok := byFolder[id]; ok {
+			if share.Writable {
+				return share, nil
+			}
+			if found == nil {
+				found = share
+			}
+		}
+
+		var folder Folder
+		if err := DB.Select("id, parent_id").Where("id = ?", id).First(&folder).Error; err != nil {
+			return nil, err
+		}
+		if folder.ParentID == nil {
+			break
+		}
+		id = *folder.ParentID
+	}
+
+	if found == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return found, nil
+}
+
+// CheckInternalShareOfObjects 检查目录 dirs 及文件 files 是否均位于共享给用户的目录中，
+// 返回对象的所有者ID，以及是否全部允许写入。被共享的目录本身不视为位于共享中
+func CheckInternalShareOfObjects(user *User, dirs, files []uint) (uint, bool, error) {
+	var (
+		owner   uint
+		parents = make(map[uint]bool)
+	)
+	setOwner := func(uid uint) bool {
+		if owner != 0 && owner != uid {
+			return false
+		}
+		owner = uid
+		return true
+	}
+
+	if len(dirs) > 0 {
+		var folders []Folder
+		if err := DB.Where("id in (?)", dirs).Find(&folders).Error; err != nil {
+			return 0, false, err
+		}
+		if len(folders) == 0 {
+			return 0, false, gorm.ErrRecordNotFound
+		}
+		for _, folder := range folders {
+			if folder.ParentID == nil || !setOwner(folder.OwnerID) {
+				return 0, false, gorm.ErrRecordNotFound
+			}
+			parents[*folder.ParentID] = true
+		}
+	}
+
+	if len(files) > 0 {
+		fileObjects, err := GetFilesByIDs(files, 0)
+		if err != nil {
+			return 0, false, err
+		}
+		if len(fileObjects) == 0 {
+			return 0, false, gorm.ErrRecordNotFound
+		}
+		for _, file := range fileObjects {
+			if !setOwner(file.UserID) {
+				return 0, false, gorm.ErrRecordNotFound
+			}
+			parents[file.FolderID] = true
+		}
+	}
+
+	if owner == 0 || owner == user.ID {
+		return 0, false, gorm.ErrRecordNotFound
+	}
+
+	writable := true
+	for parent := range parents {
+		share, err := GetInternalShareOfFolder(user, parent)
+		if err != nil {
+			return 0, false, err
+		}
+		if share.UserID != owner {
+			return 0, false, gorm.ErrRecordNotFound
+		}
+		writable = writable && share.Writable
+	}
+
+	return owner, writable, nil
+}
+
+// DeleteInternalSharesByFolderIDs 删除目录对应的共享
+func DeleteInternalSharesByFolderIDs(ids []uint) error {
+	return DB.Where("folder_id in (?)", ids).Delete(&InternalShare{}).Error
+}
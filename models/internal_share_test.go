@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInternalShare_Create(t *testing.T) {
+	asserts := assert.New(t)
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		share := InternalShare{UserID: 1, FolderID: 2, TargetUserID: 3}
+		id, err := share.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		share := InternalShare{}
+		id, err := share.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestListInternalSharesTo(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WithArgs(1, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id"}).AddRow(1, 5, 10))
+	shares, err := ListInternalSharesTo(&User{Model: gorm.Model{ID: 1}, GroupID: 2})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(shares, 1)
+	asserts.EqualValues(10, shares[0].FolderID)
+}
+
+func TestGetInternalShareOfFolder(t *testing.T) {
+	asserts := assert.New(t)
+	user := &User{Model: gorm.Model{ID: 1}, GroupID: 2}
+
+	// 没有共享
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		share, err := GetInternalShareOfFolder(user, 12)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(gorm.ErrRecordNotFound, err)
+		asserts.Nil(share)
+	}
+
+	// 上级目录被共享
+	{
+		mock.ExpectQuery("SELECT(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id", "writable"}).AddRow(1, 5, 10, false))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(12).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(12, 11))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(11).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(11, 10))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(10).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(10, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(1, nil))
+		share, err := GetInternalShareOfFolder(user, 12)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(10, share.FolderID)
+		asserts.False(share.Writable)
+	}
+
+	// 存在允许写入的共享时直接返回
+	{
+		mock.ExpectQuery("SELECT(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id", "writable"}).
+				AddRow(1, 5, 10, false).
+				AddRow(2, 5, 10, true))
+		share, err := GetInternalShareOfFolder(user, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, share.ID)
+	}
+}
+
+func TestCheckInternalShareOfObjects(t *testing.T) {
+	asserts := assert.New(t)
+	user := &User{Model: gorm.Model{ID: 1}, GroupID: 2}
+
+	// 对象属于不同用户
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "owner_id"}).AddRow(11, 10, 5))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "user_id"}).AddRow(20, 10, 6))
+		_, _, err := CheckInternalShareOfObjects(user, []uint{11}, []uint{20})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 被共享的目录本身不能操作
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "owner_id"}).AddRow(10, 1, 5))
+		mock.ExpectQuery("SELECT(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id", "writable"}).AddRow(1, 5, 10, true))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(1, nil))
+		_, _, err := CheckInternalShareOfObjects(user, []uint{10}, nil)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "user_id"}).AddRow(20, 10, 5))
+		mock.ExpectQuery("SELECT(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id", "writable"}).AddRow(1, 5, 10, true))
+		owner, writable, err := CheckInternalShareOfObjects(user, nil, []uint{20})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(5, owner)
+		asserts.True(writable)
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// LDAPIdentity 用户在 LDAP 目录中的身份
+type LDAPIdentity struct {
+	gorm.Model
+	UserID uint   `gorm:"unique_index:ldap_user_id"`
+	DN     string `gorm:"type:text"`
+	// 是否因从目录中移除而被停用
+	Disabled bool
+}
+
+// Create 创建身份关联
+func (identity *LDAPIdentity) Create() (uint, error) {
+	if err := DB.Create(identity).Error; err != nil {
+		return 0, err
+	}
+	return identity.ID, nil
+}
+
+// Update 更新身份关联
+func (identity *LDAPIdentity) Update(val map[string]interface{}) error {
+	return DB.Model(identity).Updates(val).Error
+}
+
+// GetLDAPIdentityByUserID 根据用户ID查找身份关联
+func GetLDAPIdentityByUserID(uid uint) (*LDAPIdentity, error) {
+	identity := &LDAPIdentity{}
+	res := DB.Where("user_id = ?", uid).First(identity)
+	return identity, res.Error
+}
+
+// ListLDAPIdentities 列出所有身份关联
+func ListLDAPIdentities() ([]LDAPIdentity, error) {
+	var identities []LDAPIdentity
+	res := DB.Find(&identities)
+	return identities, res.Error
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLDAPIdentity_Create(t *testing.T) {
+	asserts := assert.New(t)
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		identity := LDAPIdentity{UserID: 1, DN: "uid=user1,dc=example,dc=org"}
+		id, err := identity.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		identity := LDAPIdentity{}
+		id, err := identity.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestLDAPIdentity_Update(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	identity := LDAPIdentity{Model: gorm.Model{ID: 1}}
+	asserts.NoError(identity.Update(map[string]interface{}{"disabled": true}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetLDAPIdentityByUserID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "dn"}).AddRow(1, 2, "uid=user1"))
+	identity, err := GetLDAPIdentityByUserID(2)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Equal("uid=user1", identity.DN)
+}
+
+func TestListLDAPIdentities(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 2).AddRow(2, 3))
+	identities, err := ListLDAPIdentities()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(identities, 2)
+}
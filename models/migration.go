@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"github.com/HFO4/cloudreve/pkg/cache"
 	"github.com/HFO4/cloudreve/pkg/conf"
 	"github.com/HFO4/cloudreve/pkg/util"
@@ -35,7 +36,7 @@ func migration() {
 		DB = DB.Set("gorm:table_options", "ENGINE=InnoDB")
 	}
 	DB.AutoMigrate(&User{}, &Setting{}, &Group{}, &Policy{}, &Folder{}, &File{}, &Share{},
-		&Task{}, &Download{}, &Tag{}, &Webdav{})
+		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Trash{}, &FileVersion{}, &WebdavLock{}, &WebdavProperty{}, &AuditLog{}, &APIToken{}, &OIDCIdentity{}, &LDAPIdentity{}, &InternalShare{}, &ShareAccess{}, &StorageSnapshot{}, &FileReplica{})
 
 	// 创建初始存储策略
 	addDefaultPolicy()
@@ -49,6 +50,9 @@ func migration() {
 	// 向设置数据表添加初始设置
 	addDefaultSettings()
 
+	// 重命名与“与我共享”虚拟目录重名的对象
+	renameReservedObjects()
+
 	util.Log().Info("数据库初始化结束")
 
 }
@@ -73,6 +77,28 @@ func addDefaultPolicy() {
 	}
 }
 
+// renameReservedObjects 用户根目录下的“与我共享”会被虚拟目录遮挡，
+// 将已有的同名文件和目录重命名为“与我共享 (ID)”
+func renameReservedObjects() {
+	roots := DB.Model(&Folder{}).Select("id").Where("parent_id is NULL").QueryExpr()
+
+	var folders []Folder
+	DB.Where("name = ? AND parent_id IN (?)", SharedFolderName, roots).Find(&folders)
+	for i := 0; i < len(folders); i++ {
+		if err := folders[i].Rename(fmt.Sprintf("%s (%d)", SharedFolderName, folders[i].ID)); err != nil {
+			util.Log().Warning("无法重命名目录[%d]，%s", folders[i].ID, err)
+		}
+	}
+
+	var files []File
+	DB.Where("name = ? AND folder_id IN (?)", SharedFolderName, roots).Find(&files)
+	for i := 0; i < len(files); i++ {
+		if err := files[i].Rename(fmt.Sprintf("%s (%d)", SharedFolderName, files[i].ID)); err != nil {
+			util.Log().Warning("无法重命名文件[%d]，%s", files[i].ID, err)
+		}
+	}
+}
+
 func addDefaultSettings() {
 	defaultSettings := []Setting{
 		{Name: "siteURL", Value: `http://localhost`, Type: "basic"},
@@ -107,8 +133,11 @@ func addDefaultSettings() {
 		{Name: "onedrive_callback_check", Value: `20`, Type: "timeout"},
 		{Name: "aria2_call_timeout", Value: `5`, Type: "timeout"},
 		{Name: "onedrive_chunk_retries", Value: `1`, Type: "retry"},
+		{Name: "slave_chunk_retries", Value: `3`, Type: "retry"},
 		{Name: "onedrive_source_timeout", Value: `1800`, Type: "timeout"},
 		{Name: "reset_after_upload_failed", Value: `0`, Type: "upload"},
+		{Name: "upload_dedup", Value: `0`, Type: "upload"},
+		{Name: "upload_chunk_size", Value: `5242880`, Type: "upload"},
 		{Name: "login_captcha", Value: `0`, Type: "login"},
 		{Name: "reg_captcha", Value: `0`, Type: "login"},
 		{Name: "email_active", Value: `0`, Type: "register"},
@@ -120,6 +149,11 @@ solid #e9e9e9;"bgcolor="#fff"><tr style="font-family: 'Helvetica Neue',Helvetica
 14px; margin: 0;"><td class="alert alert-warning"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 16px; vertical-align: top; color: #fff; font-weight: 500; text-align: center; border-radius: 3px 3px 0 0; background-color: #009688; margin: 0; padding: 20px;"align="center"bgcolor="#FF9F00"valign="top">激活{siteTitle}账户</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-wrap"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 20px;"valign="top"><table width="100%"cellpadding="0"cellspacing="0"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica 
 Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">亲爱的<strong style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;">{userName}</strong>：</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">感谢您注册{siteTitle},请点击下方按钮完成账户激活。</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top"><a href="{activationUrl}"class="btn-primary"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; color: #FFF; text-decoration: none; line-height: 2em; font-weight: bold; text-align: center; cursor: pointer; display: inline-block; border-radius: 5px; text-transform: capitalize; background-color: #009688; margin: 0; border-color: #009688; border-style: solid; border-width: 10px 20px;">激活账户</a></td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">感谢您选择{siteTitle}。</td></tr></table></td></tr></table><div class="footer"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; width: 100%; clear: both; color: #999; margin: 0; padding: 20px;"><table width="100%"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="aligncenter content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 12px; vertical-align: top; color: #999; text-align: center; margin: 0; padding: 0 0 20px;"align="center"valign="top">此邮件由系统自动发送，请不要直接回复。</td></tr></table></div></div></td><td style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0;"valign="top"></td></tr></table></body></html>`, Type: "mail_template"},
 		{Name: "forget_captcha", Value: `0`, Type: "login"},
+		{Name: "login_lock_threshold", Value: `5`, Type: "login"},
+		{Name: "login_ip_lock_threshold", Value: `20`, Type: "login"},
+		{Name: "login_lock_duration", Value: `60`, Type: "login"},
+		{Name: "login_lock_max_duration", Value: `3600`, Type: "login"},
+		{Name: "login_fail_window", Value: `3600`, Type: "login"},
 		{Name: "mail_reset_pwd_template", Value: `<!DOCTYPE html PUBLIC"-//W3C//DTD XHTML 1.0 Transitional//EN""http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd"><html xmlns="http://www.w3.org/1999/xhtml"style="font-family: 'Helvetica Neue', Helvetica, Arial, sans-serif; box-sizing: border-box; 
 font-size: 14px; margin: 0;"><head><meta name="viewport"content="width=device-width"/><meta http-equiv="Content-Type"content="text/html; charset=UTF-8"/><title>重设密码</title><style type="text/css">img{max-width:100%}body{-webkit-font-smoothing:antialiased;-webkit-text-size-adjust:none;width:100%!important;height:100%;line-height:1.6em}body{background-color:#f6f6f6}@media only screen and(max-width:640px){body{padding:0!important}h1{font-weight:800!important;margin:20px 0 5px!important}h2{font-weight:800!important;margin:20px 0 5px!important}h3{font-weight:800!important;margin:20px 0 5px!important}h4{font-weight:800!important;margin:20px 0 5px!important}h1{font-size:22px!important}h2{font-size:18px!important}h3{font-size:16px!important}.container{padding:0!important;width:100%!important}.content{padding:0!important}.content-wrap{padding:10px!important}.invoice{width:100%!important}}</style></head><body itemscope itemtype="http://schema.org/EmailMessage"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: 
 border-box; font-size: 14px; -webkit-font-smoothing: antialiased; -webkit-text-size-adjust: none; width: 100% !important; height: 100%; line-height: 1.6em; background-color: #f6f6f6; margin: 0;"bgcolor="#f6f6f6"><table class="body-wrap"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; width: 100%; background-color: #f6f6f6; margin: 0;"bgcolor="#f6f6f6"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; 
@@ -127,6 +161,7 @@ box-sizing: border-box; font-size: 14px; margin: 0;"><td style="font-family: 'He
 solid #e9e9e9;"bgcolor="#fff"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 
 14px; margin: 0;"><td class="alert alert-warning"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 16px; vertical-align: top; color: #fff; font-weight: 500; text-align: center; border-radius: 3px 3px 0 0; background-color: #2196F3; margin: 0; padding: 20px;"align="center"bgcolor="#FF9F00"valign="top">重设{siteTitle}密码</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-wrap"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 20px;"valign="top"><table width="100%"cellpadding="0"cellspacing="0"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica 
 Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">亲爱的<strong style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;">{userName}</strong>：</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">请点击下方按钮完成密码重设。如果非你本人操作，请忽略此邮件。</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top"><a href="{resetUrl}"class="btn-primary"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; color: #FFF; text-decoration: none; line-height: 2em; font-weight: bold; text-align: center; cursor: pointer; display: inline-block; border-radius: 5px; text-transform: capitalize; background-color: #2196F3; margin: 0; border-color: #2196F3; border-style: solid; border-width: 10px 20px;">重设密码</a></td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">感谢您选择{siteTitle}。</td></tr></table></td></tr></table><div class="footer"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; width: 100%; clear: both; color: #999; margin: 0; padding: 20px;"><table width="100%"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="aligncenter content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 12px; vertical-align: top; color: #999; text-align: center; margin: 0; padding: 0 0 20px;"align="center"valign="top">此邮件由系统自动发送，请不要直接回复。</td></tr></table></div></div></td><td style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0;"valign="top"></td></tr></table></body></html>`, Type: "mail_template"},
+		{Name: "mail_file_request_template", Value: `<!DOCTYPE html><html><head><meta http-equiv="Content-Type"content="text/html; charset=UTF-8"/><title>收到新文件</title></head><body style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; font-size: 14px; background-color: #f6f6f6; margin: 0;"><div style="max-width: 600px; margin: 20px auto; padding: 20px; background-color: #fff; border: 1px solid #e9e9e9; border-radius: 3px;"><h2 style="font-size: 18px; margin: 0 0 16px;">{siteTitle} 文件收集</h2><p>亲爱的<strong>{userName}</strong>：</p><p>您的文件收集“{shareName}”收到了新文件 <strong>{fileName}</strong>（{fileSize}），已保存至 {filePath}。</p><p><a href="{siteUrl}"style="color: #FFF; text-decoration: none; background-color: #348eda; padding: 8px 16px; border-radius: 5px; display: inline-block;">查看文件</a></p><p style="color: #999; font-size: 12px;">此邮件由系统自动发送，请勿直接回复。{siteSecTitle}</p></div></body></html>`, Type: "mail_template"},
 		{Name: "db_version_" + conf.RequiredDBVersion, Value: `installed`, Type: "version"},
 		{Name: "hot_share_num", Value: `10`, Type: "share"},
 		{Name: "gravatar_server", Value: `https://www.gravatar.com/`, Type: "avatar"},
@@ -138,7 +173,10 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 		{Name: "aria2_options", Value: `{}`, Type: "aria2"},
 		{Name: "aria2_interval", Value: `60`, Type: "aria2"},
 		{Name: "max_worker_num", Value: `10`, Type: "task"},
+		{Name: "max_worker_num_per_user", Value: `0`, Type: "task"},
 		{Name: "max_parallel_transfer", Value: `4`, Type: "task"},
+		{Name: "archive_list_max_size", Value: `1073741824`, Type: "task"},
+		{Name: "archive_list_max_entries", Value: `1000`, Type: "task"},
 		{Name: "secret_key", Value: util.RandStringRunes(256), Type: "auth"},
 		{Name: "temp_path", Value: "temp", Type: "path"},
 		{Name: "avatar_path", Value: "avatar", Type: "path"},
@@ -149,7 +187,41 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 		{Name: "home_view_method", Value: "icon", Type: "view"},
 		{Name: "share_view_method", Value: "list", Type: "view"},
 		{Name: "cron_garbage_collect", Value: "@hourly", Type: "cron"},
+		{Name: "cron_purge_trash", Value: "@daily", Type: "cron"},
+		{Name: "cron_flush_search_index", Value: "@every 5m", Type: "cron"},
+		{Name: "cron_clean_audit_log", Value: "@daily", Type: "cron"},
+		{Name: "cron_clean_share_access", Value: "@daily", Type: "cron"},
+		{Name: "cron_sync_ldap", Value: "@hourly", Type: "cron"},
+		{Name: "cron_storage_snapshot", Value: "@daily", Type: "cron"},
+		{Name: "search_index_path", Value: "search.index", Type: "search"},
+		{Name: "search_content_max_size", Value: "10485760", Type: "search"},
+		{Name: "trash_retention", Value: "2592000", Type: "trash"},
+		{Name: "audit_log_retention", Value: "15552000", Type: "audit"},
+		{Name: "share_access_retention", Value: "15552000", Type: "share"},
+		{Name: "storage_snapshot_retention", Value: "31536000", Type: "statistics"},
 		{Name: "authn_enabled", Value: "0", Type: "authn"},
+		{Name: "oidc_enabled", Value: "0", Type: "oidc"},
+		{Name: "oidc_issuer", Value: "", Type: "oidc"},
+		{Name: "oidc_client_id", Value: "", Type: "oidc"},
+		{Name: "oidc_client_secret", Value: "", Type: "oidc"},
+		{Name: "oidc_scopes", Value: "openid email profile", Type: "oidc"},
+		{Name: "oidc_auto_register", Value: "1", Type: "oidc"},
+		{Name: "oidc_default_group", Value: "2", Type: "oidc"},
+		{Name: "oidc_group_claim", Value: "groups", Type: "oidc"},
+		{Name: "oidc_group_mapping", Value: "[]", Type: "oidc"},
+		{Name: "ldap_enabled", Value: "0", Type: "ldap"},
+		{Name: "ldap_url", Value: "ldap://127.0.0.1:389", Type: "ldap"},
+		{Name: "ldap_start_tls", Value: "0", Type: "ldap"},
+		{Name: "ldap_skip_verify", Value: "0", Type: "ldap"},
+		{Name: "ldap_bind_dn", Value: "", Type: "ldap"},
+		{Name: "ldap_bind_password", Value: "", Type: "ldap"},
+		{Name: "ldap_base_dn", Value: "", Type: "ldap"},
+		{Name: "ldap_user_filter", Value: "(&(objectClass=person)(mail=%s))", Type: "ldap"},
+		{Name: "ldap_nick_attr", Value: "cn", Type: "ldap"},
+		{Name: "ldap_group_attr", Value: "memberOf", Type: "ldap"},
+		{Name: "ldap_group_mapping", Value: "[]", Type: "ldap"},
+		{Name: "ldap_default_group", Value: "2", Type: "ldap"},
+		{Name: "ldap_check_ttl", Value: "300", Type: "ldap"},
 		{Name: "captcha_height", Value: "60", Type: "captcha"},
 		{Name: "captcha_width", Value: "240", Type: "captcha"},
 		{Name: "captcha_mode", Value: "3", Type: "captcha"},
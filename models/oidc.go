@@ -0,0 +1,33 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// OIDCIdentity 用户在 OpenID Provider 中的身份
+type OIDCIdentity struct {
+	gorm.Model
+	UserID  uint   `gorm:"index:oidc_user_id"`
+	Issuer  string `gorm:"size:255;unique_index:oidc_subject"` // 签发者
+	Subject string `gorm:"size:255;unique_index:oidc_subject"` // 用户在签发者中的唯一标识 sub
+}
+
+// Create 创建身份关联
+func (identity *OIDCIdentity) Create() (uint, error) {
+	if err := DB.Create(identity).Error; err != nil {
+		return 0, err
+	}
+	return identity.ID, nil
+}
+
+// Delete 删除身份关联
+func (identity *OIDCIdentity) Delete() error {
+	return DB.Unscoped().Delete(identity).Error
+}
+
+// GetOIDCIdentity 根据签发者和 sub 查找身份关联
+func GetOIDCIdentity(issuer, subject string) (*OIDCIdentity, error) {
+	identity := &OIDCIdentity{}
+	res := DB.Where("issuer = ? and subject = ?", issuer, subject).First(identity)
+	return identity, res.Error
+}
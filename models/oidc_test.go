@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOIDCIdentity_Create(t *testing.T) {
+	asserts := assert.New(t)
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		identity := OIDCIdentity{UserID: 1, Issuer: "https://idp", Subject: "sub"}
+		id, err := identity.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		identity := OIDCIdentity{}
+		id, err := identity.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestOIDCIdentity_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	identity := OIDCIdentity{Model: gorm.Model{ID: 1}}
+	asserts.NoError(identity.Delete())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetOIDCIdentity(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WithArgs("https://idp", "sub").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 2))
+	identity, err := GetOIDCIdentity("https://idp", "sub")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(2, identity.UserID)
+}
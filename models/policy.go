@@ -35,6 +35,7 @@ type Policy struct {
 	ThumbNameRule      string
 	IsOriginLinkEnable bool
 	Options            string `gorm:"type:text"`
+	ReplicaPolicyID    uint   // 副本存储策略ID，为0时不复制上传的文件
 
 	// 数据库忽略字段
 	OptionsSerialized PolicyOption `gorm:"-"`
@@ -54,6 +55,12 @@ type PolicyOption struct {
 
 	// Region 区域代码
 	Region string `json:"region"`
+
+	// HostKey SFTP服务器公钥，连接时只接受此公钥
+	HostKey string `json:"host_key,omitempty"`
+
+	// Encrypt 是否加密存储文件，仅本机及从机策略有效
+	Encrypt bool `json:"encrypt,omitempty"`
 }
 
 var thumbSuffix = map[string][]string{
@@ -64,6 +71,8 @@ var thumbSuffix = map[string][]string{
 	"upyun":    {".svg", ".jpg", ".jpeg", ".png", ".gif", ".webp", ".tiff", ".bmp"},
 	"s3":       {},
 	"remote":   {},
+	"sftp":     {},
+	"webdav":   {},
 	"onedrive": {"*"},
 }
 
@@ -188,7 +197,7 @@ func (policy Policy) getOriginNameRule(origin string) string {
 		case "qiniu":
 			// 七牛会将$(fname)自动替换为原始文件名
 			return "$(fname)"
-		case "local", "remote":
+		case "local", "remote", "sftp", "webdav":
 			return origin
 		case "oss", "cos":
 			// OSS会将${filename}自动替换为原始文件名
@@ -219,7 +228,7 @@ func (policy *Policy) IsThumbExist(name string) bool {
 
 // IsTransitUpload 返回此策略上传给定size文件时是否需要服务端中转
 func (policy *Policy) IsTransitUpload(size uint64) bool {
-	if policy.Type == "local" {
+	if policy.Type == "local" || policy.Type == "sftp" || policy.Type == "webdav" {
 		return true
 	}
 	if policy.Type == "onedrive" && size < 4*1024*1024 {
@@ -233,6 +242,11 @@ func (policy *Policy) IsPathGenerateNeeded() bool {
 	return policy.Type != "remote"
 }
 
+// IsEncrypted 返回此策略下新写入的文件是否加密存储
+func (policy *Policy) IsEncrypted() bool {
+	return policy.OptionsSerialized.Encrypt && (policy.Type == "local" || policy.Type == "remote")
+}
+
 // IsThumbGenerateNeeded 返回此策略是否需要在上传后生成缩略图
 func (policy *Policy) IsThumbGenerateNeeded() bool {
 	return policy.Type == "local"
@@ -245,14 +259,9 @@ func (policy *Policy) CanStructureBeListed() bool {
 
 // GetUploadURL 获取文件上传服务API地址
 func (policy *Policy) GetUploadURL() string {
-	server, err := url.Parse(policy.Server)
-	if err != nil {
-		return policy.Server
-	}
-
 	var controller *url.URL
 	switch policy.Type {
-	case "local", "onedrive":
+	case "local", "onedrive", "sftp", "webdav":
 		return "/api/v3/file/upload"
 	case "remote":
 		controller, _ = url.Parse("/api/v3/slave/upload")
@@ -265,6 +274,11 @@ func (policy *Policy) GetUploadURL() string {
 	default:
 		controller, _ = url.Parse("")
 	}
+
+	server, err := url.Parse(policy.Server)
+	if err != nil {
+		return policy.Server
+	}
 	return server.ResolveReference(controller).String()
 }
 
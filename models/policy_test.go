@@ -185,6 +185,20 @@ func TestPolicy_GetUploadURL(t *testing.T) {
 		asserts.Equal("http://127.0.0.1/api/v3/slave/upload", policy.GetUploadURL())
 	}
 
+	// SFTP
+	{
+		policy := Policy{Type: "sftp", Server: "127.0.0.1:22"}
+		asserts.Equal("/api/v3/file/upload", policy.GetUploadURL())
+		asserts.True(policy.IsTransitUpload(1024 * 1024 * 1024))
+	}
+
+	// WebDAV
+	{
+		policy := Policy{Type: "webdav", Server: "https://dav.example.com/remote.php/dav/"}
+		asserts.Equal("/api/v3/file/upload", policy.GetUploadURL())
+		asserts.True(policy.IsTransitUpload(1024 * 1024 * 1024))
+	}
+
 	// OSS
 	{
 		policy := Policy{Type: "oss", BucketName: "base", Server: "127.0.0.1"}
@@ -219,6 +233,18 @@ func TestPolicy_IsPathGenerateNeeded(t *testing.T) {
 	asserts.False(policy.IsPathGenerateNeeded())
 }
 
+func TestPolicy_IsEncrypted(t *testing.T) {
+	asserts := assert.New(t)
+	policy := Policy{Type: "local"}
+	asserts.False(policy.IsEncrypted())
+	policy.OptionsSerialized.Encrypt = true
+	asserts.True(policy.IsEncrypted())
+	policy.Type = "remote"
+	asserts.True(policy.IsEncrypted())
+	policy.Type = "oss"
+	asserts.False(policy.IsEncrypted())
+}
+
 func TestPolicy_ClearCache(t *testing.T) {
 	asserts := assert.New(t)
 	cache.Set("policy_202", 1, 0)
@@ -8,6 +8,7 @@ import (
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"path"
 	"strings"
 	"time"
 )
@@ -26,6 +27,14 @@ type Share struct {
 	PreviewEnabled  bool       // 是否允许直接预览
 	SourceName      string     `gorm:"index:source"` // 用于搜索的字段
 
+	// 文件收集，访客只能上传文件至源目录，不能浏览或下载其中的内容
+	FileRequest bool
+	MaxSize     uint64 // 单个文件大小上限，0 表示不限制
+	AllowedExts string // 允许上传的扩展名，以英文逗号分隔，空值表示不限制
+	UploadQuota uint64 // 收集文件总大小上限，0 表示不限制
+	Uploaded    uint64 // 已收集文件的总大小
+	Uploads     int    // 已收集的文件数
+
 	// 数据库忽略字段
 	User   User   `gorm:"PRELOAD:false,association_autoupdate:false"`
 	File   File   `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -87,6 +96,36 @@ func (share *Share) IsAvailable() bool {
 	return true
 }
 
+// CanUpload 检查文件收集是否可以接收名为 name、大小为 size 的文件
+func (share *Share) CanUpload(name string, size uint64) error {
+	if !share.FileRequest {
+		return errors.New("此分享不接收上传")
+	}
+	if share.MaxSize > 0 && size > share.MaxSize {
+		return errors.New("文件大小超出限制")
+	}
+	if share.UploadQuota > 0 && share.Uploaded+size > share.UploadQuota {
+		return errors.New("文件收集容量已满")
+	}
+	if share.AllowedExts != "" {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+		if !util.ContainsString(strings.Split(strings.ToLower(share.AllowedExts), ","), ext) {
+			return errors.New("不允许上传此类型的文件")
+		}
+	}
+	return nil
+}
+
+// Received 文件收集接收到大小为 size 的文件，增加已收集数量
+func (share *Share) Received(size uint64) {
+	share.Uploads++
+	share.Uploaded += size
+	DB.Model(share).UpdateColumns(map[string]interface{}{
+		"uploads":  gorm.Expr("uploads + ?", 1),
+		"uploaded": gorm.Expr("uploaded + ?", size),
+	})
+}
+
 // Creator 获取分享的创建者
 func (share *Share) Creator() *User {
 	if share.User.ID == 0 {
@@ -187,11 +226,18 @@ func (share *Share) Update(props map[string]interface{}) error {
 
 // Delete 删除分享
 func (share *Share) Delete() error {
-	return DB.Model(share).Delete(share).Error
+	if err := DB.Model(share).Delete(share).Error; err != nil {
+		return err
+	}
+	return DeleteShareAccesses([]uint{share.ID})
 }
 
 // DeleteShareBySourceIDs 根据原始资源类型和ID删除文件
 func DeleteShareBySourceIDs(sources []uint, isDir bool) error {
+	shares := DB.Model(&Share{}).Select("id").Where("source_id in (?) and is_dir = ?", sources, isDir).QueryExpr()
+	if err := DB.Unscoped().Where("share_id in (?)", shares).Delete(&ShareAccess{}).Error; err != nil {
+		return err
+	}
 	return DB.Where("source_id in (?) and is_dir = ?", sources, isDir).Delete(&Share{}).Error
 }
 
@@ -234,7 +280,7 @@ func SearchShares(page, pageSize int, order, keywords string) ([]Share, int) {
 	}
 
 	dbChain := DB
-	dbChain = dbChain.Where("password = ? and remain_downloads <> 0 and (expires is NULL or expires > ?) and source_name like ?", "", time.Now(), "%"+strings.Join(availableList, "%")+"%")
+	dbChain = dbChain.Where("password = ? and file_request = ? and remain_downloads <> 0 and (expires is NULL or expires > ?) and source_name like ?", "", false, time.Now(), "%"+strings.Join(availableList, "%")+"%")
 
 	// 计算总数用于分页
 	dbChain.Model(&Share{}).Count(&total)
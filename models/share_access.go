@@ -0,0 +1,131 @@
+package model
+
+import (
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// ShareAccess 分享访问记录
+type ShareAccess struct {
+	gorm.Model
+	ShareID   uint   `gorm:"index:share_access_share_id"`
+	Action    string // 访问类型
+	UserID    uint   // 访问者ID，未登录时为0
+	IP        string
+	UserAgent string `gorm:"type:text"`
+	Referer   string `gorm:"type:text"`
+	Path      string `gorm:"type:text"` // 下载的文件或目录相对于分享的路径
+}
+
+const (
+	// ShareAccessView 查看分享
+	ShareAccessView = "view"
+	// ShareAccessDownload 下载、预览或打包下载分享中的文件
+	ShareAccessDownload = "download"
+)
+
+// ShareFileStat 分享中文件的下载统计
+type ShareFileStat struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// Create 创建访问记录
+func (access *ShareAccess) Create() error {
+	return DB.Create(access).Error
+}
+
+// RecordAccess 记录请求 c 中用户 user 对分享的访问，filePath 为下载的文件相对于分享的路径
+func (share *Share) RecordAccess(c *gin.Context, user *User, action, filePath string) {
+	access := &ShareAccess{
+		ShareID: share.ID,
+		Action:  action,
+		Path:    filePath,
+	}
+	if user != nil {
+		access.UserID = user.ID
+	}
+	if c != nil {
+		access.IP = c.ClientIP()
+		access.UserAgent = c.Request.UserAgent()
+		access.Referer = c.Request.Referer()
+	}
+
+	if err := access.Create(); err != nil {
+		util.Log().Warning("无法记录分享访问，%s", err)
+	}
+}
+
+// CountShareAccessesByDay 按日统计 start 之后的访问次数，返回日期（2006-01-02）及访问类型到次数的映射，
+// shareID 为 0 时统计所有分享
+func CountShareAccessesByDay(shareID uint, start time.Time) map[string]map[string]int {
+	var rows []struct {
+		Day    string
+		Action string
+		Count  int
+	}
+	dbChain := DB.Model(&ShareAccess{}).
+		Select("DATE(created_at) as day, action, count(*) as count").
+		Where("created_at >= ?", start)
+	if shareID != 0 {
+		dbChain = dbChain.Where("share_id = ?", shareID)
+	}
+	dbChain.Group("DATE(created_at), action").Scan(&rows)
+
+	res := make(map[string]map[string]int)
+	for _, row := range rows {
+		// 部分数据库驱动将日期解析为时间，只保留日期部分
+		if len(row.Day) > 10 {
+			row.Day = row.Day[:10]
+		}
+		if res[row.Day] == nil {
+			res[row.Day] = make(map[string]int)
+		}
+		res[row.Day][row.Action] += row.Count
+	}
+	return res
+}
+
+// DeleteShareAccesses 删除分享 shareIDs 的访问记录
+func DeleteShareAccesses(shareIDs []uint) error {
+	return DB.Unscoped().Where("share_id in (?)", shareIDs).Delete(&ShareAccess{}).Error
+}
+
+// DeleteShareAccessesOfUser 删除用户 uid 创建的分享的访问记录
+func DeleteShareAccessesOfUser(uid uint) error {
+	shares := DB.Unscoped().Model(&Share{}).Select("id").Where("user_id = ?", uid).QueryExpr()
+	return DB.Unscoped().Where("share_id in (?)", shares).Delete(&ShareAccess{}).Error
+}
+
+// DeleteShareAccessesBefore 删除 before 之前的访问记录，返回删除的条数
+func DeleteShareAccessesBefore(before time.Time) (int64, error) {
+	result := DB.Unscoped().Where("created_at < ?", before).Delete(&ShareAccess{})
+	return result.RowsAffected, result.Error
+}
+
+// TopShareFiles 列出分享中下载次数最多的文件
+func TopShareFiles(shareID uint, limit int) []ShareFileStat {
+	var stats []ShareFileStat
+	DB.Model(&ShareAccess{}).
+		Select("path, count(*) as count").
+		Where("share_id = ? and action = ?", shareID, ShareAccessDownload).
+		Group("path").
+		Order("count desc").
+		Limit(limit).
+		Scan(&stats)
+	return stats
+}
+
+// ListShareAccesses 分页列出分享的访问记录，按时间倒序
+func ListShareAccesses(shareID uint, page, pageSize int) ([]ShareAccess, int) {
+	var (
+		accesses []ShareAccess
+		total    int
+	)
+	dbChain := DB.Where("share_id = ?", shareID)
+	dbChain.Model(&ShareAccess{}).Count(&total)
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order("id desc").Find(&accesses)
+	return accesses, total
+}
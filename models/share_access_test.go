@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShare_RecordAccess(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Model: gorm.Model{ID: 1}}
+
+	// 成功
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.Header.Set("Referer", "https://example.com/")
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, ShareAccessDownload, 2, sqlmock.AnyArg(), sqlmock.AnyArg(), "https://example.com/", "/a.txt").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		share.RecordAccess(c, &User{Model: gorm.Model{ID: 2}}, ShareAccessDownload, "/a.txt")
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败时不影响请求
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		share.RecordAccess(nil, nil, ShareAccessView, "")
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestCountShareAccessesByDay(t *testing.T) {
+	asserts := assert.New(t)
+	start := time.Now().Add(-24 * time.Hour)
+
+	// 指定分享
+	{
+		mock.ExpectQuery("SELECT DATE(.+)share_accesses(.+)GROUP BY DATE(.+)").WithArgs(start, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"day", "action", "count"}).
+				AddRow("2020-01-01", ShareAccessView, 3).
+				AddRow("2020-01-01T00:00:00Z", ShareAccessDownload, 1).
+				AddRow("2020-01-02", ShareAccessView, 2))
+		res := CountShareAccessesByDay(1, start)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(3, res["2020-01-01"][ShareAccessView])
+		asserts.Equal(1, res["2020-01-01"][ShareAccessDownload])
+		asserts.Equal(2, res["2020-01-02"][ShareAccessView])
+		asserts.Equal(0, res["2020-01-03"][ShareAccessView])
+	}
+
+	// 所有分享
+	{
+		mock.ExpectQuery("SELECT DATE(.+)share_accesses(.+)GROUP BY DATE(.+)").WithArgs(start).
+			WillReturnRows(sqlmock.NewRows([]string{"day", "action", "count"}))
+		asserts.Empty(CountShareAccessesByDay(0, start))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestDeleteShareAccesses(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 指定分享
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)share_id(.+)").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+		asserts.NoError(DeleteShareAccesses([]uint{1, 2}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 用户的所有分享
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)SELECT id FROM(.+)shares(.+)user_id(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+		asserts.NoError(DeleteShareAccessesOfUser(1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 过期记录
+	{
+		before := time.Now()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)created_at(.+)").WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+		deleted, err := DeleteShareAccessesBefore(before)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(3, deleted)
+	}
+}
+
+func TestTopShareFiles(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT path, count(.+)").WithArgs(1, ShareAccessDownload).
+		WillReturnRows(sqlmock.NewRows([]string{"path", "count"}).AddRow("/a.txt", 3).AddRow("/b.txt", 1))
+	stats := TopShareFiles(1, 10)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Len(stats, 2)
+	asserts.Equal("/a.txt", stats[0].Path)
+	asserts.Equal(3, stats[0].Count)
+}
+
+func TestListShareAccesses(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT count(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("SELECT(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "share_id"}).AddRow(2, 1).AddRow(1, 1))
+	accesses, total := ListShareAccesses(1, 1, 10)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(2, total)
+	asserts.Len(accesses, 2)
+}
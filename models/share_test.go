@@ -289,12 +289,21 @@ func TestShare_UpdateAndDelete(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)").
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		err := share.Delete()
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
 	}
 
 	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)SELECT id FROM(.+)shares(.+)").
+			WithArgs(1, true).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").
 			WillReturnResult(sqlmock.NewResult(1, 1))
@@ -318,12 +327,63 @@ func TestListShares(t *testing.T) {
 	asserts.Equal(2, total)
 }
 
+func TestShare_CanUpload(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 非文件收集
+	{
+		share := Share{}
+		asserts.Error(share.CanUpload("a.txt", 1))
+	}
+
+	// 不限制
+	{
+		share := Share{FileRequest: true}
+		asserts.NoError(share.CanUpload("a.txt", 1024))
+	}
+
+	// 单文件大小超出限制
+	{
+		share := Share{FileRequest: true, MaxSize: 10}
+		asserts.Error(share.CanUpload("a.txt", 11))
+		asserts.NoError(share.CanUpload("a.txt", 10))
+	}
+
+	// 总容量超出限制
+	{
+		share := Share{FileRequest: true, UploadQuota: 10, Uploaded: 8}
+		asserts.Error(share.CanUpload("a.txt", 3))
+		asserts.NoError(share.CanUpload("a.txt", 2))
+	}
+
+	// 扩展名
+	{
+		share := Share{FileRequest: true, AllowedExts: "jpg,png"}
+		asserts.NoError(share.CanUpload("a.JPG", 1))
+		asserts.Error(share.CanUpload("a.txt", 1))
+		asserts.Error(share.CanUpload("jpg", 1))
+	}
+}
+
+func TestShare_Received(t *testing.T) {
+	asserts := assert.New(t)
+	share := Share{Model: gorm.Model{ID: 1}, FileRequest: true}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	share.Received(10)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(1, share.Uploads)
+	asserts.EqualValues(10, share.Uploaded)
+}
+
 func TestSearchShares(t *testing.T) {
 	asserts := assert.New(t)
 
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT(.+)").
-		WithArgs("", sqlmock.AnyArg(), "%1%2%").
+		WithArgs("", false, sqlmock.AnyArg(), "%1%2%").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	res, total := SearchShares(1, 10, "id", "1 2")
 	asserts.NoError(mock.ExpectationsWereMet())
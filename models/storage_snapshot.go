@@ -0,0 +1,233 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StorageSnapshot 容量使用快照，由定时任务周期性记录，用于统计容量增长
+type StorageSnapshot struct {
+	gorm.Model
+	Batch    int64  `gorm:"index:storage_snapshot_batch"` // 快照批次，为记录时的时间戳
+	Scope    string `gorm:"index:storage_snapshot_scope"` // 统计维度
+	TargetID uint   // 用户、用户组或存储策略ID
+	Type     string // 文件类型，统计维度为 type 时有效
+	Size     uint64
+	Files    int
+}
+
+// 容量统计维度
+const (
+	StorageScopeSite   = "site"
+	StorageScopeUser   = "user"
+	StorageScopeGroup  = "group"
+	StorageScopePolicy = "policy"
+	StorageScopeType   = "type"
+)
+
+// StorageTypeOther 不属于任何已知类型的文件
+const StorageTypeOther = "other"
+
+// StorageUsage 容量使用统计结果
+type StorageUsage struct {
+	TargetID uint   `json:"id"`
+	Type     string `json:"type,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Size     uint64 `json:"size"`
+	Files    int    `json:"files"`
+}
+
+// StorageUsageBySite 统计全站的容量使用
+func StorageUsageBySite() ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := DB.Model(&File{}).Select("0 as target_id, sum(size) as size, count(*) as files").Scan(&usages).Error
+	return usages, err
+}
+
+// StorageUsageByUser 按用户统计容量使用
+func StorageUsageByUser() ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := DB.Model(&File{}).
+		Select("user_id as target_id, sum(size) as size, count(*) as files").
+		Group("user_id").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// StorageUsageByGroup 按用户组统计容量使用
+func StorageUsageByGroup() ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := DB.Model(&File{}).
+		Select("users.group_id as target_id, sum(files.size) as size, count(*) as files").
+		Joins("inner join users on users.id = files.user_id").
+		Group("users.group_id").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// StorageUsageByPolicy 按存储策略统计容量使用
+func StorageUsageByPolicy() ([]StorageUsage, error) {
+	var usages []StorageUsage
+	err := DB.Model(&File{}).
+		Select("policy_id as target_id, sum(size) as size, count(*) as files").
+		Group("policy_id").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// StorageUsageByType 按文件类型统计容量使用，types 为各类型包含的扩展名，
+// 不属于任何类型的文件计入 other。uid 不为 0 时只统计此用户的文件
+func StorageUsageByType(uid uint, types map[string][]string) ([]StorageUsage, error) {
+	var (
+		usages []StorageUsage
+		cases  []string
+		args   []interface{}
+	)
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		exts := types[name]
+		conditions := make([]string, 0, len(exts))
+		for _, ext := range exts {
+			conditions = append(conditions, "name like ?")
+			args = append(args, "%."+ext)
+		}
+		if len(conditions) == 0 {
+			continue
+		}
+		cases = append(cases, "when "+strings.Join(conditions, " or ")+" then ?")
+		args = append(args, name)
+	}
+	args = append(args, StorageTypeOther)
+
+	typeExpr := "case " + strings.Join(cases, " ") + " else ? end"
+	dbChain := DB.Model(&File{}).Select("("+typeExpr+") as type, sum(size) as size, count(*) as files", args...)
+	if uid != 0 {
+		dbChain = dbChain.Where("user_id = ?", uid)
+	}
+	err := dbChain.Group("type").Scan(&usages).Error
+	return usages, err
+}
+
+// StorageUsageByFolder 统计用户目录 parentID 下各子目录（包含其所有下级目录）的容量使用，
+// 直接位于 parentID 下的文件以 TargetID 为 parentID 的记录返回
+func StorageUsageByFolder(uid, parentID uint) ([]StorageUsage, error) {
+	var folders []Folder
+	if err := DB.Select("id, name, parent_id").Where("owner_id = ?", uid).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	var fileUsages []StorageUsage
+	if err := DB.Model(&File{}).
+		Select("folder_id as target_id, sum(size) as size, count(*) as files").
+		Where("user_id = ?", uid).
+		Group("folder_id").
+		Scan(&fileUsages).Error; err != nil {
+		return nil, err
+	}
+
+	parents := make(map[uint]uint, len(folders))
+	for _, folder := range folders {
+		if folder.ParentID != nil {
+			parents[folder.ID] = *folder.ParentID
+		}
+	}
+
+	// child 返回目录 id 所属的 parentID 的直接子目录，不在 parentID 之下时返回 false
+	child := func(id uint) (uint, bool) {
+		if id == parentID {
+			return parentID, true
+		}
+		for {
+			parent, ok := parents[id]
+			if !ok {
+				return 0, false
+			}
+			if parent == parentID {
+				return id, true
+			}
+			id = parent
+		}
+	}
+
+	// 将各目录的文件向上归入 parentID 的直接子目录
+	results := make(map[uint]*StorageUsage)
+	for _, usage := range fileUsages {
+		id, ok := child(usage.TargetID)
+		if !ok {
+			continue
+		}
+		if _, ok := results[id]; !ok {
+			results[id] = &StorageUsage{TargetID: id}
+		}
+		results[id].Size += usage.Size
+		results[id].Files += usage.Files
+	}
+
+	usages := make([]StorageUsage, 0, len(results))
+	for _, folder := range folders {
+		if usage, ok := results[folder.ID]; ok {
+			usage.Name = folder.Name
+			usages = append(usages, *usage)
+		}
+	}
+	return usages, nil
+}
+
+// CreateStorageSnapshots 将统计结果记录为批次 batch 中维度为 scope 的快照
+func CreateStorageSnapshots(batch int64, scope string, usages []StorageUsage) error {
+	tx := DB.Begin()
+	for _, usage := range usages {
+		snapshot := StorageSnapshot{
+			Batch:    batch,
+			Scope:    scope,
+			TargetID: usage.TargetID,
+			Type:     usage.Type,
+			Size:     usage.Size,
+			Files:    usage.Files,
+		}
+		if err := tx.Create(&snapshot).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// LatestStorageSnapshots 列出维度 scope 最近一批快照中容量最大的 limit 条
+func LatestStorageSnapshots(scope string, limit int) ([]StorageSnapshot, error) {
+	var (
+		latest    StorageSnapshot
+		snapshots []StorageSnapshot
+	)
+	if err := DB.Where("scope = ?", scope).Order("batch desc").First(&latest).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return snapshots, nil
+		}
+		return nil, err
+	}
+
+	err := DB.Where("scope = ? and batch = ?", scope, latest.Batch).Order("size desc").Limit(limit).Find(&snapshots).Error
+	return snapshots, err
+}
+
+// ListStorageSnapshots 列出 after 之后维度 scope 下对象 targetID 的快照，按时间顺序排列。
+// 统计维度为 type 时，typ 指定文件类型
+func ListStorageSnapshots(scope string, targetID uint, typ string, after time.Time) ([]StorageSnapshot, error) {
+	var snapshots []StorageSnapshot
+	err := DB.Where("scope = ? and target_id = ? and type = ? and created_at >= ?", scope, targetID, typ, after).
+		Order("batch").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// DeleteStorageSnapshotsBefore 删除 before 之前记录的快照，返回删除的条数
+func DeleteStorageSnapshotsBefore(before time.Time) (int64, error) {
+	result := DB.Unscoped().Where("created_at < ?", before).Delete(&StorageSnapshot{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStorageUsageByUser(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT user_id as target_id(.+)GROUP BY user_id").
+		WillReturnRows(sqlmock.NewRows([]string{"target_id", "size", "files"}).AddRow(1, 100, 2).AddRow(2, 50, 1))
+	usages, err := StorageUsageByUser()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(usages, 2)
+	asserts.EqualValues(100, usages[0].Size)
+	asserts.Equal(2, usages[0].Files)
+}
+
+func TestStorageUsageByType(t *testing.T) {
+	asserts := assert.New(t)
+	types := map[string][]string{
+		"video": {"mp4"},
+		"image": {"png", "jpg"},
+	}
+
+	mock.ExpectQuery("SELECT \\(case when name like \\? or name like \\? then \\? when name like \\? then \\? else \\? end\\) as type(.+)").
+		WithArgs("%.png", "%.jpg", "image", "%.mp4", "video", StorageTypeOther, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "size", "files"}).AddRow("image", 10, 1).AddRow(StorageTypeOther, 5, 1))
+	usages, err := StorageUsageByType(1, types)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(usages, 2)
+	asserts.Equal("image", usages[0].Type)
+}
+
+func TestStorageUsageByFolder(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		// 1 为根目录，2、3 为其子目录，4 位于 2 之下
+		mock.ExpectQuery("SELECT id, name, parent_id(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).
+				AddRow(1, "/", nil).
+				AddRow(2, "a", 1).
+				AddRow(3, "b", 1).
+				AddRow(4, "c", 2))
+		mock.ExpectQuery("SELECT folder_id as target_id(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"target_id", "size", "files"}).
+				AddRow(1, 1, 1).
+				AddRow(2, 10, 1).
+				AddRow(4, 100, 2).
+				AddRow(5, 1000, 1))
+		usages, err := StorageUsageByFolder(1, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(usages, 2)
+		asserts.EqualValues(1, usages[0].TargetID)
+		asserts.EqualValues(1, usages[0].Size)
+		asserts.EqualValues(2, usages[1].TargetID)
+		asserts.Equal("a", usages[1].Name)
+		asserts.EqualValues(110, usages[1].Size)
+		asserts.Equal(3, usages[1].Files)
+	}
+
+	// 统计失败
+	{
+		mock.ExpectQuery("SELECT id, name, parent_id(.+)").WillReturnError(errors.New("error"))
+		_, err := StorageUsageByFolder(1, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestCreateStorageSnapshots(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		err := CreateStorageSnapshots(1, StorageScopeUser, []StorageUsage{{TargetID: 1}, {TargetID: 2}})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := CreateStorageSnapshots(1, StorageScopeUser, []StorageUsage{{TargetID: 1}})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestLatestStorageSnapshots(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 没有快照
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(StorageScopeUser).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		snapshots, err := LatestStorageSnapshots(StorageScopeUser, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(snapshots, 0)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(StorageScopeUser).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "batch"}).AddRow(3, 100))
+		mock.ExpectQuery("SELECT(.+)").WithArgs(StorageScopeUser, 100).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "batch", "size"}).AddRow(3, 100, 20).AddRow(2, 100, 10))
+		snapshots, err := LatestStorageSnapshots(StorageScopeUser, 10)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(snapshots, 2)
+	}
+}
+
+func TestDeleteStorageSnapshotsBefore(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	deleted, err := DeleteStorageSnapshotsBefore(time.Now())
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(3, deleted)
+}
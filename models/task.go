@@ -14,6 +14,10 @@ type Task struct {
 	Progress int    // 进度
 	Error    string `gorm:"type:text"` // 错误信息
 	Props    string `gorm:"type:text"` // 任务属性
+	Priority int    // 优先级，数值越大越先执行
+
+	ProcessedSize uint64 // 已处理的字节数
+	TotalSize     uint64 // 已知的待处理总字节数
 }
 
 // Create 创建任务记录
@@ -35,11 +39,39 @@ func (task *Task) SetProgress(progress int) error {
 	return DB.Model(task).Select("progress").Updates(map[string]interface{}{"progress": progress}).Error
 }
 
+// SetSizeProgress 设定按字节计算的任务进度
+func (task *Task) SetSizeProgress(processed, total uint64) error {
+	return DB.Model(task).Select("processed_size", "total_size").
+		Updates(map[string]interface{}{"processed_size": processed, "total_size": total}).Error
+}
+
+// SetPriority 设定任务优先级
+func (task *Task) SetPriority(priority int) error {
+	return DB.Model(task).Select("priority").Updates(map[string]interface{}{"priority": priority}).Error
+}
+
+// Reset 清除任务的执行结果及进度，重新设为排队中，用于重试任务
+func (task *Task) Reset(status int) error {
+	return DB.Model(task).Select("status", "progress", "error", "processed_size", "total_size").
+		Updates(map[string]interface{}{
+			"status":         status,
+			"progress":       0,
+			"error":          "",
+			"processed_size": 0,
+			"total_size":     0,
+		}).Error
+}
+
 // SetError 设定错误信息
 func (task *Task) SetError(err string) error {
 	return DB.Model(task).Select("error").Updates(map[string]interface{}{"error": err}).Error
 }
 
+// SetProps 更新任务属性，用于记录可恢复的执行进度
+func (task *Task) SetProps(props string) error {
+	return DB.Model(task).Select("props").Updates(map[string]interface{}{"props": props}).Error
+}
+
 // GetTasksByStatus 根据状态检索任务
 func GetTasksByStatus(status ...int) []Task {
 	var tasks []Task
@@ -54,6 +86,13 @@ func GetTasksByID(id interface{}) (*Task, error) {
 	return task, result.Error
 }
 
+// GetTaskByIDAndUser 根据ID检索用户所属的任务
+func GetTaskByIDAndUser(id, uid uint) (*Task, error) {
+	task := &Task{}
+	result := DB.Where("id = ? AND user_id = ?", id, uid).First(task)
+	return task, result.Error
+}
+
 // ListTasks 列出用户所属的任务
 func ListTasks(uid uint, page, pageSize int, order string) ([]Task, int) {
 	var (
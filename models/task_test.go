@@ -71,6 +71,18 @@ func TestTask_SetProgress(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestTask_SetProps(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)props(.+)").WithArgs("{}", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.SetProps("{}"))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
 func TestGetTasksByID(t *testing.T) {
 	asserts := assert.New(t)
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
@@ -91,3 +103,60 @@ func TestListTasks(t *testing.T) {
 	asserts.EqualValues(5, total)
 	asserts.Len(res, 1)
 }
+
+func TestTask_SetSizeProgress(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)processed_size(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.SetSizeProgress(1, 2))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestTask_SetPriority(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)priority(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.SetPriority(1))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestTask_Reset(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.Reset(0))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetTaskByIDAndUser(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		res, err := GetTaskByIDAndUser(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, res.ID)
+	}
+
+	// 不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, 3).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetTaskByIDAndUser(1, 3)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
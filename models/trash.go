@@ -0,0 +1,203 @@
+package model
+
+import (
+	"fmt"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// Trash 回收站记录，每条记录对应一个被删除的顶级文件或目录
+type Trash struct {
+	gorm.Model
+	UserID     uint   `gorm:"index:trash_user_id"`
+	ObjectType string // 对象类型（文件/目录）
+	ObjectID   uint   // 被删除的文件或目录ID
+	Name       string // 对象原始名称
+	OriginPath string `gorm:"type:text"` // 删除前所在父目录的路径
+	Size       uint64 // 对象大小，目录为其下所有文件大小之和
+}
+
+const (
+	// TrashFileType 回收站中的文件
+	TrashFileType = "file"
+	// TrashFolderType 回收站中的目录
+	TrashFolderType = "dir"
+)
+
+// Create 创建回收站记录
+func (trash *Trash) Create() (uint, error) {
+	if err := DB.Create(trash).Error; err != nil {
+		util.Log().Warning("无法插入回收站记录, %s", err)
+		return 0, err
+	}
+	return trash.ID, nil
+}
+
+// TrashName 对象在回收站中存放时使用的名称，避免与原位置新建的同名对象冲突
+func (trash *Trash) TrashName() string {
+	return fmt.Sprintf("trash_%d", trash.ID)
+}
+
+// GetTrashByIDs 根据ID和用户批量获取回收站记录
+func GetTrashByIDs(ids []uint, uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("id in (?) AND user_id = ?", ids, uid).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetTrashByUID 获取用户所有的回收站记录
+func GetTrashByUID(uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("user_id = ?", uid).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetExpiredTrash 获取在给定时间之前删除的回收站记录
+func GetExpiredTrash(before time.Time) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("created_at < ?", before).Find(&trashes)
+	return trashes, result.Error
+}
+
+// ListTrash 分页列出用户回收站中的记录
+func ListTrash(uid uint, page, pageSize int, order string) ([]Trash, int) {
+	var (
+		trashes []Trash
+		total   int
+	)
+	dbChain := DB.Where("user_id = ?", uid)
+
+	// 计算总数用于分页
+	dbChain.Model(&Trash{}).Count(&total)
+
+	// 查询记录
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order(order).Find(&trashes)
+
+	return trashes, total
+}
+
+// Delete 删除回收站记录
+func (trash *Trash) Delete() error {
+	return DB.Unscoped().Delete(trash).Error
+}
+
+// MoveToTrash 将文件移入回收站，文件会被重命名并脱离原目录
+func (file *File) MoveToTrash(trash *Trash) error {
+	return DB.Unscoped().Model(file).Updates(map[string]interface{}{
+		"name":       trash.TrashName(),
+		"folder_id":  0,
+		"deleted_at": trash.CreatedAt,
+	}).Error
+}
+
+// RestoreTo 将回收站中的文件以name为名恢复至parent目录下
+func (file *File) RestoreTo(parent *Folder, name string) error {
+	return DB.Unscoped().Model(file).Updates(map[string]interface{}{
+		"name":       name,
+		"folder_id":  parent.ID,
+		"deleted_at": gorm.Expr("NULL"),
+	}).Error
+}
+
+// MoveToTrash 将目录及其所有子对象移入回收站，目录会被重命名并脱离原目录
+func (folder *Folder) MoveToTrash(trash *Trash) error {
+	// 列出所有递归子目录
+	folders, err := GetRecursiveChildFolder([]uint{folder.ID}, folder.OwnerID, false)
+	if err != nil {
+		return err
+	}
+	folderIDs := make([]uint, 0, len(folders)+1)
+	folderIDs = append(folderIDs, folder.ID)
+	for _, value := range folders {
+		folderIDs = append(folderIDs, value.ID)
+	}
+
+	// 标记所有子文件、子目录为已删除
+	tx := DB.Begin()
+	if err := tx.Model(&File{}).Where("folder_id in (?)", folderIDs).
+		Update("deleted_at", trash.CreatedAt).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&Folder{}).Where("id in (?)", folderIDs[1:]).
+		Update("deleted_at", trash.CreatedAt).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Model(folder).Updates(map[string]interface{}{
+		"name":       trash.TrashName(),
+		"parent_id":  gorm.Expr("NULL"),
+		"deleted_at": trash.CreatedAt,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// RestoreTo 将回收站中的目录及其所有子对象以name为名恢复至parent目录下
+func (folder *Folder) RestoreTo(parent *Folder, name string) error {
+	folders, err := GetTrashedChildFolder(folder.ID, folder.OwnerID)
+	if err != nil {
+		return err
+	}
+	folderIDs := make([]uint, 0, len(folders))
+	for _, value := range folders {
+		folderIDs = append(folderIDs, value.ID)
+	}
+
+	tx := DB.Begin()
+	if err := tx.Unscoped().Model(&File{}).Where("folder_id in (?)", folderIDs).
+		Update("deleted_at", gorm.Expr("NULL")).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Unscoped().Model(&Folder{}).Where("id in (?) AND id <> ?", folderIDs, folder.ID).
+		Update("deleted_at", gorm.Expr("NULL")).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Model(folder).Updates(map[string]interface{}{
+		"name":       name,
+		"parent_id":  parent.ID,
+		"deleted_at": gorm.Expr("NULL"),
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// GetTrashedFile 根据ID查找回收站中的文件
+func GetTrashedFile(id, uid uint) (*File, error) {
+	var file File
+	result := DB.Unscoped().Where("id = ? AND user_id = ? AND deleted_at is not NULL", id, uid).First(&file)
+	return &file, result.Error
+}
+
+// GetTrashedFolder 根据ID查找回收站中的目录
+func GetTrashedFolder(id, uid uint) (*Folder, error) {
+	var folder Folder
+	result := DB.Unscoped().Where("id = ? AND owner_id = ? AND deleted_at is not NULL", id, uid).First(&folder)
+	return &folder, result.Error
+}
+
+// GetTrashedChildFolder 查找回收站中目录的所有递归子目录，包括自身
+func GetTrashedChildFolder(id, uid uint) ([]Folder, error) {
+	return getRecursiveChildFolder(DB.Unscoped(), []uint{id}, uid, true)
+}
+
+// GetTrashedChildFiles 批量检索回收站中目录下的子文件
+func GetTrashedChildFiles(folders []Folder) ([]File, error) {
+	folderIDs := make([]uint, 0, len(folders))
+	for _, value := range folders {
+		folderIDs = append(folderIDs, value.ID)
+	}
+
+	var files []File
+	result := DB.Unscoped().Where("folder_id in (?)", folderIDs).Find(&files)
+	return files, result.Error
+}
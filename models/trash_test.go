@@ -0,0 +1,168 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTrash_Create(t *testing.T) {
+	asserts := assert.New(t)
+	trash := Trash{}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		id, err := trash.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(1, id)
+		asserts.Equal("trash_1", trash.TrashName())
+	}
+
+	// 失败
+	{
+		trash := Trash{}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		id, err := trash.Create()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.EqualValues(0, id)
+	}
+}
+
+func TestGetTrashByIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	res, err := GetTrashByIDs([]uint{1, 2}, 3)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
+}
+
+func TestGetExpiredTrash(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	res, err := GetExpiredTrash(time.Now())
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+}
+
+func TestListTrash(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	res, total := ListTrash(1, 1, 10, "created_at desc")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(2, total)
+	asserts.Len(res, 2)
+}
+
+func TestFile_MoveToTrash(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{}
+	file.ID = 1
+	trash := Trash{}
+	trash.ID = 5
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)").
+		WithArgs(sqlmock.AnyArg(), 0, "trash_5", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(file.MoveToTrash(&trash))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestFolder_MoveToTrash(t *testing.T) {
+	asserts := assert.New(t)
+	folder := Folder{OwnerID: 1}
+	folder.ID = 1
+	trash := Trash{}
+	trash.ID = 5
+
+	// 成功
+	{
+		// 递归查找子目录
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 标记子文件、子目录
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		// 更新自身
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(folder.MoveToTrash(&trash))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新自身失败，整体回滚
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(folder.MoveToTrash(&trash))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 标记子文件失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(folder.MoveToTrash(&trash))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFolder_RestoreTo(t *testing.T) {
+	asserts := assert.New(t)
+	folder := Folder{OwnerID: 1}
+	folder.ID = 1
+	parent := Folder{}
+	parent.ID = 3
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").
+			WithArgs("restored", 3, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(folder.RestoreTo(&parent, "restored"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 恢复子目录失败，整体回滚
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(folder.RestoreTo(&parent, "restored"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -277,3 +277,71 @@ func (user *User) UpdateOptions() error {
 	}
 	return user.Update(map[string]interface{}{"options": user.Options})
 }
+
+// CreateExternalUser 为首次通过 OIDC、LDAP 等外部身份登录的用户创建账号，账号使用随机密码，
+// 只能通过外部身份登录。nicks 为依次选用的昵称，均为空时使用邮箱前缀；昵称已被占用时追加随机后缀重试
+func CreateExternalUser(email string, group uint, nicks ...string) (*User, error) {
+	user := NewUser()
+	user.Email = email
+	for _, nick := range nicks {
+		if nick != "" {
+			user.Nick = nick
+			break
+		}
+	}
+	if user.Nick == "" {
+		user.Nick = strings.Split(email, "@")[0]
+	}
+	if runes := []rune(user.Nick); len(runes) > 40 {
+		user.Nick = string(runes[:40])
+	}
+	user.SetPassword(util.RandStringRunes(64))
+	user.Status = Active
+	user.GroupID = group
+
+	err := DB.Create(&user).Error
+	if err != nil && isNickTaken(user.Nick) {
+		user.ID = 0
+		user.Nick += "_" + util.RandStringRunes(4)
+		err = DB.Create(&user).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// isNickTaken 昵称是否已被占用，包括已删除的用户
+func isNickTaken(nick string) bool {
+	count := 0
+	if err := DB.Unscoped().Model(&User{}).Where("nick = ?", nick).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// MatchGroupMapping 按映射规则计算外部身份所属的用户组，返回是否有规则匹配。mappings 为 JSON 数组，
+// 每条规则的 key 字段为要匹配的值（如 OIDC 声明值、LDAP 组 DN），group 字段为用户组ID；
+// foldCase 为 true 时比较忽略大小写
+func MatchGroupMapping(mappings, key string, values []string, foldCase bool) (uint, bool) {
+	var rules []map[string]interface{}
+	if err := json.Unmarshal([]byte(mappings), &rules); err != nil {
+		return 0, false
+	}
+
+	for _, rule := range rules {
+		expected, _ := rule[key].(string)
+		group, ok := rule["group"].(float64)
+		if expected == "" || !ok {
+			continue
+		}
+		for _, value := range values {
+			if value == expected || (foldCase && strings.EqualFold(value, expected)) {
+				return uint(group), true
+			}
+		}
+	}
+
+	return 0, false
+}
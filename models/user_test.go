@@ -7,6 +7,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -434,3 +435,79 @@ func TestUser_UpdateOptions(t *testing.T) {
 	asserts.NoError(user.UpdateOptions())
 	asserts.NoError(mock.ExpectationsWereMet())
 }
+
+func TestCreateExternalUser(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 使用独立的数据库Mock，不受其他测试遗留的预期影响
+	db, mock, err := sqlmock.New()
+	asserts.NoError(err)
+	defer db.Close()
+	DB, _ = gorm.Open("mysql", db)
+	defer func() { DB = mockDB }()
+
+	// 依次选用昵称
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)users").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)folders").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		user, err := CreateExternalUser("a@example.com", 3, "", "Name")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal("Name", user.Nick)
+		asserts.EqualValues(3, user.GroupID)
+		asserts.Equal(Active, user.Status)
+	}
+
+	// 昵称被占用时追加后缀重试
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)users").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		mock.ExpectQuery("SELECT(.+)").WithArgs("a").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)users").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectExec("INSERT(.+)folders").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		user, err := CreateExternalUser("a@example.com", 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(strings.HasPrefix(user.Nick, "a_"))
+	}
+
+	// 其他错误不重试
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)users").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		mock.ExpectQuery("SELECT(.+)").WithArgs("a").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		user, err := CreateExternalUser("a@example.com", 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(user)
+	}
+}
+
+func TestMatchGroupMapping(t *testing.T) {
+	asserts := assert.New(t)
+	mappings := `[{"dn":"cn=staff,dc=example,dc=org","group":3},{"dn":"cn=admins,dc=example,dc=org","group":1}]`
+
+	// 按规则顺序匹配
+	group, ok := MatchGroupMapping(mappings, "dn", []string{"cn=admins,dc=example,dc=org", "cn=staff,dc=example,dc=org"}, false)
+	asserts.True(ok)
+	asserts.EqualValues(3, group)
+
+	// 忽略大小写
+	_, ok = MatchGroupMapping(mappings, "dn", []string{"CN=Admins,DC=example,DC=org"}, false)
+	asserts.False(ok)
+	group, ok = MatchGroupMapping(mappings, "dn", []string{"CN=Admins,DC=example,DC=org"}, true)
+	asserts.True(ok)
+	asserts.EqualValues(1, group)
+
+	// 字段不匹配或格式错误
+	_, ok = MatchGroupMapping(mappings, "claim", []string{"cn=staff,dc=example,dc=org"}, false)
+	asserts.False(ok)
+	_, ok = MatchGroupMapping("not json", "dn", []string{"cn=staff,dc=example,dc=org"}, false)
+	asserts.False(ok)
+}
@@ -2,8 +2,12 @@ package model
 
 import (
 	"github.com/jinzhu/gorm"
+	"time"
 )
 
+// webdavTouchInterval 更新账户最后使用时间的最小间隔，避免每个请求都写入数据库
+const webdavTouchInterval = time.Minute
+
 // Webdav 应用账户
 type Webdav struct {
 	gorm.Model
@@ -11,6 +15,9 @@ type Webdav struct {
 	Password string `gorm:"unique_index:password_only_on"` // 应用密码
 	UserID   uint   `gorm:"unique_index:password_only_on"` // 用户ID
 	Root     string `gorm:"type:text"`                     // 根目录
+	Readonly bool   // 是否只读
+	// 最后使用时间
+	LastUsedAt *time.Time
 }
 
 // Create 创建账户
@@ -21,6 +28,15 @@ func (webdav *Webdav) Create() (uint, error) {
 	return webdav.ID, nil
 }
 
+// Touch 记录账户的最后使用时间，距上次记录不足 webdavTouchInterval 时忽略
+func (webdav *Webdav) Touch() error {
+	now := time.Now()
+	if webdav.LastUsedAt != nil && now.Sub(*webdav.LastUsedAt) < webdavTouchInterval {
+		return nil
+	}
+	return DB.Model(webdav).UpdateColumn("last_used_at", now).Error
+}
+
 // GetWebdavByPassword 根据密码和用户查找Webdav应用
 func GetWebdavByPassword(password string, uid uint) (*Webdav, error) {
 	webdav := &Webdav{}
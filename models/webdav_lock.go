@@ -0,0 +1,88 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// WebdavLock 持久化的WebDAV锁，供多个实例共享
+type WebdavLock struct {
+	gorm.Model
+	Token     string `gorm:"size:64;unique_index:lock_token"` // 锁令牌
+	UserID    uint   `gorm:"index:lock_user_id"`              // 用户ID
+	Root      string `gorm:"type:text"`                       // 被锁定的资源路径
+	ZeroDepth bool   // 是否仅锁定资源本身
+	OwnerXML  string `gorm:"type:text"` // 锁持有者信息
+	// 锁时长，单位为纳秒，负数表示永不过期
+	Duration int64
+	// 过期时间，为空时永不过期
+	ExpiresAt *time.Time `gorm:"index:lock_expires_at"`
+	// 被请求占用的截止时间，为空或已过去时表示未被占用
+	HeldUntil *time.Time
+}
+
+// Create 创建锁
+func (lock *WebdavLock) Create() error {
+	return DB.Create(lock).Error
+}
+
+// IsHeld 锁在 now 时是否正被请求占用
+func (lock *WebdavLock) IsHeld(now time.Time) bool {
+	return lock.HeldUntil != nil && now.Before(*lock.HeldUntil)
+}
+
+// GetWebdavLocksByUID 列出用户所有的锁
+func GetWebdavLocksByUID(uid uint) ([]WebdavLock, error) {
+	var locks []WebdavLock
+	result := DB.Where("user_id = ?", uid).Find(&locks)
+	return locks, result.Error
+}
+
+// GetWebdavLockByToken 根据令牌和用户查找锁
+func GetWebdavLockByToken(token string, uid uint) (*WebdavLock, error) {
+	lock := &WebdavLock{}
+	result := DB.Where("token = ? and user_id = ?", token, uid).First(lock)
+	return lock, result.Error
+}
+
+// DeleteExpiredWebdavLocks 删除用户在 now 时已过期且未被占用的锁
+func DeleteExpiredWebdavLocks(uid uint, now time.Time) error {
+	return DB.Unscoped().
+		Where("user_id = ? and expires_at < ? and (held_until is null or held_until < ?)", uid, now, now).
+		Delete(&WebdavLock{}).Error
+}
+
+// HoldWebdavLock 尝试占用未被占用的锁至 until，返回是否占用成功。
+// 占用通过单条条件更新完成，多个实例同时占用时只有一个会成功
+func HoldWebdavLock(token string, uid uint, now, until time.Time) (bool, error) {
+	result := DB.Model(&WebdavLock{}).
+		Where("token = ? and user_id = ? and (held_until is null or held_until < ?)", token, uid, now).
+		UpdateColumn("held_until", until)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseWebdavLock 解除锁的占用
+func ReleaseWebdavLock(token string, uid uint) error {
+	return DB.Model(&WebdavLock{}).
+		Where("token = ? and user_id = ?", token, uid).
+		UpdateColumn("held_until", gorm.Expr("NULL")).Error
+}
+
+// RefreshWebdavLock 更新未被占用的锁的时长及过期时间，返回是否更新成功
+func RefreshWebdavLock(token string, uid uint, now time.Time, duration int64, expires *time.Time) (bool, error) {
+	result := DB.Model(&WebdavLock{}).
+		Where("token = ? and user_id = ? and (held_until is null or held_until < ?)", token, uid, now).
+		UpdateColumns(map[string]interface{}{
+			"duration":   duration,
+			"expires_at": expires,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteWebdavLock 删除未被占用的锁，返回是否删除成功
+func DeleteWebdavLock(token string, uid uint, now time.Time) (bool, error) {
+	result := DB.Unscoped().
+		Where("token = ? and user_id = ? and (held_until is null or held_until < ?)", token, uid, now).
+		Delete(&WebdavLock{})
+	return result.RowsAffected > 0, result.Error
+}
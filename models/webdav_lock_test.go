@@ -0,0 +1,170 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWebdavLock_Create(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)webdav_locks(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		lock := WebdavLock{Token: "token", UserID: 1, Root: "/a"}
+		asserts.NoError(lock.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, lock.ID)
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)webdav_locks(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		lock := WebdavLock{Token: "token", UserID: 1, Root: "/a"}
+		asserts.Error(lock.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestWebdavLock_IsHeld(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	lock := WebdavLock{}
+
+	// 未被占用
+	asserts.False(lock.IsHeld(now))
+
+	// 占用中
+	until := now.Add(time.Minute)
+	lock.HeldUntil = &until
+	asserts.True(lock.IsHeld(now))
+
+	// 占用已超时
+	asserts.False(lock.IsHeld(now.Add(time.Hour)))
+}
+
+func TestGetWebdavLockByToken(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 存在
+	{
+		mock.ExpectQuery("SELECT(.+)webdav_locks(.+)").
+			WithArgs("token", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "token", "root"}).AddRow(1, "token", "/a"))
+		lock, err := GetWebdavLockByToken("token", 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal("/a", lock.Root)
+	}
+
+	// 不存在
+	{
+		mock.ExpectQuery("SELECT(.+)webdav_locks(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetWebdavLockByToken("token", 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestGetWebdavLocksByUID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)webdav_locks(.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "token"}).AddRow(1, "a").AddRow(2, "b"))
+	locks, err := GetWebdavLocksByUID(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(locks, 2)
+}
+
+func TestDeleteExpiredWebdavLocks(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)webdav_locks(.+)expires_at(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteExpiredWebdavLocks(1, time.Now()))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestHoldWebdavLock(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+
+	// 占用成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)webdav_locks(.+)held_until(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		ok, err := HoldWebdavLock("token", 1, now, now.Add(time.Hour))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(ok)
+	}
+
+	// 已被占用
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)webdav_locks(.+)held_until(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		ok, err := HoldWebdavLock("token", 1, now, now.Add(time.Hour))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.False(ok)
+	}
+}
+
+func TestReleaseWebdavLock(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)webdav_locks(.+)held_until(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.NoError(ReleaseWebdavLock("token", 1))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestRefreshWebdavLock(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	expires := now.Add(time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)webdav_locks(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ok, err := RefreshWebdavLock("token", 1, now, int64(time.Minute), &expires)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.True(ok)
+}
+
+func TestDeleteWebdavLock(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 删除成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)webdav_locks(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		ok, err := DeleteWebdavLock("token", 1, time.Now())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(ok)
+	}
+
+	// 锁被占用或不存在
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)webdav_locks(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		ok, err := DeleteWebdavLock("token", 1, time.Now())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.False(ok)
+	}
+}
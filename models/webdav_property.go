@@ -0,0 +1,108 @@
+package model
+
+import (
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// WebdavProperty 通过 PROPPATCH 设置的 WebDAV 自定义（dead）属性
+type WebdavProperty struct {
+	gorm.Model
+	ObjectID uint   `gorm:"index:property_object"` // 文件或目录ID
+	IsDir    bool   `gorm:"index:property_object"` // 是否为目录
+	Space    string // 属性命名空间
+	Local    string `gorm:"column:local_name"` // 属性名，local 为 MySQL 保留字
+	Lang     string // xml:lang
+	InnerXML string `gorm:"type:text"` // 属性值
+}
+
+// GetWebdavPropertiesByObjectIDs 获取文件或目录的属性
+func GetWebdavPropertiesByObjectIDs(ids []uint, isDir bool) ([]WebdavProperty, error) {
+	var props []WebdavProperty
+	result := DB.Where("object_id in (?) and is_dir = ?", ids, isDir).Find(&props)
+	return props, result.Error
+}
+
+// GetWebdavPropertiesInFolder 获取目录下所有直接子文件及子目录的属性
+func GetWebdavPropertiesInFolder(folderID uint) ([]WebdavProperty, error) {
+	var props []WebdavProperty
+	files := DB.Model(&File{}).Select("id").Where("folder_id = ?", folderID).QueryExpr()
+	folders := DB.Model(&Folder{}).Select("id").Where("parent_id = ?", folderID).QueryExpr()
+	result := DB.Where(
+		"(is_dir = ? and object_id in (?)) or (is_dir = ? and object_id in (?))",
+		false, files, true, folders,
+	).Find(&props)
+	return props, result.Error
+}
+
+// PatchWebdavProperties 按顺序设置或删除对象的属性，set 中的属性覆盖同名属性，
+// remove 中的属性只需指定 Space 和 Local。全部成功或全部失败
+func PatchWebdavProperties(objectID uint, isDir bool, set, remove []WebdavProperty) error {
+	tx := DB.Begin()
+	for _, prop := range remove {
+		if err := deleteWebdavProperty(tx, objectID, isDir, prop); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, prop := range set {
+		if err := deleteWebdavProperty(tx, objectID, isDir, prop); err != nil {
+			tx.Rollback()
+			return err
+		}
+		prop.Model = gorm.Model{}
+		prop.ObjectID = objectID
+		prop.IsDir = isDir
+		if err := tx.Create(&prop).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func deleteWebdavProperty(tx *gorm.DB, objectID uint, isDir bool, prop WebdavProperty) error {
+	return tx.Unscoped().
+		Where("object_id = ? and is_dir = ? and space = ? and local_name = ?", objectID, isDir, prop.Space, prop.Local).
+		Delete(&WebdavProperty{}).Error
+}
+
+// CopyWebdavProperties 将对象的属性复制到新对象，idMap 为原对象ID到新对象ID的映射
+func CopyWebdavProperties(idMap map[uint]uint, isDir bool) error {
+	if len(idMap) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(idMap))
+	for id := range idMap {
+		ids = append(ids, id)
+	}
+	props, err := GetWebdavPropertiesByObjectIDs(ids, isDir)
+	if err != nil {
+		return err
+	}
+
+	for _, prop := range props {
+		prop.Model = gorm.Model{}
+		prop.ObjectID = idMap[prop.ObjectID]
+		if err := DB.Create(&prop).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyWebdavPropertiesOrLog 复制对象的属性，失败时只记录日志，不影响复制操作本身
+func copyWebdavPropertiesOrLog(idMap map[uint]uint, isDir bool) {
+	if err := CopyWebdavProperties(idMap, isDir); err != nil {
+		util.Log().Warning("无法复制WebDAV属性, %s", err)
+	}
+}
+
+// DeleteWebdavPropertiesByObjectIDs 删除文件或目录的所有属性
+func DeleteWebdavPropertiesByObjectIDs(ids []uint, isDir bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Unscoped().Where("object_id in (?) and is_dir = ?", ids, isDir).Delete(&WebdavProperty{}).Error
+}
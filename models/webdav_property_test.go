@@ -0,0 +1,114 @@
+package model
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetWebdavPropertiesByObjectIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)webdav_properties(.+)").
+		WithArgs(1, 2, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "object_id", "local_name"}).AddRow(1, 1, "tag"))
+	props, err := GetWebdavPropertiesByObjectIDs([]uint{1, 2}, true)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	if asserts.Len(props, 1) {
+		asserts.Equal("tag", props[0].Local)
+	}
+}
+
+func TestGetWebdavPropertiesInFolder(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)webdav_properties(.+)files(.+)folders(.+)").
+		WithArgs(false, 1, true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "object_id", "is_dir"}).AddRow(1, 2, false).AddRow(2, 3, true))
+	props, err := GetWebdavPropertiesInFolder(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(props, 2)
+}
+
+func TestPatchWebdavProperties(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)webdav_properties(.+)local_name = (.+)").WithArgs(1, false, "urn:x", "old").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)webdav_properties(.+)local_name = (.+)").WithArgs(1, false, "urn:x", "new").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT(.+)webdav_properties(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := PatchWebdavProperties(
+			1,
+			false,
+			[]WebdavProperty{{Space: "urn:x", Local: "new", InnerXML: "value"}},
+			[]WebdavProperty{{Space: "urn:x", Local: "old"}},
+		)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+
+	// 写入失败，回滚
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)webdav_properties(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT(.+)webdav_properties(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := PatchWebdavProperties(
+			1,
+			true,
+			[]WebdavProperty{{Space: "urn:x", Local: "new", InnerXML: "value"}},
+			nil,
+		)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestCopyWebdavProperties(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 无需复制
+	{
+		asserts.NoError(CopyWebdavProperties(map[uint]uint{}, false))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)webdav_properties(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "object_id", "local_name"}).AddRow(1, 1, "tag"))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)webdav_properties(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, 2, false, "", "tag", "", "").
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		asserts.NoError(CopyWebdavProperties(map[uint]uint{1: 2}, false))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)webdav_properties(.+)").WillReturnError(errors.New("error"))
+		asserts.Error(CopyWebdavProperties(map[uint]uint{1: 2}, false))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestDeleteWebdavPropertiesByObjectIDs(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 无需删除
+	asserts.NoError(DeleteWebdavPropertiesByObjectIDs([]uint{}, false))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 成功
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)webdav_properties(.+)").WithArgs(1, 2, true).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteWebdavPropertiesByObjectIDs([]uint{1, 2}, true))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestWebdav_Create(t *testing.T) {
@@ -58,3 +59,39 @@ func TestDeleteWebDAVAccountByID(t *testing.T) {
 	asserts.NoError(DeleteTagByID(1, 1))
 	asserts.NoError(mock.ExpectationsWereMet())
 }
+
+func TestWebdav_Touch(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 首次使用
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)last_used_at(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		account := Webdav{}
+		account.ID = 1
+		asserts.NoError(account.Touch())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 距上次使用时间过短，忽略
+	{
+		now := time.Now()
+		account := Webdav{LastUsedAt: &now}
+		account.ID = 1
+		asserts.NoError(account.Touch())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 数据库错误
+	{
+		last := time.Now().Add(-time.Hour)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)last_used_at(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		account := Webdav{LastUsedAt: &last}
+		account.ID = 1
+		asserts.Error(account.Touch())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
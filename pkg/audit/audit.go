@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 操作类型
+const (
+	ActionLogin       = "login"
+	ActionUpload      = "upload"
+	ActionUpdate      = "update"
+	ActionDownload    = "download"
+	ActionDelete      = "delete"
+	ActionTrash       = "trash"
+	ActionRestore     = "restore"
+	ActionMove        = "move"
+	ActionCopy        = "copy"
+	ActionRename      = "rename"
+	ActionShareCreate = "share_create"
+	ActionShareUpdate = "share_update"
+	ActionShareDelete = "share_delete"
+
+	ActionInternalShareCreate = "internal_share_create"
+	ActionInternalShareUpdate = "internal_share_update"
+	ActionInternalShareDelete = "internal_share_delete"
+
+	ActionAdminSetting        = "admin_setting"
+	ActionAdminPolicySave     = "admin_policy_save"
+	ActionAdminPolicyDelete   = "admin_policy_delete"
+	ActionAdminGroupSave      = "admin_group_save"
+	ActionAdminGroupDelete    = "admin_group_delete"
+	ActionAdminUserSave       = "admin_user_save"
+	ActionAdminUserDelete     = "admin_user_delete"
+	ActionAdminUserBan        = "admin_user_ban"
+	ActionAdminUserUnlock     = "admin_user_unlock"
+	ActionAdminFileDelete     = "admin_file_delete"
+	ActionAdminShareDelete    = "admin_share_delete"
+	ActionAdminDownloadDelete = "admin_download_delete"
+	ActionAdminTaskDelete     = "admin_task_delete"
+	ActionAdminTaskCancel     = "admin_task_cancel"
+	ActionAdminTaskRetry      = "admin_task_retry"
+	ActionAdminTaskPriority   = "admin_task_priority"
+	ActionAdminImport         = "admin_import"
+	ActionAdminMigrate        = "admin_migrate"
+	ActionAdminEncrypt        = "admin_encrypt"
+)
+
+// queueSize 待写入日志队列的长度
+const queueSize = 1024
+
+// queue 待写入的日志，为空时不记录审计日志
+var queue chan *model.AuditLog
+
+// Init 启动审计日志写入协程，日志异步写入数据库，不阻塞请求
+func Init() {
+	queue = make(chan *model.AuditLog, queueSize)
+	go func(queue chan *model.AuditLog) {
+		for log := range queue {
+			save(log)
+		}
+	}(queue)
+}
+
+// Enabled 是否记录审计日志
+func Enabled() bool {
+	return queue != nil
+}
+
+// Record 记录请求 c 中用户 user 对 target 的操作，err 为空时视为操作成功。
+// user 为空时尝试从请求上下文中获取当前用户
+func Record(c *gin.Context, user *model.User, action, target string, err error) {
+	if !Enabled() {
+		return
+	}
+
+	log := &model.AuditLog{
+		Action: action,
+		Target: target,
+		Result: model.AuditSuccess,
+	}
+
+	if user == nil && c != nil {
+		if userCtx, ok := c.Get("user"); ok {
+			user, _ = userCtx.(*model.User)
+		}
+	}
+	if user != nil {
+		log.UserID = user.ID
+		log.Actor = user.Email
+	}
+
+	if c != nil {
+		log.IP = c.ClientIP()
+		log.UserAgent = c.Request.UserAgent()
+	}
+
+	if err != nil {
+		log.Result = model.AuditFailed
+		log.Detail = err.Error()
+	}
+
+	select {
+	case queue <- log:
+	default:
+		// 队列已满时直接写入，避免丢失记录
+		save(log)
+	}
+}
+
+// RecordResponse 记录请求 c 中当前用户对 target 的操作，操作结果取自服务返回的响应
+func RecordResponse(c *gin.Context, action, target string, res serializer.Response) {
+	var err error
+	if res.Code != 0 {
+		err = errors.New(res.Msg)
+	}
+	Record(c, nil, action, target, err)
+}
+
+// RecordContext 与 Record 相同，请求信息从 ctx 中的 fsctx.GinCtx 获取
+func RecordContext(ctx context.Context, user *model.User, action, target string, err error) {
+	c, _ := ctx.Value(fsctx.GinCtx).(*gin.Context)
+	Record(c, user, action, target, err)
+}
+
+func save(log *model.AuditLog) {
+	if err := log.Create(); err != nil {
+		util.Log().Warning("无法写入审计日志, %s", err)
+	}
+}
@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func testContext() *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/api/v3/user/session", nil)
+	c.Request.Header.Set("User-Agent", "test-agent")
+	c.Request.RemoteAddr = "192.168.1.1:1234"
+	return c
+}
+
+func TestRecord(t *testing.T) {
+	asserts := assert.New(t)
+	user := &model.User{Model: gorm.Model{ID: 1}, Email: "a@example.com"}
+
+	// 未初始化时不记录
+	{
+		queue = nil
+		asserts.False(Enabled())
+		Record(testContext(), user, ActionLogin, "a@example.com", nil)
+	}
+
+	queue = make(chan *model.AuditLog, 1)
+	defer func() { queue = nil }()
+
+	// 成功
+	{
+		Record(testContext(), user, ActionLogin, "a@example.com", nil)
+		log := <-queue
+		asserts.EqualValues(1, log.UserID)
+		asserts.Equal("a@example.com", log.Actor)
+		asserts.Equal("192.168.1.1", log.IP)
+		asserts.Equal("test-agent", log.UserAgent)
+		asserts.Equal(ActionLogin, log.Action)
+		asserts.Equal(model.AuditSuccess, log.Result)
+		asserts.Empty(log.Detail)
+	}
+
+	// 失败，从请求上下文中获取用户
+	{
+		c := testContext()
+		c.Set("user", user)
+		Record(c, nil, ActionShareDelete, "share", errors.New("error"))
+		log := <-queue
+		asserts.EqualValues(1, log.UserID)
+		asserts.Equal(model.AuditFailed, log.Result)
+		asserts.Equal("error", log.Detail)
+	}
+
+	// 无请求上下文
+	{
+		Record(nil, nil, ActionDelete, "/a", nil)
+		log := <-queue
+		asserts.EqualValues(0, log.UserID)
+		asserts.Empty(log.IP)
+	}
+}
+
+func TestRecordResponse(t *testing.T) {
+	asserts := assert.New(t)
+	queue = make(chan *model.AuditLog, 1)
+	defer func() { queue = nil }()
+
+	// 成功
+	{
+		RecordResponse(testContext(), ActionAdminSetting, "siteName", serializer.Response{})
+		log := <-queue
+		asserts.Equal(model.AuditSuccess, log.Result)
+	}
+
+	// 失败
+	{
+		RecordResponse(testContext(), ActionAdminSetting, "siteName", serializer.Err(serializer.CodeDBError, "更新失败", nil))
+		log := <-queue
+		asserts.Equal(model.AuditFailed, log.Result)
+		asserts.Equal("更新失败", log.Detail)
+	}
+}
+
+func TestRecordContext(t *testing.T) {
+	asserts := assert.New(t)
+	queue = make(chan *model.AuditLog, 1)
+	defer func() { queue = nil }()
+
+	ctx := context.WithValue(context.Background(), fsctx.GinCtx, testContext())
+	RecordContext(ctx, &model.User{Model: gorm.Model{ID: 2}}, ActionMove, "/a -> /b", nil)
+	log := <-queue
+	asserts.EqualValues(2, log.UserID)
+	asserts.Equal("192.168.1.1", log.IP)
+	asserts.Equal("/a -> /b", log.Target)
+}
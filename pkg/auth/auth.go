@@ -30,8 +30,8 @@ type Auth interface {
 }
 
 // SignRequest 对PUT\POST等复杂HTTP请求签名，如果请求Header中
-// 包含 X-Policy， 则此请求会被认定为上传请求，只会对URI部分和
-// Policy部分进行签名。其他请求则会对URI和Body部分进行签名。
+// 包含 X-Policy， 则此请求会被认定为上传请求，只会对请求方法、URI部分和
+// Policy部分进行签名。其他请求则会对请求方法、URI和Body部分进行签名。
 func SignRequest(instance Auth, r *http.Request, expires int64) *http.Request {
 	// 处理有效期
 	if expires > 0 {
@@ -64,7 +64,7 @@ func CheckRequest(instance Auth, r *http.Request) error {
 // 返回待签名/验证的字符串
 func getSignContent(r *http.Request) (rawSignString string) {
 	if policy, ok := r.Header["X-Policy"]; ok {
-		rawSignString = serializer.NewRequestSignString(r.URL.Path, policy[0], "")
+		rawSignString = serializer.NewRequestSignString(r.Method, r.URL.Path, policy[0], "")
 	} else {
 		var body = []byte{}
 		if r.Body != nil {
@@ -72,7 +72,7 @@ func getSignContent(r *http.Request) (rawSignString string) {
 			_ = r.Body.Close()
 			r.Body = ioutil.NopCloser(bytes.NewReader(body))
 		}
-		rawSignString = serializer.NewRequestSignString(r.URL.Path, "", string(body))
+		rawSignString = serializer.NewRequestSignString(r.Method, r.URL.Path, "", string(body))
 	}
 	return rawSignString
 }
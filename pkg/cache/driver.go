@@ -39,6 +39,9 @@ type Driver interface {
 
 	// 删除值
 	Delete(keys []string, prefix string) error
+
+	// 仅在键不存在时设置值，返回是否设置成功，ttl为过期时间，单位为秒
+	Add(key string, value interface{}, ttl int) (bool, error)
 }
 
 // Set 设置缓存值
@@ -51,6 +54,11 @@ func Get(key string) (interface{}, bool) {
 	return Store.Get(key)
 }
 
+// Add 仅在键不存在时设置缓存值，可用于多个实例间互斥
+func Add(key string, value interface{}, ttl int) (bool, error) {
+	return Store.Add(key, value, ttl)
+}
+
 // Deletes 删除值
 func Deletes(keys []string, prefix string) error {
 	return Store.Delete(keys, prefix)
@@ -9,6 +9,8 @@ import (
 // MemoStore 内存存储驱动
 type MemoStore struct {
 	Store *sync.Map
+
+	mu sync.Mutex
 }
 
 // item 存储的对象
@@ -109,3 +111,14 @@ func (store *MemoStore) Delete(keys []string, prefix string) error {
 	}
 	return nil
 }
+
+// Add 仅在键不存在时存储值
+func (store *MemoStore) Add(key string, value interface{}, ttl int) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := getValue(store.Store.Load(key)); ok {
+		return false, nil
+	}
+	store.Store.Store(key, newItem(value, ttl))
+	return true, nil
+}
@@ -70,6 +70,35 @@ func TestMemoStore_Get(t *testing.T) {
 
 }
 
+func TestMemoStore_Add(t *testing.T) {
+	asserts := assert.New(t)
+	store := NewMemoStore()
+
+	// 键不存在
+	{
+		ok, err := store.Add("add", "val", 1)
+		asserts.NoError(err)
+		asserts.True(ok)
+	}
+
+	// 键已存在
+	{
+		ok, err := store.Add("add", "other", 1)
+		asserts.NoError(err)
+		asserts.False(ok)
+		val, _ := store.Get("add")
+		asserts.Equal("val", val)
+	}
+
+	// 已过期的键
+	{
+		time.Sleep(time.Duration(2) * time.Second)
+		ok, err := store.Add("add", "other", 1)
+		asserts.NoError(err)
+		asserts.True(ok)
+	}
+}
+
 func TestMemoStore_Gets(t *testing.T) {
 	asserts := assert.New(t)
 	store := NewMemoStore()
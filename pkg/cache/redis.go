@@ -101,6 +101,34 @@ func (store *RedisStore) Set(key string, value interface{}, ttl int) error {
 
 }
 
+// Add 仅在键不存在时存储值
+func (store *RedisStore) Add(key string, value interface{}, ttl int) (bool, error) {
+	rc := store.pool.Get()
+	defer rc.Close()
+
+	serialized, err := serializer(value)
+	if err != nil {
+		return false, err
+	}
+
+	if rc.Err() != nil {
+		return false, rc.Err()
+	}
+
+	args := redis.Args{}.Add(key, serialized)
+	if ttl > 0 {
+		args = args.Add("EX", ttl)
+	}
+	_, err = redis.String(rc.Do("SET", append(args, "NX")...))
+	if err == redis.ErrNil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Get 取值
 func (store *RedisStore) Get(key string) (interface{}, bool) {
 	rc := store.pool.Get()
@@ -96,6 +96,44 @@ func TestRedisStore_Set(t *testing.T) {
 
 }
 
+func TestRedisStore_Add(t *testing.T) {
+	asserts := assert.New(t)
+	conn := redigomock.NewConn()
+	pool := &redis.Pool{
+		Dial:    func() (redis.Conn, error) { return conn, nil },
+		MaxIdle: 10,
+	}
+	store := &RedisStore{pool: pool}
+
+	// 设置成功
+	{
+		cmd := conn.Command("SET", "test", redigomock.NewAnyData(), "EX", 10, "NX").Expect("OK")
+		ok, err := store.Add("test", "test val", 10)
+		asserts.NoError(err)
+		asserts.True(ok)
+		asserts.Equal(1, conn.Stats(cmd))
+	}
+
+	// 键已存在
+	{
+		conn.Clear()
+		cmd := conn.Command("SET", "test", redigomock.NewAnyData(), "NX").Expect(nil)
+		ok, err := store.Add("test", "test val", 0)
+		asserts.NoError(err)
+		asserts.False(ok)
+		asserts.Equal(1, conn.Stats(cmd))
+	}
+
+	// 命令出错
+	{
+		conn.Clear()
+		conn.Command("SET", "test", redigomock.NewAnyData(), "NX").ExpectError(errors.New("error"))
+		ok, err := store.Add("test", "test val", 0)
+		asserts.Error(err)
+		asserts.False(ok)
+	}
+}
+
 func TestRedisStore_Get(t *testing.T) {
 	asserts := assert.New(t)
 	conn := redigomock.NewConn()
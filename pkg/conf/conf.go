@@ -42,6 +42,7 @@ type slave struct {
 	Secret          string `validate:"omitempty,gte=64"`
 	CallbackTimeout int    `validate:"omitempty,gte=1"`
 	SignatureTTL    int    `validate:"omitempty,gte=1"`
+	MaxWorkerNum    int    `validate:"omitempty,gte=1"`
 }
 
 // captcha 验证码配置
@@ -67,6 +68,18 @@ type redis struct {
 	DB       string
 }
 
+// webdav WebDAV 配置
+type webdav struct {
+	// 锁存储方式，部署多个实例时应使用 database，或在共用 Redis 时使用 cache
+	LockStore string `validate:"eq=memory|eq=database|eq=cache"`
+}
+
+// encryption 存储加密配置
+type encryption struct {
+	// Base64 编码的 32 字节主密钥，用于加密每个文件的随机密钥
+	MasterKey string
+}
+
 // 缩略图 配置
 type thumb struct {
 	MaxWidth   uint
@@ -122,15 +135,17 @@ func Init(path string) {
 	}
 
 	sections := map[string]interface{}{
-		"Database":  DatabaseConfig,
-		"System":    SystemConfig,
-		"SSL":       SSLConfig,
-		"Unix":      UnixConfig,
-		"Captcha":   CaptchaConfig,
-		"Redis":     RedisConfig,
-		"Thumbnail": ThumbConfig,
-		"CORS":      CORSConfig,
-		"Slave":     SlaveConfig,
+		"Database":   DatabaseConfig,
+		"System":     SystemConfig,
+		"SSL":        SSLConfig,
+		"Unix":       UnixConfig,
+		"Captcha":    CaptchaConfig,
+		"Redis":      RedisConfig,
+		"Thumbnail":  ThumbConfig,
+		"CORS":       CORSConfig,
+		"Slave":      SlaveConfig,
+		"WebDAV":     WebDAVConfig,
+		"Encryption": EncryptionConfig,
 	}
 	for sectionName, sectionStruct := range sections {
 		err = mapSection(sectionName, sectionStruct)
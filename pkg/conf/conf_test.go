@@ -92,3 +92,31 @@ TablePrefix = v3_`
 	asserts.NoError(err)
 
 }
+
+func TestMapSection_WebDAV(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不支持的锁存储方式
+	testCase := `
+[WebDAV]
+LockStore = redis`
+	err := ioutil.WriteFile("testConf.ini", []byte(testCase), 0644)
+	defer func() { err = os.Remove("testConf.ini") }()
+	if err != nil {
+		panic(err)
+	}
+	asserts.Panics(func() {
+		Init("testConf.ini")
+	})
+
+	// 使用数据库存储锁
+	cfg.Section("WebDAV").Key("LockStore").SetValue("database")
+	asserts.NoError(mapSection("WebDAV", WebDAVConfig))
+	asserts.Equal("database", WebDAVConfig.LockStore)
+
+	// 使用缓存存储锁
+	cfg.Section("WebDAV").Key("LockStore").SetValue("cache")
+	asserts.NoError(mapSection("WebDAV", WebDAVConfig))
+	asserts.Equal("cache", WebDAVConfig.LockStore)
+	WebDAVConfig.LockStore = "memory"
+}
@@ -55,10 +55,21 @@ var ThumbConfig = &thumb{
 	FileSuffix: "._thumb",
 }
 
+// WebDAVConfig WebDAV配置
+var WebDAVConfig = &webdav{
+	LockStore: "memory",
+}
+
+// EncryptionConfig 存储加密配置
+var EncryptionConfig = &encryption{
+	MasterKey: "",
+}
+
 // SlaveConfig 从机配置
 var SlaveConfig = &slave{
 	CallbackTimeout: 20,
 	SignatureTTL:    60,
+	MaxWorkerNum:    10,
 }
 
 var SSLConfig = &ssl{
@@ -0,0 +1,23 @@
+package crontab
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"time"
+)
+
+func cleanAuditLog() {
+	// 读取审计日志保留时长，为 0 时不自动清理
+	retention := model.GetIntSetting("audit_log_retention", 15552000)
+	if retention <= 0 {
+		return
+	}
+
+	deleted, err := model.DeleteAuditLogsBefore(time.Now().Add(-time.Duration(retention) * time.Second))
+	if err != nil {
+		util.Log().Warning("[定时任务] 无法清理过期的审计日志, %s", err)
+		return
+	}
+
+	util.Log().Info("定时任务 [cron_clean_audit_log] 执行完毕，清理了 %d 条审计日志", deleted)
+}
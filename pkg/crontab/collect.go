@@ -3,6 +3,7 @@ package crontab
 import (
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"os"
 	"path/filepath"
@@ -14,6 +15,9 @@ func garbageCollect() {
 	// 清理打包下载产生的临时文件
 	collectArchiveFile()
 
+	// 清理过期的分片上传会话
+	filesystem.CollectChunkSessions()
+
 	// 清理过期的内置内存缓存
 	if store, ok := cache.Store.(*cache.MemoStore); ok {
 		collectCache(store)
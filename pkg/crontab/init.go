@@ -21,13 +21,25 @@ func Reload() {
 func Init() {
 	util.Log().Info("初始化定时任务...")
 	// 读取cron日程设置
-	options := model.GetSettingByNames("cron_garbage_collect")
+	options := model.GetSettingByNames("cron_garbage_collect", "cron_purge_trash", "cron_flush_search_index", "cron_clean_audit_log", "cron_clean_share_access", "cron_sync_ldap", "cron_storage_snapshot")
 	Cron := cron.New()
 	for k, v := range options {
 		var handler func()
 		switch k {
 		case "cron_garbage_collect":
 			handler = garbageCollect
+		case "cron_purge_trash":
+			handler = purgeTrash
+		case "cron_flush_search_index":
+			handler = flushSearchIndex
+		case "cron_clean_audit_log":
+			handler = cleanAuditLog
+		case "cron_clean_share_access":
+			handler = cleanShareAccess
+		case "cron_sync_ldap":
+			handler = syncLDAP
+		case "cron_storage_snapshot":
+			handler = snapshotStorage
 		default:
 			util.Log().Warning("未知定时任务类型 [%s]，跳过", k)
 			continue
@@ -0,0 +1,20 @@
+package crontab
+
+import (
+	"github.com/HFO4/cloudreve/pkg/ldap"
+	"github.com/HFO4/cloudreve/pkg/util"
+)
+
+func syncLDAP() {
+	if !ldap.Enabled() {
+		return
+	}
+
+	disabled, err := ldap.Sync()
+	if err != nil {
+		util.Log().Warning("[定时任务] 无法同步 LDAP 用户, %s", err)
+		return
+	}
+
+	util.Log().Info("定时任务 [cron_sync_ldap] 执行完毕，停用了 %d 个已从目录中移除的用户", disabled)
+}
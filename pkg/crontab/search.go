@@ -0,0 +1,12 @@
+package crontab
+
+import (
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/util"
+)
+
+func flushSearchIndex() {
+	// 将有变动的搜索索引写入磁盘
+	search.Flush()
+	util.Log().Debug("定时任务 [cron_flush_search_index] 执行完毕")
+}
@@ -0,0 +1,23 @@
+package crontab
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"time"
+)
+
+func cleanShareAccess() {
+	// 读取分享访问记录保留时长，为 0 时不自动清理
+	retention := model.GetIntSetting("share_access_retention", 15552000)
+	if retention <= 0 {
+		return
+	}
+
+	deleted, err := model.DeleteShareAccessesBefore(time.Now().Add(-time.Duration(retention) * time.Second))
+	if err != nil {
+		util.Log().Warning("[定时任务] 无法清理过期的分享访问记录, %s", err)
+		return
+	}
+
+	util.Log().Info("定时任务 [cron_clean_share_access] 执行完毕，清理了 %d 条分享访问记录", deleted)
+}
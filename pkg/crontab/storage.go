@@ -0,0 +1,44 @@
+package crontab
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"time"
+)
+
+func snapshotStorage() {
+	batch := time.Now().Unix()
+	stats := []struct {
+		scope string
+		usage func() ([]model.StorageUsage, error)
+	}{
+		{model.StorageScopeSite, model.StorageUsageBySite},
+		{model.StorageScopeUser, model.StorageUsageByUser},
+		{model.StorageScopeGroup, model.StorageUsageByGroup},
+		{model.StorageScopePolicy, model.StorageUsageByPolicy},
+		{model.StorageScopeType, func() ([]model.StorageUsage, error) {
+			return model.StorageUsageByType(0, search.TypeExtensions)
+		}},
+	}
+
+	for _, stat := range stats {
+		usages, err := stat.usage()
+		if err != nil {
+			util.Log().Warning("[定时任务] 无法统计容量使用 [%s], %s", stat.scope, err)
+			continue
+		}
+		if err := model.CreateStorageSnapshots(batch, stat.scope, usages); err != nil {
+			util.Log().Warning("[定时任务] 无法记录容量快照 [%s], %s", stat.scope, err)
+		}
+	}
+
+	// 清理过期的快照，保留时长为 0 时不清理
+	if retention := model.GetIntSetting("storage_snapshot_retention", 31536000); retention > 0 {
+		if _, err := model.DeleteStorageSnapshotsBefore(time.Now().Add(-time.Duration(retention) * time.Second)); err != nil {
+			util.Log().Warning("[定时任务] 无法清理过期的容量快照, %s", err)
+		}
+	}
+
+	util.Log().Info("定时任务 [cron_storage_snapshot] 执行完毕")
+}
@@ -0,0 +1,48 @@
+package crontab
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"time"
+)
+
+func purgeTrash() {
+	// 读取回收站保留时长，为 0 时不自动清理
+	retention := model.GetIntSetting("trash_retention", 2592000)
+	if retention <= 0 {
+		return
+	}
+
+	trashes, err := model.GetExpiredTrash(time.Now().Add(-time.Duration(retention) * time.Second))
+	if err != nil {
+		util.Log().Warning("[定时任务] 无法列取过期的回收站记录, %s", err)
+		return
+	}
+
+	// 按用户分组
+	userTrash := make(map[uint][]uint)
+	for _, trash := range trashes {
+		userTrash[trash.UserID] = append(userTrash[trash.UserID], trash.ID)
+	}
+
+	for uid, ids := range userTrash {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			continue
+		}
+
+		if err := fs.PurgeTrash(context.Background(), ids); err != nil {
+			util.Log().Warning("[定时任务] 无法清理用户[%d]的回收站, %s", uid, err)
+		}
+		fs.Recycle()
+	}
+
+	util.Log().Info("定时任务 [cron_purge_trash] 执行完毕")
+}
@@ -33,3 +33,34 @@ func NewResetEmail(userName, resetURL string) (string, string) {
 	return fmt.Sprintf("【%s】密码重置", options["siteName"]),
 		util.Replace(replace, options["mail_reset_pwd_template"])
 }
+
+// NewFileRequestEmail 新建文件收集收到新文件的通知邮件
+func NewFileRequestEmail(userName, shareName, fileName string, fileSize uint64, filePath string) (string, string) {
+	options := model.GetSettingByNames("siteName", "siteURL", "siteTitle", "mail_file_request_template")
+	replace := map[string]string{
+		"{siteTitle}":    options["siteName"],
+		"{userName}":     userName,
+		"{shareName}":    shareName,
+		"{fileName}":     fileName,
+		"{fileSize}":     formatSize(fileSize),
+		"{filePath}":     filePath,
+		"{siteUrl}":      options["siteURL"],
+		"{siteSecTitle}": options["siteTitle"],
+	}
+	return fmt.Sprintf("【%s】文件收集“%s”收到新文件", options["siteName"], shareName),
+		util.Replace(replace, options["mail_file_request_template"])
+}
+
+// formatSize 将字节数格式化为便于阅读的大小
+func formatSize(size uint64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for ; value >= 1024 && i < len(units)-1; i++ {
+		value /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	return fmt.Sprintf("%.2f %s", value, units[i])
+}
@@ -0,0 +1,346 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/HFO4/cloudreve/pkg/conf"
+	"io"
+)
+
+/*
+	加密文件格式：
+
+	文件头：Magic(7) | 主密钥标识(1) | 文件密钥随机数(12) | 使用主密钥加密的文件密钥(32+16)
+	是否加密记录在文件的数据库记录中，文件头仅用于校验，不用于判断文件是否加密；
+	主密钥标识为主密钥 SHA-256 摘要的首字节，用于区分更换主密钥前加密的文件
+	文件体：按 ChunkSize 切分的明文块，每块使用文件密钥以 AES-256-GCM 单独加密，
+	随机数为块序号，附加数据标记是否为最后一块，以检测块的重排与截断
+*/
+
+const (
+	// ChunkSize 每个加密块的明文大小
+	ChunkSize = 64 * 1024
+	// keySize 主密钥及文件密钥长度
+	keySize = 32
+	// nonceSize GCM 随机数长度
+	nonceSize = 12
+	// tagSize GCM 认证标签长度
+	tagSize = 16
+	// encryptedChunkSize 每个加密块的密文大小
+	encryptedChunkSize = ChunkSize + tagSize
+)
+
+// Magic 加密文件头标识
+var Magic = []byte("CRENC\x00\x02")
+
+// HeaderSize 加密文件头长度
+var HeaderSize = int64(len(Magic) + 1 + nonceSize + keySize + tagSize)
+
+var (
+	// ErrMasterKeyNotSet 未配置主密钥
+	ErrMasterKeyNotSet = errors.New("未配置加密主密钥")
+	// ErrInvalidMasterKey 主密钥格式有误
+	ErrInvalidMasterKey = errors.New("加密主密钥须为 Base64 编码的 32 字节密钥")
+	// ErrNotEncrypted 文件不是加密文件
+	ErrNotEncrypted = errors.New("文件未加密")
+	// ErrCorrupted 文件已损坏或被篡改
+	ErrCorrupted = errors.New("加密文件已损坏或被篡改")
+	// ErrKeyMismatch 文件不是使用当前主密钥加密的
+	ErrKeyMismatch = errors.New("文件不是使用当前主密钥加密的")
+)
+
+// MasterKey 从配置文件中读取主密钥
+func MasterKey() ([]byte, error) {
+	if conf.EncryptionConfig.MasterKey == "" {
+		return nil, ErrMasterKeyNotSet
+	}
+
+	key, err := base64.StdEncoding.DecodeString(conf.EncryptionConfig.MasterKey)
+	if err != nil || len(key) != keySize {
+		return nil, ErrInvalidMasterKey
+	}
+
+	return key, nil
+}
+
+// keyID 返回主密钥的标识
+func keyID(masterKey []byte) byte {
+	sum := sha256.Sum256(masterKey)
+	return sum[0]
+}
+
+// newAEAD 使用密钥创建 AES-GCM 实例
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 返回第 index 块使用的随机数
+func chunkNonce(index uint64) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce[nonceSize-8:], index)
+	return nonce
+}
+
+// chunkAdditionalData 返回加密块的附加数据
+func chunkAdditionalData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Writer 加密写入流，写入完成后须调用 Close 写入最后一块
+type Writer struct {
+	dst    io.Writer
+	aead   cipher.AEAD
+	buf    []byte
+	out    []byte
+	index  uint64
+	closed bool
+}
+
+// NewWriter 生成随机文件密钥，写入文件头并返回加密写入流
+func NewWriter(dst io.Writer, masterKey []byte) (*Writer, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 生成文件密钥，并使用主密钥加密
+	fileKey := make([]byte, keySize)
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, fileKey); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, HeaderSize)
+	header = append(header, Magic...)
+	header = append(header, keyID(masterKey))
+	prefix := header[:len(header):len(header)]
+	header = append(header, nonce...)
+	header = master.Seal(header, nonce, fileKey, prefix)
+	if _, err := dst.Write(header); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		dst:  dst,
+		aead: aead,
+		buf:  make([]byte, 0, ChunkSize),
+		out:  make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+// Write 实现 io.Writer，缓冲区写满且有后续数据时才加密写出，以便 Close 时标记最后一块
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("加密流已关闭")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == ChunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(w.buf[len(w.buf):ChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// flush 加密并写出缓冲区中的数据
+func (w *Writer) flush(final bool) error {
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.index), w.buf, chunkAdditionalData(final))
+	if _, err := w.dst.Write(w.out); err != nil {
+		return err
+	}
+
+	w.buf = w.buf[:0]
+	w.index++
+	return nil
+}
+
+// Close 写出最后一块，不会关闭底层写入流
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// Reader 可随机读取的解密流
+type Reader struct {
+	src  io.ReadSeeker
+	aead cipher.AEAD
+
+	bodySize int64 // 文件体密文大小
+	chunks   int64 // 加密块数量
+	size     int64 // 明文大小
+	offset   int64 // 当前读取位置
+
+	chunk      []byte // 当前已解密的块
+	chunkIndex int64  // 当前已解密块的序号
+	raw        []byte
+}
+
+// NewReader 读取文件头，返回解密流
+func NewReader(src io.ReadSeeker, masterKey []byte) (*Reader, error) {
+	master, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取密文大小
+	total, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, ErrNotEncrypted
+	}
+	if !bytes.Equal(header[:len(Magic)], Magic) {
+		return nil, ErrNotEncrypted
+	}
+	if header[len(Magic)] != keyID(masterKey) {
+		return nil, ErrKeyMismatch
+	}
+
+	// 解密文件密钥，Magic 与主密钥标识作为附加数据
+	prefixSize := len(Magic) + 1
+	nonce := header[prefixSize : prefixSize+nonceSize]
+	fileKey, err := master.Open(nil, nonce, header[prefixSize+nonceSize:], header[:prefixSize])
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	aead, err := newAEAD(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计算明文大小，最后一块至少包含认证标签
+	bodySize := total - HeaderSize
+	chunks := (bodySize + encryptedChunkSize - 1) / encryptedChunkSize
+	if chunks == 0 || bodySize-(chunks-1)*encryptedChunkSize < tagSize {
+		return nil, ErrCorrupted
+	}
+
+	return &Reader{
+		src:        src,
+		aead:       aead,
+		bodySize:   bodySize,
+		chunks:     chunks,
+		size:       bodySize - chunks*tagSize,
+		chunkIndex: -1,
+		raw:        make([]byte, encryptedChunkSize),
+	}, nil
+}
+
+// Size 返回明文大小
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// loadChunk 读取并解密第 index 块
+func (r *Reader) loadChunk(index int64) error {
+	start := index * encryptedChunkSize
+	length := r.bodySize - start
+	if length > encryptedChunkSize {
+		length = encryptedChunkSize
+	}
+
+	if _, err := r.src.Seek(HeaderSize+start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r.src, r.raw[:length]); err != nil {
+		return err
+	}
+
+	chunk, err := r.aead.Open(r.chunk[:0], chunkNonce(uint64(index)), r.raw[:length],
+		chunkAdditionalData(index == r.chunks-1))
+	if err != nil {
+		r.chunkIndex = -1
+		return ErrCorrupted
+	}
+
+	r.chunk = chunk
+	r.chunkIndex = index
+	return nil
+}
+
+// Read 实现 io.Reader
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := r.offset / ChunkSize
+	if index != r.chunkIndex {
+		if err := r.loadChunk(index); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.chunk[r.offset-index*ChunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// Seek 实现 io.Seeker，偏移量均为明文位置
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = r.offset + offset
+	case io.SeekEnd:
+		target = r.size + offset
+	default:
+		return 0, errors.New("无效的 whence")
+	}
+
+	if target < 0 {
+		return 0, errors.New("无效的偏移量")
+	}
+
+	r.offset = target
+	return target, nil
+}
+
+// Close 关闭底层文件流（如果可以关闭）
+func (r *Reader) Close() error {
+	if closer, ok := r.src.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{1}, keySize)
+
+// encryptBytes 加密 data 并返回密文
+func encryptBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMasterKey(t *testing.T) {
+	asserts := assert.New(t)
+	defer func() { conf.EncryptionConfig.MasterKey = "" }()
+
+	// 未配置
+	{
+		conf.EncryptionConfig.MasterKey = ""
+		key, err := MasterKey()
+		asserts.Equal(ErrMasterKeyNotSet, err)
+		asserts.Nil(key)
+	}
+
+	// 格式有误
+	{
+		conf.EncryptionConfig.MasterKey = "not base64"
+		_, err := MasterKey()
+		asserts.Equal(ErrInvalidMasterKey, err)
+		conf.EncryptionConfig.MasterKey = base64.StdEncoding.EncodeToString([]byte("short"))
+		_, err = MasterKey()
+		asserts.Equal(ErrInvalidMasterKey, err)
+	}
+
+	// 成功
+	{
+		conf.EncryptionConfig.MasterKey = base64.StdEncoding.EncodeToString(testKey)
+		key, err := MasterKey()
+		asserts.NoError(err)
+		asserts.Equal(testKey, key)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	asserts := assert.New(t)
+
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 100} {
+		data := make([]byte, size)
+		_, _ = rand.Read(data)
+		encrypted := encryptBytes(t, data)
+
+		// 密文不包含明文，过短的明文可能恰好出现在密文中
+		if size > ChunkSize/2 {
+			asserts.False(bytes.Contains(encrypted, data))
+		}
+
+		r, err := NewReader(bytes.NewReader(encrypted), testKey)
+		asserts.NoError(err)
+		asserts.EqualValues(size, r.Size())
+		content, err := ioutil.ReadAll(r)
+		asserts.NoError(err)
+		asserts.Equal(data, content)
+	}
+}
+
+func TestReader_Seek(t *testing.T) {
+	asserts := assert.New(t)
+	data := make([]byte, 2*ChunkSize+500)
+	_, _ = rand.Read(data)
+	r, err := NewReader(bytes.NewReader(encryptBytes(t, data)), testKey)
+	asserts.NoError(err)
+
+	// 从末尾获取大小
+	{
+		size, err := r.Seek(0, io.SeekEnd)
+		asserts.NoError(err)
+		asserts.EqualValues(len(data), size)
+		n, err := r.Read(make([]byte, 10))
+		asserts.Equal(0, n)
+		asserts.Equal(io.EOF, err)
+	}
+
+	// 跨块读取
+	{
+		_, err := r.Seek(ChunkSize-10, io.SeekStart)
+		asserts.NoError(err)
+		buf := make([]byte, 20)
+		_, err = io.ReadFull(r, buf)
+		asserts.NoError(err)
+		asserts.Equal(data[ChunkSize-10:ChunkSize+10], buf)
+	}
+
+	// 相对当前位置
+	{
+		_, err := r.Seek(-20, io.SeekCurrent)
+		asserts.NoError(err)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(r, buf)
+		asserts.NoError(err)
+		asserts.Equal(data[ChunkSize-10:ChunkSize-5], buf)
+	}
+
+	// 无效的偏移量
+	{
+		_, err := r.Seek(-1, io.SeekStart)
+		asserts.Error(err)
+		_, err = r.Seek(0, 10)
+		asserts.Error(err)
+	}
+}
+
+func TestReader_Tampered(t *testing.T) {
+	asserts := assert.New(t)
+	data := make([]byte, 2*ChunkSize)
+	encrypted := encryptBytes(t, data)
+
+	// 密文被修改
+	{
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(tampered)-1] ^= 1
+		r, err := NewReader(bytes.NewReader(tampered), testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(ErrCorrupted, err)
+	}
+
+	// 在块边界处截断
+	{
+		truncated := encrypted[:HeaderSize+encryptedChunkSize]
+		r, err := NewReader(bytes.NewReader(truncated), testKey)
+		asserts.NoError(err)
+		_, err = ioutil.ReadAll(r)
+		asserts.Equal(ErrCorrupted, err)
+	}
+
+	// 截断后最后一块不完整
+	{
+		r, err := NewReader(bytes.NewReader(encrypted[:HeaderSize+5]), testKey)
+		asserts.Equal(ErrCorrupted, err)
+		asserts.Nil(r)
+	}
+
+	// 文件头被修改
+	{
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(Magic)+1] ^= 1
+		r, err := NewReader(bytes.NewReader(tampered), testKey)
+		asserts.Equal(ErrCorrupted, err)
+		asserts.Nil(r)
+	}
+}
+
+func TestReader_Header(t *testing.T) {
+	asserts := assert.New(t)
+	encrypted := encryptBytes(t, []byte("123"))
+
+	// 文件头包含主密钥标识
+	{
+		asserts.Equal(Magic, encrypted[:len(Magic)])
+		asserts.Equal(keyID(testKey), encrypted[len(Magic)])
+	}
+
+	// 主密钥不符
+	{
+		otherKey := bytes.Repeat([]byte{2}, keySize)
+		asserts.NotEqual(keyID(testKey), keyID(otherKey))
+		r, err := NewReader(bytes.NewReader(encrypted), otherKey)
+		asserts.Equal(ErrKeyMismatch, err)
+		asserts.Nil(r)
+	}
+
+	// 主密钥标识被修改
+	{
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(Magic)] ^= 1
+		r, err := NewReader(bytes.NewReader(tampered), testKey)
+		asserts.Equal(ErrKeyMismatch, err)
+		asserts.Nil(r)
+	}
+
+	// 非加密文件无法创建解密流
+	{
+		r, err := NewReader(strings.NewReader("plain text file, long enough to contain a header of 68 bytes......"), testKey)
+		asserts.Equal(ErrNotEncrypted, err)
+		asserts.Nil(r)
+	}
+}
+
+func TestWriter_Close(t *testing.T) {
+	asserts := assert.New(t)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testKey)
+	asserts.NoError(err)
+	asserts.NoError(w.Close())
+	asserts.NoError(w.Close())
+	_, err = w.Write([]byte("1"))
+	asserts.Error(err)
+	asserts.EqualValues(HeaderSize+tagSize, buf.Len())
+
+	// 主密钥长度有误
+	_, err = NewWriter(&buf, []byte("short"))
+	asserts.Error(err)
+}
@@ -118,6 +118,12 @@ func (fs *FileSystem) Compress(ctx context.Context, folderIDs, fileIDs []uint, i
 		}
 	}
 
+	// 最后一个对象压缩过程中被取消
+	if reqContext.Err() != nil {
+		fs.cancelCompress(ctx, zipWriter, zipFile, zipFilePath)
+		return "", ErrClientCanceled
+	}
+
 	return zipFilePath, nil
 }
 
@@ -130,6 +136,11 @@ func (fs *FileSystem) cancelCompress(ctx context.Context, zipWriter *zip.Writer,
 }
 
 func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *model.Folder, zipWriter *zip.Writer, isArchive bool) {
+	// 已取消压缩时不再处理后续对象
+	if ctx.Err() != nil {
+		return
+	}
+
 	// 如果对象是文件
 	if file != nil {
 		// 切换上传策略
@@ -172,7 +183,7 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 			return
 		}
 
-		_, err = io.Copy(writer, fileToZip)
+		_, err = io.Copy(writer, WithProgress(ctx, fileToZip, file.Size))
 	} else if folder != nil {
 		// 对象是目录
 		// 获取子文件
@@ -193,57 +204,176 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 	}
 }
 
-// Decompress 解压缩给定压缩文件到dst目录
-func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
-	err := fs.ResetFileIfNotExist(ctx, src)
-	if err != nil {
-		return err
+// GetCompressFiles 递归查找给定目录和文件下所有待压缩的文件，
+// 返回文件的 Position 为其在压缩包内的父目录
+func (fs *FileSystem) GetCompressFiles(ctx context.Context, folderIDs, fileIDs []uint) ([]model.File, error) {
+	folders, err := model.GetFoldersByIDs(folderIDs, fs.User.ID)
+	if err != nil && len(folderIDs) != 0 {
+		return nil, ErrDBListObjects
 	}
 
-	tempZipFilePath := ""
-	defer func() {
-		// 结束时删除临时压缩文件
-		if tempZipFilePath != "" {
-			if err := os.Remove(tempZipFilePath); err != nil {
-				util.Log().Warning("无法删除临时压缩文件 %s , %s", tempZipFilePath, err)
-			}
+	files, err := model.GetFilesByIDs(fileIDs, fs.User.ID)
+	if err != nil && len(fileIDs) != 0 {
+		return nil, ErrDBListObjects
+	}
+
+	// 将顶级待处理对象的路径设为根路径
+	for i := 0; i < len(files); i++ {
+		files[i].Position = ""
+	}
+	for i := 0; i < len(folders); i++ {
+		folders[i].Position = ""
+	}
+
+	// 逐层遍历子目录
+	for len(folders) > 0 {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		folder := folders[0]
+		folders = folders[1:]
+
+		subFiles, err := folder.GetChildFiles()
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
 		}
-	}()
+		files = append(files, subFiles...)
+
+		subFolders, err := folder.GetChildFolder()
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+		folders = append(folders, subFolders...)
+	}
+
+	return files, nil
+}
+
+// ZipFileName 返回压缩包内文件的名称，非UTF-8编码的名称按GB18030解码
+func ZipFileName(f *zip.File) string {
+	if !f.NonUTF8 {
+		return f.Name
+	}
+
+	decoder := transform.NewReader(bytes.NewReader([]byte(f.Name)), simplifiedchinese.GB18030.NewDecoder())
+	content, _ := ioutil.ReadAll(decoder)
+	return string(content)
+}
+
+// ArchiveSavePath 返回压缩包内路径为 name 的对象解压至 dst 目录时的保存路径，
+// 保存路径不在 dst 目录下时返回错误
+func ArchiveSavePath(dst, name string) (string, error) {
+	savePath := path.Join(dst, name)
+	if !strings.HasPrefix(savePath, util.FillSlash(path.Clean(dst))) {
+		return "", fmt.Errorf("%s: illegal file path", name)
+	}
+	return savePath, nil
+}
+
+// tempArchive 下载至临时文件后打开的压缩包，关闭时删除临时文件
+type tempArchive struct {
+	Archive
+	file *os.File
+}
+
+// Close 关闭压缩包并删除临时文件
+func (archive *tempArchive) Close() error {
+	err := archive.Archive.Close()
+	archive.file.Close()
+	if removeErr := os.Remove(archive.file.Name()); removeErr != nil {
+		util.Log().Warning("无法删除临时压缩文件 %s , %s", archive.file.Name(), removeErr)
+	}
+	return err
+}
+
+// openArchive 下载压缩文件至临时目录并打开
+func (fs *FileSystem) openArchive(ctx context.Context, src string) (Archive, error) {
+	err := fs.ResetFileIfNotExist(ctx, src)
+	if err != nil {
+		return nil, err
+	}
 
 	// 下载压缩文件到临时目录
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, fs.FileTarget[0])
 	fileStream, err := fs.Handler.Get(ctx, fs.FileTarget[0].SourceName)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer fileStream.Close()
 
-	tempZipFilePath = filepath.Join(
+	tempFilePath := filepath.Join(
 		util.RelativePath(model.GetSettingByName("temp_path")),
 		"decompress",
-		fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
+		fmt.Sprintf("archive_%d", time.Now().UnixNano()),
 	)
 
-	zipFile, err := util.CreatNestedFile(tempZipFilePath)
+	file, err := util.CreatNestedFile(tempFilePath)
 	if err != nil {
-		util.Log().Warning("无法创建临时压缩文件 %s , %s", tempZipFilePath, err)
-		tempZipFilePath = ""
-		return err
+		util.Log().Warning("无法创建临时压缩文件 %s , %s", tempFilePath, err)
+		return nil, err
 	}
-	defer zipFile.Close()
 
-	_, err = io.Copy(zipFile, fileStream)
+	size, err := io.Copy(file, WithProgress(ctx, fileStream, fs.FileTarget[0].Size))
 	if err != nil {
-		util.Log().Warning("无法写入临时压缩文件 %s , %s", tempZipFilePath, err)
-		return err
+		util.Log().Warning("无法写入临时压缩文件 %s , %s", tempFilePath, err)
+		file.Close()
+		os.Remove(tempFilePath)
+		return nil, err
 	}
 
-	zipFile.Close()
+	archive, err := OpenArchive(file, size)
+	if err != nil {
+		file.Close()
+		os.Remove(tempFilePath)
+		return nil, err
+	}
+
+	return &tempArchive{Archive: archive, file: file}, nil
+}
 
-	// 解压缩文件
-	r, err := zip.OpenReader(tempZipFilePath)
+// ListArchive 列出给定压缩文件内的目录和文件，不进行解压缩。至多列出 limit 个对象，
+// 达到上限后不再继续读取，此时返回的 truncated 为 true
+func (fs *FileSystem) ListArchive(ctx context.Context, src string, limit int) (entries []ArchiveEntry, truncated bool, err error) {
+	archive, err := fs.openArchive(ctx, src)
+	if err != nil {
+		return nil, false, err
+	}
+	defer archive.Close()
+
+	entries = make([]ArchiveEntry, 0)
+	for {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+
+		// 顺序读取的压缩包须解压之前的所有内容才能得到下一个对象，达到上限后即停止
+		if len(entries) >= limit {
+			return entries, true, nil
+		}
+
+		entry, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		entry.Name = path.Clean(util.FormSlash(entry.Name))
+		entries = append(entries, *entry)
+	}
+
+	return entries, false, nil
+}
+
+// Decompress 解压缩给定压缩文件到dst目录
+func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
+	archive, err := fs.openArchive(ctx, src)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
+	defer archive.Close()
 
 	// 重设存储策略
 	fs.Policy = &fs.User.Policy
@@ -259,40 +389,59 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
 		worker <- i
 	}
 
-	for _, f := range r.File {
-		fileName := f.Name
-		// 处理非UTF-8编码
-		if f.NonUTF8 {
-			i := bytes.NewReader([]byte(fileName))
-			decoder := transform.NewReader(i, simplifiedchinese.GB18030.NewDecoder())
-			content, _ := ioutil.ReadAll(decoder)
-			fileName = string(content)
+	upload := func(fileStream io.ReadCloser, rawPath, savePath string, size uint64) {
+		err := fs.UploadFromStream(ctx, fileStream, savePath, size)
+		fileStream.Close()
+		if err != nil {
+			util.Log().Debug("无法上传压缩包内的文件%s , %s , 跳过", rawPath, err)
+		}
+	}
+
+	for {
+		// 已取消解压缩时不再处理后续文件
+		if ctx.Err() != nil {
+			break
 		}
 
-		rawPath := util.FormSlash(fileName)
-		savePath := path.Join(dst, rawPath)
-		// 路径是否合法
-		if !strings.HasPrefix(savePath, util.FillSlash(path.Clean(dst))) {
-			return fmt.Errorf("%s: illegal file path", f.Name)
+		entry, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+
+		rawPath := util.FormSlash(entry.Name)
+		savePath, err := ArchiveSavePath(dst, rawPath)
+		if err != nil {
+			wg.Wait()
+			return err
 		}
 
 		// 如果是目录
-		if f.FileInfo().IsDir() {
+		if entry.IsDir {
 			fs.CreateDirectory(ctx, savePath)
 			continue
 		}
 
 		// 上传文件
-		fileStream, err := f.Open()
+		fileStream, err := entry.Open()
 		if err != nil {
 			util.Log().Warning("无法打开压缩包内文件%s , %s , 跳过", rawPath, err)
 			continue
 		}
 
+		// 只能按顺序读取的压缩包，需读完当前文件才能读取下一个
+		if !archive.Concurrent() {
+			upload(fileStream, rawPath, savePath, entry.Size)
+			continue
+		}
+
 		select {
 		case <-worker:
 			wg.Add(1)
-			go func(fileStream io.ReadCloser, size int64) {
+			go func(fileStream io.ReadCloser, rawPath, savePath string, size uint64) {
 				defer func() {
 					worker <- 1
 					wg.Done()
@@ -302,16 +451,12 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst string) error {
 					}
 				}()
 
-				err = fs.UploadFromStream(ctx, fileStream, savePath, uint64(size))
-				fileStream.Close()
-				if err != nil {
-					util.Log().Debug("无法上传压缩包内的文件%s , %s , 跳过", rawPath, err)
-				}
-			}(fileStream, f.FileInfo().Size())
+				upload(fileStream, rawPath, savePath, size)
+			}(fileStream, rawPath, savePath, entry.Size)
 		}
 
 	}
 	wg.Wait()
-	return nil
+	return ctx.Err()
 
 }
@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"archive/zip"
 	"context"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
@@ -205,7 +206,7 @@ func TestFileSystem_Decompress(t *testing.T) {
 		asserts.EqualError(err, "read error")
 	}
 
-	// 无效zip文件
+	// 无法识别的压缩文件
 	{
 		cache.Set("setting_temp_path", "tests", 0)
 		fs.FileTarget = []model.File{{SourceName: "1.zip", Policy: model.Policy{Type: "mock"}}}
@@ -215,6 +216,19 @@ func TestFileSystem_Decompress(t *testing.T) {
 		fs.Handler = testHandler
 		err := fs.Decompress(ctx, "/1.zip", "/")
 		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrUnknownArchive, err)
+	}
+
+	// 无效zip文件
+	{
+		cache.Set("setting_temp_path", "tests", 0)
+		fs.FileTarget = []model.File{{SourceName: "1.zip", Policy: model.Policy{Type: "mock"}}}
+		fs.FileTarget[0].Policy.ID = 1
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.zip").Return(MockRSC{rs: strings.NewReader("PK\x03\x04read")}, nil)
+		fs.Handler = testHandler
+		err := fs.Decompress(ctx, "/1.zip", "/")
+		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.EqualError(err, "zip: not a valid zip file")
 	}
@@ -253,3 +267,39 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler.AssertExpectations(t)
 	}
 }
+
+func TestArchiveSavePath(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 正常路径
+	{
+		savePath, err := ArchiveSavePath("/dst", "dir/1.txt")
+		asserts.NoError(err)
+		asserts.Equal("/dst/dir/1.txt", savePath)
+	}
+
+	// 路径跳出目标目录
+	{
+		_, err := ArchiveSavePath("/dst", "../1.txt")
+		asserts.Error(err)
+		_, err = ArchiveSavePath("/dst", "../dst2/1.txt")
+		asserts.Error(err)
+	}
+}
+
+func TestZipFileName(t *testing.T) {
+	asserts := assert.New(t)
+
+	// UTF-8 文件名
+	{
+		asserts.Equal("中文.txt", ZipFileName(&zip.File{FileHeader: zip.FileHeader{Name: "中文.txt"}}))
+	}
+
+	// GB18030 文件名
+	{
+		asserts.Equal("中文.txt", ZipFileName(&zip.File{FileHeader: zip.FileHeader{
+			Name:    "\xd6\xd0\xce\xc4.txt",
+			NonUTF8: true,
+		}}))
+	}
+}
@@ -0,0 +1,279 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode"
+	"github.com/ulikunitz/xz"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// 各格式压缩包的文件头
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	zipEmptyMagic = []byte("PK\x05\x06")
+	sevenZipMagic = []byte("7z\xbc\xaf\x27\x1c")
+	rarMagic      = []byte("Rar!\x1a\x07")
+	gzipMagic     = []byte("\x1f\x8b")
+	bzip2Magic    = []byte("BZh")
+	xzMagic       = []byte("\xfd7zXZ\x00")
+)
+
+// archiveSuffixes 支持解压缩的压缩包扩展名
+var archiveSuffixes = []string{
+	".zip", ".7z", ".rar", ".tar",
+	".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz", ".tar.xz", ".txz",
+}
+
+// ArchiveEntry 压缩包内的目录或文件
+type ArchiveEntry struct {
+	// Name 以 / 分隔的完整路径
+	Name     string    `json:"name"`
+	Size     uint64    `json:"size"`
+	IsDir    bool      `json:"is_dir"`
+	Modified time.Time `json:"modified"`
+
+	open func() (io.ReadCloser, error)
+}
+
+// Open 打开压缩包内文件的内容
+func (entry *ArchiveEntry) Open() (io.ReadCloser, error) {
+	return entry.open()
+}
+
+// Archive 以只读方式打开的压缩包
+type Archive interface {
+	// Next 返回压缩包内的下一个对象，没有更多对象时返回 io.EOF
+	Next() (*ArchiveEntry, error)
+	// Concurrent 读取下一个对象后，之前对象的内容是否仍可读取
+	Concurrent() bool
+	// Close 关闭压缩包
+	Close() error
+}
+
+// IsArchive 根据文件名判断是否为支持解压缩的压缩包
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenArchive 根据文件头识别压缩包格式并打开，压缩包关闭前 r 须保持可读
+func OpenArchive(r io.ReaderAt, size int64) (Archive, error) {
+	header := make([]byte, 512)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	header = header[:n]
+	stream := io.NewSectionReader(r, 0, size)
+
+	switch {
+	case bytes.HasPrefix(header, zipMagic), bytes.HasPrefix(header, zipEmptyMagic):
+		zipReader, err := zip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return &zipArchive{files: zipReader.File}, nil
+	case bytes.HasPrefix(header, sevenZipMagic):
+		sevenZipReader, err := sevenzip.NewReader(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return &sevenZipArchive{files: sevenZipReader.File}, nil
+	case bytes.HasPrefix(header, rarMagic):
+		rarReader, err := rardecode.NewReader(stream, "")
+		if err != nil {
+			return nil, err
+		}
+		return &rarArchive{r: rarReader}, nil
+	case bytes.HasPrefix(header, gzipMagic):
+		gzipReader, err := gzip.NewReader(stream)
+		if err != nil {
+			return nil, err
+		}
+		return newTarArchive(gzipReader, gzipReader)
+	case bytes.HasPrefix(header, bzip2Magic):
+		return newTarArchive(bzip2.NewReader(stream), nil)
+	case bytes.HasPrefix(header, xzMagic):
+		xzReader, err := xz.NewReader(stream)
+		if err != nil {
+			return nil, err
+		}
+		return newTarArchive(xzReader, nil)
+	}
+
+	// 未压缩的 tar 归档没有固定的文件头
+	return newTarArchive(stream, nil)
+}
+
+// zipArchive ZIP 压缩包
+type zipArchive struct {
+	files []*zip.File
+	index int
+}
+
+func (a *zipArchive) Next() (*ArchiveEntry, error) {
+	if a.index >= len(a.files) {
+		return nil, io.EOF
+	}
+	f := a.files[a.index]
+	a.index++
+
+	return &ArchiveEntry{
+		Name:     ZipFileName(f),
+		Size:     f.UncompressedSize64,
+		IsDir:    f.FileInfo().IsDir(),
+		Modified: f.Modified,
+		open:     f.Open,
+	}, nil
+}
+
+func (a *zipArchive) Concurrent() bool {
+	return true
+}
+
+func (a *zipArchive) Close() error {
+	return nil
+}
+
+// sevenZipArchive 7z 压缩包，固实压缩的文件每次打开都需从头解压所在的数据块
+type sevenZipArchive struct {
+	files []*sevenzip.File
+	index int
+}
+
+func (a *sevenZipArchive) Next() (*ArchiveEntry, error) {
+	if a.index >= len(a.files) {
+		return nil, io.EOF
+	}
+	f := a.files[a.index]
+	a.index++
+
+	return &ArchiveEntry{
+		Name:     f.Name,
+		Size:     f.UncompressedSize,
+		IsDir:    f.FileInfo().IsDir(),
+		Modified: f.Modified,
+		open:     f.Open,
+	}, nil
+}
+
+func (a *sevenZipArchive) Concurrent() bool {
+	return true
+}
+
+func (a *sevenZipArchive) Close() error {
+	return nil
+}
+
+// rarArchive RAR 压缩包，只能按顺序读取
+type rarArchive struct {
+	r *rardecode.Reader
+}
+
+func (a *rarArchive) Next() (*ArchiveEntry, error) {
+	for {
+		header, err := a.r.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// 无法得知解压后大小的文件无法校验容量，跳过
+		if header.UnKnownSize && !header.IsDir {
+			continue
+		}
+
+		return &ArchiveEntry{
+			Name:     header.Name,
+			Size:     uint64(header.UnPackedSize),
+			IsDir:    header.IsDir,
+			Modified: header.ModificationTime,
+			open:     a.open,
+		}, nil
+	}
+}
+
+func (a *rarArchive) open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(a.r), nil
+}
+
+func (a *rarArchive) Concurrent() bool {
+	return false
+}
+
+func (a *rarArchive) Close() error {
+	return nil
+}
+
+// tarArchive tar 归档及经 gzip、bzip2、xz 压缩的 tar 归档，只能按顺序读取
+type tarArchive struct {
+	r       *tar.Reader
+	closer  io.Closer
+	pending *tar.Header
+}
+
+// newTarArchive 打开 tar 归档，读取第一个对象失败时视为无法识别的格式
+func newTarArchive(r io.Reader, closer io.Closer) (Archive, error) {
+	archive := &tarArchive{r: tar.NewReader(r), closer: closer}
+	header, err := archive.r.Next()
+	if err != nil && err != io.EOF {
+		archive.Close()
+		return nil, ErrUnknownArchive
+	}
+	archive.pending = header
+	return archive, nil
+}
+
+func (a *tarArchive) Next() (*ArchiveEntry, error) {
+	for {
+		header := a.pending
+		a.pending = nil
+		if header == nil {
+			var err error
+			if header, err = a.r.Next(); err != nil {
+				return nil, err
+			}
+		}
+
+		// 只处理目录和普通文件，跳过链接、设备等其他类型
+		info := header.FileInfo()
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			continue
+		}
+
+		return &ArchiveEntry{
+			Name:     header.Name,
+			Size:     uint64(header.Size),
+			IsDir:    info.IsDir(),
+			Modified: header.ModTime,
+			open:     a.open,
+		}, nil
+	}
+}
+
+func (a *tarArchive) open() (io.ReadCloser, error) {
+	return ioutil.NopCloser(a.r), nil
+}
+
+func (a *tarArchive) Concurrent() bool {
+	return false
+}
+
+func (a *tarArchive) Close() error {
+	if a.closer != nil {
+		return a.closer.Close()
+	}
+	return nil
+}
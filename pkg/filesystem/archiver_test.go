@@ -0,0 +1,226 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"github.com/ulikunitz/xz"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// testTar 创建包含 dir/1.txt 的 tar 归档
+func testTar(asserts *assert.Assertions) []byte {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	asserts.NoError(writer.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}))
+	asserts.NoError(writer.WriteHeader(&tar.Header{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}))
+	asserts.NoError(writer.WriteHeader(&tar.Header{Name: "dir/1.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 3}))
+	_, err := writer.Write([]byte("123"))
+	asserts.NoError(err)
+	asserts.NoError(writer.Close())
+	return buf.Bytes()
+}
+
+// readArchive 读取压缩包内所有对象，返回对象路径及文件内容
+func readArchive(asserts *assert.Assertions, archive Archive) map[string]string {
+	res := make(map[string]string)
+	for {
+		entry, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		asserts.NoError(err)
+		if err != nil {
+			break
+		}
+
+		if entry.IsDir {
+			res[entry.Name] = ""
+			continue
+		}
+		reader, err := entry.Open()
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(reader)
+		asserts.NoError(err)
+		reader.Close()
+		asserts.EqualValues(len(content), entry.Size)
+		res[entry.Name] = string(content)
+	}
+	asserts.NoError(archive.Close())
+	return res
+}
+
+func TestIsArchive(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.True(IsArchive("1.zip"))
+	asserts.True(IsArchive("1.TAR.GZ"))
+	asserts.True(IsArchive("1.tgz"))
+	asserts.True(IsArchive("1.tar.bz2"))
+	asserts.True(IsArchive("1.tar.xz"))
+	asserts.True(IsArchive("1.7z"))
+	asserts.True(IsArchive("1.rar"))
+	asserts.False(IsArchive("1.gz"))
+	asserts.False(IsArchive("1.txt"))
+}
+
+func TestOpenArchive(t *testing.T) {
+	asserts := assert.New(t)
+	expected := map[string]string{"dir/": "", "dir/1.txt": "123"}
+
+	// 无法识别的格式
+	{
+		archive, err := OpenArchive(bytes.NewReader([]byte("unknown")), 7)
+		asserts.Equal(ErrUnknownArchive, err)
+		asserts.Nil(archive)
+	}
+
+	// tar，跳过链接
+	{
+		content := testTar(asserts)
+		archive, err := OpenArchive(bytes.NewReader(content), int64(len(content)))
+		asserts.NoError(err)
+		asserts.False(archive.Concurrent())
+		asserts.Equal(expected, readArchive(asserts, archive))
+	}
+
+	// tar.gz
+	{
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err := writer.Write(testTar(asserts))
+		asserts.NoError(err)
+		asserts.NoError(writer.Close())
+
+		archive, err := OpenArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		asserts.NoError(err)
+		asserts.Equal(expected, readArchive(asserts, archive))
+	}
+
+	// gzip 压缩的不是 tar 归档
+	{
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err := writer.Write([]byte("not a tar archive"))
+		asserts.NoError(err)
+		asserts.NoError(writer.Close())
+
+		archive, err := OpenArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		asserts.Equal(ErrUnknownArchive, err)
+		asserts.Nil(archive)
+	}
+
+	// tar.xz
+	{
+		var buf bytes.Buffer
+		writer, err := xz.NewWriter(&buf)
+		asserts.NoError(err)
+		_, err = writer.Write(testTar(asserts))
+		asserts.NoError(err)
+		asserts.NoError(writer.Close())
+
+		archive, err := OpenArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		asserts.NoError(err)
+		asserts.Equal(expected, readArchive(asserts, archive))
+	}
+
+	// tar.bz2、7z、rar
+	for _, name := range []string{"tests/test.tar.bz2", "tests/test.7z", "tests/test.rar"} {
+		file, err := os.Open(name)
+		asserts.NoError(err)
+		info, err := file.Stat()
+		asserts.NoError(err)
+
+		archive, err := OpenArchive(file, info.Size())
+		asserts.NoError(err, name)
+		res := readArchive(asserts, archive)
+		asserts.Equal("123", res["dir/1.txt"], name)
+		asserts.Len(res, 2, name)
+		file.Close()
+	}
+
+	// zip
+	{
+		file, err := os.Open("tests/test.zip")
+		asserts.NoError(err)
+		info, err := file.Stat()
+		asserts.NoError(err)
+
+		archive, err := OpenArchive(file, info.Size())
+		asserts.NoError(err)
+		asserts.True(archive.Concurrent())
+		asserts.Equal(map[string]string{"test.txt": "test"}, readArchive(asserts, archive))
+		file.Close()
+	}
+}
+
+func TestFileSystem_ListArchive(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := FileSystem{
+		User: &model.User{Model: gorm.Model{ID: 1}},
+	}
+	cache.Set("setting_temp_path", "tests", 0)
+
+	// 成功
+	{
+		archiveFile, err := os.Open("tests/test.tar.bz2")
+		asserts.NoError(err)
+		fs.FileTarget = []model.File{{SourceName: "1.tar.bz2", Policy: model.Policy{Type: "mock"}}}
+		fs.FileTarget[0].Policy.ID = 1
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.tar.bz2").Return(archiveFile, nil)
+		fs.Handler = testHandler
+
+		entries, truncated, err := fs.ListArchive(ctx, "/1.tar.bz2", 10)
+		testHandler.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.False(truncated)
+		asserts.Len(entries, 2)
+		asserts.Equal("dir", entries[0].Name)
+		asserts.True(entries[0].IsDir)
+		asserts.Equal("dir/1.txt", entries[1].Name)
+		asserts.EqualValues(3, entries[1].Size)
+	}
+
+	// 超出数量上限
+	{
+		archiveFile, err := os.Open("tests/test.tar.bz2")
+		asserts.NoError(err)
+		fs.FileTarget = []model.File{{SourceName: "1.tar.bz2", Policy: model.Policy{Type: "mock"}}}
+		fs.FileTarget[0].Policy.ID = 1
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.tar.bz2").Return(archiveFile, nil)
+		fs.Handler = testHandler
+
+		entries, truncated, err := fs.ListArchive(ctx, "/1.tar.bz2", 1)
+		testHandler.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.True(truncated)
+		asserts.Len(entries, 1)
+		asserts.Equal("dir", entries[0].Name)
+	}
+
+	// 无法识别的格式
+	{
+		fs.FileTarget = []model.File{{SourceName: "1.zip", Policy: model.Policy{Type: "mock"}}}
+		fs.FileTarget[0].Policy.ID = 1
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.zip").Return(MockRSC{rs: bytes.NewReader([]byte("read"))}, nil)
+		fs.Handler = testHandler
+
+		entries, _, err := fs.ListArchive(ctx, "/1.zip", 10)
+		testHandler.AssertExpectations(t)
+		asserts.Equal(ErrUnknownArchive, err)
+		asserts.Nil(entries)
+	}
+}
@@ -0,0 +1,48 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
+	"path"
+	"strings"
+)
+
+// recordAudit 记录当前用户的文件操作
+func (fs *FileSystem) recordAudit(ctx context.Context, action, target string, err *error) {
+	audit.RecordContext(ctx, fs.operator(), action, target, *err)
+}
+
+// auditObjects 列出目录及文件的路径，用于审计日志，须在操作执行前调用
+func (fs *FileSystem) auditObjects(dirs, files []uint) string {
+	if !audit.Enabled() || fs.User == nil {
+		return ""
+	}
+
+	paths := make([]string, 0, len(dirs)+len(files))
+	if len(dirs) > 0 {
+		folders, _ := model.GetFoldersByIDs(dirs, fs.User.ID)
+		for i := 0; i < len(folders); i++ {
+			if err := folders[i].TraceRoot(); err == nil {
+				paths = append(paths, path.Join(folders[i].Position, folders[i].Name))
+			}
+		}
+	}
+	if len(files) > 0 {
+		fileObjects, _ := model.GetFilesByIDs(files, fs.User.ID)
+		parents := make(map[uint]string)
+		for i := 0; i < len(fileObjects); i++ {
+			parent, ok := parents[fileObjects[i].FolderID]
+			if !ok {
+				folders, _ := model.GetFoldersByIDs([]uint{fileObjects[i].FolderID}, fs.User.ID)
+				if len(folders) > 0 && folders[0].TraceRoot() == nil {
+					parent = path.Join(folders[0].Position, folders[0].Name)
+				}
+				parents[fileObjects[i].FolderID] = parent
+			}
+			paths = append(paths, path.Join(parent, fileObjects[i].Name))
+		}
+	}
+
+	return strings.Join(paths, ", ")
+}
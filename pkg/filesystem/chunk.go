@@ -0,0 +1,371 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/remote"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/* =================
+	 分片上传相关
+   =================
+*/
+
+// ChunkSessionPrefix 分片上传会话在缓存中的键前缀
+const ChunkSessionPrefix = "chunk_session_"
+
+// CreateUploadSession 为 file 创建分片上传会话。本机存储策略的分片暂存于临时目录，
+// 从机存储策略会在从机端创建会话，由客户端直接向从机上传分片
+func (fs *FileSystem) CreateUploadSession(ctx context.Context, file FileHeader) (*serializer.UploadSessionResponse, error) {
+	ttl := model.GetIntSetting("upload_session_timeout", 86400)
+	session := &serializer.ChunkUploadSession{
+		Key:         util.RandStringRunes(32),
+		UID:         fs.User.ID,
+		PolicyID:    fs.User.Policy.ID,
+		VirtualPath: file.GetVirtualPath(),
+		Name:        file.GetFileName(),
+		MIMEType:    file.GetMIMEType(),
+		Size:        file.GetSize(),
+		ChunkSize:   uint64(model.GetIntSetting("upload_chunk_size", 5242880)),
+	}
+	if fs.Operator != nil {
+		session.Operator = fs.Operator.ID
+	}
+	res := &serializer.UploadSessionResponse{
+		SessionID: session.Key,
+		ChunkSize: session.ChunkSize,
+		Expires:   time.Now().Add(time.Duration(ttl) * time.Second).Unix(),
+	}
+
+	switch handler := fs.Handler.(type) {
+	case local.Driver:
+	case remote.Driver:
+		uploadURL, credential, err := handler.CreateUploadSession(ctx, session, ttl)
+		if err != nil {
+			return nil, err
+		}
+		res.UploadURL = uploadURL
+		res.Credential = &credential
+
+		// 从机完成上传后通过回调创建文件记录
+		err = cache.Set(
+			"callback_"+session.Key,
+			serializer.UploadSession{
+				Key:         session.Key,
+				UID:         session.UID,
+				PolicyID:    session.PolicyID,
+				VirtualPath: session.VirtualPath,
+				Name:        session.Name,
+				Size:        session.Size,
+			},
+			ttl,
+		)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrChunkPolicyNotAllowed
+	}
+
+	if err := CreateChunkSession(session, ttl); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// GetUploadSession 获取当前用户的分片上传会话，上传至“与我共享”中的目录的会话，
+// 会将文件系统切换至目录所有者
+func (fs *FileSystem) GetUploadSession(key string) (*serializer.ChunkUploadSession, error) {
+	session, err := GetChunkSession(key)
+	if err != nil {
+		return nil, ErrUploadSessionNotExist
+	}
+
+	if session.Operator != 0 && session.Operator == fs.User.ID {
+		if err := fs.enterSharedSession(session); err != nil {
+			return nil, err
+		}
+	}
+
+	if session.UID != fs.User.ID {
+		return nil, ErrUploadSessionNotExist
+	}
+
+	if session.PolicyID != fs.User.Policy.ID {
+		return nil, serializer.NewError(serializer.CodePolicyNotAllowed, "存储策略已变更，请重新上传", nil)
+	}
+
+	return session, nil
+}
+
+// GetUploadSessionStatus 获取分片上传会话中已接收的分片
+func (fs *FileSystem) GetUploadSessionStatus(ctx context.Context, session *serializer.ChunkUploadSession) (*serializer.UploadSessionStatus, error) {
+	switch handler := fs.Handler.(type) {
+	case local.Driver:
+		received, err := ReceivedChunks(session)
+		if err != nil {
+			return nil, err
+		}
+		return &serializer.UploadSessionStatus{
+			Size:      session.Size,
+			ChunkSize: session.ChunkSize,
+			Received:  received,
+		}, nil
+	case remote.Driver:
+		return handler.GetUploadSessionStatus(ctx, session.Key)
+	default:
+		return nil, ErrChunkPolicyNotAllowed
+	}
+}
+
+// UploadChunk 保存分片上传会话中的第 index 个分片
+func (fs *FileSystem) UploadChunk(ctx context.Context, session *serializer.ChunkUploadSession, index uint, chunk io.Reader) error {
+	if _, ok := fs.Handler.(local.Driver); !ok {
+		return ErrChunkPolicyNotAllowed
+	}
+
+	return SaveChunk(session, index, chunk)
+}
+
+// CompleteUploadSession 合并已上传的分片并完成上传，
+// 本机存储策略会以文件流的形式经过 Upload 的各个钩子
+func (fs *FileSystem) CompleteUploadSession(ctx context.Context, session *serializer.ChunkUploadSession) error {
+	switch handler := fs.Handler.(type) {
+	case local.Driver:
+		chunks, err := OpenChunks(session)
+		if err != nil {
+			return err
+		}
+
+		err = fs.Upload(ctx, local.FileStream{
+			File:        chunks,
+			Size:        session.Size,
+			VirtualPath: session.VirtualPath,
+			Name:        session.Name,
+			MIMEType:    session.MIMEType,
+		})
+		if err != nil {
+			return err
+		}
+	case remote.Driver:
+		if err := handler.CompleteUploadSession(ctx, session.Key); err != nil {
+			return err
+		}
+	default:
+		return ErrChunkPolicyNotAllowed
+	}
+
+	DeleteChunkSession(session.Key)
+	return nil
+}
+
+// CancelUploadSession 取消分片上传会话，并清理已上传的分片
+func (fs *FileSystem) CancelUploadSession(ctx context.Context, session *serializer.ChunkUploadSession) error {
+	if handler, ok := fs.Handler.(remote.Driver); ok {
+		if err := handler.DeleteUploadSession(ctx, session.Key); err != nil {
+			util.Log().Warning("无法删除从机上的上传会话[%s], %s", session.Key, err)
+		}
+		_ = cache.Deletes([]string{session.Key}, "callback_")
+	}
+
+	DeleteChunkSession(session.Key)
+	return nil
+}
+
+// CreateChunkSession 保存分片上传会话，分片暂存目录在首个分片上传时创建
+func CreateChunkSession(session *serializer.ChunkUploadSession, ttl int) error {
+	if err := cache.Set(ChunkSessionPrefix+session.Key, *session, ttl); err != nil {
+		return serializer.NewError(serializer.CodeCacheOperation, "无法创建上传会话", err)
+	}
+	return nil
+}
+
+// GetChunkSession 根据 key 获取分片上传会话
+func GetChunkSession(key string) (*serializer.ChunkUploadSession, error) {
+	sessionRaw, ok := cache.Get(ChunkSessionPrefix + key)
+	if !ok {
+		return nil, ErrUploadSessionNotExist
+	}
+
+	session, ok := sessionRaw.(serializer.ChunkUploadSession)
+	if !ok {
+		return nil, ErrUploadSessionNotExist
+	}
+	return &session, nil
+}
+
+// DeleteChunkSession 删除分片上传会话及已上传的分片
+func DeleteChunkSession(key string) {
+	if err := os.RemoveAll(chunkSessionDir(key)); err != nil {
+		util.Log().Warning("无法删除上传会话[%s]的分片, %s", key, err)
+	}
+	_ = cache.Deletes([]string{key}, ChunkSessionPrefix)
+}
+
+// SaveChunk 保存第 index 个分片，分片先写入临时文件，
+// 大小校验通过后才会被视为已接收，重复上传的分片会覆盖原有分片
+func SaveChunk(session *serializer.ChunkUploadSession, index uint, chunk io.Reader) error {
+	if index >= session.ChunkCount() {
+		return ErrChunkIndexOutOfRange
+	}
+
+	dir := chunkSessionDir(session.Key)
+	if err := os.MkdirAll(dir, 0744); err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	dst := filepath.Join(dir, strconv.FormatUint(uint64(index), 10))
+	tmp := fmt.Sprintf("%s.%s.part", dst, util.RandStringRunes(8))
+	out, err := os.Create(tmp)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	// 多读取一个字节，用于检查分片是否超出预期大小
+	expected := session.ChunkLength(index)
+	written, err := io.Copy(out, io.LimitReader(chunk, int64(expected)+1))
+	out.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return ErrIO.WithError(err)
+	}
+	if uint64(written) != expected {
+		os.Remove(tmp)
+		return ErrChunkSizeMismatch
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return ErrIO.WithError(err)
+	}
+	return nil
+}
+
+// ReceivedChunks 列出已接收的分片序号
+func ReceivedChunks(session *serializer.ChunkUploadSession) ([]uint, error) {
+	received := make([]uint, 0)
+	files, err := ioutil.ReadDir(chunkSessionDir(session.Key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return received, nil
+		}
+		return nil, ErrIO.WithError(err)
+	}
+
+	for _, file := range files {
+		index, err := strconv.ParseUint(file.Name(), 10, 32)
+		if err != nil || uint(index) >= session.ChunkCount() {
+			continue
+		}
+		received = append(received, uint(index))
+	}
+	sort.Slice(received, func(i, j int) bool { return received[i] < received[j] })
+
+	return received, nil
+}
+
+// OpenChunks 按顺序读取所有分片，分片未全部上传时返回错误
+func OpenChunks(session *serializer.ChunkUploadSession) (io.ReadCloser, error) {
+	received, err := ReceivedChunks(session)
+	if err != nil {
+		return nil, err
+	}
+	if uint(len(received)) != session.ChunkCount() {
+		return nil, ErrChunkMissing
+	}
+
+	return &chunkReader{dir: chunkSessionDir(session.Key), count: session.ChunkCount()}, nil
+}
+
+// CollectChunkSessions 清理会话已过期的分片暂存目录
+func CollectChunkSessions() {
+	dirs, err := ioutil.ReadDir(chunkTempRoot())
+	if err != nil {
+		return
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		if _, ok := cache.Get(ChunkSessionPrefix + dir.Name()); ok {
+			continue
+		}
+		util.Log().Debug("删除过期的分片上传会话 [%s]", dir.Name())
+		if err := os.RemoveAll(filepath.Join(chunkTempRoot(), dir.Name())); err != nil {
+			util.Log().Debug("分片暂存目录 [%s] 删除失败 , %s", dir.Name(), err)
+		}
+	}
+}
+
+// chunkTempRoot 分片暂存目录，从机端使用默认的临时目录
+func chunkTempRoot() string {
+	tempPath := "temp"
+	if conf.SystemConfig.Mode == "master" {
+		tempPath = model.GetSettingByName("temp_path")
+	}
+	return filepath.Join(util.RelativePath(tempPath), "chunks")
+}
+
+// chunkSessionDir 分片上传会话的分片暂存目录
+func chunkSessionDir(key string) string {
+	return filepath.Join(chunkTempRoot(), filepath.Base(key))
+}
+
+// chunkReader 依次读取各个分片，同一时刻只打开一个分片文件
+type chunkReader struct {
+	dir     string
+	count   uint
+	next    uint
+	current *os.File
+}
+
+// Read 读取分片内容，当前分片读取完毕后自动打开下一个分片
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.next >= r.count {
+				return 0, io.EOF
+			}
+			file, err := os.Open(filepath.Join(r.dir, strconv.FormatUint(uint64(r.next), 10)))
+			if err != nil {
+				return 0, err
+			}
+			r.current = file
+			r.next++
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close 关闭当前打开的分片
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setChunkTempPath(t *testing.T) func() {
+	tempPath, err := ioutil.TempDir("", "cloudreve_chunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Set("setting_temp_path", tempPath, 0)
+	return func() {
+		os.RemoveAll(tempPath)
+		cache.Deletes([]string{"temp_path"}, "setting_")
+	}
+}
+
+func TestSaveChunk(t *testing.T) {
+	asserts := assert.New(t)
+	defer setChunkTempPath(t)()
+	session := &serializer.ChunkUploadSession{Key: "key", Size: 10, ChunkSize: 4}
+	defer DeleteChunkSession(session.Key)
+
+	// 序号超出范围
+	asserts.Equal(ErrChunkIndexOutOfRange, SaveChunk(session, 3, strings.NewReader("1")))
+
+	// 分片大小不符
+	asserts.Equal(ErrChunkSizeMismatch, SaveChunk(session, 0, strings.NewReader("123")))
+	asserts.Equal(ErrChunkSizeMismatch, SaveChunk(session, 0, strings.NewReader("12345")))
+	received, err := ReceivedChunks(session)
+	asserts.NoError(err)
+	asserts.Empty(received)
+
+	// 成功
+	asserts.NoError(SaveChunk(session, 0, strings.NewReader("1234")))
+	received, err = ReceivedChunks(session)
+	asserts.NoError(err)
+	asserts.Equal([]uint{0}, received)
+
+	// 分片未全部上传
+	_, err = OpenChunks(session)
+	asserts.Equal(ErrChunkMissing, err)
+
+	// 重复上传分片，按顺序合并
+	asserts.NoError(SaveChunk(session, 2, strings.NewReader("90")))
+	asserts.NoError(SaveChunk(session, 1, strings.NewReader("0000")))
+	asserts.NoError(SaveChunk(session, 1, strings.NewReader("5678")))
+	received, err = ReceivedChunks(session)
+	asserts.NoError(err)
+	asserts.Equal([]uint{0, 1, 2}, received)
+
+	chunks, err := OpenChunks(session)
+	asserts.NoError(err)
+	content, err := ioutil.ReadAll(chunks)
+	asserts.NoError(err)
+	asserts.NoError(chunks.Close())
+	asserts.Equal("1234567890", string(content))
+
+	// 删除会话
+	DeleteChunkSession(session.Key)
+	asserts.False(util.Exists(chunkSessionDir(session.Key)))
+}
+
+func TestGetChunkSession(t *testing.T) {
+	asserts := assert.New(t)
+	defer setChunkTempPath(t)()
+
+	// 不存在
+	{
+		_, err := GetChunkSession("not_exist")
+		asserts.Equal(ErrUploadSessionNotExist, err)
+	}
+
+	// 存在
+	{
+		asserts.NoError(CreateChunkSession(&serializer.ChunkUploadSession{Key: "exist", Size: 10}, 0))
+		session, err := GetChunkSession("exist")
+		asserts.NoError(err)
+		asserts.EqualValues(10, session.Size)
+		DeleteChunkSession("exist")
+		_, err = GetChunkSession("exist")
+		asserts.Error(err)
+	}
+}
+
+func TestCollectChunkSessions(t *testing.T) {
+	asserts := assert.New(t)
+	defer setChunkTempPath(t)()
+
+	active := &serializer.ChunkUploadSession{Key: "active", Size: 1, ChunkSize: 1}
+	expired := &serializer.ChunkUploadSession{Key: "expired", Size: 1, ChunkSize: 1}
+	asserts.NoError(CreateChunkSession(active, 0))
+	asserts.NoError(SaveChunk(active, 0, strings.NewReader("1")))
+	asserts.NoError(SaveChunk(expired, 0, strings.NewReader("1")))
+
+	CollectChunkSessions()
+	asserts.True(util.Exists(chunkSessionDir(active.Key)))
+	asserts.False(util.Exists(chunkSessionDir(expired.Key)))
+	DeleteChunkSession(active.Key)
+}
+
+func TestFileSystem_CreateUploadSession(t *testing.T) {
+	asserts := assert.New(t)
+	defer setChunkTempPath(t)()
+	cache.Set("setting_upload_session_timeout", "3600", 0)
+	cache.Set("setting_upload_chunk_size", "4", 0)
+	file := local.FileStream{Name: "1.txt", VirtualPath: "/", Size: 10}
+
+	// 本机存储策略
+	{
+		fs := &FileSystem{
+			User:    &model.User{Model: gorm.Model{ID: 1}, Policy: model.Policy{Model: gorm.Model{ID: 2}}},
+			Handler: local.Driver{},
+		}
+		res, err := fs.CreateUploadSession(context.Background(), file)
+		asserts.NoError(err)
+		asserts.EqualValues(4, res.ChunkSize)
+		asserts.Nil(res.Credential)
+
+		session, err := fs.GetUploadSession(res.SessionID)
+		asserts.NoError(err)
+		asserts.EqualValues(1, session.UID)
+		asserts.EqualValues(2, session.PolicyID)
+		asserts.Equal("1.txt", session.Name)
+		asserts.EqualValues(3, session.ChunkCount())
+		DeleteChunkSession(res.SessionID)
+	}
+
+	// 不支持的存储策略
+	{
+		fs := &FileSystem{
+			User:    &model.User{Model: gorm.Model{ID: 1}},
+			Handler: FileHeaderMock{},
+		}
+		_, err := fs.CreateUploadSession(context.Background(), file)
+		asserts.Equal(ErrChunkPolicyNotAllowed, err)
+	}
+
+	cache.Set("setting_upload_chunk_size", "5242880", 0)
+}
+
+func TestFileSystem_GetUploadSession(t *testing.T) {
+	asserts := assert.New(t)
+	defer setChunkTempPath(t)()
+	asserts.NoError(CreateChunkSession(&serializer.ChunkUploadSession{Key: "session", UID: 1, PolicyID: 2}, 0))
+	defer DeleteChunkSession("session")
+
+	// 成功
+	{
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}, Policy: model.Policy{Model: gorm.Model{ID: 2}}}}
+		_, err := fs.GetUploadSession("session")
+		asserts.NoError(err)
+	}
+
+	// 不属于当前用户
+	{
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 2}, Policy: model.Policy{Model: gorm.Model{ID: 2}}}}
+		_, err := fs.GetUploadSession("session")
+		asserts.Equal(ErrUploadSessionNotExist, err)
+	}
+
+	// 存储策略已变更
+	{
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}, Policy: model.Policy{Model: gorm.Model{ID: 3}}}}
+		_, err := fs.GetUploadSession("session")
+		asserts.Error(err)
+	}
+}
+
+func TestFileSystem_UploadChunk(t *testing.T) {
+	asserts := assert.New(t)
+	defer setChunkTempPath(t)()
+	session := &serializer.ChunkUploadSession{Key: "upload", Size: 4, ChunkSize: 4}
+	defer DeleteChunkSession(session.Key)
+
+	// 不支持的存储策略
+	{
+		fs := &FileSystem{Handler: FileHeaderMock{}}
+		err := fs.UploadChunk(context.Background(), session, 0, strings.NewReader("1234"))
+		asserts.Equal(ErrChunkPolicyNotAllowed, err)
+	}
+
+	// 成功
+	{
+		fs := &FileSystem{Handler: local.Driver{}}
+		asserts.NoError(fs.UploadChunk(context.Background(), session, 0, strings.NewReader("1234")))
+		status, err := fs.GetUploadSessionStatus(context.Background(), session)
+		asserts.NoError(err)
+		asserts.Equal([]uint{0}, status.Received)
+		asserts.True(util.Exists(filepath.Join(chunkSessionDir(session.Key), "0")))
+	}
+
+	// 分片未全部上传时无法完成
+	{
+		session := &serializer.ChunkUploadSession{Key: "incomplete", Size: 8, ChunkSize: 4}
+		fs := &FileSystem{Handler: local.Driver{}}
+		asserts.Equal(ErrChunkMissing, fs.CompleteUploadSession(context.Background(), session))
+	}
+}
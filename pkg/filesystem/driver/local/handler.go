@@ -7,6 +7,7 @@ import (
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/auth"
 	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/encrypt"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/filesystem/response"
 	"github.com/HFO4/cloudreve/pkg/serializer"
@@ -22,6 +23,17 @@ type Driver struct {
 	Policy *model.Policy
 }
 
+// NewSlaveDriver 创建从机按主机下发的上传策略写入文件的适配器
+func NewSlaveDriver(policy serializer.UploadPolicy) Driver {
+	return Driver{
+		Policy: &model.Policy{
+			OptionsSerialized: model.PolicyOption{
+				Encrypt: policy.Encrypt,
+			},
+		},
+	}
+}
+
 // List 递归列取给定物理路径下所有文件
 func (handler Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
 	var res []response.Object
@@ -68,7 +80,7 @@ func (handler Driver) List(ctx context.Context, path string, recursive bool) ([]
 	return res, err
 }
 
-// Get 获取文件内容
+// Get 获取文件内容，上下文中的文件记录标记为加密存储时返回可随机读取的解密流
 func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
 	// 打开文件
 	file, err := os.Open(util.RelativePath(path))
@@ -77,6 +89,24 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 		return nil, err
 	}
 
+	// 是否加密以文件记录为准，不根据文件内容判断
+	if fileModel, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok && fileModel.Encrypted {
+		key, err := encrypt.MasterKey()
+		if err != nil {
+			file.Close()
+			util.Log().Warning("无法解密文件 %s，%s", path, err)
+			return nil, err
+		}
+
+		reader, err := encrypt.NewReader(file, key)
+		if err != nil {
+			file.Close()
+			util.Log().Warning("无法解密文件 %s，%s", path, err)
+			return nil, err
+		}
+		return reader, nil
+	}
+
 	// 开启一个协程，用于请求结束后关闭reader
 	// go closeReader(ctx, file)
 
@@ -93,11 +123,22 @@ func closeReader(ctx context.Context, closer io.Closer) {
 	}
 }
 
-// Put 将文件流保存到指定目录
+// Put 将文件流保存到指定目录，存储策略开启加密时加密写入
 func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
 	defer file.Close()
 	dst = util.RelativePath(filepath.FromSlash(dst))
 
+	// 开启加密时先确认主密钥可用
+	var masterKey []byte
+	if handler.Policy != nil && handler.Policy.OptionsSerialized.Encrypt {
+		key, err := encrypt.MasterKey()
+		if err != nil {
+			util.Log().Warning("无法加密文件，%s", err)
+			return err
+		}
+		masterKey = key
+	}
+
 	// 如果目标目录不存在，创建
 	basePath := filepath.Dir(dst)
 	if !util.Exists(basePath) {
@@ -116,6 +157,18 @@ func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, s
 	}
 	defer out.Close()
 
+	// 加密写入文件内容
+	if masterKey != nil {
+		writer, err := encrypt.NewWriter(out, masterKey)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(writer, file); err != nil {
+			return err
+		}
+		return writer.Close()
+	}
+
 	// 写入文件内容
 	_, err = io.Copy(out, file)
 	return err
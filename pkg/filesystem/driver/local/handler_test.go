@@ -1,10 +1,12 @@
 package local
 
 import (
+	"bytes"
 	"context"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/auth"
 	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/HFO4/cloudreve/pkg/encrypt"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/jinzhu/gorm"
@@ -96,6 +98,77 @@ func TestHandler_Get(t *testing.T) {
 	asserts.Nil(rs)
 }
 
+func TestHandler_Encrypt(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{Policy: &model.Policy{OptionsSerialized: model.PolicyOption{Encrypt: true}}}
+	ctx := context.Background()
+	defer func() { conf.EncryptionConfig.MasterKey = "" }()
+
+	// 未配置主密钥
+	{
+		conf.EncryptionConfig.MasterKey = ""
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("123456")), "TestHandler_Encrypt.txt", 6)
+		asserts.Error(err)
+		asserts.False(util.Exists(util.RelativePath("TestHandler_Encrypt.txt")))
+	}
+
+	// 成功，加密存储并可随机读取
+	{
+		conf.EncryptionConfig.MasterKey = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("123456")), "TestHandler_Encrypt.txt", 6)
+		asserts.NoError(err)
+		defer os.Remove(util.RelativePath("TestHandler_Encrypt.txt"))
+
+		raw, err := ioutil.ReadFile(util.RelativePath("TestHandler_Encrypt.txt"))
+		asserts.NoError(err)
+		asserts.NotContains(string(raw), "123456")
+
+		encryptedCtx := context.WithValue(ctx, fsctx.FileModelCtx, model.File{Encrypted: true})
+		rs, err := Driver{}.Get(encryptedCtx, "TestHandler_Encrypt.txt")
+		asserts.NoError(err)
+		size, err := rs.Seek(0, io.SeekEnd)
+		asserts.NoError(err)
+		asserts.EqualValues(6, size)
+		_, err = rs.Seek(2, io.SeekStart)
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("3456", string(content))
+		asserts.NoError(rs.Close())
+	}
+
+	// 文件记录未标记为加密，按原样读取
+	{
+		rs, err := handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, model.File{}), "TestHandler_Encrypt.txt")
+		asserts.NoError(err)
+		raw, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.True(bytes.HasPrefix(raw, encrypt.Magic))
+		asserts.NoError(rs.Close())
+	}
+
+	// 读取时未配置主密钥
+	{
+		conf.EncryptionConfig.MasterKey = ""
+		rs, err := handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, model.File{Encrypted: true}), "TestHandler_Encrypt.txt")
+		asserts.Error(err)
+		asserts.Nil(rs)
+	}
+
+	// 以加密文件头开头的明文文件不会被误解密
+	{
+		plain := append(append([]byte{}, encrypt.Magic...), []byte("plain text")...)
+		asserts.NoError(ioutil.WriteFile(util.RelativePath("TestHandler_Encrypt_plain.txt"), plain, 0644))
+		defer os.Remove(util.RelativePath("TestHandler_Encrypt_plain.txt"))
+		rs, err := handler.Get(ctx, "TestHandler_Encrypt_plain.txt")
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal(plain, content)
+		asserts.NoError(rs.Close())
+	}
+}
+
 func TestHandler_Thumb(t *testing.T) {
 	asserts := assert.New(t)
 	handler := Driver{}
@@ -83,6 +83,10 @@ func (handler Driver) getAPIUrl(scope string, routes ...string) string {
 		controller, _ = url.Parse("/api/v3/slave/thumb")
 	case "list":
 		controller, _ = url.Parse("/api/v3/slave/list")
+	case "upload_session":
+		controller, _ = url.Parse("/api/v3/slave/upload/session")
+	case "task":
+		controller, _ = url.Parse("/api/v3/slave/task")
 	default:
 		controller = serverURL
 	}
@@ -130,19 +134,25 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	return resp, nil
 }
 
-// Put 将文件流保存到指定目录
+// Put 将文件流保存到指定目录，超过分片大小的文件通过分片上传会话保存
 func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
 	defer file.Close()
 
 	// 凭证有效期
 	credentialTTL := model.GetIntSetting("upload_credential_timeout", 3600)
 
+	chunkSize := uint64(model.GetIntSetting("upload_chunk_size", 5242880))
+	if chunkSize > 0 && size > chunkSize {
+		return handler.putChunks(ctx, file, dst, size, chunkSize, credentialTTL)
+	}
+
 	// 生成上传策略
 	policy := serializer.UploadPolicy{
 		SavePath:   path.Dir(dst),
 		FileName:   path.Base(dst),
 		AutoRename: false,
 		MaxSize:    size,
+		Encrypt:    handler.Policy.OptionsSerialized.Encrypt,
 	}
 	credential, err := handler.getUploadCredential(ctx, policy, int64(credentialTTL))
 	if err != nil {
@@ -224,8 +234,14 @@ func (handler Driver) Delete(ctx context.Context, files []string) ([]string, err
 
 // Thumb 获取文件缩略图
 func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	// 从机根据文件记录中的加密状态读取缩略图
+	encrypted := false
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
+		encrypted = file.Encrypted
+	}
+
 	sourcePath := base64.RawURLEncoding.EncodeToString([]byte(path))
-	thumbURL := handler.getAPIUrl("thumb") + "/" + sourcePath
+	thumbURL := fmt.Sprintf("%s/%t/%s", handler.getAPIUrl("thumb"), encrypted, sourcePath)
 	ttl := model.GetIntSetting("preview_timeout", 60)
 	signedThumbURL, err := auth.SignURI(handler.AuthInstance, thumbURL, int64(ttl))
 	if err != nil {
@@ -247,10 +263,12 @@ func (handler Driver) Source(
 	isDownload bool,
 	speed int,
 ) (string, error) {
-	// 尝试从上下文获取文件名
+	// 尝试从上下文获取文件名及加密状态
 	fileName := "file"
+	encrypted := false
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
 		fileName = file.Name
+		encrypted = file.Encrypted
 	}
 
 	serverURL, err := url.Parse(handler.Policy.Server)
@@ -279,7 +297,7 @@ func (handler Driver) Source(
 	sourcePath := base64.RawURLEncoding.EncodeToString([]byte(path))
 	signedURI, err = auth.SignURI(
 		handler.AuthInstance,
-		fmt.Sprintf("%s/%d/%s/%s", controller, speed, sourcePath, fileName),
+		fmt.Sprintf("%s/%d/%t/%s/%s", controller, speed, encrypted, sourcePath, fileName),
 		ttl,
 	)
 
@@ -294,31 +312,41 @@ func (handler Driver) Source(
 
 // Token 获取上传策略和认证Token
 func (handler Driver) Token(ctx context.Context, TTL int64, key string) (serializer.UploadCredential, error) {
+	return handler.getUploadCredential(ctx, handler.getCallbackPolicy(key), TTL)
+}
+
+// getCallbackPolicy 生成客户端直传使用的上传策略，上传完成后从机向主机发送回调
+func (handler Driver) getCallbackPolicy(key string) serializer.UploadPolicy {
 	// 生成回调地址
 	siteURL := model.GetSiteURL()
 	apiBaseURI, _ := url.Parse("/api/v3/callback/remote/" + key)
 	apiURL := siteURL.ResolveReference(apiBaseURI)
 
 	// 生成上传策略
-	policy := serializer.UploadPolicy{
+	return serializer.UploadPolicy{
 		SavePath:         handler.Policy.DirNameRule,
 		FileName:         handler.Policy.FileNameRule,
 		AutoRename:       handler.Policy.AutoRename,
 		MaxSize:          handler.Policy.MaxSize,
 		AllowedExtension: handler.Policy.OptionsSerialized.FileType,
 		CallbackURL:      apiURL.String(),
+		Encrypt:          handler.Policy.OptionsSerialized.Encrypt,
 	}
-	return handler.getUploadCredential(ctx, policy, TTL)
 }
 
 func (handler Driver) getUploadCredential(ctx context.Context, policy serializer.UploadPolicy, TTL int64) (serializer.UploadCredential, error) {
+	return handler.signUploadPolicy(policy, "POST", "/api/v3/slave/upload", TTL)
+}
+
+// signUploadPolicy 对上传策略、上传接口的请求方法 method 及路径 uploadPath 进行签名
+func (handler Driver) signUploadPolicy(policy serializer.UploadPolicy, method, uploadPath string, TTL int64) (serializer.UploadCredential, error) {
 	policyEncoded, err := policy.EncodeUploadPolicy()
 	if err != nil {
 		return serializer.UploadCredential{}, err
 	}
 
 	// 签名上传策略
-	uploadRequest, _ := http.NewRequest("POST", "/api/v3/slave/upload", nil)
+	uploadRequest, _ := http.NewRequest(method, uploadPath, nil)
 	uploadRequest.Header = map[string][]string{
 		"X-Policy": {policyEncoded},
 	}
@@ -127,7 +127,23 @@ func TestHandler_Source(t *testing.T) {
 		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
 		res, err := handler.Source(ctx, "", url.URL{}, 10, false, 0)
 		asserts.NoError(err)
-		asserts.Contains(res, "api/v3/slave/source/0")
+		asserts.Contains(res, "api/v3/slave/source/0/false/")
+	}
+
+	// 成功 加密存储的文件
+	{
+		handler := Driver{
+			Policy:       &model.Policy{Server: "/"},
+			AuthInstance: auth.HMACAuth{},
+		}
+		file := model.File{
+			SourceName: "1.txt",
+			Encrypted:  true,
+		}
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+		res, err := handler.Source(ctx, "", url.URL{}, 10, true, 0)
+		asserts.NoError(err)
+		asserts.Contains(res, "api/v3/slave/download/0/true/")
 	}
 }
 
@@ -378,6 +394,7 @@ func TestHandler_Put(t *testing.T) {
 	}
 	ctx := context.Background()
 	asserts.NoError(cache.Set("setting_upload_credential_timeout", "3600", 0))
+	asserts.NoError(cache.Set("setting_upload_chunk_size", "5242880", 0))
 
 	// 成功
 	{
@@ -465,4 +482,11 @@ func TestHandler_Thumb(t *testing.T) {
 	resp, err := handler.Thumb(ctx, "/1.txt")
 	asserts.NoError(err)
 	asserts.True(resp.Redirect)
+	asserts.Contains(resp.URL, "/thumb/false/")
+
+	// 加密存储的文件
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, model.File{Encrypted: true})
+	resp, err = handler.Thumb(ctx, "/1.txt")
+	asserts.NoError(err)
+	asserts.Contains(resp.URL, "/thumb/true/")
 }
@@ -0,0 +1,238 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"io"
+	"net/url"
+	"path"
+	"time"
+)
+
+// CreateUploadSession 在从机创建分片上传会话，返回客户端直传分片的地址及凭证，
+// 上传完成后从机会向主机发送回调
+func (handler Driver) CreateUploadSession(ctx context.Context, session *serializer.ChunkUploadSession, ttl int) (string, serializer.UploadCredential, error) {
+	policy := handler.getCallbackPolicy(session.Key)
+	if err := handler.createSlaveSession(ctx, session, policy, ttl); err != nil {
+		return "", serializer.UploadCredential{}, err
+	}
+
+	// 凭证只用于上传分片，无法用于合并分片等同一路径上的其他接口
+	uploadURL := handler.getAPIUrl("upload_session", session.Key)
+	credential, err := handler.signUploadPolicy(policy, "PUT", sessionPath(session.Key), int64(ttl))
+	if err != nil {
+		return "", serializer.UploadCredential{}, err
+	}
+
+	return uploadURL, credential, nil
+}
+
+// GetUploadSessionStatus 获取从机上分片上传会话已接收的分片
+func (handler Driver) GetUploadSessionStatus(ctx context.Context, key string) (*serializer.UploadSessionStatus, error) {
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	signedURI, err := auth.SignURI(handler.AuthInstance, handler.getAPIUrl("upload_session", key), int64(signTTL))
+	if err != nil {
+		return nil, serializer.NewError(serializer.CodeEncryptError, "无法对URL进行签名", err)
+	}
+
+	resp, err := handler.Client.Request(
+		"GET",
+		signedURI.String(),
+		nil,
+		request.WithContext(ctx),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, errors.New(resp.Msg)
+	}
+
+	var status serializer.UploadSessionStatus
+	if resStr, ok := resp.Data.(string); ok {
+		if err := json.Unmarshal([]byte(resStr), &status); err != nil {
+			return nil, err
+		}
+	}
+	return &status, nil
+}
+
+// CompleteUploadSession 通知从机合并分片并完成上传
+func (handler Driver) CompleteUploadSession(ctx context.Context, key string) error {
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	resp, err := handler.Client.Request(
+		"POST",
+		handler.getAPIUrl("upload_session", key),
+		nil,
+		request.WithContext(ctx),
+		request.WithCredential(handler.AuthInstance, int64(signTTL)),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return errors.New(resp.Msg)
+	}
+
+	return nil
+}
+
+// DeleteUploadSession 删除从机上的分片上传会话
+func (handler Driver) DeleteUploadSession(ctx context.Context, key string) error {
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	signedURI, err := auth.SignURI(handler.AuthInstance, handler.getAPIUrl("upload_session", key), int64(signTTL))
+	if err != nil {
+		return serializer.NewError(serializer.CodeEncryptError, "无法对URL进行签名", err)
+	}
+
+	resp, err := handler.Client.Request(
+		"DELETE",
+		signedURI.String(),
+		nil,
+		request.WithContext(ctx),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return errors.New(resp.Msg)
+	}
+
+	return nil
+}
+
+// putChunks 通过分片上传会话将文件流保存到从机，单个分片上传失败时会重试
+func (handler Driver) putChunks(ctx context.Context, file io.Reader, dst string, size, chunkSize uint64, credentialTTL int) error {
+	session := &serializer.ChunkUploadSession{
+		Key:       util.RandStringRunes(32),
+		Name:      path.Base(dst),
+		Size:      size,
+		ChunkSize: chunkSize,
+	}
+	policy := serializer.UploadPolicy{
+		SavePath:   path.Dir(dst),
+		FileName:   path.Base(dst),
+		AutoRename: false,
+		MaxSize:    size,
+		Encrypt:    handler.Policy.OptionsSerialized.Encrypt,
+	}
+
+	ttl := model.GetIntSetting("upload_session_timeout", 86400)
+	if err := handler.createSlaveSession(ctx, session, policy, ttl); err != nil {
+		return err
+	}
+
+	credential, err := handler.signUploadPolicy(policy, "PUT", sessionPath(session.Key), int64(credentialTTL))
+	if err != nil {
+		handler.DeleteUploadSession(ctx, session.Key)
+		return err
+	}
+
+	// 逐个上传分片
+	retries := model.GetIntSetting("slave_chunk_retries", 3)
+	buf := make([]byte, chunkSize)
+	for index := uint(0); index < session.ChunkCount(); index++ {
+		chunk := buf[:session.ChunkLength(index)]
+		if _, err := io.ReadFull(file, chunk); err != nil {
+			handler.DeleteUploadSession(ctx, session.Key)
+			return err
+		}
+
+		for retried := 0; ; retried++ {
+			err = handler.uploadChunk(ctx, session.Key, index, chunk, credential)
+			if err == nil {
+				break
+			}
+			if retried >= retries {
+				handler.DeleteUploadSession(ctx, session.Key)
+				return err
+			}
+			util.Log().Debug("分片[%d]上传失败，%s，重试(%d/%d)", index, err, retried+1, retries)
+		}
+	}
+
+	if err := handler.CompleteUploadSession(ctx, session.Key); err != nil {
+		handler.DeleteUploadSession(ctx, session.Key)
+		return err
+	}
+
+	return nil
+}
+
+// createSlaveSession 请求从机创建分片上传会话
+func (handler Driver) createSlaveSession(ctx context.Context, session *serializer.ChunkUploadSession, policy serializer.UploadPolicy, ttl int) error {
+	reqBody := serializer.RemoteUploadSessionRequest{
+		Key:       session.Key,
+		Name:      session.Name,
+		MIMEType:  session.MIMEType,
+		Size:      session.Size,
+		ChunkSize: session.ChunkSize,
+		TTL:       ttl,
+		Policy:    policy,
+	}
+	reqBodyEncoded, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	resp, err := handler.Client.Request(
+		"POST",
+		handler.getAPIUrl("upload_session"),
+		bytes.NewReader(reqBodyEncoded),
+		request.WithContext(ctx),
+		request.WithCredential(handler.AuthInstance, int64(signTTL)),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return errors.New(resp.Msg)
+	}
+
+	return nil
+}
+
+// uploadChunk 向从机上传单个分片
+func (handler Driver) uploadChunk(ctx context.Context, key string, index uint, chunk []byte, credential serializer.UploadCredential) error {
+	chunkURL, err := url.Parse(handler.getAPIUrl("upload_session", key))
+	if err != nil {
+		return err
+	}
+	chunkURL.RawQuery = fmt.Sprintf("chunk=%d", index)
+
+	resp, err := handler.Client.Request(
+		"PUT",
+		chunkURL.String(),
+		bytes.NewReader(chunk),
+		request.WithContext(ctx),
+		request.WithHeader(map[string][]string{
+			"Authorization": {credential.Token},
+			"X-Policy":      {credential.Policy},
+		}),
+		request.WithContentLength(int64(len(chunk))),
+		request.WithTimeout(time.Duration(0)),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return errors.New(resp.Msg)
+	}
+
+	return nil
+}
+
+// sessionPath 从机分片上传会话的接口路径
+func sessionPath(key string) string {
+	return path.Join("/api/v3/slave/upload/session", key)
+}
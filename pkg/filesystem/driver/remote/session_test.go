@@ -0,0 +1,258 @@
+package remote
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func sessionResponse(body string) *request.Response {
+	return &request.Response{
+		Err: nil,
+		Response: &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		},
+	}
+}
+
+func TestDriver_CreateUploadSession(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:      "remote",
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	asserts.NoError(cache.Set("setting_slave_api_timeout", "60", 0))
+	asserts.NoError(cache.Set("setting_siteURL", "http://cloudreve.org", 0))
+	session := &serializer.ChunkUploadSession{Key: "key", Name: "1.txt", Size: 10, ChunkSize: 4}
+
+	// 成功
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/upload/session",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`))
+		handler.Client = clientMock
+		uploadURL, credential, err := handler.CreateUploadSession(context.Background(), session, 3600)
+		clientMock.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.Equal("http://test.com/api/v3/slave/upload/session/key", uploadURL)
+		asserts.NotEmpty(credential.Token)
+		asserts.NotEmpty(credential.Policy)
+
+		// 凭证只能用于上传分片
+		for method, valid := range map[string]bool{"PUT": true, "POST": false} {
+			req, _ := http.NewRequest(method, uploadURL, nil)
+			req.Header["Authorization"] = []string{credential.Token}
+			req.Header["X-Policy"] = []string{credential.Policy}
+			asserts.Equal(valid, auth.CheckRequest(handler.AuthInstance, req) == nil, method)
+		}
+	}
+
+	// 从机返回错误
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/upload/session",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":40002,"msg":"error"}`))
+		handler.Client = clientMock
+		_, _, err := handler.CreateUploadSession(context.Background(), session, 3600)
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+	}
+}
+
+func TestDriver_GetUploadSessionStatus(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:      "remote",
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	asserts.NoError(cache.Set("setting_slave_api_timeout", "60", 0))
+
+	// 成功
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"GET",
+			testMock.MatchedBy(func(target string) bool {
+				return strings.HasPrefix(target, "http://test.com/api/v3/slave/upload/session/key?sign=")
+			}),
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0,"data":"{\"size\":10,\"chunk_size\":4,\"received\":[0,2]}"}`))
+		handler.Client = clientMock
+		status, err := handler.GetUploadSessionStatus(context.Background(), "key")
+		clientMock.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.Equal([]uint{0, 2}, status.Received)
+	}
+
+	// 会话不存在
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"GET",
+			testMock.Anything,
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":404,"msg":"error"}`))
+		handler.Client = clientMock
+		_, err := handler.GetUploadSessionStatus(context.Background(), "key")
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+	}
+}
+
+func TestDriver_putChunks(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:      "remote",
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	ctx := context.Background()
+	asserts.NoError(cache.Set("setting_upload_credential_timeout", "3600", 0))
+	asserts.NoError(cache.Set("setting_upload_chunk_size", "4", 0))
+	asserts.NoError(cache.Set("setting_upload_session_timeout", "3600", 0))
+	asserts.NoError(cache.Set("setting_slave_api_timeout", "60", 0))
+	asserts.NoError(cache.Set("setting_slave_chunk_retries", "1", 0))
+
+	// 成功，第二个分片重试一次
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/upload/session",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`))
+		clientMock.On(
+			"Request",
+			"PUT",
+			testMock.MatchedBy(func(target string) bool { return strings.HasSuffix(target, "?chunk=0") }),
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`)).Once()
+		clientMock.On(
+			"Request",
+			"PUT",
+			testMock.MatchedBy(func(target string) bool { return strings.HasSuffix(target, "?chunk=1") }),
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":40002,"msg":"error"}`)).Once()
+		clientMock.On(
+			"Request",
+			"PUT",
+			testMock.MatchedBy(func(target string) bool { return strings.HasSuffix(target, "?chunk=1") }),
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`)).Once()
+		clientMock.On(
+			"Request",
+			"PUT",
+			testMock.MatchedBy(func(target string) bool { return strings.HasSuffix(target, "?chunk=2") }),
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`)).Once()
+		clientMock.On(
+			"Request",
+			"POST",
+			testMock.MatchedBy(func(target string) bool {
+				return strings.HasPrefix(target, "http://test.com/api/v3/slave/upload/session/")
+			}),
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`)).Once()
+		handler.Client = clientMock
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("1234567890")), "/dir/1.txt", 10)
+		clientMock.AssertExpectations(t)
+		asserts.NoError(err)
+	}
+
+	// 分片超过重试次数，删除从机会话
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/upload/session",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`))
+		clientMock.On(
+			"Request",
+			"PUT",
+			testMock.Anything,
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":40002,"msg":"error"}`)).Twice()
+		clientMock.On(
+			"Request",
+			"DELETE",
+			testMock.Anything,
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`)).Once()
+		handler.Client = clientMock
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("1234567890")), "/dir/1.txt", 10)
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+	}
+
+	// 文件流提前结束
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/upload/session",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`))
+		clientMock.On(
+			"Request",
+			"DELETE",
+			testMock.Anything,
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`)).Once()
+		handler.Client = clientMock
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("123")), "/dir/1.txt", 10)
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+	}
+
+	asserts.NoError(cache.Set("setting_upload_chunk_size", "5242880", 0))
+}
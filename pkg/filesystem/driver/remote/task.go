@@ -0,0 +1,109 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+)
+
+// CreateTask 在从机上创建后台任务，props 为任务属性，返回从机上的任务ID
+func (handler Driver) CreateTask(ctx context.Context, taskType int, props interface{}) (string, error) {
+	propsEncoded, err := json.Marshal(props)
+	if err != nil {
+		return "", err
+	}
+
+	reqBody := serializer.SlaveTaskRequest{
+		Type:  taskType,
+		Props: string(propsEncoded),
+		Policy: serializer.UploadPolicy{
+			Encrypt: handler.Policy.OptionsSerialized.Encrypt,
+		},
+	}
+	reqBodyEncoded, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	resp, err := handler.Client.Request(
+		"POST",
+		handler.getAPIUrl("task"),
+		bytes.NewReader(reqBodyEncoded),
+		request.WithContext(ctx),
+		request.WithCredential(handler.AuthInstance, int64(signTTL)),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return "", err
+	}
+	if resp.Code != 0 {
+		return "", errors.New(resp.Msg)
+	}
+
+	id, ok := resp.Data.(string)
+	if !ok || id == "" {
+		return "", errors.New("未知的返回结果格式")
+	}
+	return id, nil
+}
+
+// GetTask 获取从机上任务的执行状态及进度
+func (handler Driver) GetTask(ctx context.Context, id string) (*serializer.SlaveTaskStatus, error) {
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	signedURI, err := auth.SignURI(handler.AuthInstance, handler.getAPIUrl("task", id), int64(signTTL))
+	if err != nil {
+		return nil, serializer.NewError(serializer.CodeEncryptError, "无法对URL进行签名", err)
+	}
+
+	resp, err := handler.Client.Request(
+		"GET",
+		signedURI.String(),
+		nil,
+		request.WithContext(ctx),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != 0 {
+		return nil, errors.New(resp.Msg)
+	}
+
+	var status serializer.SlaveTaskStatus
+	resStr, ok := resp.Data.(string)
+	if !ok {
+		return nil, errors.New("未知的返回结果格式")
+	}
+	if err := json.Unmarshal([]byte(resStr), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// DeleteTask 删除从机上的任务，任务仍在执行时会被取消
+func (handler Driver) DeleteTask(ctx context.Context, id string) error {
+	signTTL := model.GetIntSetting("slave_api_timeout", 60)
+	signedURI, err := auth.SignURI(handler.AuthInstance, handler.getAPIUrl("task", id), int64(signTTL))
+	if err != nil {
+		return serializer.NewError(serializer.CodeEncryptError, "无法对URL进行签名", err)
+	}
+
+	resp, err := handler.Client.Request(
+		"DELETE",
+		signedURI.String(),
+		nil,
+		request.WithContext(ctx),
+	).CheckHTTPResponse(200).DecodeResponse()
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return errors.New(resp.Msg)
+	}
+
+	return nil
+}
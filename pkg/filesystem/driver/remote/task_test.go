@@ -0,0 +1,166 @@
+package remote
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"strings"
+	"testing"
+)
+
+func TestDriver_CreateTask(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:      "remote",
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	asserts.NoError(cache.Set("setting_slave_api_timeout", "60", 0))
+
+	// 成功
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/task",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0,"data":"id"}`))
+		handler.Client = clientMock
+		id, err := handler.CreateTask(context.Background(), 1, map[string]string{"src": "1.zip"})
+		clientMock.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.Equal("id", id)
+	}
+
+	// 从机返回错误
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/task",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":40001,"msg":"error"}`))
+		handler.Client = clientMock
+		id, err := handler.CreateTask(context.Background(), 1, nil)
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+		asserts.Empty(id)
+	}
+
+	// 未返回任务ID
+	{
+		clientMock := ClientMock{}
+		clientMock.On(
+			"Request",
+			"POST",
+			"http://test.com/api/v3/slave/task",
+			testMock.Anything,
+			testMock.Anything,
+		).Return(sessionResponse(`{"code":0}`))
+		handler.Client = clientMock
+		_, err := handler.CreateTask(context.Background(), 1, nil)
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+	}
+}
+
+func TestDriver_GetTask(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:      "remote",
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	asserts.NoError(cache.Set("setting_slave_api_timeout", "60", 0))
+	isTaskURL := testMock.MatchedBy(func(url string) bool {
+		return strings.HasPrefix(url, "http://test.com/api/v3/slave/task/id?sign=")
+	})
+
+	// 成功
+	{
+		clientMock := ClientMock{}
+		clientMock.On("Request", "GET", isTaskURL, testMock.Anything, testMock.Anything).
+			Return(sessionResponse(`{"code":0,"data":"{\"status\":4,\"processed_size\":1,\"total_size\":2,\"objects\":[{\"name\":\"1.txt\"}]}"}`))
+		handler.Client = clientMock
+		status, err := handler.GetTask(context.Background(), "id")
+		clientMock.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.Equal(4, status.Status)
+		asserts.EqualValues(1, status.ProcessedSize)
+		asserts.EqualValues(2, status.TotalSize)
+		asserts.Len(status.Objects, 1)
+	}
+
+	// 从机返回错误
+	{
+		clientMock := ClientMock{}
+		clientMock.On("Request", "GET", isTaskURL, testMock.Anything, testMock.Anything).
+			Return(sessionResponse(`{"code":404,"msg":"error"}`))
+		handler.Client = clientMock
+		status, err := handler.GetTask(context.Background(), "id")
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+		asserts.Nil(status)
+	}
+
+	// 无法解析状态
+	{
+		clientMock := ClientMock{}
+		clientMock.On("Request", "GET", isTaskURL, testMock.Anything, testMock.Anything).
+			Return(sessionResponse(`{"code":0,"data":"?"}`))
+		handler.Client = clientMock
+		status, err := handler.GetTask(context.Background(), "id")
+		clientMock.AssertExpectations(t)
+		asserts.Error(err)
+		asserts.Nil(status)
+	}
+}
+
+func TestDriver_DeleteTask(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{
+			Type:      "remote",
+			SecretKey: "test",
+			Server:    "http://test.com",
+		},
+		AuthInstance: auth.HMACAuth{},
+	}
+	asserts.NoError(cache.Set("setting_slave_api_timeout", "60", 0))
+	isTaskURL := testMock.MatchedBy(func(url string) bool {
+		return strings.HasPrefix(url, "http://test.com/api/v3/slave/task/id?sign=")
+	})
+
+	// 成功
+	{
+		clientMock := ClientMock{}
+		clientMock.On("Request", "DELETE", isTaskURL, testMock.Anything, testMock.Anything).
+			Return(sessionResponse(`{"code":0}`))
+		handler.Client = clientMock
+		asserts.NoError(handler.DeleteTask(context.Background(), "id"))
+		clientMock.AssertExpectations(t)
+	}
+
+	// 从机返回错误
+	{
+		clientMock := ClientMock{}
+		clientMock.On("Request", "DELETE", isTaskURL, testMock.Anything, testMock.Anything).
+			Return(sessionResponse(`{"code":404,"msg":"error"}`))
+		handler.Client = clientMock
+		asserts.Error(handler.DeleteTask(context.Background(), "id"))
+		clientMock.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,188 @@
+package sftp
+
+import (
+	"errors"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/util"
+	sftpsdk "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dialTimeout 建立SSH连接的超时时间
+const dialTimeout = 10 * time.Second
+
+var (
+	// ErrHostKeyRequired 未填写服务器公钥
+	ErrHostKeyRequired = errors.New("须填写SFTP服务器公钥")
+	// ErrInvalidHostKey 服务器公钥无法解析
+	ErrInvalidHostKey = errors.New("无法解析服务器公钥")
+)
+
+var (
+	// clients 各存储策略复用的客户端
+	clients = make(map[uint]*Client)
+	lock    sync.Mutex
+)
+
+// Client SFTP客户端，延迟建立连接，连接断开后在下次使用时重连
+type Client struct {
+	Policy model.Policy
+
+	mu   sync.Mutex
+	conn *ssh.Client
+	sftp *sftpsdk.Client
+}
+
+// NewClient 获取存储策略对应的客户端，连接参数未变时复用已有连接
+func NewClient(policy *model.Policy) *Client {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if client, ok := clients[policy.ID]; ok {
+		if client.Policy.Server == policy.Server &&
+			client.Policy.AccessKey == policy.AccessKey &&
+			client.Policy.SecretKey == policy.SecretKey &&
+			client.Policy.Options == policy.Options {
+			return client
+		}
+		client.Close()
+	}
+
+	client := &Client{Policy: *policy}
+	clients[policy.ID] = client
+	return client
+}
+
+// Session 获取SFTP会话，尚未连接时建立连接
+func (client *Client) Session() (*sftpsdk.Client, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.sftp != nil {
+		return client.sftp, nil
+	}
+
+	conn, session, err := Dial(&client.Policy)
+	if err != nil {
+		return nil, err
+	}
+	client.conn, client.sftp = conn, session
+	return session, nil
+}
+
+// Check 操作出错后检查连接是否仍然可用，不可用时关闭连接以便下次重连
+func (client *Client) Check() {
+	client.mu.Lock()
+	session := client.sftp
+	client.mu.Unlock()
+
+	if session == nil {
+		return
+	}
+	if _, err := session.Getwd(); err != nil {
+		util.Log().Debug("SFTP连接已断开，%s", err)
+		client.Close()
+	}
+}
+
+// Close 关闭连接
+func (client *Client) Close() {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.sftp != nil {
+		client.sftp.Close()
+		client.sftp = nil
+	}
+	if client.conn != nil {
+		client.conn.Close()
+		client.conn = nil
+	}
+}
+
+// Dial 使用存储策略的连接参数建立SSH连接并开启SFTP会话。Server 为
+// 服务器地址，AccessKey 为用户名，SecretKey 为密码或PEM格式私钥
+func Dial(policy *model.Policy) (*ssh.Client, *sftpsdk.Client, error) {
+	config, err := clientConfig(policy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := ssh.Dial("tcp", serverAddr(policy.Server), config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := sftpsdk.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, session, nil
+}
+
+// TestConnection 测试存储策略能否连接并访问存储根目录
+func TestConnection(policy *model.Policy) error {
+	conn, session, err := Dial(policy)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer session.Close()
+
+	info, err := session.Stat(rootPath(policy))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("存储根目录不是目录")
+	}
+	return nil
+}
+
+// clientConfig 生成SSH客户端配置
+func clientConfig(policy *model.Policy) (*ssh.ClientConfig, error) {
+	var auth ssh.AuthMethod
+	if signer, err := ssh.ParsePrivateKey([]byte(policy.SecretKey)); err == nil {
+		auth = ssh.PublicKeys(signer)
+	} else {
+		auth = ssh.Password(policy.SecretKey)
+	}
+
+	hostKey, err := ParseHostKey(policy.OptionsSerialized.HostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            policy.AccessKey,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         dialTimeout,
+	}, nil
+}
+
+// ParseHostKey 解析 authorized_keys 格式的服务器公钥，连接时只接受此公钥
+func ParseHostKey(key string) (ssh.PublicKey, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, ErrHostKeyRequired
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return nil, ErrInvalidHostKey
+	}
+	return hostKey, nil
+}
+
+// serverAddr 补全服务器地址中省略的端口
+func serverAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(server, "22")
+	}
+	return server
+}
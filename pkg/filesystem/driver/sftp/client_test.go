@@ -0,0 +1,93 @@
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"net"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	asserts := assert.New(t)
+	policy := &model.Policy{Model: gorm.Model{ID: 10}, Server: "127.0.0.1", AccessKey: "user"}
+
+	// 连接参数未变时复用
+	{
+		client := NewClient(policy)
+		asserts.Equal(client, NewClient(&model.Policy{Model: gorm.Model{ID: 10}, Server: "127.0.0.1", AccessKey: "user"}))
+	}
+
+	// 连接参数改变
+	{
+		client := NewClient(policy)
+		policy.SecretKey = "new"
+		newClient := NewClient(policy)
+		asserts.False(client == newClient)
+		asserts.Equal("new", newClient.Policy.SecretKey)
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未填写服务器公钥
+	{
+		_, err := clientConfig(&model.Policy{AccessKey: "user", SecretKey: "password"})
+		asserts.Equal(ErrHostKeyRequired, err)
+	}
+
+	// 服务器公钥无法解析
+	{
+		policy := &model.Policy{AccessKey: "user", SecretKey: "password"}
+		policy.OptionsSerialized.HostKey = "invalid"
+		_, err := clientConfig(policy)
+		asserts.Equal(ErrInvalidHostKey, err)
+	}
+
+	// 密码认证，指定服务器公钥
+	{
+		policy := &model.Policy{AccessKey: "user", SecretKey: "password"}
+		policy.OptionsSerialized.HostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+		config, err := clientConfig(policy)
+		asserts.NoError(err)
+		asserts.Equal("user", config.User)
+		asserts.Len(config.Auth, 1)
+		asserts.NotNil(config.HostKeyCallback)
+
+		// 服务器出示其他公钥时拒绝连接
+		public, _, _ := ed25519.GenerateKey(rand.Reader)
+		other, _ := ssh.NewPublicKey(public)
+		asserts.Error(config.HostKeyCallback("127.0.0.1:22", &net.TCPAddr{}, other))
+	}
+}
+
+func TestServerAddr(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal("127.0.0.1:22", serverAddr("127.0.0.1"))
+	asserts.Equal("127.0.0.1:2222", serverAddr("127.0.0.1:2222"))
+	asserts.Equal("nas.local:22", serverAddr("nas.local"))
+}
+
+func TestClient_Session(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 连接失败
+	{
+		client := &Client{Policy: model.Policy{Server: "127.0.0.1:1"}}
+		session, err := client.Session()
+		asserts.Error(err)
+		asserts.Nil(session)
+	}
+
+	// 未连接时关闭、检查
+	{
+		client := &Client{}
+		client.Check()
+		client.Close()
+		asserts.Nil(client.sftp)
+	}
+}
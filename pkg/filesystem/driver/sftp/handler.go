@@ -0,0 +1,237 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Driver SFTP策略适配器，文件上传、下载均由服务端中转
+type Driver struct {
+	Policy *model.Policy
+	Client *Client
+}
+
+// rootPath 返回存储策略在服务器上的根目录，未设定时为登录用户的默认目录
+func rootPath(policy *model.Policy) string {
+	if policy.BucketName == "" {
+		return "."
+	}
+	return policy.BucketName
+}
+
+// remotePath 将存储路径转换为服务器上的路径
+func (handler Driver) remotePath(p string) string {
+	return path.Join(rootPath(handler.Policy), filepath.ToSlash(p))
+}
+
+// relativePath 返回服务器上的路径相对于 root 的路径
+func relativePath(root, p string) string {
+	if root == "." {
+		return p
+	}
+	return strings.TrimPrefix(p, root+"/")
+}
+
+// List 列取给定路径下的文件
+func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	session, err := handler.Client.Session()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []response.Object
+	root := handler.remotePath(base)
+	walker := session.Walk(root)
+	for walker.Step() {
+		// 跳过根目录
+		if walker.Path() == root {
+			if err := walker.Err(); err != nil {
+				handler.Client.Check()
+				return nil, err
+			}
+			continue
+		}
+
+		info := walker.Stat()
+		if err := walker.Err(); err != nil {
+			util.Log().Warning("无法遍历目录 %s, %s", walker.Path(), err)
+			if info != nil && info.IsDir() {
+				walker.SkipDir()
+			}
+			continue
+		}
+
+		rel := relativePath(root, walker.Path())
+		res = append(res, response.Object{
+			Name:         info.Name(),
+			RelativePath: rel,
+			Source:       path.Join(base, rel),
+			Size:         uint64(info.Size()),
+			IsDir:        info.IsDir(),
+			LastModify:   info.ModTime(),
+		})
+
+		// 如果非递归，则不步入目录
+		if !recursive && info.IsDir() {
+			walker.SkipDir()
+		}
+	}
+
+	return res, nil
+}
+
+// Get 获取文件内容
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	session, err := handler.Client.Session()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := session.Open(handler.remotePath(path))
+	if err != nil {
+		util.Log().Debug("无法打开文件：%s", err)
+		handler.Client.Check()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Put 将文件流保存到指定目录
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+
+	session, err := handler.Client.Session()
+	if err != nil {
+		return err
+	}
+
+	// 创建目标目录
+	dst = handler.remotePath(dst)
+	if err := session.MkdirAll(path.Dir(dst)); err != nil {
+		util.Log().Warning("无法创建目录，%s", err)
+		handler.Client.Check()
+		return err
+	}
+
+	// 创建目标文件
+	out, err := session.Create(dst)
+	if err != nil {
+		util.Log().Warning("无法创建文件，%s", err)
+		handler.Client.Check()
+		return err
+	}
+	defer out.Close()
+
+	// 写入文件内容
+	if _, err := io.Copy(out, file); err != nil {
+		handler.Client.Check()
+		return err
+	}
+	return nil
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	session, err := handler.Client.Session()
+	if err != nil {
+		return files, err
+	}
+
+	deleteFailed := make([]string, 0, len(files))
+	var retErr error
+	for _, value := range files {
+		if err := session.Remove(handler.remotePath(value)); err != nil {
+			util.Log().Warning("无法删除文件，%s", err)
+			retErr = err
+			deleteFailed = append(deleteFailed, value)
+		}
+	}
+
+	if retErr != nil {
+		handler.Client.Check()
+	}
+	return deleteFailed, retErr
+}
+
+// Thumb 获取文件缩略图
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return nil, errors.New("未实现")
+}
+
+// Source 获取外链URL，文件内容由服务端中转
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	file, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return "", errors.New("无法获取文件记录上下文")
+	}
+
+	// 是否启用了CDN
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		baseURL = *cdnURL
+	}
+
+	var (
+		signedURI *url.URL
+		err       error
+	)
+	if isDownload {
+		// 创建下载会话，将文件信息写入缓存
+		downloadSessionID := util.RandStringRunes(16)
+		err = cache.Set("download_"+downloadSessionID, file, int(ttl))
+		if err != nil {
+			return "", serializer.NewError(serializer.CodeCacheOperation, "无法创建下载会话", err)
+		}
+
+		// 签名生成文件记录
+		signedURI, err = auth.SignURI(
+			auth.General,
+			fmt.Sprintf("/api/v3/file/download/%s", downloadSessionID),
+			ttl,
+		)
+	} else {
+		// 签名生成文件记录
+		signedURI, err = auth.SignURI(
+			auth.General,
+			fmt.Sprintf("/api/v3/file/get/%d/%s", file.ID, file.Name),
+			ttl,
+		)
+	}
+
+	if err != nil {
+		return "", serializer.NewError(serializer.CodeEncryptError, "无法对URL进行签名", err)
+	}
+
+	finalURL := baseURL.ResolveReference(signedURI).String()
+	return finalURL, nil
+}
+
+// Token 获取上传策略和认证Token，SFTP策略由服务端中转上传，直接返回空值
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
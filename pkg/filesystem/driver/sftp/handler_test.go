@@ -0,0 +1,229 @@
+package sftp
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	sftpsdk "github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestDriver 创建连接到进程内SFTP服务器的适配器，存储根目录为临时目录，
+// 返回的函数用于断开连接并清理临时目录
+func newTestDriver(t *testing.T) (Driver, string, func()) {
+	root, err := ioutil.TempDir("", "cloudreve_sftp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	server, err := sftpsdk.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		server.Serve()
+		serverWriter.Close()
+	}()
+
+	session, err := sftpsdk.NewClientPipe(clientReader, clientWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &model.Policy{BucketName: filepath.ToSlash(root)}
+	return Driver{
+		Policy: policy,
+		Client: &Client{Policy: *policy, sftp: session},
+	}, root, func() {
+		session.Close()
+		os.RemoveAll(root)
+	}
+}
+
+func TestDriver_Put(t *testing.T) {
+	asserts := assert.New(t)
+	handler, root, clean := newTestDriver(t)
+	defer clean()
+	ctx := context.Background()
+
+	// 成功，自动创建目录
+	{
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("test input file")), "uploads/1/test.txt", 15)
+		asserts.NoError(err)
+		content, err := ioutil.ReadFile(filepath.Join(root, "uploads", "1", "test.txt"))
+		asserts.NoError(err)
+		asserts.Equal("test input file", string(content))
+	}
+
+	// 目录与已有文件冲突
+	{
+		err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("test input file")), "uploads/1/test.txt/a.txt", 15)
+		asserts.Error(err)
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	asserts := assert.New(t)
+	handler, root, clean := newTestDriver(t)
+	defer clean()
+	ctx := context.Background()
+	asserts.NoError(ioutil.WriteFile(filepath.Join(root, "test.txt"), []byte("123456"), 0644))
+
+	// 成功，可随机读取
+	{
+		rs, err := handler.Get(ctx, "test.txt")
+		asserts.NoError(err)
+		_, err = rs.Seek(3, io.SeekStart)
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("456", string(content))
+		asserts.NoError(rs.Close())
+	}
+
+	// 文件不存在
+	{
+		rs, err := handler.Get(ctx, "not_exist.txt")
+		asserts.Error(err)
+		asserts.Nil(rs)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	handler, root, clean := newTestDriver(t)
+	defer clean()
+	ctx := context.Background()
+	asserts.NoError(ioutil.WriteFile(filepath.Join(root, "test.txt"), []byte("123"), 0644))
+
+	// 部分文件不存在
+	{
+		failed, err := handler.Delete(ctx, []string{"test.txt", "not_exist.txt"})
+		asserts.Error(err)
+		asserts.Equal([]string{"not_exist.txt"}, failed)
+		_, err = os.Stat(filepath.Join(root, "test.txt"))
+		asserts.True(os.IsNotExist(err))
+	}
+}
+
+func TestDriver_List(t *testing.T) {
+	asserts := assert.New(t)
+	handler, root, clean := newTestDriver(t)
+	defer clean()
+	ctx := context.Background()
+	asserts.NoError(os.MkdirAll(filepath.Join(root, "import", "sub"), 0755))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(root, "import", "a.txt"), []byte("123"), 0644))
+	asserts.NoError(ioutil.WriteFile(filepath.Join(root, "import", "sub", "b.txt"), []byte("1234"), 0644))
+
+	// 非递归
+	{
+		res, err := handler.List(ctx, "import", false)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+		for _, object := range res {
+			if object.IsDir {
+				asserts.Equal("sub", object.RelativePath)
+			} else {
+				asserts.Equal("a.txt", object.RelativePath)
+				asserts.Equal("import/a.txt", object.Source)
+				asserts.EqualValues(3, object.Size)
+			}
+		}
+	}
+
+	// 递归，列取结果可用于读取文件
+	{
+		res, err := handler.List(ctx, "import", true)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		var source string
+		for _, object := range res {
+			if object.Name == "b.txt" {
+				asserts.Equal("sub/b.txt", object.RelativePath)
+				source = object.Source
+			}
+		}
+		rs, err := handler.Get(ctx, source)
+		asserts.NoError(err)
+		rs.Close()
+	}
+
+	// 目录不存在
+	{
+		res, err := handler.List(ctx, "not_exist", true)
+		asserts.Error(err)
+		asserts.Empty(res)
+	}
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{}
+	res, err := handler.Thumb(context.Background(), "test.jpg")
+	asserts.Error(err)
+	asserts.Nil(res)
+}
+
+func TestDriver_Source(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{},
+	}
+	ctx := context.Background()
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+	file := model.File{
+		Model: gorm.Model{
+			ID: 1,
+		},
+		Name: "test.jpg",
+	}
+	baseURL, err := url.Parse("https://cloudreve.org")
+	asserts.NoError(err)
+
+	// 成功
+	{
+		ctx := context.WithValue(ctx, fsctx.FileModelCtx, file)
+		sourceURL, err := handler.Source(ctx, "", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "sign=")
+		asserts.Contains(sourceURL, "https://cloudreve.org/api/v3/file/get/1/test.jpg")
+	}
+
+	// 无法获取上下文
+	{
+		sourceURL, err := handler.Source(ctx, "", *baseURL, 0, false, 0)
+		asserts.Error(err)
+		asserts.Empty(sourceURL)
+	}
+
+	// 设定了CDN
+	{
+		handler.Policy.BaseURL = "https://cqu.edu.cn"
+		ctx := context.WithValue(ctx, fsctx.FileModelCtx, file)
+		sourceURL, err := handler.Source(ctx, "", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "https://cqu.edu.cn")
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{}
+	res, err := handler.Token(context.Background(), 10, "key")
+	asserts.NoError(err)
+	asserts.Empty(res)
+}
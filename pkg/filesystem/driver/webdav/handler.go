@@ -0,0 +1,250 @@
+package webdav
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Driver WebDAV策略适配器，作为客户端访问其他WebDAV服务器。
+// Server 为WebDAV服务的根地址，AccessKey、SecretKey 为认证用户名及密码
+type Driver struct {
+	Policy     *model.Policy
+	HTTPClient request.Client
+}
+
+// remoteURL 返回存储路径在服务器上的URL，isDir 为真时以 / 结尾
+func (handler Driver) remoteURL(p string, isDir bool) (string, error) {
+	server, err := url.Parse(handler.Policy.Server)
+	if err != nil {
+		return "", err
+	}
+
+	server.Path = path.Join("/", server.Path, filepath.ToSlash(p))
+	if isDir && !strings.HasSuffix(server.Path, "/") {
+		server.Path += "/"
+	}
+	return server.String(), nil
+}
+
+// request 向服务器发送请求，附带认证信息
+func (handler Driver) request(ctx context.Context, method, p string, isDir bool, body io.Reader, opts ...request.Option) *request.Response {
+	target, err := handler.remoteURL(p, isDir)
+	if err != nil {
+		return &request.Response{Err: err}
+	}
+
+	header := http.Header{}
+	if handler.Policy.AccessKey != "" {
+		credential := handler.Policy.AccessKey + ":" + handler.Policy.SecretKey
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credential)))
+	}
+
+	opts = append([]request.Option{request.WithContext(ctx), request.WithHeader(header)}, opts...)
+	return handler.HTTPClient.Request(method, target, body, opts...)
+}
+
+// checkStatus 检查响应状态码是否为 expected 之一，检查后关闭响应正文
+func checkStatus(resp *request.Response, expected ...int) error {
+	if resp.Err != nil {
+		return resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	for _, status := range expected {
+		if resp.Response.StatusCode == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("服务器返回非正常HTTP状态%d", resp.Response.StatusCode)
+}
+
+// mkdirAll 依次创建 dir 及其不存在的上级目录
+func (handler Driver) mkdirAll(ctx context.Context, dir string) error {
+	dir = path.Clean("/" + filepath.ToSlash(dir))
+	if dir == "/" {
+		return nil
+	}
+
+	// 目录已存在时无需创建
+	if checkStatus(handler.request(ctx, "PROPFIND", dir, true, nil,
+		request.WithHeader(http.Header{"Depth": {"0"}})), http.StatusMultiStatus) == nil {
+		return nil
+	}
+
+	if err := handler.mkdirAll(ctx, path.Dir(dir)); err != nil {
+		return err
+	}
+
+	// 已存在的目录返回 405
+	return checkStatus(handler.request(ctx, "MKCOL", dir, true, nil),
+		http.StatusCreated, http.StatusMethodNotAllowed)
+}
+
+// List 列取给定路径下的文件
+func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	var res []response.Object
+
+	dirs := []string{""}
+	for len(dirs) > 0 {
+		rel := dirs[0]
+		dirs = dirs[1:]
+
+		objects, err := handler.propfind(ctx, path.Join(base, rel))
+		if err != nil {
+			// 根目录无法列取时直接返回错误
+			if rel == "" {
+				return nil, err
+			}
+			util.Log().Warning("无法遍历目录 %s, %s", rel, err)
+			continue
+		}
+
+		for _, object := range objects {
+			object.RelativePath = path.Join(rel, object.Name)
+			object.Source = path.Join(base, object.RelativePath)
+			res = append(res, object)
+
+			// 如果递归，则步入目录
+			if recursive && object.IsDir {
+				dirs = append(dirs, object.RelativePath)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Get 获取文件内容，返回的文件流可通过 Range 请求随机读取
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	reader := &rangeReader{ctx: ctx, handler: handler, path: path, size: -1}
+	if err := reader.open(); err != nil {
+		util.Log().Debug("无法打开文件：%s", err)
+		return nil, err
+	}
+
+	// 服务器未返回大小时，尝试从文件记录获取
+	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok && reader.size < 0 {
+		reader.size = int64(file.Size)
+	}
+
+	return reader, nil
+}
+
+// Put 将文件流保存到指定目录
+func (handler Driver) Put(ctx context.Context, file io.ReadCloser, dst string, size uint64) error {
+	defer file.Close()
+
+	// 创建目标目录
+	if err := handler.mkdirAll(ctx, path.Dir(filepath.ToSlash(dst))); err != nil {
+		util.Log().Warning("无法创建目录，%s", err)
+		return err
+	}
+
+	return checkStatus(handler.request(ctx, "PUT", dst, false, file,
+		request.WithContentLength(int64(size)),
+		request.WithTimeout(time.Duration(0)),
+	), http.StatusOK, http.StatusCreated, http.StatusNoContent)
+}
+
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	deleteFailed := make([]string, 0, len(files))
+	var retErr error
+	for _, value := range files {
+		err := checkStatus(handler.request(ctx, "DELETE", value, false, nil),
+			http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+		if err != nil {
+			util.Log().Warning("无法删除文件，%s", err)
+			retErr = err
+			deleteFailed = append(deleteFailed, value)
+		}
+	}
+
+	return deleteFailed, retErr
+}
+
+// Thumb 获取文件缩略图
+func (handler Driver) Thumb(ctx context.Context, path string) (*response.ContentResponse, error) {
+	return nil, errors.New("未实现")
+}
+
+// Source 获取外链URL，文件内容由服务端中转
+func (handler Driver) Source(
+	ctx context.Context,
+	path string,
+	baseURL url.URL,
+	ttl int64,
+	isDownload bool,
+	speed int,
+) (string, error) {
+	file, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return "", errors.New("无法获取文件记录上下文")
+	}
+
+	// 是否启用了CDN
+	if handler.Policy.BaseURL != "" {
+		cdnURL, err := url.Parse(handler.Policy.BaseURL)
+		if err != nil {
+			return "", err
+		}
+		baseURL = *cdnURL
+	}
+
+	var (
+		signedURI *url.URL
+		err       error
+	)
+	if isDownload {
+		// 创建下载会话，将文件信息写入缓存
+		downloadSessionID := util.RandStringRunes(16)
+		err = cache.Set("download_"+downloadSessionID, file, int(ttl))
+		if err != nil {
+			return "", serializer.NewError(serializer.CodeCacheOperation, "无法创建下载会话", err)
+		}
+
+		// 签名生成文件记录
+		signedURI, err = auth.SignURI(
+			auth.General,
+			fmt.Sprintf("/api/v3/file/download/%s", downloadSessionID),
+			ttl,
+		)
+	} else {
+		// 签名生成文件记录
+		signedURI, err = auth.SignURI(
+			auth.General,
+			fmt.Sprintf("/api/v3/file/get/%d/%s", file.ID, file.Name),
+			ttl,
+		)
+	}
+
+	if err != nil {
+		return "", serializer.NewError(serializer.CodeEncryptError, "无法对URL进行签名", err)
+	}
+
+	finalURL := baseURL.ResolveReference(signedURI).String()
+	return finalURL, nil
+}
+
+// Token 获取上传策略和认证Token，WebDAV策略由服务端中转上传，直接返回空值
+func (handler Driver) Token(ctx context.Context, ttl int64, key string) (serializer.UploadCredential, error) {
+	return serializer.UploadCredential{}, nil
+}
@@ -0,0 +1,242 @@
+package webdav
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/auth"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestDriver 创建连接到内存WebDAV服务器的适配器，服务器根地址为 /dav/
+func newTestDriver() (Driver, *httptest.Server) {
+	dav := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	}))
+
+	return Driver{
+		Policy: &model.Policy{
+			Server:    server.URL + "/dav/",
+			AccessKey: "user",
+			SecretKey: "pass",
+		},
+		HTTPClient: request.HTTPClient{},
+	}, server
+}
+
+func put(handler Driver, dst, content string) error {
+	return handler.Put(context.Background(), ioutil.NopCloser(strings.NewReader(content)), dst, uint64(len(content)))
+}
+
+func TestDriver_Put(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server := newTestDriver()
+	defer server.Close()
+
+	// 成功，自动创建目录
+	{
+		asserts.NoError(put(handler, "uploads/1/test.txt", "test input file"))
+		asserts.NoError(put(handler, "uploads/1/test2.txt", "test input file"))
+		rs, err := handler.Get(context.Background(), "uploads/1/test.txt")
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("test input file", string(content))
+	}
+
+	// 认证失败
+	{
+		handler.Policy.SecretKey = "wrong"
+		asserts.Error(put(handler, "uploads/1/test.txt", "test input file"))
+	}
+}
+
+func TestDriver_Get(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server := newTestDriver()
+	defer server.Close()
+	ctx := context.Background()
+	asserts.NoError(put(handler, "test.txt", "123456"))
+
+	// 成功，可随机读取
+	{
+		rs, err := handler.Get(ctx, "test.txt")
+		asserts.NoError(err)
+
+		size, err := rs.Seek(0, io.SeekEnd)
+		asserts.NoError(err)
+		asserts.EqualValues(6, size)
+
+		_, err = rs.Seek(3, io.SeekStart)
+		asserts.NoError(err)
+		content, err := ioutil.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("456", string(content))
+
+		_, err = rs.Seek(-5, io.SeekCurrent)
+		asserts.NoError(err)
+		buf := make([]byte, 2)
+		_, err = io.ReadFull(rs, buf)
+		asserts.NoError(err)
+		asserts.Equal("23", string(buf))
+		asserts.NoError(rs.Close())
+	}
+
+	// 文件不存在
+	{
+		rs, err := handler.Get(ctx, "not_exist.txt")
+		asserts.Error(err)
+		asserts.Nil(rs)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server := newTestDriver()
+	defer server.Close()
+	ctx := context.Background()
+	asserts.NoError(put(handler, "test.txt", "123"))
+
+	// 成功，不存在的文件视为已删除
+	{
+		failed, err := handler.Delete(ctx, []string{"test.txt", "not_exist.txt"})
+		asserts.NoError(err)
+		asserts.Empty(failed)
+		_, err = handler.Get(ctx, "test.txt")
+		asserts.Error(err)
+	}
+
+	// 认证失败
+	{
+		handler.Policy.SecretKey = "wrong"
+		failed, err := handler.Delete(ctx, []string{"test.txt"})
+		asserts.Error(err)
+		asserts.Equal([]string{"test.txt"}, failed)
+	}
+}
+
+func TestDriver_List(t *testing.T) {
+	asserts := assert.New(t)
+	handler, server := newTestDriver()
+	defer server.Close()
+	ctx := context.Background()
+	asserts.NoError(put(handler, "import/a.txt", "123"))
+	asserts.NoError(put(handler, "import/sub dir/b.txt", "1234"))
+
+	// 非递归
+	{
+		res, err := handler.List(ctx, "import", false)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+		for _, object := range res {
+			if object.IsDir {
+				asserts.Equal("sub dir", object.RelativePath)
+			} else {
+				asserts.Equal("a.txt", object.RelativePath)
+				asserts.Equal("import/a.txt", object.Source)
+				asserts.EqualValues(3, object.Size)
+			}
+		}
+	}
+
+	// 递归，列取结果可用于读取文件
+	{
+		res, err := handler.List(ctx, "import", true)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		var source string
+		for _, object := range res {
+			if object.Name == "b.txt" {
+				asserts.Equal("sub dir/b.txt", object.RelativePath)
+				source = object.Source
+			}
+		}
+		rs, err := handler.Get(ctx, source)
+		asserts.NoError(err)
+		rs.Close()
+	}
+
+	// 目录不存在
+	{
+		res, err := handler.List(ctx, "not_exist", true)
+		asserts.Error(err)
+		asserts.Empty(res)
+	}
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{}
+	res, err := handler.Thumb(context.Background(), "test.jpg")
+	asserts.Error(err)
+	asserts.Nil(res)
+}
+
+func TestDriver_Source(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{
+		Policy: &model.Policy{},
+	}
+	ctx := context.Background()
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+	file := model.File{
+		Model: gorm.Model{
+			ID: 1,
+		},
+		Name: "test.jpg",
+	}
+	baseURL, err := url.Parse("https://cloudreve.org")
+	asserts.NoError(err)
+
+	// 成功
+	{
+		ctx := context.WithValue(ctx, fsctx.FileModelCtx, file)
+		sourceURL, err := handler.Source(ctx, "", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "sign=")
+		asserts.Contains(sourceURL, "https://cloudreve.org/api/v3/file/get/1/test.jpg")
+	}
+
+	// 无法获取上下文
+	{
+		sourceURL, err := handler.Source(ctx, "", *baseURL, 0, false, 0)
+		asserts.Error(err)
+		asserts.Empty(sourceURL)
+	}
+
+	// 设定了CDN
+	{
+		handler.Policy.BaseURL = "https://cqu.edu.cn"
+		ctx := context.WithValue(ctx, fsctx.FileModelCtx, file)
+		sourceURL, err := handler.Source(ctx, "", *baseURL, 0, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(sourceURL, "https://cqu.edu.cn")
+	}
+}
+
+func TestDriver_Token(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{}
+	res, err := handler.Token(context.Background(), 10, "key")
+	asserts.NoError(err)
+	asserts.Empty(res)
+}
@@ -0,0 +1,96 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// propfindBody 列取目录时请求的属性
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/><D:getlastmodified/></D:prop></D:propfind>`
+
+// multistatus PROPFIND 响应
+type multistatus struct {
+	Responses []propResponse `xml:"DAV: response"`
+}
+
+type propResponse struct {
+	Href     string     `xml:"DAV: href"`
+	Propstat []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Status string `xml:"DAV: status"`
+	Prop   struct {
+		ResourceType struct {
+			Collection *struct{} `xml:"DAV: collection"`
+		} `xml:"DAV: resourcetype"`
+		ContentLength uint64 `xml:"DAV: getcontentlength"`
+		LastModified  string `xml:"DAV: getlastmodified"`
+	} `xml:"DAV: prop"`
+}
+
+// propfind 列取目录 dir 下的直接子对象，返回对象的 RelativePath 及 Source 未设定
+func (handler Driver) propfind(ctx context.Context, dir string) ([]response.Object, error) {
+	resp := handler.request(ctx, "PROPFIND", dir, true, strings.NewReader(propfindBody),
+		request.WithHeader(http.Header{
+			"Depth":        {"1"},
+			"Content-Type": {"application/xml; charset=utf-8"},
+		}),
+	).CheckHTTPResponse(http.StatusMultiStatus)
+	if resp.Err != nil {
+		if resp.Response != nil {
+			resp.Response.Body.Close()
+		}
+		return nil, resp.Err
+	}
+	defer resp.Response.Body.Close()
+
+	var res multistatus
+	if err := xml.NewDecoder(resp.Response.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	// 请求的目录在服务器上的路径
+	target, _ := handler.remoteURL(dir, true)
+	targetURL, _ := url.Parse(target)
+	dirPath := path.Clean(targetURL.Path)
+
+	objects := make([]response.Object, 0, len(res.Responses))
+	for _, item := range res.Responses {
+		href, err := url.Parse(item.Href)
+		if err != nil {
+			continue
+		}
+
+		// 跳过目录本身
+		itemPath := path.Clean(href.Path)
+		if itemPath == dirPath || path.Dir(itemPath) != dirPath {
+			continue
+		}
+
+		object := response.Object{Name: path.Base(itemPath)}
+		for _, stat := range item.Propstat {
+			if !strings.Contains(stat.Status, " 200 ") {
+				continue
+			}
+			object.IsDir = stat.Prop.ResourceType.Collection != nil
+			object.Size = stat.Prop.ContentLength
+			if modified, err := http.ParseTime(stat.Prop.LastModified); err == nil {
+				object.LastModify = modified
+			} else {
+				object.LastModify = time.Now()
+			}
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,106 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"io"
+	"net/http"
+	"time"
+)
+
+// rangeReader 可随机读取的远程文件流，Seek 后的读取通过 Range 请求获取
+type rangeReader struct {
+	ctx     context.Context
+	handler Driver
+	path    string
+
+	body   io.ReadCloser
+	offset int64
+	size   int64
+}
+
+// open 从当前位置开始请求文件内容
+func (reader *rangeReader) open() error {
+	var opts []request.Option
+	expected := http.StatusOK
+	if reader.offset > 0 {
+		opts = append(opts, request.WithHeader(http.Header{
+			"Range": {fmt.Sprintf("bytes=%d-", reader.offset)},
+		}))
+		expected = http.StatusPartialContent
+	}
+	opts = append(opts, request.WithTimeout(time.Duration(0)))
+
+	resp := reader.handler.request(reader.ctx, "GET", reader.path, false, nil, opts...).
+		CheckHTTPResponse(expected)
+	if resp.Err != nil {
+		if resp.Response != nil {
+			resp.Response.Body.Close()
+		}
+		return resp.Err
+	}
+
+	if reader.offset == 0 && resp.Response.ContentLength >= 0 {
+		reader.size = resp.Response.ContentLength
+	}
+	reader.body = resp.Response.Body
+	return nil
+}
+
+// Read 实现 io.Reader
+func (reader *rangeReader) Read(p []byte) (int, error) {
+	if reader.size >= 0 && reader.offset >= reader.size {
+		return 0, io.EOF
+	}
+
+	if reader.body == nil {
+		if err := reader.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := reader.body.Read(p)
+	reader.offset += int64(n)
+	return n, err
+}
+
+// Seek 实现 io.Seeker，位置改变时关闭当前请求
+func (reader *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	var target int64
+	switch whence {
+	case io.SeekStart:
+		target = offset
+	case io.SeekCurrent:
+		target = reader.offset + offset
+	case io.SeekEnd:
+		if reader.size < 0 {
+			return 0, errors.New("无法获取文件大小")
+		}
+		target = reader.size + offset
+	default:
+		return 0, errors.New("无效的 whence")
+	}
+
+	if target < 0 {
+		return 0, errors.New("无效的偏移量")
+	}
+
+	if target != reader.offset && reader.body != nil {
+		reader.body.Close()
+		reader.body = nil
+	}
+	reader.offset = target
+	return target, nil
+}
+
+// Close 实现 io.Closer
+func (reader *rangeReader) Close() error {
+	if reader.body == nil {
+		return nil
+	}
+	err := reader.body.Close()
+	reader.body = nil
+	return err
+}
@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+)
+
+/* ================
+	 存储加密相关
+   ================
+*/
+
+// getContentEncrypted 返回新写入的物理文件是否加密存储，上下文未指定时由存储策略 policy 决定
+func getContentEncrypted(ctx context.Context, policy *model.Policy) bool {
+	if encrypted, ok := ctx.Value(fsctx.EncryptedCtx).(bool); ok {
+		return encrypted
+	}
+	return policy.IsEncrypted()
+}
+
+// EncryptFile 使用已开启加密存储的原存储策略重新写入文件，并将共用同一物理文件的
+// 记录指向加密后的物理文件，原物理文件随后删除。已加密的文件会被跳过，
+// 此时返回的 encrypted 为假
+func (fs *FileSystem) EncryptFile(ctx context.Context, file *model.File) (encrypted bool, err error) {
+	policy := file.GetPolicy()
+	if !policy.OptionsSerialized.Encrypt {
+		return false, ErrEncryptNotEnabled
+	}
+
+	if file.Encrypted {
+		return false, nil
+	}
+
+	if err := fs.MigrateFile(ctx, file, policy, true); err != nil {
+		return false, err
+	}
+
+	// 本机策略的缩略图以明文保存，删除后重新生成
+	if policy.Type == "local" && file.PicInfo != "" {
+		fs.Handler.Delete(ctx, []string{fs.GetThumbPath(file)})
+		fs.GenerateThumbnail(ctx, file)
+	}
+
+	return true, nil
+}
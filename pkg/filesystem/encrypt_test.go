@@ -0,0 +1,96 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFileSystem_EncryptFile(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	newFile := func(policy model.Policy) *model.File {
+		return &model.File{
+			Model:      gorm.Model{ID: 1},
+			Name:       "a.txt",
+			SourceName: "old/a.txt",
+			UserID:     1,
+			FolderID:   1,
+			Size:       3,
+			PolicyID:   1,
+			Policy:     policy,
+		}
+	}
+	encryptedPolicy := model.Policy{
+		Model:             gorm.Model{ID: 1},
+		Type:              "mock",
+		DirNameRule:       "uploads",
+		FileNameRule:      "{originname}",
+		OptionsSerialized: model.PolicyOption{Encrypt: true},
+	}
+
+	// 存储策略未开启加密
+	{
+		file := newFile(model.Policy{Model: gorm.Model{ID: 1}, Type: "mock"})
+		encrypted, err := fs.EncryptFile(ctx, file)
+		asserts.Equal(ErrEncryptNotEnabled, err)
+		asserts.False(encrypted)
+	}
+
+	// 成功，在同一存储策略下重新写入并删除原文件
+	{
+		file := newFile(encryptedPolicy)
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "old/a.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		testHandler.On("Put", testMock.Anything, testMock.Anything, "uploads/a.txt").Run(func(args testMock.Arguments) {
+			ioutil.ReadAll(args.Get(1).(io.Reader))
+		}).Return(nil)
+		testHandler.On("Delete", testMock.Anything, []string{"old/a.txt"}).Return([]string{}, nil)
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(false, 1, "uploads/a.txt", 1, "old/a.txt").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE(.+)file_replicas(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		encrypted, err := fs.EncryptFile(ctx, file)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.True(encrypted)
+		asserts.Equal("uploads/a.txt", file.SourceName)
+	}
+
+	// 已加密的文件被跳过
+	{
+		policy := encryptedPolicy
+		policy.Type = "local"
+		file := newFile(policy)
+		file.Encrypted = true
+		encrypted, err := fs.EncryptFile(ctx, file)
+		asserts.NoError(err)
+		asserts.False(encrypted)
+		asserts.Equal("old/a.txt", file.SourceName)
+	}
+
+	// 本机策略下文件不存在
+	{
+		policy := encryptedPolicy
+		policy.Type = "local"
+		file := newFile(policy)
+		file.SourceName = "TestFileSystem_EncryptFile_notExist.txt"
+		encrypted, err := fs.EncryptFile(ctx, file)
+		asserts.Error(err)
+		asserts.False(encrypted)
+	}
+}
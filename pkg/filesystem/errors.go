@@ -13,6 +13,7 @@ var (
 	ErrIllegalObjectName       = errors.New("目标名称非法")
 	ErrClientCanceled          = errors.New("客户端取消操作")
 	ErrRootProtected           = errors.New("无法对根目录进行操作")
+	ErrReservedName            = errors.New("根目录下不能使用“与我共享”作为名称")
 	ErrInsertFileRecord        = serializer.NewError(serializer.CodeDBError, "无法插入文件记录", nil)
 	ErrFileExisted             = serializer.NewError(serializer.CodeObjectExist, "同名文件或目录已存在", nil)
 	ErrFolderExisted           = serializer.NewError(serializer.CodeObjectExist, "同名目录已存在", nil)
@@ -21,4 +22,16 @@ var (
 	ErrIO                      = serializer.NewError(serializer.CodeIOFailed, "无法读取文件数据", nil)
 	ErrDBListObjects           = serializer.NewError(serializer.CodeDBError, "无法列取对象记录", nil)
 	ErrDBDeleteObjects         = serializer.NewError(serializer.CodeDBError, "无法删除对象记录", nil)
+	ErrUploadSessionNotExist   = serializer.NewError(404, "上传会话不存在或已过期", nil)
+	ErrChunkPolicyNotAllowed   = serializer.NewError(serializer.CodePolicyNotAllowed, "当前存储策略不支持分片上传", nil)
+	ErrChunkIndexOutOfRange    = serializer.NewError(serializer.CodeParamErr, "分片序号超出范围", nil)
+	ErrChunkSizeMismatch       = serializer.NewError(serializer.CodeParamErr, "分片大小与预期不符", nil)
+	ErrChunkMissing            = serializer.NewError(serializer.CodeParamErr, "仍有分片未上传", nil)
+	ErrSharedReadOnly          = serializer.NewError(serializer.CodeNoPermissionErr, "没有写入此共享目录的权限", nil)
+	ErrSharedOwnerMismatch     = serializer.NewError(serializer.CodeNoPermissionErr, "无法在共享目录与其他目录之间操作", nil)
+	ErrMigrateSizeMismatch     = serializer.NewError(serializer.CodeIOFailed, "迁移后的文件大小与原文件不符", nil)
+	ErrMigrateFileRecord       = serializer.NewError(serializer.CodeDBError, "无法更新文件记录", nil)
+	ErrReplicaNotExist         = serializer.NewError(404, "文件没有可用的副本", nil)
+	ErrEncryptNotEnabled       = serializer.NewError(serializer.CodePolicyNotAllowed, "存储策略未开启加密存储", nil)
+	ErrUnknownArchive          = serializer.NewError(serializer.CodeParamErr, "不支持此格式的压缩文件", nil)
 )
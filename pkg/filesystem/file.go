@@ -56,6 +56,28 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder) (*model
 	file := ctx.Value(fsctx.FileHeaderCtx).(FileHeader)
 	filePath := ctx.Value(fsctx.SavePathCtx).(string)
 
+	// 覆盖同名文件，原有内容保存为历史版本
+	if fs.IsVersionEnabled() {
+		if exist, originFile := fs.IsChildFileExist(parent, file.GetFileName()); exist {
+			encrypted := getContentEncrypted(ctx, &fs.User.Policy)
+			err := fs.replaceWithVersion(ctx, originFile, filePath, getContentHash(ctx), file.GetSize(), fs.User.Policy.ID, encrypted)
+			if err != nil {
+				if err := fs.Trigger(ctx, "AfterValidateFailed"); err != nil {
+					util.Log().Debug("AfterValidateFailed 钩子执行失败，%s", err)
+				}
+				return nil, err
+			}
+
+			originFile.SourceName = filePath
+			originFile.Hash = getContentHash(ctx)
+			originFile.Size = file.GetSize()
+			originFile.PolicyID = fs.User.Policy.ID
+			originFile.Encrypted = encrypted
+			indexFile(originFile, &fs.User.Policy, true)
+			return originFile, nil
+		}
+	}
+
 	newFile := model.File{
 		Name:       file.GetFileName(),
 		SourceName: filePath,
@@ -64,6 +86,8 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder) (*model
 		FolderID:   parent.ID,
 		PolicyID:   fs.User.Policy.ID,
 		AccessDate: time.Now(),
+		Hash:       getContentHash(ctx),
+		Encrypted:  getContentEncrypted(ctx, &fs.User.Policy),
 	}
 
 	if fs.User.Policy.IsThumbExist(file.GetFileName()) {
@@ -79,6 +103,12 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder) (*model
 		return nil, ErrFileExisted.WithError(err)
 	}
 
+	// 相同存储策略下内容相同的文件共用物理文件
+	if newFile.Hash != "" && model.IsTrueVal(model.GetSettingByName("upload_dedup")) {
+		fs.deduplicate(ctx, &newFile)
+	}
+
+	indexFile(&newFile, &fs.User.Policy, false)
 	return &newFile, nil
 }
 
@@ -168,9 +198,12 @@ func (fs *FileSystem) GetContent(ctx context.Context, id uint) (response.RSClose
 	}
 	ctx = context.WithValue(ctx, fsctx.FileModelCtx, fs.FileTarget[0])
 
-	// 获取文件流
+	// 获取文件流，失败时尝试从副本读取
 	rs, err := fs.Handler.Get(ctx, fs.FileTarget[0].SourceName)
 	if err != nil {
+		if replicaRs, replicaErr := fs.getReplicaContent(ctx, &fs.FileTarget[0]); replicaErr == nil {
+			return replicaRs, nil
+		}
 		return nil, ErrIO.WithError(err)
 	}
 
@@ -206,6 +239,17 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 		failedFile, _ := fs.Handler.Delete(ctx, sourceNames)
 		failed[policyID] = failedFile
 
+		// 删除已删除文件的副本
+		deleted := make([]string, 0, len(sourceNames))
+		for _, source := range sourceNames {
+			if !util.ContainsString(failedFile, source) {
+				deleted = append(deleted, source)
+			}
+		}
+		if len(deleted) > 0 {
+			fs.deleteReplicas(ctx, policyID, deleted)
+		}
+
 	}
 
 	return failed
@@ -291,6 +335,10 @@ func (fs *FileSystem) signURL(ctx context.Context, file *model.File, ttl int64,
 	siteURL := model.GetSiteURL()
 	source, err := fs.Handler.Source(ctx, fs.FileTarget[0].SourceName, *siteURL, ttl, isDownload, fs.User.Group.SpeedLimit)
 	if err != nil {
+		// 尝试获取副本的外链
+		if replicaSource, replicaErr := fs.getReplicaSource(ctx, &fs.FileTarget[0], *siteURL, ttl, isDownload); replicaErr == nil {
+			return replicaSource, nil
+		}
 		return "", serializer.NewError(serializer.CodeNotSet, "无法获取外链", err)
 	}
 
@@ -318,7 +366,12 @@ func (fs *FileSystem) resetFileIDIfNotExist(ctx context.Context, id uint) error
 	if len(fs.FileTarget) == 0 {
 		file, err := model.GetFilesByIDs([]uint{id}, fs.User.ID)
 		if err != nil || len(file) == 0 {
-			return ErrObjectNotExist
+			// 尝试作为共享给当前用户的文件读取
+			shared, err := fs.sharedFile(id)
+			if err != nil {
+				return err
+			}
+			file = []model.File{*shared}
 		}
 		fs.FileTarget = []model.File{file[0]}
 	}
@@ -18,7 +18,9 @@ import (
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/qiniu"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/remote"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/s3"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/sftp"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/upyun"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/webdav"
 	"github.com/HFO4/cloudreve/pkg/filesystem/response"
 	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
@@ -85,6 +87,8 @@ type FileSystem struct {
 	DirTarget []model.Folder
 	// 相对根目录
 	Root *model.Folder
+	// 代替目录所有者执行操作的用户，如通过“与我共享”操作或向文件收集上传时，此时 User 为目录所有者
+	Operator *model.User
 	// 互斥锁
 	Lock sync.Mutex
 
@@ -114,6 +118,7 @@ func (fs *FileSystem) Recycle() {
 // reset 重设文件系统，以便回收使用
 func (fs *FileSystem) reset() {
 	fs.User = nil
+	fs.Operator = nil
 	fs.CleanTargets()
 	fs.Policy = nil
 	fs.Hooks = nil
@@ -226,6 +231,18 @@ func (fs *FileSystem) DispatchHandler() error {
 			Policy: currentPolicy,
 		}
 		return nil
+	case "sftp":
+		fs.Handler = sftp.Driver{
+			Policy: currentPolicy,
+			Client: sftp.NewClient(currentPolicy),
+		}
+		return nil
+	case "webdav":
+		fs.Handler = webdav.Driver{
+			Policy:     currentPolicy,
+			HTTPClient: request.HTTPClient{},
+		}
+		return nil
 	default:
 		return ErrUnknownPolicyType
 	}
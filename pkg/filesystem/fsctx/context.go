@@ -35,4 +35,8 @@ const (
 	IgnoreConflictCtx
 	// RetryCtx 失败重试次数
 	RetryCtx
+	// ProgressCtx 后台任务的进度报告器
+	ProgressCtx
+	// EncryptedCtx 新增文件的物理文件是否加密存储，未指定时由存储策略决定
+	EncryptedCtx
 )
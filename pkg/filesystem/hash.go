@@ -0,0 +1,85 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"hash"
+)
+
+/* =================
+	 文件内容摘要
+   =================
+*/
+
+// hashFileHeader 在读取上传文件的同时计算内容的 SHA-256 摘要
+type hashFileHeader struct {
+	FileHeader
+	hash hash.Hash
+	read uint64
+}
+
+// Read 读取文件内容并更新摘要
+func (file *hashFileHeader) Read(p []byte) (int, error) {
+	n, err := file.FileHeader.Read(p)
+	if n > 0 {
+		file.hash.Write(p[:n])
+		file.read += uint64(n)
+	}
+	return n, err
+}
+
+// Hash 返回十六进制表示的内容摘要，文件未被完整读取时返回空字符串
+func (file *hashFileHeader) Hash() string {
+	if file.read != file.GetSize() {
+		return ""
+	}
+	return hex.EncodeToString(file.hash.Sum(nil))
+}
+
+// withContentHash 为由服务端中转的上传（本机、从机、SFTP、WebDAV存储策略）包装摘要计算，
+// 其他存储策略直接返回原文件
+func (fs *FileSystem) withContentHash(file FileHeader) FileHeader {
+	if fs.Policy == nil {
+		return file
+	}
+
+	switch fs.Policy.Type {
+	case "local", "remote", "sftp", "webdav":
+		return &hashFileHeader{FileHeader: file, hash: sha256.New()}
+	default:
+		return file
+	}
+}
+
+// getContentHash 从上下文中获取已上传文件的内容摘要
+func getContentHash(ctx context.Context) string {
+	if file, ok := ctx.Value(fsctx.FileHeaderCtx).(*hashFileHeader); ok {
+		return file.Hash()
+	}
+	return ""
+}
+
+// deduplicate 同一存储策略下已有相同内容的物理文件时，
+// 将 file 指向已有的物理文件，并删除本次上传的副本。
+// 物理文件由所有引用它的文件共同持有，删除时由 RemoveFilesWithSoftLinks 保留
+func (fs *FileSystem) deduplicate(ctx context.Context, file *model.File) {
+	existed, err := model.GetFileByHash(file.Hash, file.Size, file.PolicyID, file.SourceName)
+	if err != nil {
+		return
+	}
+
+	uploaded, encrypted := file.SourceName, file.Encrypted
+	if err := file.UpdateSource(existed.SourceName, existed.Encrypted); err != nil {
+		file.SourceName, file.Encrypted = uploaded, encrypted
+		util.Log().Warning("无法将文件[%d]指向已有的物理文件, %s", file.ID, err)
+		return
+	}
+
+	if failed, err := fs.Handler.Delete(ctx, []string{uploaded}); err != nil {
+		util.Log().Warning("无法删除重复的物理文件 %v, %s", failed, err)
+	}
+}
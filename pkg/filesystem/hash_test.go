@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFileSystem_withContentHash(t *testing.T) {
+	asserts := assert.New(t)
+	file := local.FileStream{
+		File: ioutil.NopCloser(strings.NewReader("123")),
+		Size: 3,
+	}
+
+	// 未设定存储策略
+	{
+		fs := &FileSystem{}
+		asserts.Equal(file, fs.withContentHash(file))
+	}
+
+	// 不经服务端中转的存储策略
+	{
+		fs := &FileSystem{Policy: &model.Policy{Type: "oss"}}
+		asserts.Equal(file, fs.withContentHash(file))
+	}
+
+	// 本机存储策略
+	{
+		fs := &FileSystem{Policy: &model.Policy{Type: "local"}}
+		wrapped := fs.withContentHash(file)
+		ctx := context.WithValue(context.Background(), fsctx.FileHeaderCtx, wrapped)
+
+		// 未读取完成
+		asserts.Empty(getContentHash(ctx))
+
+		content, err := ioutil.ReadAll(wrapped)
+		asserts.NoError(err)
+		asserts.Equal("123", string(content))
+		sum := sha256.Sum256([]byte("123"))
+		asserts.Equal(hex.EncodeToString(sum[:]), getContentHash(ctx))
+	}
+}
+
+func TestGetContentHash(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Empty(getContentHash(context.Background()))
+	asserts.Empty(getContentHash(context.WithValue(context.Background(), fsctx.FileHeaderCtx, local.FileStream{})))
+}
+
+func TestFileSystem_deduplicate(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 无相同内容的文件
+	{
+		fs := &FileSystem{}
+		file := &model.File{SourceName: "new", Hash: "abc", Size: 3, PolicyID: 1}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("abc", 3, 1, "new").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}))
+		fs.deduplicate(context.Background(), file)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("new", file.SourceName)
+	}
+
+	// 指向已有物理文件并删除副本
+	{
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Delete", testMock.Anything, []string{"new"}).Return([]string{}, nil)
+		fs := &FileSystem{Handler: testHandler}
+		file := &model.File{SourceName: "new", Hash: "abc", Size: 3, PolicyID: 1}
+		file.ID = 2
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("abc", 3, 1, "new").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "encrypted"}).AddRow(1, "old", true))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(true, "old", sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		fs.deduplicate(context.Background(), file)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.Equal("old", file.SourceName)
+		asserts.True(file.Encrypted)
+	}
+
+	// 更新记录失败，保留副本
+	{
+		fs := &FileSystem{}
+		file := &model.File{SourceName: "new", Hash: "abc", Size: 3, PolicyID: 1}
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs("abc", 3, 1, "new").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(1, "old"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		fs.deduplicate(context.Background(), file)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("new", file.SourceName)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"io/ioutil"
+	"path"
 	"strings"
 )
 
@@ -98,6 +100,9 @@ func HookValidateFile(ctx context.Context, fs *FileSystem) error {
 	if !fs.ValidateLegalName(ctx, file.GetFileName()) {
 		return ErrIllegalObjectName
 	}
+	if fs.Root == nil && path.Join(file.GetVirtualPath(), file.GetFileName()) == SharedRootPath {
+		return ErrReservedName
+	}
 
 	// 验证扩展名
 	if !fs.ValidateExtension(ctx, file.GetFileName()) {
@@ -181,6 +186,13 @@ func HookClearFileSize(ctx context.Context, fs *FileSystem) error {
 	if !ok {
 		return ErrObjectNotExist
 	}
+
+	// 清空后的内容由 HookCleanFileContent 按存储策略当前的设置写入
+	if encrypted := fs.Policy.IsEncrypted(); encrypted != originFile.Encrypted {
+		if err := originFile.UpdateEncrypted(encrypted); err != nil {
+			return err
+		}
+	}
 	return originFile.UpdateSize(0)
 }
 
@@ -205,6 +217,25 @@ func HookUpdateSourceName(ctx context.Context, fs *FileSystem) error {
 	return originFile.UpdateSourceName(originFile.SourceName)
 }
 
+// HookSaveFileVersion 将被覆盖文件的原有内容保存为历史版本，
+// 并将文件指向新上传的物理文件
+func HookSaveFileVersion(ctx context.Context, fs *FileSystem) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+	newFile := ctx.Value(fsctx.FileHeaderCtx).(FileHeader)
+	savePath := ctx.Value(fsctx.SavePathCtx).(string)
+
+	// 数据库中的记录仍指向原有内容
+	file, err := fs.getVersionedFile(originFile.ID)
+	if err != nil {
+		return err
+	}
+
+	return fs.replaceWithVersion(ctx, file, savePath, getContentHash(ctx), newFile.GetSize(), fs.Policy.ID, fs.Policy.IsEncrypted())
+}
+
 // GenericAfterUpdate 文件内容更新后
 func GenericAfterUpdate(ctx context.Context, fs *FileSystem) error {
 	// 更新文件尺寸
@@ -224,6 +255,21 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem) error {
 		return err
 	}
 
+	// 更新内容摘要
+	if hash := getContentHash(ctx); hash != "" || originFile.Hash != "" {
+		if err := originFile.UpdateHash(hash); err != nil {
+			return err
+		}
+	}
+
+	// 新内容按存储策略当前的设置决定是否加密
+	if encrypted := fs.Policy.IsEncrypted(); encrypted != originFile.Encrypted {
+		if err := originFile.UpdateEncrypted(encrypted); err != nil {
+			return err
+		}
+		originFile.Encrypted = encrypted
+	}
+
 	// 尝试清空原有缩略图并重新生成
 	if originFile.GetPolicy().IsThumbGenerateNeeded() {
 		go func() {
@@ -234,6 +280,10 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem) error {
 		}()
 	}
 
+	// 文件内容已变化，重新建立索引
+	indexFile(&originFile, originFile.GetPolicy(), true)
+
+	audit.RecordContext(ctx, fs.User, audit.ActionUpdate, path.Join(originFile.Position, originFile.Name), nil)
 	return nil
 }
 
@@ -246,6 +296,7 @@ func SlaveAfterUpload(ctx context.Context, fs *FileSystem) error {
 	file := model.File{
 		Name:       fileHeader.GetFileName(),
 		SourceName: ctx.Value(fsctx.SavePathCtx).(string),
+		Encrypted:  policy.Encrypt,
 	}
 	fs.GenerateThumbnail(ctx, &file)
 
@@ -278,11 +329,11 @@ func GenericAfterUpload(ctx context.Context, fs *FileSystem) error {
 		folder = newFolder
 	}
 
-	// 检查文件是否存在
+	// 检查文件是否存在，保留历史版本时同名文件会被覆盖
 	if ok, _ := fs.IsChildFileExist(
 		folder,
 		ctx.Value(fsctx.FileHeaderCtx).(FileHeader).GetFileName(),
-	); ok {
+	); ok && !fs.IsVersionEnabled() {
 		return ErrFileExisted
 	}
 
@@ -298,5 +349,6 @@ func GenericAfterUpload(ctx context.Context, fs *FileSystem) error {
 		go fs.GenerateThumbnail(ctx, file)
 	}
 
+	audit.RecordContext(ctx, fs.User, audit.ActionUpload, path.Join(virtualPath, file.Name), nil)
 	return nil
 }
@@ -59,6 +59,11 @@ func TestGenericBeforeUpload(t *testing.T) {
 	file.Name = "1.t/xt"
 	ctx = context.WithValue(context.Background(), fsctx.FileHeaderCtx, file)
 	asserts.Error(HookValidateFile(ctx, &fs))
+
+	file.Name = SharedFolderName
+	file.VirtualPath = "/"
+	ctx = context.WithValue(context.Background(), fsctx.FileHeaderCtx, file)
+	asserts.Equal(ErrReservedName, HookValidateFile(ctx, &fs))
 }
 
 func TestGenericAfterUploadCanceled(t *testing.T) {
@@ -386,7 +391,7 @@ func TestHookClearFileSize(t *testing.T) {
 	asserts := assert.New(t)
 	fs := &FileSystem{User: &model.User{
 		Model: gorm.Model{ID: 1},
-	}}
+	}, Policy: &model.Policy{Type: "local"}}
 
 	// 成功
 	{
@@ -405,6 +410,30 @@ func TestHookClearFileSize(t *testing.T) {
 		asserts.NoError(err)
 	}
 
+	// 成功，存储策略开启加密
+	{
+		ctx := context.WithValue(
+			context.Background(),
+			fsctx.FileModelCtx,
+			model.File{Model: gorm.Model{ID: 1}},
+		)
+		fs.Policy.OptionsSerialized.Encrypt = true
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)encrypted(.+)").
+			WithArgs(true, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").
+			WithArgs(0, sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		err := HookClearFileSize(ctx, fs)
+		fs.Policy.OptionsSerialized.Encrypt = false
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+
 	// 上下文对象不存在
 	{
 		ctx := context.Background()
@@ -447,7 +476,7 @@ func TestGenericAfterUpdate(t *testing.T) {
 	asserts := assert.New(t)
 	fs := &FileSystem{User: &model.User{
 		Model: gorm.Model{ID: 1},
-	}}
+	}, Policy: &model.Policy{Type: "local"}}
 
 	// 成功 是图像文件
 	{
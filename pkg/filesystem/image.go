@@ -1,14 +1,17 @@
 package filesystem
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/filesystem/response"
 	"github.com/HFO4/cloudreve/pkg/thumb"
 	"github.com/HFO4/cloudreve/pkg/util"
+	"io/ioutil"
 	"path/filepath"
 	"strconv"
 )
@@ -61,6 +64,7 @@ func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 	// 新建上下文
 	newCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	newCtx = context.WithValue(newCtx, fsctx.FileModelCtx, *file)
 
 	// 获取文件数据
 	source, err := fs.Handler.Get(newCtx, file.SourceName)
@@ -96,7 +100,7 @@ func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 		image.GetThumb(fs.GenerateThumbnailSize(w, h))
 		// 保存到文件
 		//err = image.Save(util.RelativePath(file.SourceName + conf.ThumbConfig.FileSuffix))
-		err = image.Save(util.RelativePath(fs.GetThumbPath(file)))
+		err = fs.saveThumb(newCtx, image, file)
 		if err != nil {
 			util.Log().Warning("无法保存缩略图：%s", err)
 			return
@@ -118,6 +122,21 @@ func (fs *FileSystem) GenerateThumbnail(ctx context.Context, file *model.File) {
 	}
 }
 
+// saveThumb 保存缩略图，本机或从机上加密存储的文件，其缩略图同样加密写入
+func (fs *FileSystem) saveThumb(ctx context.Context, image *thumb.Thumb, file *model.File) error {
+	if _, ok := fs.Handler.(local.Driver); ok && file.Encrypted {
+		var buf bytes.Buffer
+		if err := image.Encode(&buf); err != nil {
+			return err
+		}
+		size := uint64(buf.Len())
+		handler := local.Driver{Policy: &model.Policy{OptionsSerialized: model.PolicyOption{Encrypt: true}}}
+		return handler.Put(ctx, ioutil.NopCloser(&buf), fs.GetThumbPath(file), size)
+	}
+
+	return image.Save(util.RelativePath(fs.GetThumbPath(file)))
+}
+
 func (fs *FileSystem) GetThumbPath(file *model.File) string {
 	return filepath.Join(fs.User.Policy.GenerateThumbPath(file.UserID), strconv.Itoa(int(file.UserID))+"_"+file.Name+conf.ThumbConfig.FileSuffix)
 }
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/search"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"path"
@@ -28,10 +30,13 @@ type Object struct {
 	Date   string `json:"date"`
 	Access string `json:"access"`
 	Key    string `json:"key,omitempty"`
+	Hash   string `json:"hash,omitempty"`
 }
 
 // Rename 重命名对象
 func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string) (err error) {
+	defer fs.recordAudit(ctx, audit.ActionRename, fs.auditObjects(dir, file)+" -> "+new, &err)
+
 	// 验证新名字
 	if !fs.ValidateLegalName(ctx, new) || (len(file) > 0 && !fs.ValidateExtension(ctx, new)) {
 		return ErrIllegalObjectName
@@ -43,11 +48,16 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 		if err != nil || len(fileObject) == 0 {
 			return ErrPathNotExist
 		}
+		if new == SharedFolderName && fs.isRootFolder(fileObject[0].FolderID) {
+			return ErrReservedName
+		}
 
 		err = fileObject[0].Rename(new)
 		if err != nil {
 			return ErrFileExisted
 		}
+		fileObject[0].Name = new
+		indexFiles(fileObject[:1])
 		return nil
 	}
 
@@ -56,11 +66,15 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 		if err != nil || len(folderObject) == 0 {
 			return ErrPathNotExist
 		}
+		if new == SharedFolderName && folderObject[0].ParentID != nil && fs.isRootFolder(*folderObject[0].ParentID) {
+			return ErrReservedName
+		}
 
 		err = folderObject[0].Rename(new)
 		if err != nil {
 			return ErrFileExisted
 		}
+		fs.indexFolders(dir[:1])
 		return nil
 	}
 
@@ -69,7 +83,9 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 
 // Copy 复制src目录下的文件或目录到dst，
 // 暂时只支持单文件
-func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst string) error {
+func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst string) (err error) {
+	defer fs.recordAudit(ctx, audit.ActionCopy, fs.auditObjects(dirs, files)+" -> "+dst, &err)
+
 	// 获取目的目录
 	isDstExist, dstFolder := fs.IsPathExist(dst)
 	isSrcExist, srcFolder := fs.IsPathExist(src)
@@ -77,6 +93,9 @@ func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst str
 	if !isDstExist || !isSrcExist {
 		return ErrPathNotExist
 	}
+	if err := fs.checkReservedNames(dstFolder, dirs, files); err != nil {
+		return err
+	}
 
 	// 记录复制的文件的总容量
 	var newUsedStorage uint64
@@ -102,11 +121,16 @@ func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst str
 	// 扣除容量
 	fs.User.IncreaseStorageWithoutCheck(newUsedStorage)
 
+	// 为复制出的文件建立索引
+	fs.indexCopiedObjects(dirs, files, dstFolder)
+
 	return nil
 }
 
 // Move 移动文件和目录, 将id列表dirs和files从src移动至dst
-func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst string) error {
+func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst string) (err error) {
+	defer fs.recordAudit(ctx, audit.ActionMove, fs.auditObjects(dirs, files)+" -> "+dst, &err)
+
 	// 获取目的目录
 	isDstExist, dstFolder := fs.IsPathExist(dst)
 	isSrcExist, srcFolder := fs.IsPathExist(src)
@@ -114,9 +138,12 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 	if !isDstExist || !isSrcExist {
 		return ErrPathNotExist
 	}
+	if err := fs.checkReservedNames(dstFolder, dirs, files); err != nil {
+		return err
+	}
 
 	// 处理目录及子文件移动
-	err := srcFolder.MoveFolderTo(dirs, dstFolder)
+	err = srcFolder.MoveFolderTo(dirs, dstFolder)
 	if err != nil {
 		return serializer.NewError(serializer.CodeDBError, "操作失败，可能有重名冲突", err)
 	}
@@ -127,22 +154,24 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 		return serializer.NewError(serializer.CodeDBError, "操作失败，可能有重名冲突", err)
 	}
 
+	// 更新已移动文件及已移动目录下所有文件的索引
+	if len(files) > 0 {
+		if moved, err := model.GetFilesByIDs(files, fs.User.ID); err == nil {
+			indexFiles(moved)
+		}
+	}
+	if len(dirs) > 0 {
+		fs.indexFolders(dirs)
+	}
+
 	// 移动文件
 
 	return err
 }
 
 // Delete 递归删除对象, force 为 true 时强制删除文件记录，忽略物理删除是否成功
-func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool) error {
-	// 已删除的总容量,map用于去重
-	var deletedStorage = make(map[uint]uint64)
-	var totalStorage = make(map[uint]uint64)
-	// 已删除的文件ID
-	var deletedFileIDs = make([]uint, 0, len(fs.FileTarget))
-	// 删除失败的文件的父目录ID
-
-	// 所有文件的ID
-	var allFileIDs = make([]uint, 0, len(fs.FileTarget))
+func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool) (err error) {
+	defer fs.recordAudit(ctx, audit.ActionDelete, fs.auditObjects(dirs, files), &err)
 
 	// 列出要删除的目录
 	if len(dirs) > 0 {
@@ -160,12 +189,33 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 		}
 	}
 
+	return fs.deleteTargets(ctx, force)
+}
+
+// deleteTargets 删除当前所有目标文件及目录的物理文件和记录，并归还容量，
+// force 为 true 时强制删除文件记录，忽略物理删除是否成功
+func (fs *FileSystem) deleteTargets(ctx context.Context, force bool) error {
+	// 已删除的总容量,map用于去重
+	var deletedStorage = make(map[uint]uint64)
+	var totalStorage = make(map[uint]uint64)
+	// 已删除的文件ID
+	var deletedFileIDs = make([]uint, 0, len(fs.FileTarget))
+
+	// 所有文件的ID
+	var allFileIDs = make([]uint, 0, len(fs.FileTarget))
+
 	// 去除待删除文件中包含软连接的部分
 	filesToBeDelete, err := model.RemoveFilesWithSoftLinks(fs.FileTarget)
 	if err != nil {
 		return ErrDBListObjects.WithError(err)
 	}
 
+	// 去除仍被其他文件的历史版本引用的部分
+	filesToBeDelete, err = model.RemoveFilesWithVersions(filesToBeDelete)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
 	// 根据存储策略将文件分组
 	policyGroup := fs.GroupFileByPolicy(ctx, filesToBeDelete)
 
@@ -199,6 +249,23 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 	// 删除文件记录对应的分享记录
 	model.DeleteShareBySourceIDs(deletedFileIDs, false)
 
+	// 删除文件的搜索索引
+	search.Default.Delete(deletedFileIDs...)
+
+	// 删除文件的WebDAV属性
+	model.DeleteWebdavPropertiesByObjectIDs(deletedFileIDs, false)
+
+	// 删除文件的历史版本
+	if len(deletedFileIDs) > 0 {
+		versions, err := model.GetVersionsByFileIDs(deletedFileIDs)
+		if err == nil {
+			err = fs.deleteFileVersions(ctx, versions)
+		}
+		if err != nil {
+			util.Log().Warning("无法删除文件的历史版本, %s", err)
+		}
+	}
+
 	// 归还容量
 	var total uint64
 	for _, value := range deletedStorage {
@@ -219,6 +286,10 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force bool
 
 		// 删除目录记录对应的分享记录
 		model.DeleteShareBySourceIDs(allFolderIDs, true)
+		model.DeleteInternalSharesByFolderIDs(allFolderIDs)
+
+		// 删除目录的WebDAV属性
+		model.DeleteWebdavPropertiesByObjectIDs(allFolderIDs, true)
 	}
 
 	if notDeleted := len(fs.FileTarget) - len(deletedFileIDs); notDeleted > 0 {
@@ -266,6 +337,11 @@ func (fs *FileSystem) ListDeleteFiles(ctx context.Context, ids []uint) error {
 // 有些情况下（如在分享页面列对象）时，
 // 路径需要截取掉被分享目录路径之前的部分。
 func (fs *FileSystem) List(ctx context.Context, dirPath string, pathProcessor func(string) string) ([]Object, error) {
+	// “与我共享”虚拟目录
+	if fs.Root == nil && fs.Operator == nil && IsSharedPath(dirPath) {
+		return fs.listShared(ctx, dirPath, pathProcessor)
+	}
+
 	// 获取父目录
 	isExist, folder := fs.IsPathExist(dirPath)
 	if !isExist {
@@ -283,6 +359,13 @@ func (fs *FileSystem) List(ctx context.Context, dirPath string, pathProcessor fu
 	// 获取子文件
 	childFiles, _ = folder.GetChildFiles()
 
+	// 用户根目录下显示“与我共享”虚拟目录
+	if fs.Root == nil && fs.Operator == nil && folder.ParentID == nil {
+		if shared, err := fs.SharedFolders(); err == nil && len(shared) > 0 {
+			childFolders = append(childFolders, *fs.SharedRoot())
+		}
+	}
+
 	return fs.listObjects(ctx, parentPath, childFiles, childFolders, pathProcessor), nil
 }
 
@@ -371,6 +454,7 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 			Type:   "file",
 			Date:   file.CreatedAt.Format("2006-01-02 15:04:05"),
 			Access: file.AccessDate.Format("2006-01-02 15:04:05"),
+			Hash:   file.Hash,
 		}
 		if shareKey != "" {
 			newFile.Key = shareKey
@@ -414,6 +498,10 @@ func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*mo
 		parent = newParent
 	}
 
+	if isReservedName(parent, dir) {
+		return nil, ErrReservedName
+	}
+
 	// 是否有同名文件
 	if ok, _ := fs.IsChildFileExist(parent, dir); ok {
 		return nil, ErrFileExisted
@@ -375,6 +375,8 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "source_name", "policy_id", "size"}).AddRow(1, "1.txt", "1.txt", 603, 2))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		// 查找被历史版本引用的文件
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 查找软连接
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 查询上传策略
@@ -386,6 +388,10 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
@@ -415,6 +421,8 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "source_name", "policy_id", "size"}).AddRow(1, "2.txt", "2.txt", 365, 2))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		// 查找被历史版本引用的文件
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 查询上传策略
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(365, "local"))
 		// 删除文件记录
@@ -424,9 +432,15 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 查找文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 归还容量
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").
@@ -439,6 +453,10 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
@@ -473,6 +491,8 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name", "source_name", "policy_id", "size"}).AddRow(1, "2.txt", "2.txt", 602, 2))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		// 查找被历史版本引用的文件
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 查询上传策略
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(602, "local"))
 		// 删除文件记录
@@ -482,9 +502,15 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 查找文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 归还容量
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").
@@ -497,6 +523,10 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
@@ -700,6 +730,19 @@ func TestFileSystem_Rename(t *testing.T) {
 		asserts.Equal(ErrFileExisted, err)
 	}
 
+	// 重命名目录 与根目录下的“与我共享”重名
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(10, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(10, "old", 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		err := fs.Rename(ctx, []uint{10}, []uint{}, SharedFolderName)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrReservedName, err)
+	}
+
 	// 未选中任何对象
 	{
 		err := fs.Rename(ctx, []uint{}, []uint{}, "new")
@@ -0,0 +1,118 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"io"
+	"path"
+)
+
+/* ================
+	 存储策略迁移
+   ================
+*/
+
+// countingReader 统计已读取字节数的ReadCloser，用于校验迁移的数据量
+type countingReader struct {
+	io.ReadCloser
+	n uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += uint64(n)
+	return n, err
+}
+
+// copyFile 将文件的物理内容复制到存储策略dst下并校验大小，返回新的存储路径。
+// 成功返回后 fs.Handler 为 dst 的适配器
+func (fs *FileSystem) copyFile(ctx context.Context, file *model.File, dst *model.Policy) (string, error) {
+	src := file.GetPolicy()
+	if src.ID == 0 {
+		return "", ErrUnknownPolicyType
+	}
+
+	// 按目标存储策略的命名规则生成新的存储路径
+	virtualPath := "/"
+	if folders, err := model.GetFoldersByIDs([]uint{file.FolderID}, file.UserID); err == nil && len(folders) > 0 {
+		if err := folders[0].TraceRoot(); err == nil {
+			virtualPath = path.Join(folders[0].Position, folders[0].Name)
+		}
+	}
+	savePath := path.Join(
+		dst.GeneratePath(file.UserID, virtualPath),
+		dst.GenerateFileName(file.UserID, file.Name),
+	)
+
+	// 两个存储策略指向同一存储位置时，避免覆盖原文件
+	if savePath == file.SourceName && src.Type == dst.Type && src.Server == dst.Server && src.BucketName == dst.BucketName {
+		savePath = path.Join(path.Dir(savePath), util.RandStringRunes(8)+"_"+path.Base(savePath))
+	}
+
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, *file)
+	ctx = context.WithValue(ctx, fsctx.SavePathCtx, savePath)
+	ctx = context.WithValue(ctx, fsctx.FileSizeCtx, file.Size)
+
+	// 获取原文件流
+	fs.Policy = src
+	if err := fs.DispatchHandler(); err != nil {
+		return "", err
+	}
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return "", ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	// 写入目标存储策略
+	fs.Policy = dst
+	if err := fs.DispatchHandler(); err != nil {
+		return "", err
+	}
+	reader := &countingReader{ReadCloser: WithProgress(ctx, rs, file.Size)}
+	if err := fs.Handler.Put(ctx, reader, savePath, file.Size); err != nil {
+		// 清理已写入的部分内容
+		fs.Handler.Delete(ctx, []string{savePath})
+		return "", ErrIO.WithError(err)
+	}
+
+	if reader.n != file.Size {
+		fs.Handler.Delete(ctx, []string{savePath})
+		return "", ErrMigrateSizeMismatch
+	}
+
+	return savePath, nil
+}
+
+// MigrateFile 将文件的物理内容复制到存储策略dst下，校验大小后将此文件及共用
+// 同一物理文件的记录指向新的物理文件。deleteSource 为真时删除原物理文件，删除
+// 失败只记录警告。文件的历史版本仍保留在原存储策略下
+func (fs *FileSystem) MigrateFile(ctx context.Context, file *model.File, dst *model.Policy, deleteSource bool) error {
+	src := file.GetPolicy()
+	savePath, err := fs.copyFile(ctx, file, dst)
+	if err != nil {
+		return err
+	}
+
+	if err := model.MigrateFileSource(src.ID, file.SourceName, dst.ID, savePath, dst.IsEncrypted()); err != nil {
+		fs.Handler.Delete(ctx, []string{savePath})
+		return ErrMigrateFileRecord.WithError(err)
+	}
+
+	if deleteSource {
+		fs.Policy = src
+		if err := fs.DispatchHandler(); err != nil {
+			util.Log().Warning("无法删除已迁移的原文件[%s]，%s", file.SourceName, err)
+		} else if failed, err := fs.Handler.Delete(ctx, []string{file.SourceName}); err != nil || len(failed) > 0 {
+			util.Log().Warning("无法删除已迁移的原文件[%s]，%s", file.SourceName, err)
+		}
+	}
+
+	file.SourceName = savePath
+	file.PolicyID = dst.ID
+	file.Policy = *dst
+	file.Encrypted = dst.IsEncrypted()
+	return nil
+}
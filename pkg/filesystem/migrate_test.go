@@ -0,0 +1,129 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestFileSystem_MigrateFile(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	dst := &model.Policy{Model: gorm.Model{ID: 2}, Type: "mock", DirNameRule: "uploads", FileNameRule: "{originname}"}
+	newFile := func(size uint64) *model.File {
+		return &model.File{
+			Model:      gorm.Model{ID: 1},
+			Name:       "a.txt",
+			SourceName: "old/a.txt",
+			UserID:     1,
+			FolderID:   1,
+			Size:       size,
+			PolicyID:   1,
+			Policy:     model.Policy{Model: gorm.Model{ID: 1}, Type: "mock"},
+		}
+	}
+	readAll := func(args testMock.Arguments) {
+		ioutil.ReadAll(args.Get(1).(io.Reader))
+	}
+
+	// 成功，删除原文件
+	{
+		file := newFile(3)
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "old/a.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		testHandler.On("Put", testMock.Anything, testMock.Anything, "uploads/a.txt").Run(readAll).Return(nil)
+		testHandler.On("Delete", testMock.Anything, []string{"old/a.txt"}).Return([]string{}, nil)
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(false, 2, "uploads/a.txt", 1, "old/a.txt").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE(.+)file_replicas(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		err := fs.MigrateFile(ctx, file, dst, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.NoError(err)
+		asserts.Equal("uploads/a.txt", file.SourceName)
+		asserts.EqualValues(2, file.PolicyID)
+	}
+
+	// 大小不符，删除已写入的文件
+	{
+		file := newFile(10)
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "old/a.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		testHandler.On("Put", testMock.Anything, testMock.Anything, "uploads/a.txt").Run(readAll).Return(nil)
+		testHandler.On("Delete", testMock.Anything, []string{"uploads/a.txt"}).Return([]string{}, nil)
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		err := fs.MigrateFile(ctx, file, dst, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.Equal(ErrMigrateSizeMismatch, err)
+		asserts.Equal("old/a.txt", file.SourceName)
+	}
+
+	// 写入失败，删除已写入的部分内容
+	{
+		file := newFile(3)
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "old/a.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		testHandler.On("Put", testMock.Anything, testMock.Anything, "uploads/a.txt").Return(errors.New("error"))
+		testHandler.On("Delete", testMock.Anything, []string{"uploads/a.txt"}).Return([]string{}, nil)
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		err := fs.MigrateFile(ctx, file, dst, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.Error(err)
+		asserts.Equal("old/a.txt", file.SourceName)
+	}
+
+	// 更新记录失败，删除已写入的文件
+	{
+		file := newFile(3)
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "old/a.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		testHandler.On("Put", testMock.Anything, testMock.Anything, "uploads/a.txt").Run(readAll).Return(nil)
+		testHandler.On("Delete", testMock.Anything, []string{"uploads/a.txt"}).Return([]string{}, nil)
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := fs.MigrateFile(ctx, file, dst, false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.Error(err)
+	}
+
+	// 无法读取原文件
+	{
+		file := newFile(3)
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "old/a.txt").Return(request.NopRSCloser{}, errors.New("error"))
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		err := fs.MigrateFile(ctx, file, dst, false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+		asserts.Error(err)
+	}
+}
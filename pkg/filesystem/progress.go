@@ -0,0 +1,49 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"io"
+)
+
+// ProgressReporter 按字节报告数据处理进度，由后台任务通过 fsctx.ProgressCtx 传入，
+// 可能被多个协程同时调用
+type ProgressReporter interface {
+	// AddTotal 增加已知的待处理字节数
+	AddTotal(size uint64)
+	// AddProcessed 增加已处理的字节数
+	AddProcessed(size uint64)
+}
+
+// progressReader 读取时检查上下文是否已取消，并向进度报告器报告读取的字节数
+type progressReader struct {
+	io.ReadCloser
+	ctx      context.Context
+	reporter ProgressReporter
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if r.reporter != nil && n > 0 {
+		r.reporter.AddProcessed(uint64(n))
+	}
+	return n, err
+}
+
+// WithProgress 包装大小为 size 的文件流，使其在上下文取消后中止读取，
+// 上下文中有进度报告器时同时报告待处理及已读取的字节数
+func WithProgress(ctx context.Context, rc io.ReadCloser, size uint64) io.ReadCloser {
+	reporter, _ := ctx.Value(fsctx.ProgressCtx).(ProgressReporter)
+	if reporter == nil && ctx.Done() == nil {
+		return rc
+	}
+
+	if reporter != nil {
+		reporter.AddTotal(size)
+	}
+	return &progressReader{ReadCloser: rc, ctx: ctx, reporter: reporter}
+}
@@ -0,0 +1,54 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+type testProgress struct {
+	total     uint64
+	processed uint64
+}
+
+func (p *testProgress) AddTotal(size uint64) {
+	p.total += size
+}
+
+func (p *testProgress) AddProcessed(size uint64) {
+	p.processed += size
+}
+
+func TestWithProgress(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 无需包装
+	{
+		rc := ioutil.NopCloser(strings.NewReader("123"))
+		asserts.Equal(rc, WithProgress(context.Background(), rc, 3))
+	}
+
+	// 报告进度
+	{
+		reporter := &testProgress{}
+		ctx := context.WithValue(context.Background(), fsctx.ProgressCtx, reporter)
+		rc := WithProgress(ctx, ioutil.NopCloser(strings.NewReader("123")), 3)
+		content, err := ioutil.ReadAll(rc)
+		asserts.NoError(err)
+		asserts.Equal("123", string(content))
+		asserts.EqualValues(3, reporter.total)
+		asserts.EqualValues(3, reporter.processed)
+	}
+
+	// 上下文已取消
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		rc := WithProgress(ctx, ioutil.NopCloser(strings.NewReader("123")), 3)
+		cancel()
+		_, err := ioutil.ReadAll(rc)
+		asserts.Equal(context.Canceled, err)
+	}
+}
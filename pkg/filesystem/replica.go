@@ -0,0 +1,149 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"net/url"
+)
+
+/* ================
+	 文件副本
+   ================
+*/
+
+// ReplicateFile 将文件复制到其存储策略指定的副本存储策略下并记录副本，
+// 已有副本时以新内容替换。存储策略未设定副本时直接返回
+func (fs *FileSystem) ReplicateFile(ctx context.Context, file *model.File) error {
+	src := file.GetPolicy()
+	if src.ReplicaPolicyID == 0 || src.ReplicaPolicyID == src.ID {
+		return nil
+	}
+
+	dst, err := model.GetPolicyByID(src.ReplicaPolicyID)
+	if err != nil {
+		return err
+	}
+
+	previous, _ := model.GetFileReplicas(src.ID, file.SourceName)
+
+	savePath, err := fs.copyFile(ctx, file, &dst)
+	if err != nil {
+		return err
+	}
+
+	replica := &model.FileReplica{
+		PolicyID:        src.ID,
+		SourceName:      file.SourceName,
+		ReplicaPolicyID: dst.ID,
+		ReplicaSource:   savePath,
+		Size:            file.Size,
+		Encrypted:       dst.IsEncrypted(),
+	}
+	if err := model.SaveFileReplica(replica); err != nil {
+		fs.Handler.Delete(ctx, []string{savePath})
+		return ErrMigrateFileRecord.WithError(err)
+	}
+
+	// 删除被替换的旧副本
+	for _, old := range previous {
+		if old.ReplicaPolicyID == dst.ID && old.ReplicaSource != savePath {
+			if _, err := fs.Handler.Delete(ctx, []string{old.ReplicaSource}); err != nil {
+				util.Log().Warning("无法删除旧副本[%s]，%s", old.ReplicaSource, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// withReplica 依次切换至文件的各个副本执行 fn，直到 fn 成功。
+// 执行结束后恢复原有的存储策略及适配器
+func (fs *FileSystem) withReplica(ctx context.Context, file *model.File, fn func(ctx context.Context, replica *model.File) error) error {
+	replicas, err := model.GetFileReplicas(file.PolicyID, file.SourceName)
+	if err != nil {
+		return err
+	}
+	if len(replicas) == 0 {
+		return ErrReplicaNotExist
+	}
+
+	policy, handler := fs.Policy, fs.Handler
+	defer func() {
+		fs.Policy, fs.Handler = policy, handler
+	}()
+
+	for _, replica := range replicas {
+		replicaFile := replica.AsFile(file)
+		fs.Policy = replicaFile.GetPolicy()
+		if fs.Policy.ID == 0 {
+			continue
+		}
+		if err = fs.DispatchHandler(); err != nil {
+			continue
+		}
+
+		if err = fn(context.WithValue(ctx, fsctx.FileModelCtx, replicaFile), &replicaFile); err == nil {
+			return nil
+		}
+		util.Log().Warning("无法从副本[%s]读取文件，%s", replica.ReplicaSource, err)
+	}
+
+	return err
+}
+
+// getReplicaContent 主存储策略无法读取文件时，从副本获取文件流
+func (fs *FileSystem) getReplicaContent(ctx context.Context, file *model.File) (response.RSCloser, error) {
+	var rs response.RSCloser
+	err := fs.withReplica(ctx, file, func(ctx context.Context, replica *model.File) (err error) {
+		rs, err = fs.Handler.Get(ctx, replica.SourceName)
+		return err
+	})
+	return rs, err
+}
+
+// getReplicaSource 主存储策略无法获取外链时，获取副本的外链
+func (fs *FileSystem) getReplicaSource(ctx context.Context, file *model.File, baseURL url.URL, ttl int64, isDownload bool) (string, error) {
+	var source string
+	err := fs.withReplica(ctx, file, func(ctx context.Context, replica *model.File) (err error) {
+		source, err = fs.Handler.Source(ctx, replica.SourceName, baseURL, ttl, isDownload, fs.User.Group.SpeedLimit)
+		return err
+	})
+	return source, err
+}
+
+// deleteReplicas 删除存储策略policyID下给定物理文件的所有副本及副本记录
+func (fs *FileSystem) deleteReplicas(ctx context.Context, policyID uint, sources []string) {
+	replicas, err := model.GetReplicasBySources(policyID, sources)
+	if err != nil || len(replicas) == 0 {
+		return
+	}
+
+	// 按副本存储策略分组
+	grouped := make(map[uint][]string)
+	ids := make([]uint, 0, len(replicas))
+	for _, replica := range replicas {
+		grouped[replica.ReplicaPolicyID] = append(grouped[replica.ReplicaPolicyID], replica.ReplicaSource)
+		ids = append(ids, replica.ID)
+	}
+
+	for replicaPolicyID, replicaSources := range grouped {
+		policy, err := model.GetPolicyByID(replicaPolicyID)
+		if err != nil {
+			continue
+		}
+		fs.Policy = &policy
+		if err := fs.DispatchHandler(); err != nil {
+			continue
+		}
+		if failed, err := fs.Handler.Delete(ctx, replicaSources); err != nil {
+			util.Log().Warning("无法删除 %d 个文件副本，%s", len(failed), err)
+		}
+	}
+
+	if err := model.DeleteFileReplicaByIDs(ids); err != nil {
+		util.Log().Warning("无法删除副本记录，%s", err)
+	}
+}
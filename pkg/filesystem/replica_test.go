@@ -0,0 +1,133 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	testMock "github.com/stretchr/testify/mock"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileSystem_ReplicateFile(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+
+	// 未设定副本存储策略
+	{
+		file := &model.File{Policy: model.Policy{Model: gorm.Model{ID: 1}, Type: "mock"}}
+		asserts.NoError(fs.ReplicateFile(ctx, file))
+	}
+
+	// 成功，删除被替换的旧副本
+	{
+		cache.Set("policy_2", model.Policy{Model: gorm.Model{ID: 2}, Type: "mock", DirNameRule: "replica", FileNameRule: "{originname}"}, 0)
+		file := &model.File{
+			Model:      gorm.Model{ID: 1},
+			Name:       "a.txt",
+			SourceName: "a.txt",
+			UserID:     1,
+			FolderID:   1,
+			Size:       3,
+			PolicyID:   1,
+			Policy:     model.Policy{Model: gorm.Model{ID: 1}, Type: "mock", ReplicaPolicyID: 2},
+		}
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "a.txt").Return(MockRSC{rs: strings.NewReader("123")}, nil)
+		testHandler.On("Put", testMock.Anything, testMock.Anything, "replica/a.txt").Run(func(args testMock.Arguments) {
+			ioutil.ReadAll(args.Get(1).(io.Reader))
+		}).Return(nil)
+		testHandler.On("Delete", testMock.Anything, []string{"replica/old.txt"}).Return([]string{}, nil)
+		fs.Handler = testHandler
+
+		// 原有副本
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WithArgs(1, "a.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "replica_source"}).AddRow(5, 2, "replica/old.txt"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		// 更新副本记录
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_source"}).AddRow(5, "replica/old.txt"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)file_replicas(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		asserts.NoError(fs.ReplicateFile(ctx, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+		testHandler.AssertExpectations(t)
+	}
+
+	// 无法读取原文件
+	{
+		file := &model.File{
+			SourceName: "a.txt",
+			PolicyID:   1,
+			Policy:     model.Policy{Model: gorm.Model{ID: 1}, Type: "mock", ReplicaPolicyID: 2},
+		}
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "a.txt").Return(request.NopRSCloser{}, errors.New("error"))
+		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.Error(fs.ReplicateFile(ctx, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileSystem_GetContentFromReplica(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+
+	file, err := os.Create(util.RelativePath("TestFileSystem_GetContentFromReplica.txt"))
+	asserts.NoError(err)
+	_ = file.Close()
+	cache.Set("policy_3", model.Policy{Model: gorm.Model{ID: 3}, Type: "local"}, 0)
+
+	// 主存储策略读取失败，从副本读取
+	{
+		fs.FileTarget = []model.File{{
+			Model:      gorm.Model{ID: 1},
+			SourceName: "not_exist.txt",
+			PolicyID:   1,
+			Policy:     model.Policy{Model: gorm.Model{ID: 1}, Type: "local"},
+		}}
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WithArgs(1, "not_exist.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "replica_source"}).
+				AddRow(1, 3, "TestFileSystem_GetContentFromReplica.txt"))
+		rs, err := fs.GetContent(ctx, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		if rs != nil {
+			rs.Close()
+		}
+		// 恢复原有存储策略
+		asserts.EqualValues(1, fs.Policy.ID)
+		fs.CleanTargets()
+	}
+
+	// 没有副本
+	{
+		fs.FileTarget = []model.File{{
+			Model:      gorm.Model{ID: 1},
+			SourceName: "not_exist.txt",
+			PolicyID:   1,
+			Policy:     model.Policy{Model: gorm.Model{ID: 1}, Type: "local"},
+		}}
+		mock.ExpectQuery("SELECT(.+)file_replicas(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := fs.GetContent(ctx, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		fs.CleanTargets()
+	}
+}
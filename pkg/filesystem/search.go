@@ -0,0 +1,341 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+/* =================
+	 搜索索引相关
+   =================
+*/
+
+// rebuildBatchSize 重建索引时每批读取的文件数
+const rebuildBatchSize = 500
+
+// rebuilding 是否正在重建索引
+var rebuilding int32
+
+const (
+	// extractWorkers 后台同时提取正文的文件数
+	extractWorkers = 2
+	// extractQueueSize 等待提取正文的最大文件数，队列已满时提交方等待
+	extractQueueSize = 1024
+)
+
+// extractJob 等待提取正文的文件
+type extractJob struct {
+	file   model.File
+	policy *model.Policy
+}
+
+var (
+	extractQueue = make(chan extractJob, extractQueueSize)
+	extractOnce  sync.Once
+)
+
+// InitSearchIndex 加载搜索索引并在后台与数据库同步，索引不存在或已损坏时在后台重建
+func InitSearchIndex() {
+	indexPath := util.RelativePath(model.GetSettingByName("search_index_path"))
+	if err := search.Init(indexPath); err != nil {
+		util.Log().Info("无法加载搜索索引，将在后台重建, %s", err)
+		go RebuildSearchIndex()
+		return
+	}
+	go SyncSearchIndex()
+}
+
+// RebuildSearchIndex 根据数据库中的文件记录重建搜索索引，重建期间搜索会回退为按文件名检索
+func RebuildSearchIndex() {
+	if !atomic.CompareAndSwapInt32(&rebuilding, 0, 1) {
+		util.Log().Warning("搜索索引正在重建，请勿重复操作")
+		return
+	}
+	defer atomic.StoreInt32(&rebuilding, 0)
+
+	util.Log().Info("开始重建搜索索引...")
+	search.Default.Reset()
+	if total, _, err := syncSearchIndex(); err == nil {
+		util.Log().Info("搜索索引重建完成，共索引 %d 个文件", total)
+	}
+}
+
+// SyncSearchIndex 将从磁盘加载的索引与数据库同步，补全上次保存后新增、修改的文件，
+// 删除已不存在的文件。同步期间搜索会回退为按文件名检索
+func SyncSearchIndex() {
+	if !atomic.CompareAndSwapInt32(&rebuilding, 0, 1) {
+		util.Log().Warning("搜索索引正在重建，请勿重复操作")
+		return
+	}
+	defer atomic.StoreInt32(&rebuilding, 0)
+
+	if total, updated, err := syncSearchIndex(); err == nil {
+		util.Log().Info("搜索索引同步完成，共 %d 个文件，更新 %d 个", total, updated)
+	}
+}
+
+// syncSearchIndex 遍历数据库中的文件记录，更新与索引不一致的文件并删除已不存在的文件，
+// 完成后将索引标记为可用并写入磁盘。返回文件总数及更新的文件数
+func syncSearchIndex() (int, int, error) {
+	limit := model.GetIntSetting("search_content_max_size", 10485760)
+	policies := make(map[uint]*model.Policy)
+	paths := make(folderPaths)
+	stale := make(map[uint]struct{})
+	for _, id := range search.Default.IDs() {
+		stale[id] = struct{}{}
+	}
+
+	var (
+		lastID  uint
+		total   int
+		updated int
+	)
+	for {
+		files, err := model.GetFilesAfterID(lastID, rebuildBatchSize)
+		if err != nil {
+			util.Log().Warning("无法列取文件，搜索索引同步中止, %s", err)
+			return 0, 0, err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for i := 0; i < len(files); i++ {
+			delete(stale, files[i].ID)
+			doc := paths.document(&files[i])
+			old, exist := search.Default.Get(files[i].ID)
+			if exist && old.Equal(&doc) {
+				continue
+			}
+			search.Default.Put(doc)
+			updated++
+
+			policy, ok := policies[files[i].PolicyID]
+			if !ok {
+				if p, err := model.GetPolicyByID(files[i].PolicyID); err == nil {
+					policy = &p
+				}
+				policies[files[i].PolicyID] = policy
+			}
+			// 修改时间变化的文件内容可能已改变，需重新提取正文
+			refresh := exist && !old.UpdatedAt.Equal(doc.UpdatedAt)
+			if shouldExtractContent(&files[i], policy, refresh) {
+				extractContent(files[i], policy, limit)
+			}
+		}
+
+		total += len(files)
+		lastID = files[len(files)-1].ID
+	}
+
+	for id := range stale {
+		search.Default.Delete(id)
+	}
+
+	search.Default.SetReady()
+	search.Flush()
+	return total, updated, nil
+}
+
+// SearchIndex 使用搜索索引查找当前用户的文件，返回第 page 页（从 1 开始）的对象及结果总数，
+// page 为 0 时返回全部结果
+func (fs *FileSystem) SearchIndex(ctx context.Context, query *search.Query, page, pageSize int) ([]Object, int, error) {
+	// 限定目录时包括其所有子目录
+	if query.In != "" {
+		exist, folder := fs.IsPathExist(query.In)
+		if !exist {
+			return nil, 0, ErrPathNotExist
+		}
+		folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+		if err != nil {
+			return nil, 0, ErrDBListObjects.WithError(err)
+		}
+		query.Folders = make([]uint, 0, len(folders))
+		for _, value := range folders {
+			query.Folders = append(query.Folders, value.ID)
+		}
+	}
+
+	offset, limit := 0, 0
+	if page > 0 {
+		offset, limit = (page-1)*pageSize, pageSize
+	}
+	ids, total := search.Default.Search(fs.User.ID, query, offset, limit)
+	if len(ids) == 0 {
+		return []Object{}, total, nil
+	}
+
+	files, err := model.GetFilesByIDs(ids, fs.User.ID)
+	if err != nil {
+		return nil, 0, ErrDBListObjects.WithError(err)
+	}
+
+	// 按索引给出的相关度排序
+	rank := make(map[uint]int, len(ids))
+	for i, id := range ids {
+		rank[id] = i
+	}
+	sort.Slice(files, func(i, j int) bool { return rank[files[i].ID] < rank[files[j].ID] })
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), total, nil
+}
+
+// indexFiles 更新文件名、所在目录等元数据的索引
+func indexFiles(files []model.File) {
+	paths := make(folderPaths)
+	for i := 0; i < len(files); i++ {
+		search.Default.Put(paths.document(&files[i]))
+	}
+}
+
+// indexFolders 目录重命名或移动后，更新其下所有文件的所在目录路径
+func (fs *FileSystem) indexFolders(dirs []uint) {
+	folders, err := model.GetRecursiveChildFolder(dirs, fs.User.ID, true)
+	if err != nil {
+		util.Log().Warning("无法更新目录下文件的搜索索引, %s", err)
+		return
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	files, err := model.GetChildFilesOfFolders(&folders)
+	if err != nil {
+		util.Log().Warning("无法更新目录下文件的搜索索引, %s", err)
+		return
+	}
+	indexFiles(files)
+}
+
+// indexFile 更新文件的索引，文件内容有变化时 refresh 应为 true，以便重新提取正文
+func indexFile(file *model.File, policy *model.Policy, refresh bool) {
+	search.Default.Put(searchDocument(file))
+	if shouldExtractContent(file, policy, refresh) {
+		queueExtractContent(*file, policy)
+	}
+}
+
+// queueExtractContent 将文件加入正文提取队列，由固定数量的后台 worker 依次提取，
+// 避免批量添加文件时同时读取大量文件
+func queueExtractContent(file model.File, policy *model.Policy) {
+	extractOnce.Do(func() {
+		for i := 0; i < extractWorkers; i++ {
+			go func() {
+				for job := range extractQueue {
+					extractContent(job.file, job.policy, model.GetIntSetting("search_content_max_size", 10485760))
+				}
+			}()
+		}
+	})
+	extractQueue <- extractJob{file: file, policy: policy}
+}
+
+// indexCopiedObjects 为复制到 dst 目录下的文件建立索引
+func (fs *FileSystem) indexCopiedObjects(dirs, files []uint, dst *model.Folder) {
+	folders := make([]model.Folder, 0, 1)
+	if len(files) > 0 {
+		folders = append(folders, *dst)
+	}
+	if len(dirs) > 0 {
+		if src, err := model.GetFoldersByIDs(dirs[:1], fs.User.ID); err == nil && len(src) > 0 {
+			if copied, err := dst.GetChild(src[0].Name); err == nil {
+				children, _ := model.GetRecursiveChildFolder([]uint{copied.ID}, fs.User.ID, true)
+				folders = append(folders, children...)
+			}
+		}
+	}
+	if len(folders) == 0 {
+		return
+	}
+
+	copiedFiles, err := model.GetChildFilesOfFolders(&folders)
+	if err != nil {
+		util.Log().Warning("无法为复制的文件建立搜索索引, %s", err)
+		return
+	}
+	indexFiles(copiedFiles)
+}
+
+// searchDocument 文件对应的索引文档
+func searchDocument(file *model.File) search.Document {
+	return make(folderPaths).document(file)
+}
+
+// folderPaths 缓存目录ID对应的完整路径，用于批量生成索引文档
+type folderPaths map[uint]string
+
+// document 文件对应的索引文档
+func (paths folderPaths) document(file *model.File) search.Document {
+	return search.Document{
+		ID:        file.ID,
+		UID:       file.UserID,
+		FolderID:  file.FolderID,
+		Path:      paths.resolve(file.FolderID, file.UserID),
+		Name:      file.Name,
+		Size:      file.Size,
+		UpdatedAt: file.UpdatedAt,
+		Source:    search.SourceKey(file.PolicyID, file.SourceName),
+	}
+}
+
+// resolve 返回目录的完整路径，目录不存在时返回空字符串
+func (paths folderPaths) resolve(id, uid uint) string {
+	if p, ok := paths[id]; ok {
+		return p
+	}
+
+	p := ""
+	folders, err := model.GetFoldersByIDs([]uint{id}, uid)
+	if err == nil && len(folders) > 0 {
+		if folders[0].ParentID == nil {
+			p = "/"
+		} else if parent := paths.resolve(*folders[0].ParentID, uid); parent != "" {
+			p = path.Join(parent, folders[0].Name)
+		}
+	}
+	paths[id] = p
+	return p
+}
+
+// shouldExtractContent 是否需要提取文件正文。仅提取由服务端中转存储的
+// （本机、从机、SFTP、WebDAV存储策略）文件，refresh 为 false 时物理文件已有正文索引则跳过
+func shouldExtractContent(file *model.File, policy *model.Policy, refresh bool) bool {
+	if policy == nil || (policy.Type != "local" && policy.Type != "remote" && policy.Type != "sftp" && policy.Type != "webdav") {
+		return false
+	}
+	if !search.CanExtract(file.Name) {
+		return false
+	}
+	return refresh || !search.Default.HasContent(search.SourceKey(file.PolicyID, file.SourceName))
+}
+
+// extractContent 读取物理文件并更新正文索引，至多读取 limit 字节
+func extractContent(file model.File, policy *model.Policy, limit int) {
+	fs := &FileSystem{User: &model.User{}, Policy: policy}
+	if err := fs.DispatchHandler(); err != nil {
+		return
+	}
+
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		util.Log().Debug("无法读取文件[%d]以提取正文, %s", file.ID, err)
+		return
+	}
+	defer rs.Close()
+
+	text, err := search.ExtractText(file.Name, rs, int64(limit))
+	if err != nil {
+		util.Log().Debug("无法提取文件[%d]的正文, %s", file.ID, err)
+		return
+	}
+	search.Default.PutContent(search.SourceKey(file.PolicyID, file.SourceName), text)
+}
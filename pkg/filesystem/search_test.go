@@ -0,0 +1,157 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileSystem_SearchIndex(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	now := time.Now()
+	search.Default.Put(search.Document{ID: 101, UID: 1, Name: "search_a.txt", UpdatedAt: now})
+	search.Default.Put(search.Document{ID: 102, UID: 1, Name: "search_b.txt", UpdatedAt: now.Add(-time.Hour)})
+	defer search.Default.Delete(101, 102)
+
+	// 按相关度排序
+	{
+		query := search.NewQuery()
+		query.Terms = []string{"search_"}
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(101, 102, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(102, "search_b.txt").AddRow(101, "search_a.txt"))
+		objects, total, err := fs.SearchIndex(context.Background(), query, 0, 0)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(2, total)
+		asserts.Len(objects, 2)
+		asserts.Equal("search_a.txt", objects[0].Name)
+		asserts.Equal("search_b.txt", objects[1].Name)
+	}
+
+	// 分页
+	{
+		query := search.NewQuery()
+		query.Terms = []string{"search_"}
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(102, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(102, "search_b.txt"))
+		objects, total, err := fs.SearchIndex(context.Background(), query, 2, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(2, total)
+		asserts.Len(objects, 1)
+	}
+
+	// 无结果时不查询数据库
+	{
+		query := search.NewQuery()
+		query.Terms = []string{"not_exist"}
+		objects, total, err := fs.SearchIndex(context.Background(), query, 0, 0)
+		asserts.NoError(err)
+		asserts.Equal(0, total)
+		asserts.Empty(objects)
+	}
+}
+
+func TestFolderPaths_Resolve(t *testing.T) {
+	asserts := assert.New(t)
+	paths := make(folderPaths)
+
+	// 逐级向上查找，已解析的目录不再查询
+	{
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(3, "work", 2))
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(2, "docs", 1))
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(1, "/", nil))
+		asserts.Equal("/docs/work", paths.resolve(3, 1))
+		asserts.Equal("/docs", paths.resolve(2, 1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 目录不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").
+			WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}))
+		asserts.Equal("", paths.resolve(4, 1))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestShouldExtractContent(t *testing.T) {
+	asserts := assert.New(t)
+	file := &model.File{Name: "1.md", SourceName: "extract_test", PolicyID: 1}
+
+	asserts.False(shouldExtractContent(file, nil, false))
+	asserts.False(shouldExtractContent(file, &model.Policy{Type: "oss"}, false))
+	asserts.False(shouldExtractContent(&model.File{Name: "1.jpg"}, &model.Policy{Type: "local"}, false))
+	asserts.True(shouldExtractContent(file, &model.Policy{Type: "remote"}, false))
+
+	// 已有正文索引
+	search.Default.Put(searchDocument(&model.File{Model: gorm.Model{ID: 103}, Name: "1.md", SourceName: "extract_test", PolicyID: 1}))
+	defer search.Default.Delete(103)
+	search.Default.PutContent(search.SourceKey(1, "extract_test"), "content")
+	asserts.False(shouldExtractContent(file, &model.Policy{Type: "local"}, false))
+	asserts.True(shouldExtractContent(file, &model.Policy{Type: "local"}, true))
+}
+
+func TestExtractContent(t *testing.T) {
+	asserts := assert.New(t)
+	tempFile, err := ioutil.TempFile("", "cloudreve_extract")
+	asserts.NoError(err)
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.WriteString("hello searchable content")
+	asserts.NoError(err)
+	tempFile.Close()
+
+	file := model.File{Model: gorm.Model{ID: 104}, UserID: 1, Name: "1.txt", SourceName: tempFile.Name(), PolicyID: 1}
+	search.Default.Put(searchDocument(&file))
+	defer search.Default.Delete(104)
+
+	// 文件不存在
+	extractContent(model.File{Name: "1.txt", SourceName: "not_exist", PolicyID: 1}, &model.Policy{Type: "local"}, 100)
+	asserts.False(search.Default.HasContent(search.SourceKey(1, "not_exist")))
+
+	// 成功
+	extractContent(file, &model.Policy{Type: "local"}, 100)
+	asserts.True(search.Default.HasContent(search.SourceKey(1, tempFile.Name())))
+	query := search.NewQuery()
+	query.Terms = []string{"searchable"}
+	ids, _ := search.Default.Search(1, query, 0, 0)
+	asserts.Equal([]uint{104}, ids)
+}
+
+func TestQueueExtractContent(t *testing.T) {
+	asserts := assert.New(t)
+	tempFile, err := ioutil.TempFile("", "cloudreve_extract")
+	asserts.NoError(err)
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.WriteString("queued content")
+	asserts.NoError(err)
+	tempFile.Close()
+
+	file := model.File{Model: gorm.Model{ID: 105}, UserID: 1, Name: "1.txt", SourceName: tempFile.Name(), PolicyID: 1}
+	search.Default.Put(searchDocument(&file))
+	defer search.Default.Delete(105)
+
+	asserts.NoError(cache.Set("setting_search_content_max_size", "100", -1))
+	queueExtractContent(file, &model.Policy{Type: "local"})
+	asserts.Eventually(func() bool {
+		return search.Default.HasContent(search.SourceKey(1, tempFile.Name()))
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,331 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"path"
+	"strings"
+	"time"
+)
+
+/* =================
+	 站内共享相关
+   =================
+*/
+
+// SharedFolderName “与我共享”虚拟目录的名称
+const SharedFolderName = model.SharedFolderName
+
+// SharedRootPath “与我共享”虚拟目录的路径，其下为共享给当前用户的目录
+const SharedRootPath = "/" + SharedFolderName
+
+// sharedEntry “与我共享”中的一个目录
+type sharedEntry struct {
+	// 在“与我共享”中显示的名称
+	name   string
+	share  *model.InternalShare
+	folder model.Folder
+}
+
+// IsSharedPath 路径是否为“与我共享”虚拟目录或其下的路径
+func IsSharedPath(p string) bool {
+	p = path.Clean(p)
+	return p == SharedRootPath || strings.HasPrefix(p, SharedRootPath+"/")
+}
+
+// IsSharedRoot 目录是否为“与我共享”虚拟目录
+func IsSharedRoot(folder *model.Folder) bool {
+	return folder.ID == 0 && folder.Name == SharedFolderName
+}
+
+// isReservedName 名称 name 在目录 parent 下是否为保留名称。根目录下的“与我共享”会被虚拟目录遮挡
+func isReservedName(parent *model.Folder, name string) bool {
+	return parent.ParentID == nil && name == SharedFolderName
+}
+
+// isRootFolder 目录 id 是否为当前用户的根目录
+func (fs *FileSystem) isRootFolder(id uint) bool {
+	root, err := fs.User.Root()
+	return err == nil && root.ID == id
+}
+
+// checkReservedNames 检查移动或复制至目录 dst 的目录 dirs 及文件 files 是否使用了保留名称
+func (fs *FileSystem) checkReservedNames(dst *model.Folder, dirs, files []uint) error {
+	if dst.ParentID != nil || len(dirs)+len(files) == 0 {
+		return nil
+	}
+
+	folders, _ := model.GetFoldersByIDs(dirs, fs.User.ID)
+	for i := 0; i < len(folders); i++ {
+		if isReservedName(dst, folders[i].Name) {
+			return ErrReservedName
+		}
+	}
+	fileObjects, _ := model.GetFilesByIDs(files, fs.User.ID)
+	for i := 0; i < len(fileObjects); i++ {
+		if isReservedName(dst, fileObjects[i].Name) {
+			return ErrReservedName
+		}
+	}
+	return nil
+}
+
+// operator 返回实际执行操作的用户
+func (fs *FileSystem) operator() *model.User {
+	if fs.Operator != nil {
+		return fs.Operator
+	}
+	return fs.User
+}
+
+// SharedRoot 返回“与我共享”虚拟目录
+func (fs *FileSystem) SharedRoot() *model.Folder {
+	root := &model.Folder{Name: SharedFolderName, Position: "/", OwnerID: fs.User.ID}
+	root.CreatedAt = time.Now()
+	root.UpdatedAt = root.CreatedAt
+	return root
+}
+
+// SharedFolders 列出“与我共享”下的目录，目录名为其在“与我共享”中显示的名称
+func (fs *FileSystem) SharedFolders() ([]model.Folder, error) {
+	entries, err := fs.sharedEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]model.Folder, 0, len(entries))
+	for _, entry := range entries {
+		folder := entry.folder
+		folder.Name = entry.name
+		folder.Position = SharedRootPath
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// sharedEntries 列出共享给当前用户的目录。同一目录被多次共享时使用允许写入的记录，
+// 与已有目录重名时在名称后附加共享ID
+func (fs *FileSystem) sharedEntries() ([]sharedEntry, error) {
+	shares, err := model.ListInternalSharesTo(fs.operator())
+	if err != nil || len(shares) == 0 {
+		return nil, err
+	}
+
+	entries := make([]sharedEntry, 0, len(shares))
+	index := make(map[uint]int, len(shares))
+	names := make(map[string]bool, len(shares))
+	for i := 0; i < len(shares); i++ {
+		if j, ok := index[shares[i].FolderID]; ok {
+			if shares[i].Writable {
+				entries[j].share = &shares[i]
+			}
+			continue
+		}
+
+		// 目录已被删除或移入回收站
+		folders, err := model.GetFoldersByIDs([]uint{shares[i].FolderID}, shares[i].UserID)
+		if err != nil || len(folders) == 0 {
+			continue
+		}
+
+		name := folders[0].Name
+		if names[name] {
+			name = fmt.Sprintf("%s (%d)", name, shares[i].ID)
+		}
+		names[name] = true
+		index[shares[i].FolderID] = len(entries)
+		entries = append(entries, sharedEntry{name: name, share: &shares[i], folder: folders[0]})
+	}
+
+	return entries, nil
+}
+
+// enterShared 找到路径 p 所在的共享目录，并将文件系统切换至其所有者，
+// 返回共享目录及 p 相对于共享目录的路径
+func (fs *FileSystem) enterShared(p string, write bool) (*sharedEntry, string, error) {
+	p = path.Clean(p)
+	if !IsSharedPath(p) {
+		return nil, "", ErrPathNotExist
+	}
+	if p == SharedRootPath {
+		return nil, "", ErrRootProtected
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(p, SharedRootPath+"/"), "/", 2)
+	rest := "/"
+	if len(parts) > 1 {
+		rest += parts[1]
+	}
+
+	entries, err := fs.sharedEntries()
+	if err != nil {
+		return nil, "", ErrDBListObjects.WithError(err)
+	}
+
+	for i := 0; i < len(entries); i++ {
+		if entries[i].name != parts[0] {
+			continue
+		}
+		if write && !entries[i].share.Writable {
+			return nil, "", ErrSharedReadOnly
+		}
+		if err := fs.switchOwner(entries[i].share.UserID); err != nil {
+			return nil, "", err
+		}
+		return &entries[i], rest, nil
+	}
+
+	return nil, "", ErrPathNotExist
+}
+
+// switchOwner 将文件系统切换为共享目录的所有者，此后的写入操作计入所有者的容量，
+// 并使用所有者的存储策略
+func (fs *FileSystem) switchOwner(uid uint) error {
+	if fs.Operator != nil {
+		if fs.User.ID != uid {
+			return ErrSharedOwnerMismatch
+		}
+		return nil
+	}
+
+	owner, err := model.GetActiveUserByID(uid)
+	if err != nil {
+		return ErrPathNotExist
+	}
+
+	fs.Operator = fs.User
+	fs.User = &owner
+	fs.Policy = nil
+	return fs.DispatchHandler()
+}
+
+// EnterSharedPath 若 p 位于“与我共享”中的目录下，将文件系统切换至目录所有者，并返回 p
+// 在所有者文件系统中的路径；否则原样返回 p。write 为 true 时要求共享允许写入
+func (fs *FileSystem) EnterSharedPath(p string, write bool) (string, error) {
+	if fs.Root != nil || !IsSharedPath(p) {
+		// 已切换至他人的文件系统时，不能再访问自己的目录
+		if fs.Operator != nil {
+			return "", ErrSharedOwnerMismatch
+		}
+		return p, nil
+	}
+
+	entry, rest, err := fs.enterShared(p, write)
+	if err != nil {
+		return "", err
+	}
+
+	folder := entry.folder
+	if err := folder.TraceRoot(); err != nil {
+		return "", ErrPathNotExist
+	}
+	return path.Join(folder.Position, folder.Name, rest), nil
+}
+
+// MountShared 将路径 p 所在的共享目录作为文件系统的根目录，并切换至目录所有者，
+// 返回共享目录在“与我共享”中的路径
+func (fs *FileSystem) MountShared(p string, write bool) (string, error) {
+	if fs.Root != nil {
+		return "", ErrPathNotExist
+	}
+
+	entry, _, err := fs.enterShared(p, write)
+	if err != nil {
+		return "", err
+	}
+
+	root := entry.folder
+	root.Position = ""
+	root.Name = "/"
+	fs.Root = &root
+	return path.Join(SharedRootPath, entry.name), nil
+}
+
+// EnterSharedObjects 若目录 dirs 及文件 files 不属于当前用户，检查其是否位于共享给当前用户的
+// 目录中，并将文件系统切换至其所有者。被共享的目录本身只能由所有者操作
+func (fs *FileSystem) EnterSharedObjects(dirs, files []uint, write bool) error {
+	if len(dirs)+len(files) == 0 {
+		return nil
+	}
+
+	// 对象均属于当前用户时无需切换
+	if fs.Operator == nil {
+		folders, _ := model.GetFoldersByIDs(dirs, fs.User.ID)
+		fileObjects, _ := model.GetFilesByIDs(files, fs.User.ID)
+		if len(folders) == len(dirs) && len(fileObjects) == len(files) {
+			return nil
+		}
+	}
+
+	owner, writable, err := model.CheckInternalShareOfObjects(fs.operator(), dirs, files)
+	if err != nil {
+		return ErrObjectNotExist
+	}
+	if write && !writable {
+		return ErrSharedReadOnly
+	}
+
+	return fs.switchOwner(owner)
+}
+
+// sharedFile 查找位于共享给当前用户的目录中的文件，不切换文件系统，用于只读操作
+func (fs *FileSystem) sharedFile(id uint) (*model.File, error) {
+	if _, _, err := model.CheckInternalShareOfObjects(fs.operator(), nil, []uint{id}); err != nil {
+		return nil, ErrObjectNotExist
+	}
+
+	files, err := model.GetFilesByIDs([]uint{id}, 0)
+	if err != nil || len(files) == 0 {
+		return nil, ErrObjectNotExist
+	}
+	return &files[0], nil
+}
+
+// enterSharedSession 将文件系统切换至分片上传会话所在共享目录的所有者，并重新检查写入权限
+func (fs *FileSystem) enterSharedSession(session *serializer.ChunkUploadSession) error {
+	if err := fs.switchOwner(session.UID); err != nil {
+		return err
+	}
+
+	exist, folder := fs.IsPathExist(session.VirtualPath)
+	if !exist {
+		return ErrPathNotExist
+	}
+
+	share, err := model.GetInternalShareOfFolder(fs.Operator, folder.ID)
+	if err != nil || share.UserID != session.UID {
+		return ErrUploadSessionNotExist
+	}
+	if !share.Writable {
+		return ErrSharedReadOnly
+	}
+
+	return nil
+}
+
+// listShared 列出“与我共享”虚拟目录，或其中共享目录下的内容
+func (fs *FileSystem) listShared(ctx context.Context, dirPath string, pathProcessor func(string) string) ([]Object, error) {
+	dirPath = path.Clean(dirPath)
+	if dirPath == SharedRootPath {
+		folders, err := fs.SharedFolders()
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+		return fs.listObjects(ctx, SharedRootPath, nil, folders, pathProcessor), nil
+	}
+
+	entry, rest, err := fs.enterShared(dirPath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 以共享目录作为根目录，子对象的路径即为其在“与我共享”下的路径
+	root := entry.folder
+	root.Position = SharedRootPath
+	root.Name = entry.name
+	fs.Root = &root
+
+	return fs.List(ctx, rest, pathProcessor)
+}
@@ -0,0 +1,121 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIsSharedPath(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(IsSharedPath("/与我共享"))
+	asserts.True(IsSharedPath("/与我共享/"))
+	asserts.True(IsSharedPath("/与我共享/docs/a"))
+	asserts.False(IsSharedPath("/"))
+	asserts.False(IsSharedPath("/与我共享2"))
+	asserts.False(IsSharedPath("/docs/与我共享"))
+}
+
+func TestIsReservedName(t *testing.T) {
+	asserts := assert.New(t)
+	parent := uint(1)
+	asserts.True(isReservedName(&model.Folder{}, SharedFolderName))
+	asserts.False(isReservedName(&model.Folder{}, "docs"))
+	asserts.False(isReservedName(&model.Folder{ParentID: &parent}, SharedFolderName))
+}
+
+func TestFileSystem_EnterSharedPath(t *testing.T) {
+	asserts := assert.New(t)
+	user := &model.User{Model: gorm.Model{ID: 1}, GroupID: 2}
+
+	// 非共享路径原样返回
+	{
+		fs := &FileSystem{User: user}
+		p, err := fs.EnterSharedPath("/docs", true)
+		asserts.NoError(err)
+		asserts.Equal("/docs", p)
+		asserts.Nil(fs.Operator)
+	}
+
+	// 已切换至他人文件系统时不能访问自己的目录
+	{
+		fs := &FileSystem{User: &model.User{}, Operator: user}
+		_, err := fs.EnterSharedPath("/docs", true)
+		asserts.Equal(ErrSharedOwnerMismatch, err)
+	}
+
+	// 虚拟目录本身
+	{
+		fs := &FileSystem{User: user}
+		_, err := fs.EnterSharedPath(SharedRootPath, false)
+		asserts.Equal(ErrRootProtected, err)
+	}
+
+	// 只读共享
+	{
+		fs := &FileSystem{User: user}
+		mock.ExpectQuery("SELECT(.+)internal_shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id", "writable"}).AddRow(1, 5, 10, false))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(10, "docs", 5))
+		_, err := fs.EnterSharedPath("/与我共享/docs/a", true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrSharedReadOnly, err)
+		asserts.Nil(fs.Operator)
+	}
+
+	// 共享不存在
+	{
+		fs := &FileSystem{User: user}
+		mock.ExpectQuery("SELECT(.+)internal_shares(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := fs.EnterSharedPath("/与我共享/docs", false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrPathNotExist, err)
+	}
+}
+
+func TestFileSystem_SharedFolders(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}, GroupID: 2}}
+
+	// 重名目录及已删除目录
+	mock.ExpectQuery("SELECT(.+)internal_shares(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id", "writable"}).
+			AddRow(1, 5, 10, false).
+			AddRow(2, 6, 20, false).
+			AddRow(3, 5, 10, true).
+			AddRow(4, 7, 30, false))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(10, "docs", 5))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(20, "docs", 6))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}))
+	folders, err := fs.SharedFolders()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(folders, 2)
+	asserts.Equal("docs", folders[0].Name)
+	asserts.Equal("docs (2)", folders[1].Name)
+	asserts.Equal(SharedRootPath, folders[0].Position)
+}
+
+func TestFileSystem_ListSharedRoot(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}, GroupID: 2}}
+
+	mock.ExpectQuery("SELECT(.+)internal_shares(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "folder_id"}).AddRow(1, 5, 10))
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(10, "docs", 5))
+	objects, err := fs.List(context.Background(), SharedRootPath, nil)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(objects, 1)
+	asserts.Equal("docs", objects[0].Name)
+	asserts.Equal(SharedRootPath, objects[0].Path)
+}
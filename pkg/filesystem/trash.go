@@ -0,0 +1,308 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"path"
+	"strings"
+)
+
+/* =================
+	   回收站相关
+   =================
+*/
+
+// Trash 将目录和文件移入回收站，移入回收站的对象不会释放占用的容量
+func (fs *FileSystem) Trash(ctx context.Context, dirs, files []uint) (err error) {
+	defer fs.recordAudit(ctx, audit.ActionTrash, fs.auditObjects(dirs, files), &err)
+
+	// 移入目录
+	if len(dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := 0; i < len(folders); i++ {
+			if folders[i].ParentID == nil {
+				return ErrRootProtected
+			}
+			if err := fs.trashFolder(&folders[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 移入文件
+	if len(files) > 0 {
+		fileObjects, err := model.GetFilesByIDs(files, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for i := 0; i < len(fileObjects); i++ {
+			if err := fs.trashFile(&fileObjects[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// trashFolder 将单个目录及其子对象移入回收站
+func (fs *FileSystem) trashFolder(folder *model.Folder) error {
+	// 计算目录所在路径
+	if err := folder.TraceRoot(); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	// 统计目录下所有文件的大小
+	subFolders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	subFiles, err := model.GetChildFilesOfFolders(&subFolders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	var size uint64
+	for _, file := range subFiles {
+		size += file.Size
+	}
+
+	trash := model.Trash{
+		UserID:     fs.User.ID,
+		ObjectType: model.TrashFolderType,
+		ObjectID:   folder.ID,
+		Name:       folder.Name,
+		OriginPath: folder.Position,
+		Size:       size,
+	}
+	if _, err := trash.Create(); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	if err := folder.MoveToTrash(&trash); err != nil {
+		trash.Delete()
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	// 回收站中的文件不出现在搜索结果中
+	fileIDs := make([]uint, 0, len(subFiles))
+	for _, file := range subFiles {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	search.Default.Delete(fileIDs...)
+
+	return nil
+}
+
+// trashFile 将单个文件移入回收站
+func (fs *FileSystem) trashFile(file *model.File) error {
+	// 计算文件所在路径
+	parents, err := model.GetFoldersByIDs([]uint{file.FolderID}, fs.User.ID)
+	if err != nil || len(parents) == 0 {
+		return ErrPathNotExist
+	}
+	if err := parents[0].TraceRoot(); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	trash := model.Trash{
+		UserID:     fs.User.ID,
+		ObjectType: model.TrashFileType,
+		ObjectID:   file.ID,
+		Name:       file.Name,
+		OriginPath: path.Join(parents[0].Position, parents[0].Name),
+		Size:       file.Size,
+	}
+	if _, err := trash.Create(); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	if err := file.MoveToTrash(&trash); err != nil {
+		trash.Delete()
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	search.Default.Delete(file.ID)
+	return nil
+}
+
+// RestoreTrash 将回收站中的对象恢复至原路径，原路径不存在时会重新创建，
+// 与已有对象重名时自动重命名
+func (fs *FileSystem) RestoreTrash(ctx context.Context, ids []uint) error {
+	trashes, err := model.GetTrashByIDs(ids, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	for i := 0; i < len(trashes); i++ {
+		err := fs.restoreTrash(ctx, &trashes[i])
+		audit.RecordContext(ctx, fs.User, audit.ActionRestore, path.Join(trashes[i].OriginPath, trashes[i].Name), err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreTrash 恢复单个回收站对象
+func (fs *FileSystem) restoreTrash(ctx context.Context, trash *model.Trash) error {
+	// 获取或重新创建原父目录
+	exist, parent := fs.IsPathExist(trash.OriginPath)
+	if !exist {
+		var err error
+		parent, err = fs.CreateDirectory(ctx, trash.OriginPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch trash.ObjectType {
+	case model.TrashFileType:
+		file, err := model.GetTrashedFile(trash.ObjectID, fs.User.ID)
+		if err != nil {
+			return ErrObjectNotExist.WithError(err)
+		}
+		if err := file.RestoreTo(parent, fs.availableName(parent, trash.Name, true)); err != nil {
+			return serializer.NewError(serializer.CodeDBError, "无法恢复文件", err)
+		}
+		indexFile(file, file.GetPolicy(), false)
+	case model.TrashFolderType:
+		folder, err := model.GetTrashedFolder(trash.ObjectID, fs.User.ID)
+		if err != nil {
+			return ErrObjectNotExist.WithError(err)
+		}
+		if err := folder.RestoreTo(parent, fs.availableName(parent, trash.Name, false)); err != nil {
+			return serializer.NewError(serializer.CodeDBError, "无法恢复目录", err)
+		}
+		fs.indexRestoredFolder(folder)
+	}
+
+	if err := trash.Delete(); err != nil {
+		util.Log().Warning("无法删除回收站记录[%d], %s", trash.ID, err)
+	}
+	return nil
+}
+
+// indexRestoredFolder 为从回收站恢复的目录下的文件重新建立索引
+func (fs *FileSystem) indexRestoredFolder(folder *model.Folder) {
+	folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		util.Log().Warning("无法为恢复的目录[%d]建立搜索索引, %s", folder.ID, err)
+		return
+	}
+	files, err := model.GetChildFilesOfFolders(&folders)
+	if err != nil {
+		util.Log().Warning("无法为恢复的目录[%d]建立搜索索引, %s", folder.ID, err)
+		return
+	}
+
+	for i := 0; i < len(files); i++ {
+		indexFile(&files[i], files[i].GetPolicy(), false)
+	}
+}
+
+// availableName 返回parent目录下不与已有对象重名的名称，
+// 重名时在名称（扩展名之前）追加序号
+func (fs *FileSystem) availableName(parent *model.Folder, name string, isFile bool) string {
+	var ext string
+	if isFile {
+		ext = path.Ext(name)
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	newName := name
+	for i := 1; fs.isChildNameTaken(parent, newName); i++ {
+		newName = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+
+	return newName
+}
+
+// isChildNameTaken 确定folder目录下是否已有名为name的文件或目录
+func (fs *FileSystem) isChildNameTaken(folder *model.Folder, name string) bool {
+	if ok, _ := fs.IsChildFileExist(folder, name); ok {
+		return true
+	}
+	_, err := folder.GetChild(name)
+	return err == nil
+}
+
+// PurgeTrash 彻底删除回收站中的对象，并归还其占用的容量
+func (fs *FileSystem) PurgeTrash(ctx context.Context, ids []uint) error {
+	trashes, err := model.GetTrashByIDs(ids, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	return fs.purgeTrash(ctx, trashes)
+}
+
+// EmptyTrash 清空用户的回收站
+func (fs *FileSystem) EmptyTrash(ctx context.Context) error {
+	trashes, err := model.GetTrashByUID(fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	return fs.purgeTrash(ctx, trashes)
+}
+
+// purgeTrash 逐条彻底删除回收站对象，删除失败的记录会被保留以便下次重试
+func (fs *FileSystem) purgeTrash(ctx context.Context, trashes []model.Trash) error {
+	failed := 0
+	for i := 0; i < len(trashes); i++ {
+		fs.CleanTargets()
+
+		switch trashes[i].ObjectType {
+		case model.TrashFileType:
+			if file, err := model.GetTrashedFile(trashes[i].ObjectID, fs.User.ID); err == nil {
+				fs.SetTargetFile(&[]model.File{*file})
+			}
+		case model.TrashFolderType:
+			folders, err := model.GetTrashedChildFolder(trashes[i].ObjectID, fs.User.ID)
+			if err != nil {
+				failed++
+				continue
+			}
+			files, err := model.GetTrashedChildFiles(folders)
+			if err != nil {
+				failed++
+				continue
+			}
+			fs.SetTargetDir(&folders)
+			fs.SetTargetFile(&files)
+		}
+
+		err := fs.deleteTargets(ctx, false)
+		audit.RecordContext(ctx, fs.User, audit.ActionDelete, path.Join(trashes[i].OriginPath, trashes[i].Name), err)
+		if err != nil {
+			util.Log().Warning("无法彻底删除回收站对象[%d], %s", trashes[i].ID, err)
+			failed++
+			continue
+		}
+
+		if err := trashes[i].Delete(); err != nil {
+			util.Log().Warning("无法删除回收站记录[%d], %s", trashes[i].ID, err)
+		}
+	}
+	fs.CleanTargets()
+
+	if failed > 0 {
+		return serializer.NewError(
+			serializer.CodeNotFullySuccess,
+			fmt.Sprintf("有 %d 个对象未能彻底删除", failed),
+			nil,
+		)
+	}
+	return nil
+}
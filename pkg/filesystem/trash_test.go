@@ -0,0 +1,371 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestFileSystem_Trash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	// 移入文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "folder_id", "user_id", "size"}).AddRow(1, "1.txt", 2, 1, 10))
+		// 计算所在路径
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(2, "dir", 1, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(1, "/", nil, 1))
+		// 创建回收站记录
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)trashes(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, model.TrashFileType, 1, "1.txt", "/dir", 10).
+			WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		// 重命名并脱离原目录
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs(sqlmock.AnyArg(), 0, "trash_5", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		search.Default.Put(search.Document{ID: 1, UID: 1, Name: "1.txt"})
+		asserts.NoError(fs.Trash(ctx, nil, []uint{1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+		_, exist := search.Default.Get(1)
+		asserts.False(exist)
+	}
+
+	// 移入目录，记录其下所有文件的大小
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(3, "sub", 1, 1))
+		// 计算所在路径
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(1, "/", nil, 1))
+		// 统计子文件大小
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(3, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(4, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(3, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size"}).AddRow(6, 5).AddRow(7, 7))
+		// 创建回收站记录
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)trashes(.+)").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, model.TrashFolderType, 3, "sub", "/", 12).
+			WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectCommit()
+		// 标记子对象并移动目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(3, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(4, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").
+			WithArgs(sqlmock.AnyArg(), "trash_6", sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		asserts.NoError(fs.Trash(ctx, []uint{3}, nil))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 根目录不能移入回收站
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(1, "/", nil, 1))
+		asserts.Equal(ErrRootProtected, fs.Trash(ctx, []uint{1}, nil))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 移入失败时删除回收站记录
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "folder_id", "user_id", "size"}).AddRow(1, "1.txt", 2, 1, 10))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(2, "/", nil, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)trashes(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := fs.Trash(ctx, nil, []uint{1})
+		asserts.Error(err)
+		asserts.Equal(ErrDBDeleteObjects.Code, err.(serializer.AppError).Code)
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileSystem_RestoreTrash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	asserts.NoError(cache.Set("policy_1", model.Policy{Model: gorm.Model{ID: 1}, Type: "oss"}, -1))
+	defer cache.Deletes([]string{"policy_1"}, "")
+
+	// 原父目录已被删除时重新创建
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "object_type", "object_id", "name", "origin_path"}).
+				AddRow(5, 1, model.TrashFileType, 1, "1.txt", "/dir"))
+		// 原父目录不存在
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1, "dir").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		// 重新创建
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "dir").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(8, 1))
+		mock.ExpectCommit()
+		// 恢复文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id", "policy_id"}).AddRow(1, "trash_5", 1, 1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(8, "1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(8, 1, "1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs(8, "1.txt", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除回收站记录
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		asserts.NoError(fs.RestoreTrash(ctx, []uint{5}))
+		asserts.NoError(mock.ExpectationsWereMet())
+		_, exist := search.Default.Get(1)
+		asserts.True(exist)
+		search.Default.Delete(1)
+	}
+
+	// 与已有对象重名时自动重命名
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WithArgs(6, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "object_type", "object_id", "name", "origin_path"}).
+				AddRow(6, 1, model.TrashFolderType, 3, "sub", "/"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(3, "trash_6", 1))
+		// sub 已被占用
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "sub").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1, "sub").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(9, "sub"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, "sub (1)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 1, "sub (1)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		// 恢复目录及其子对象
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(3, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE(.+)folders(.+)").
+			WithArgs("sub (1)", 1, sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 重新建立索引
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(3, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WithArgs(6).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		asserts.NoError(fs.RestoreTrash(ctx, []uint{6}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 其他用户的回收站记录
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WithArgs(7, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}))
+		asserts.NoError(fs.RestoreTrash(ctx, []uint{7}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileSystem_PurgeTrash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}, Storage: 15}}
+	ctx := context.Background()
+	asserts.NoError(cache.Set("policy_1", model.Policy{Model: gorm.Model{ID: 1}, Type: "local"}, -1))
+	defer cache.Deletes([]string{"policy_1"}, "")
+
+	// 其他用户的回收站记录
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WithArgs(7, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}))
+		asserts.NoError(fs.PurgeTrash(ctx, []uint{7}))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(15, fs.User.Storage)
+	}
+
+	// 彻底删除并归还容量
+	{
+		file, err := os.Create(util.RelativePath("purge_trash.txt"))
+		asserts.NoError(err)
+		file.Close()
+
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WithArgs(5, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "object_type", "object_id", "name", "origin_path", "size"}).
+				AddRow(5, 1, model.TrashFileType, 1, "1.txt", "/", 10))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id", "policy_id", "source_name", "size"}).
+				AddRow(1, "trash_5", 1, 1, "purge_trash.txt", 10))
+		// 查找软连接
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 查找被历史版本引用的文件
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 删除文件记录
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)files(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除对应分享
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 删除WebDAV属性
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)webdav_properties(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 查找文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 归还容量
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)users(.+)").WithArgs(10, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 文件全部删除成功，继续删除目录
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)folders(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)internal_shares(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 删除回收站记录
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)trashes(.+)").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		asserts.NoError(fs.PurgeTrash(ctx, []uint{5}))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(5, fs.User.Storage)
+		asserts.False(util.Exists(util.RelativePath("purge_trash.txt")))
+	}
+
+	// 物理文件删除失败时保留回收站记录
+	{
+		mock.ExpectQuery("SELECT(.+)trashes(.+)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "object_type", "object_id", "name", "origin_path", "size"}).
+				AddRow(5, 1, model.TrashFileType, 1, "1.txt", "/", 5))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id", "policy_id", "source_name", "size"}).
+				AddRow(1, "trash_5", 1, 1, "purge_trash_not_exist.txt", 5))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)share_accesses(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)shares(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := fs.EmptyTrash(ctx)
+		asserts.Error(err)
+		asserts.Equal(serializer.CodeNotFullySuccess, err.(serializer.AppError).Code)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(5, fs.User.Storage)
+	}
+}
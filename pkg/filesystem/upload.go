@@ -31,6 +31,10 @@ func (fs *FileSystem) Upload(ctx context.Context, file FileHeader) (err error) {
 		return err
 	}
 
+	// 钩子可能重设存储策略，此后再决定是否计算内容摘要
+	file = fs.withContentHash(file)
+	ctx = context.WithValue(ctx, fsctx.FileHeaderCtx, file)
+
 	// 生成文件名和路径,
 	var savePath string
 	// 如果是更新操作就从上下文中获取
@@ -228,7 +232,7 @@ func (fs *FileSystem) UploadFromStream(ctx context.Context, src io.ReadCloser, d
 	filePath := path.Dir(dst)
 	fileName, _ = fs.GetUniqueFileName(ctx, fileName, filePath)
 	fileData := local.FileStream{
-		File:        src,
+		File:        WithProgress(ctx, src, size),
 		Size:        size,
 		Name:        fileName,
 		VirtualPath: filePath,
@@ -0,0 +1,187 @@
+package filesystem
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"path"
+)
+
+/* =================
+	 文件历史版本
+   =================
+*/
+
+// IsVersionEnabled 返回当前用户覆盖文件时是否保留历史版本
+func (fs *FileSystem) IsVersionEnabled() bool {
+	return fs.User.Group.OptionsSerialized.MaxVersions > 0
+}
+
+// GenerateVersionSavePath 为覆盖已有文件的新内容生成物理路径，
+// 文件名前追加随机字符串，避免与原有内容或其他历史版本冲突
+func (fs *FileSystem) GenerateVersionSavePath(ctx context.Context, file FileHeader) string {
+	savePath := fs.GenerateSavePath(ctx, file)
+	return path.Join(path.Dir(savePath), util.RandStringRunes(8)+"_"+path.Base(savePath))
+}
+
+// ListFileVersions 列出文件的历史版本
+func (fs *FileSystem) ListFileVersions(ctx context.Context, fileID uint) ([]model.FileVersion, error) {
+	if _, err := fs.getVersionedFile(fileID); err != nil {
+		return nil, err
+	}
+
+	versions, err := model.GetVersionsByFileID(fileID, fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+	return versions, nil
+}
+
+// GetVersionDownloadURL 创建文件历史版本的下载链接
+func (fs *FileSystem) GetVersionDownloadURL(ctx context.Context, fileID, versionID uint, timeout string) (string, error) {
+	file, version, err := fs.getFileVersion(fileID, versionID)
+	if err != nil {
+		return "", err
+	}
+
+	versionFile := version.AsFile(file)
+	ttl := model.GetIntSetting(timeout, 60)
+	return fs.signURL(ctx, &versionFile, int64(ttl), true)
+}
+
+// RestoreFileVersion 将文件恢复至指定历史版本，当前内容会被保存为新的历史版本
+func (fs *FileSystem) RestoreFileVersion(ctx context.Context, fileID, versionID uint) error {
+	file, version, err := fs.getFileVersion(fileID, versionID)
+	if err != nil {
+		return err
+	}
+
+	if err := file.RestoreVersion(version); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "无法恢复历史版本", err)
+	}
+
+	indexFile(file, file.GetPolicy(), false)
+	return nil
+}
+
+// DeleteFileVersions 删除文件的历史版本，并归还其占用的容量
+func (fs *FileSystem) DeleteFileVersions(ctx context.Context, fileID uint, versionIDs []uint) error {
+	if _, err := fs.getVersionedFile(fileID); err != nil {
+		return err
+	}
+
+	versions, err := model.GetVersionsByIDs(versionIDs, fileID, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	return fs.deleteFileVersions(ctx, versions)
+}
+
+// getVersionedFile 查找当前用户的文件
+func (fs *FileSystem) getVersionedFile(fileID uint) (*model.File, error) {
+	files, err := model.GetFilesByIDs([]uint{fileID}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return nil, ErrObjectNotExist
+	}
+	return &files[0], nil
+}
+
+// getFileVersion 查找当前用户的文件及其指定历史版本
+func (fs *FileSystem) getFileVersion(fileID, versionID uint) (*model.File, *model.FileVersion, error) {
+	file, err := fs.getVersionedFile(fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	versions, err := model.GetVersionsByIDs([]uint{versionID}, fileID, fs.User.ID)
+	if err != nil || len(versions) == 0 {
+		return nil, nil, serializer.NewError(serializer.CodeNotFound, "历史版本不存在", err)
+	}
+
+	return file, &versions[0], nil
+}
+
+// replaceWithVersion 将文件的原有内容保存为历史版本，文件改为指向新上传的物理文件，
+// 并清理超出用户组限制的历史版本
+func (fs *FileSystem) replaceWithVersion(ctx context.Context, file *model.File, sourceName, hash string, size uint64, policyID uint, encrypted bool) error {
+	if err := file.ReplaceContent(sourceName, hash, size, policyID, encrypted); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "无法保存历史版本", err)
+	}
+
+	fs.pruneFileVersions(ctx, file)
+	return nil
+}
+
+// pruneFileVersions 删除超出用户组保留数量的较早历史版本
+func (fs *FileSystem) pruneFileVersions(ctx context.Context, file *model.File) {
+	versions, err := model.GetVersionsByFileID(file.ID, fs.User.ID)
+	if err != nil {
+		util.Log().Warning("无法列取文件[%d]的历史版本, %s", file.ID, err)
+		return
+	}
+
+	max := fs.User.Group.OptionsSerialized.MaxVersions
+	if max < 0 {
+		max = 0
+	}
+	if len(versions) <= max {
+		return
+	}
+
+	if err := fs.deleteFileVersions(ctx, versions[max:]); err != nil {
+		util.Log().Warning("无法清理文件[%d]的历史版本, %s", file.ID, err)
+	}
+}
+
+// deleteFileVersions 删除历史版本的物理文件及记录，并归还其占用的容量，
+// 物理文件仍被其他文件或版本引用时仅删除记录
+func (fs *FileSystem) deleteFileVersions(ctx context.Context, versions []model.FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	// 删除物理文件会切换存储策略，结束后还原
+	policy, handler := fs.Policy, fs.Handler
+	defer func() {
+		fs.Policy, fs.Handler = policy, handler
+	}()
+
+	// 去除物理文件仍被引用的版本
+	toBeDeleted, err := model.RemoveVersionsWithSoftLinks(versions)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	// 按照存储策略分组删除物理文件
+	files := make([]model.File, 0, len(toBeDeleted))
+	for _, version := range toBeDeleted {
+		files = append(files, model.File{
+			SourceName: version.SourceName,
+			PolicyID:   version.PolicyID,
+		})
+	}
+	failed := fs.deleteGroupedFile(ctx, fs.GroupFileByPolicy(ctx, files))
+	for policyID, sources := range failed {
+		if len(sources) > 0 {
+			util.Log().Warning("无法删除存储策略[%d]下的历史版本物理文件 %v", policyID, sources)
+		}
+	}
+
+	// 删除记录并归还容量
+	var (
+		ids   = make([]uint, 0, len(versions))
+		total uint64
+	)
+	for _, version := range versions {
+		ids = append(ids, version.ID)
+		total += version.Size
+	}
+	if err := model.DeleteVersionsByIDs(ids); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+	fs.User.DeductionStorage(total)
+
+	return nil
+}
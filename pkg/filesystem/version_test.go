@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"path"
+	"testing"
+)
+
+func TestFileSystem_IsVersionEnabled(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	asserts.False(fs.IsVersionEnabled())
+
+	fs.User.Group.OptionsSerialized.MaxVersions = 3
+	asserts.True(fs.IsVersionEnabled())
+}
+
+func TestFileSystem_GenerateVersionSavePath(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+		Policy: model.Policy{
+			DirNameRule:  "uploads/{uid}/{path}",
+			FileNameRule: "{originname}",
+		},
+	}}
+	file := local.FileStream{Name: "1.txt", VirtualPath: "/"}
+
+	origin := fs.GenerateSavePath(context.Background(), file)
+	first := fs.GenerateVersionSavePath(context.Background(), file)
+	second := fs.GenerateVersionSavePath(context.Background(), file)
+	asserts.NotEqual(origin, first)
+	asserts.NotEqual(first, second)
+	asserts.Equal(path.Dir(origin), path.Dir(first))
+}
+
+func TestFileSystem_ListFileVersions(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	// 文件不存在
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		res, err := fs.ListFileVersions(ctx, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrObjectNotExist, err)
+		asserts.Nil(res)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(1))
+		res, err := fs.ListFileVersions(ctx, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+	}
+}
+
+func TestFileSystem_DeleteFileVersions(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}, Storage: 10}}
+	ctx := context.Background()
+
+	// 物理文件仍被引用，仅删除记录并归还容量
+	mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id", "size"}).AddRow(2, "1.txt", 1, 3))
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(1, "1.txt", 1))
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)users(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := fs.DeleteFileVersions(ctx, 1, []uint{2})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(7, fs.User.Storage)
+}
@@ -8,12 +8,14 @@ import "github.com/speps/go-hashids"
 
 // ID类型
 const (
-	ShareID  = iota // 分享
-	UserID          // 用户
-	FileID          // 文件ID
-	FolderID        // 目录ID
-	TagID           // 标签ID
-	PolicyID        // 存储策略ID
+	ShareID   = iota // 分享
+	UserID           // 用户
+	FileID           // 文件ID
+	FolderID         // 目录ID
+	TagID            // 标签ID
+	PolicyID         // 存储策略ID
+	TrashID          // 回收站记录ID
+	VersionID        // 文件历史版本ID
 )
 
 var (
@@ -0,0 +1,150 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	goldap "github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("LDAP 用户名或密码错误")
+	// ErrUnsupportedScheme 不支持的服务器地址协议
+	ErrUnsupportedScheme = errors.New("LDAP 服务器地址须以 ldap:// 或 ldaps:// 开头")
+	// ErrStartTLSOverLDAPS ldaps:// 连接无需再使用 StartTLS
+	ErrStartTLSOverLDAPS = errors.New("ldaps:// 连接无需启用 StartTLS")
+)
+
+// Entry 搜索结果中的条目
+type Entry struct {
+	DN string
+	// Attributes 属性名（小写）到属性值的映射
+	Attributes map[string][]string
+}
+
+// Get 返回属性 name 的第一个值
+func (entry *Entry) Get(name string) string {
+	if values := entry.Attributes[strings.ToLower(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// GetAll 返回属性 name 的全部值
+func (entry *Entry) GetAll(name string) []string {
+	return entry.Attributes[strings.ToLower(name)]
+}
+
+// Conn LDAP 连接，不支持并发操作
+type Conn struct {
+	conn    *goldap.Conn
+	timeout time.Duration
+}
+
+// Dial 连接至 LDAP 服务器，address 形如 ldap://host:389 或 ldaps://host:636。
+// startTLS 为 true 时在 ldap:// 连接上使用 StartTLS 升级为加密连接
+func Dial(address string, timeout time.Duration, startTLS, skipVerify bool) (*Conn, error) {
+	target, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         target.Hostname(),
+		InsecureSkipVerify: skipVerify,
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn *goldap.Conn
+	switch target.Scheme {
+	case "ldap":
+		host := target.Host
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "389")
+		}
+		conn, err = goldap.DialURL("ldap://"+host, goldap.DialWithDialer(dialer))
+	case "ldaps":
+		if startTLS {
+			return nil, ErrStartTLSOverLDAPS
+		}
+		host := target.Host
+		if target.Port() == "" {
+			host = net.JoinHostPort(target.Hostname(), "636")
+		}
+		conn, err = goldap.DialURL("ldaps://"+host, goldap.DialWithDialer(dialer), goldap.DialWithTLSConfig(tlsConfig))
+	default:
+		return nil, ErrUnsupportedScheme
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+
+	if startTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return &Conn{conn: conn, timeout: timeout}, nil
+}
+
+// Close 关闭连接
+func (c *Conn) Close() error {
+	c.conn.Close()
+	return nil
+}
+
+// Bind 使用 DN 和密码进行简单绑定。空密码会被服务器视为匿名绑定，须由调用方拒绝
+func (c *Conn) Bind(dn, password string) error {
+	if err := c.conn.Bind(dn, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	return nil
+}
+
+// Search 在 base 下搜索符合 filter 的条目，只返回 attributes 中的属性
+func (c *Conn) Search(base, filter string, attributes []string) ([]*Entry, error) {
+	return c.search(base, goldap.ScopeWholeSubtree, filter, attributes)
+}
+
+// Read 读取 dn 对应的条目，不存在时返回空
+func (c *Conn) Read(dn string, attributes []string) (*Entry, error) {
+	entries, err := c.search(dn, goldap.ScopeBaseObject, "(objectClass=*)", attributes)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+func (c *Conn) search(base string, scope int, filter string, attributes []string) ([]*Entry, error) {
+	res, err := c.conn.Search(goldap.NewSearchRequest(
+		base, scope, goldap.NeverDerefAliases, 0, int(c.timeout/time.Second), false,
+		filter, attributes, nil,
+	))
+	if err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(res.Entries))
+	for _, item := range res.Entries {
+		entry := &Entry{DN: item.DN, Attributes: make(map[string][]string)}
+		for _, attr := range item.Attributes {
+			name := strings.ToLower(attr.Name)
+			entry.Attributes[name] = append(entry.Attributes[name], attr.Values...)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
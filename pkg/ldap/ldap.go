@@ -0,0 +1,286 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/util"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// dialTimeout 连接及单次操作的超时时间
+const dialTimeout = 10 * time.Second
+
+var (
+	// ErrNotLDAPUser 用户不在目录中且未关联 LDAP 身份，应继续使用本地密码校验
+	ErrNotLDAPUser = errors.New("用户不是 LDAP 用户")
+	// ErrUserRemoved 用户已从目录中移除
+	ErrUserRemoved = errors.New("用户已从 LDAP 目录中移除")
+)
+
+// Enabled 返回是否启用了 LDAP 认证
+func Enabled() bool {
+	return model.IsTrueVal(model.GetSettingByName("ldap_enabled"))
+}
+
+// directory 使用服务账号绑定的目录连接
+type directory struct {
+	conn    *Conn
+	options map[string]string
+}
+
+// openDirectory 连接至目录服务器，并使用服务账号绑定
+func openDirectory() (*directory, error) {
+	options := model.GetSettingByNames(
+		"ldap_url",
+		"ldap_start_tls",
+		"ldap_skip_verify",
+		"ldap_bind_dn",
+		"ldap_bind_password",
+		"ldap_base_dn",
+		"ldap_user_filter",
+		"ldap_nick_attr",
+		"ldap_group_attr",
+		"ldap_group_mapping",
+		"ldap_default_group",
+	)
+
+	conn, err := Dial(
+		options["ldap_url"],
+		dialTimeout,
+		model.IsTrueVal(options["ldap_start_tls"]),
+		model.IsTrueVal(options["ldap_skip_verify"]),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未设置服务账号时匿名搜索
+	if options["ldap_bind_dn"] != "" {
+		if err := conn.Bind(options["ldap_bind_dn"], options["ldap_bind_password"]); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return &directory{conn: conn, options: options}, nil
+}
+
+// find 按邮箱查找用户条目，不存在或匹配多个条目时返回 nil
+func (dir *directory) find(email string) (*Entry, error) {
+	filter := strings.Replace(dir.options["ldap_user_filter"], "%s", goldap.EscapeFilter(email), -1)
+	entries, err := dir.conn.Search(dir.options["ldap_base_dn"], filter, []string{
+		dir.options["ldap_nick_attr"],
+		dir.options["ldap_group_attr"],
+	})
+	if err != nil || len(entries) != 1 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// group 根据映射规则计算条目对应的用户组，返回是否有规则匹配。组 DN 不区分大小写
+func (dir *directory) group(entry *Entry) (uint, bool) {
+	return model.MatchGroupMapping(
+		dir.options["ldap_group_mapping"],
+		"dn",
+		entry.GetAll(dir.options["ldap_group_attr"]),
+		true,
+	)
+}
+
+// provision 创建或更新条目对应的用户及身份关联，同步用户组，重新启用因移出目录被停用的用户
+func (dir *directory) provision(user *model.User, identity *model.LDAPIdentity, entry *Entry, email string) (*model.User, error) {
+	group, mapped := dir.group(entry)
+
+	if user == nil {
+		if !mapped {
+			group = uint(model.GetIntSetting("ldap_default_group", 2))
+		}
+		created, err := model.CreateExternalUser(email, group, entry.Get(dir.options["ldap_nick_attr"]))
+		if err != nil {
+			return nil, err
+		}
+		user = created
+	} else if mapped && user.GroupID != group {
+		if err := user.Update(map[string]interface{}{"group_id": group}); err != nil {
+			return nil, err
+		}
+	}
+
+	if identity == nil {
+		identity = &model.LDAPIdentity{UserID: user.ID, DN: entry.DN}
+		if _, err := identity.Create(); err != nil {
+			return nil, err
+		}
+	} else {
+		updates := make(map[string]interface{})
+		if identity.DN != entry.DN {
+			updates["dn"] = entry.DN
+		}
+		if identity.Disabled {
+			updates["disabled"] = false
+			user.SetStatus(model.Active)
+			util.Log().Info("LDAP 用户[%s]已重新加入目录，恢复启用", user.Email)
+		}
+		if len(updates) > 0 {
+			if err := identity.Update(updates); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 重新读取以加载用户组
+	res, err := model.GetUserByID(user.ID)
+	return &res, err
+}
+
+// disable 停用已从目录中移除的用户，已被管理员封禁的用户不做标记，避免重新加入目录后被解封
+func disable(user *model.User, identity *model.LDAPIdentity) {
+	if user.Status != model.Active {
+		return
+	}
+
+	user.SetStatus(model.Baned)
+	if err := identity.Update(map[string]interface{}{"disabled": true}); err != nil {
+		util.Log().Warning("无法更新 LDAP 身份关联, %s", err)
+	}
+	util.Log().Info("LDAP 用户[%s]已从目录中移除，停用此用户", user.Email)
+}
+
+// Authenticate 使用目录校验邮箱及密码，成功时返回对应的用户，用户不存在时自动创建。
+// 返回 ErrNotLDAPUser 时应继续使用本地密码校验
+func Authenticate(email, password string) (*model.User, error) {
+	// 空密码会被服务器视为匿名绑定
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	var (
+		user     *model.User
+		identity *model.LDAPIdentity
+	)
+	if existed, err := getUserByEmail(email); err == nil {
+		user = &existed
+		if res, err := model.GetLDAPIdentityByUserID(user.ID); err == nil {
+			identity = res
+		} else if !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+	}
+
+	// 目录不可用时，未关联 LDAP 身份的用户仍可使用本地密码登录
+	dir, err := openDirectory()
+	if err != nil {
+		util.Log().Warning("无法连接 LDAP 服务器, %s", err)
+		if identity == nil {
+			return nil, ErrNotLDAPUser
+		}
+		return nil, err
+	}
+	defer dir.conn.Close()
+
+	entry, err := dir.find(email)
+	if err != nil {
+		util.Log().Warning("无法搜索 LDAP 用户, %s", err)
+		if identity == nil {
+			return nil, ErrNotLDAPUser
+		}
+		return nil, err
+	}
+	if entry == nil {
+		if identity == nil {
+			return nil, ErrNotLDAPUser
+		}
+		disable(user, identity)
+		return nil, ErrUserRemoved
+	}
+
+	if err := dir.conn.Bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+
+	return dir.provision(user, identity, entry, email)
+}
+
+// Check 检查 LDAP 用户是否仍存在于目录中，移除的用户会被停用；未关联 LDAP 身份的用户直接通过。
+// 检查结果缓存 ldap_check_ttl 秒
+func Check(user *model.User) error {
+	key := fmt.Sprintf("ldap_check_%d", user.ID)
+	if _, ok := cache.Get(key); ok {
+		return nil
+	}
+
+	identity, err := model.GetLDAPIdentityByUserID(user.ID)
+	if err == nil {
+		dir, err := openDirectory()
+		if err != nil {
+			return err
+		}
+		defer dir.conn.Close()
+
+		entry, err := dir.find(user.Email)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			disable(user, identity)
+			return ErrUserRemoved
+		}
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	cache.Set(key, true, model.GetIntSetting("ldap_check_ttl", 300))
+	return nil
+}
+
+// Sync 检查所有关联了 LDAP 身份的用户，停用已从目录中移除的用户，并同步用户组
+func Sync() (disabled int, err error) {
+	identities, err := model.ListLDAPIdentities()
+	if err != nil || len(identities) == 0 {
+		return 0, err
+	}
+
+	dir, err := openDirectory()
+	if err != nil {
+		return 0, err
+	}
+	defer dir.conn.Close()
+
+	for i := range identities {
+		user, err := model.GetUserByID(identities[i].UserID)
+		if err != nil {
+			continue
+		}
+
+		entry, err := dir.find(user.Email)
+		if err != nil {
+			return disabled, err
+		}
+		if entry == nil {
+			if user.Status == model.Active {
+				disabled++
+			}
+			disable(&user, &identities[i])
+			continue
+		}
+
+		if _, err := dir.provision(&user, &identities[i], entry, user.Email); err != nil {
+			util.Log().Warning("无法同步 LDAP 用户[%s], %s", user.Email, err)
+		}
+	}
+
+	return disabled, nil
+}
+
+// getUserByEmail 按邮箱查找任意状态的用户
+func getUserByEmail(email string) (model.User, error) {
+	var user model.User
+	res := model.DB.Set("gorm:auto_preload", true).Where("email = ?", email).First(&user)
+	return user, res.Error
+}
@@ -0,0 +1,287 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTLSOID StartTLS 扩展操作的 OID
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// testServer 用于测试的本地目录服务器，只实现测试用到的操作
+type testServer struct {
+	listener  net.Listener
+	tlsConfig *tls.Config
+	passwords map[string]string
+	entries   []*Entry
+	// 是否有连接使用了 StartTLS
+	upgraded chan bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &testServer{
+		listener:  listener,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}},
+		passwords: map[string]string{
+			"cn=admin,dc=example,dc=org":  "admin",
+			"uid=user1,dc=example,dc=org": "password",
+		},
+		entries: []*Entry{
+			{DN: "uid=user1,dc=example,dc=org", Attributes: map[string][]string{
+				"objectclass": {"person"},
+				"mail":        {"user1@example.com"},
+				"cn":          {"User One"},
+				"memberof":    {"cn=staff,dc=example,dc=org", "cn=admins,dc=example,dc=org"},
+			}},
+			{DN: "uid=user2,dc=example,dc=org", Attributes: map[string][]string{
+				"objectclass": {"person"},
+				"mail":        {"user2@example.com"},
+				"cn":          {"User (Two)"},
+			}},
+		},
+		upgraded: make(chan bool, 10),
+	}
+	go server.serve()
+	return server
+}
+
+// selfSignedCert 生成测试用的自签名证书
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (server *testServer) url() string {
+	return "ldap://" + server.listener.Addr().String()
+}
+
+func (server *testServer) serve() {
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.handle(conn)
+	}
+}
+
+func (server *testServer) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	for {
+		message, err := ber.ReadPacket(conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id := message.Children[0].Value.(int64)
+		op := message.Children[1]
+
+		switch op.Tag {
+		case goldap.ApplicationBindRequest:
+			code := goldap.LDAPResultSuccess
+			if password, ok := server.passwords[str(op.Children[1])]; !ok || password != str(op.Children[2]) {
+				code = goldap.LDAPResultInvalidCredentials
+			}
+			server.reply(conn, id, result(goldap.ApplicationBindResponse, code))
+		case goldap.ApplicationExtendedRequest:
+			if str(op.Children[0]) != startTLSOID {
+				server.reply(conn, id, result(goldap.ApplicationExtendedResponse, goldap.LDAPResultProtocolError))
+				continue
+			}
+			server.reply(conn, id, result(goldap.ApplicationExtendedResponse, goldap.LDAPResultSuccess))
+			tlsConn := tls.Server(conn, server.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			server.upgraded <- true
+		case goldap.ApplicationSearchRequest:
+			base := strings.ToLower(str(op.Children[0]))
+			scope := op.Children[1].Value.(int64)
+			for _, entry := range server.entries {
+				dn := strings.ToLower(entry.DN)
+				if (scope == goldap.ScopeBaseObject && dn != base) || !strings.HasSuffix(dn, base) || !match(op.Children[6], entry) {
+					continue
+				}
+				res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, goldap.ApplicationSearchResultEntry, nil, "")
+				res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, ""))
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				for _, name := range op.Children[7].Children {
+					attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+					attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, str(name), ""))
+					values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+					for _, value := range entry.GetAll(str(name)) {
+						values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+					}
+					attr.AppendChild(values)
+					attrs.AppendChild(attr)
+				}
+				res.AppendChild(attrs)
+				server.reply(conn, id, res)
+			}
+			server.reply(conn, id, result(goldap.ApplicationSearchResultDone, goldap.LDAPResultSuccess))
+		case goldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (server *testServer) reply(conn net.Conn, id int64, op *ber.Packet) {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	message.AppendChild(op)
+	conn.Write(message.Bytes())
+}
+
+// result 生成只包含结果码的响应
+func result(tag ber.Tag, code int) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	return res
+}
+
+// str 读取字符串类型的值
+func str(p *ber.Packet) string {
+	return p.Data.String()
+}
+
+// match 判断条目是否符合过滤器，只实现测试用到的类型
+func match(filter *ber.Packet, entry *Entry) bool {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !match(child, entry) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterOr:
+		for _, child := range filter.Children {
+			if match(child, entry) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterNot:
+		return !match(filter.Children[0], entry)
+	case goldap.FilterPresent:
+		return len(entry.GetAll(str(filter))) > 0
+	case goldap.FilterEqualityMatch:
+		for _, value := range entry.GetAll(str(filter.Children[0])) {
+			if strings.EqualFold(value, str(filter.Children[1])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestConn(t *testing.T) {
+	asserts := assert.New(t)
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	// 不支持的协议
+	{
+		_, err := Dial("http://127.0.0.1", time.Second, false, false)
+		asserts.Equal(ErrUnsupportedScheme, err)
+		_, err = Dial("ldaps://127.0.0.1", time.Second, true, false)
+		asserts.Equal(ErrStartTLSOverLDAPS, err)
+	}
+
+	conn, err := Dial(server.url(), time.Second, false, false)
+	asserts.NoError(err)
+	defer conn.Close()
+
+	// 绑定
+	{
+		asserts.Equal(ErrInvalidCredentials, conn.Bind("cn=admin,dc=example,dc=org", "wrong"))
+		asserts.NoError(conn.Bind("cn=admin,dc=example,dc=org", "admin"))
+	}
+
+	// 搜索
+	{
+		entries, err := conn.Search("dc=example,dc=org", "(&(objectClass=person)(mail=USER1@example.com))", []string{"cn", "memberOf"})
+		asserts.NoError(err)
+		asserts.Len(entries, 1)
+		asserts.Equal("uid=user1,dc=example,dc=org", entries[0].DN)
+		asserts.Equal("User One", entries[0].Get("CN"))
+		asserts.Len(entries[0].GetAll("memberof"), 2)
+		asserts.Empty(entries[0].Get("mail"))
+
+		entries, err = conn.Search("dc=example,dc=org", "(objectClass=person)", nil)
+		asserts.NoError(err)
+		asserts.Len(entries, 2)
+
+		entries, err = conn.Search("dc=example,dc=org", "(mail="+goldap.EscapeFilter("*")+")", nil)
+		asserts.NoError(err)
+		asserts.Len(entries, 0)
+	}
+
+	// 读取条目
+	{
+		entry, err := conn.Read("uid=user2,dc=example,dc=org", []string{"cn"})
+		asserts.NoError(err)
+		asserts.Equal("User (Two)", entry.Get("cn"))
+
+		entry, err = conn.Read("uid=user3,dc=example,dc=org", []string{"cn"})
+		asserts.NoError(err)
+		asserts.Nil(entry)
+	}
+}
+
+func TestConn_StartTLS(t *testing.T) {
+	asserts := assert.New(t)
+	server := newTestServer(t)
+	defer server.listener.Close()
+
+	// 证书不受信任
+	{
+		_, err := Dial(server.url(), time.Second, true, false)
+		asserts.Error(err)
+	}
+
+	// 跳过证书校验
+	{
+		conn, err := Dial(server.url(), time.Second, true, true)
+		asserts.NoError(err)
+		defer conn.Close()
+		asserts.True(<-server.upgraded)
+		asserts.NoError(conn.Bind("uid=user1,dc=example,dc=org", "password"))
+		entry, err := conn.Read("uid=user1,dc=example,dc=org", []string{"cn"})
+		asserts.NoError(err)
+		asserts.Equal("User One", entry.Get("cn"))
+	}
+}
@@ -0,0 +1,192 @@
+package lockout
+
+import (
+	"encoding/gob"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"sync"
+	"time"
+)
+
+// Record 登录失败记录，保存于缓存中
+type Record struct {
+	// 连续失败次数
+	Failures int
+	// 锁定截止时间，为零值或已过去时表示未锁定
+	LockedUntil time.Time
+}
+
+const (
+	userPrefix = "login_fail_user_"
+	ipPrefix   = "login_fail_ip_"
+	// lockedUsersKey 被锁定的用户ID到锁定截止时间（Unix 时间戳）
+	lockedUsersKey = "login_locked_users"
+)
+
+// maxShift 计算锁定时长时的最大倍增次数，避免溢出
+const maxShift = 30
+
+// mu 保护同一实例内对失败记录的读写
+var mu sync.Mutex
+
+func init() {
+	gob.Register(Record{})
+	gob.Register(map[uint]int64{})
+}
+
+func userKey(uid uint) string {
+	return fmt.Sprintf("%d", uid)
+}
+
+// Check 检查用户 uid 及来源 ip 是否因登录失败次数过多而被锁定，返回剩余的锁定时长，
+// 未锁定时返回 0。uid 为 0 时只检查 ip
+func Check(uid uint, ip string) time.Duration {
+	now := time.Now()
+	var remain time.Duration
+	if uid > 0 {
+		if record, ok := get(userPrefix + userKey(uid)); ok && record.LockedUntil.After(now) {
+			remain = record.LockedUntil.Sub(now)
+		}
+	}
+	if record, ok := get(ipPrefix + ip); ok && record.LockedUntil.After(now) && record.LockedUntil.Sub(now) > remain {
+		remain = record.LockedUntil.Sub(now)
+	}
+	return remain
+}
+
+// Fail 记录一次登录失败，失败次数达到阈值后锁定用户及来源IP，此后每次失败锁定时长翻倍。
+// uid 为 0 时（如用户不存在）只记录 ip
+func Fail(uid uint, ip string) {
+	base := time.Duration(model.GetIntSetting("login_lock_duration", 60)) * time.Second
+	maxDuration := time.Duration(model.GetIntSetting("login_lock_max_duration", 3600)) * time.Second
+	window := model.GetIntSetting("login_fail_window", 3600)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now()
+	if uid > 0 {
+		threshold := model.GetIntSetting("login_lock_threshold", 5)
+		if until, locked := fail(userPrefix+userKey(uid), threshold, base, maxDuration, window, now); locked {
+			setLockedUser(uid, until)
+			util.Log().Warning("用户[%d]登录失败次数过多，锁定至 %s", uid, until.Format(time.RFC3339))
+		}
+	}
+	threshold := model.GetIntSetting("login_ip_lock_threshold", 20)
+	if until, locked := fail(ipPrefix+ip, threshold, base, maxDuration, window, now); locked {
+		util.Log().Warning("来源[%s]登录失败次数过多，锁定至 %s", ip, until.Format(time.RFC3339))
+	}
+}
+
+// fail 增加 key 的失败次数，返回是否因此被锁定及锁定截止时间
+func fail(key string, threshold int, base, maxDuration time.Duration, window int, now time.Time) (time.Time, bool) {
+	record, _ := get(key)
+	record.Failures++
+
+	locked := false
+	if threshold > 0 && record.Failures >= threshold {
+		duration := maxDuration
+		if shift := record.Failures - threshold; shift < maxShift && base<<uint(shift) < maxDuration {
+			duration = base << uint(shift)
+		}
+		record.LockedUntil = now.Add(duration)
+		locked = true
+	}
+
+	// 失败记录在最后一次失败或锁定结束 window 秒后过期
+	ttl := window
+	if locked {
+		ttl += int(record.LockedUntil.Sub(now).Seconds())
+	}
+	if err := cache.Set(key, record, ttl); err != nil {
+		util.Log().Warning("无法保存登录失败记录, %s", err)
+	}
+
+	return record.LockedUntil, locked
+}
+
+// Success 登录成功后清除用户的失败记录
+func Success(uid uint) {
+	if _, ok := get(userPrefix + userKey(uid)); !ok {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	reset(uid)
+}
+
+// Unlock 手动解除用户的锁定并清除失败记录
+func Unlock(uid uint) {
+	mu.Lock()
+	defer mu.Unlock()
+	reset(uid)
+}
+
+func reset(uid uint) {
+	if err := cache.Deletes([]string{userKey(uid)}, userPrefix); err != nil {
+		util.Log().Warning("无法清除登录失败记录, %s", err)
+	}
+
+	users := lockedUsers()
+	if _, ok := users[uid]; ok {
+		delete(users, uid)
+		saveLockedUsers(users)
+	}
+}
+
+// LockedUsers 列出当前被锁定的用户ID及锁定截止时间
+func LockedUsers() map[uint]time.Time {
+	mu.Lock()
+	defer mu.Unlock()
+
+	now := time.Now().Unix()
+	res := make(map[uint]time.Time)
+	for uid, until := range lockedUsers() {
+		if until > now {
+			res[uid] = time.Unix(until, 0)
+		}
+	}
+	return res
+}
+
+func setLockedUser(uid uint, until time.Time) {
+	users := lockedUsers()
+
+	// 顺便清理已解除锁定的用户
+	now := time.Now().Unix()
+	for id, lockedUntil := range users {
+		if lockedUntil <= now {
+			delete(users, id)
+		}
+	}
+
+	users[uid] = until.Unix()
+	saveLockedUsers(users)
+}
+
+func lockedUsers() map[uint]int64 {
+	if users, ok := cache.Get(lockedUsersKey); ok {
+		if res, ok := users.(map[uint]int64); ok {
+			return res
+		}
+	}
+	return make(map[uint]int64)
+}
+
+func saveLockedUsers(users map[uint]int64) {
+	if err := cache.Set(lockedUsersKey, users, 0); err != nil {
+		util.Log().Warning("无法保存被锁定的用户列表, %s", err)
+	}
+}
+
+func get(key string) (Record, bool) {
+	if record, ok := cache.Get(key); ok {
+		if res, ok := record.(Record); ok {
+			return res, true
+		}
+	}
+	return Record{}, false
+}
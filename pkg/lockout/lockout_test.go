@@ -0,0 +1,92 @@
+package lockout
+
+import (
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func setSettings(threshold, ipThreshold string) {
+	cache.Set("setting_login_lock_threshold", threshold, 0)
+	cache.Set("setting_login_ip_lock_threshold", ipThreshold, 0)
+	cache.Set("setting_login_lock_duration", "60", 0)
+	cache.Set("setting_login_lock_max_duration", "200", 0)
+	cache.Set("setting_login_fail_window", "3600", 0)
+}
+
+func TestFail(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+	setSettings("2", "0")
+
+	// 未达到阈值
+	{
+		Fail(1, "1.1.1.1")
+		asserts.Zero(Check(1, "1.1.1.1"))
+		asserts.Empty(LockedUsers())
+	}
+
+	// 达到阈值，锁定用户
+	{
+		Fail(1, "1.1.1.1")
+		remain := Check(1, "1.1.1.1")
+		asserts.True(remain > 50*time.Second && remain <= 60*time.Second)
+		asserts.Contains(LockedUsers(), uint(1))
+		// 其他用户不受影响
+		asserts.Zero(Check(2, "1.1.1.1"))
+	}
+
+	// 锁定时长翻倍
+	{
+		Fail(1, "1.1.1.1")
+		remain := Check(1, "1.1.1.1")
+		asserts.True(remain > 110*time.Second && remain <= 120*time.Second)
+	}
+
+	// 不超过最长锁定时长
+	{
+		Fail(1, "1.1.1.1")
+		Fail(1, "1.1.1.1")
+		remain := Check(1, "1.1.1.1")
+		asserts.True(remain > 190*time.Second && remain <= 200*time.Second)
+	}
+}
+
+func TestFail_IP(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+	setSettings("0", "2")
+
+	Fail(0, "2.2.2.2")
+	asserts.Zero(Check(0, "2.2.2.2"))
+	Fail(3, "2.2.2.2")
+	asserts.NotZero(Check(0, "2.2.2.2"))
+	asserts.NotZero(Check(4, "2.2.2.2"))
+	asserts.Zero(Check(3, "3.3.3.3"))
+	asserts.Empty(LockedUsers())
+}
+
+func TestUnlock(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+	setSettings("1", "0")
+
+	// 手动解锁
+	{
+		Fail(1, "1.1.1.1")
+		asserts.NotZero(Check(1, "1.1.1.1"))
+		Unlock(1)
+		asserts.Zero(Check(1, "1.1.1.1"))
+		asserts.Empty(LockedUsers())
+	}
+
+	// 登录成功后清除失败记录
+	{
+		setSettings("2", "0")
+		Fail(1, "1.1.1.1")
+		Success(1)
+		Fail(1, "1.1.1.1")
+		asserts.Zero(Check(1, "1.1.1.1"))
+	}
+}
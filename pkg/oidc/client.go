@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/request"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// discoveryTTL Provider 元数据及公钥的缓存时间（秒）
+const discoveryTTL = 3600
+
+// Client OpenID Connect 客户端（Relying Party）
+type Client struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Redirect     string
+	Scopes       []string
+
+	Request request.Client
+}
+
+// NewClient 新建客户端
+func NewClient(issuer, clientID, clientSecret, redirect string, scopes []string) *Client {
+	return &Client{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Redirect:     redirect,
+		Scopes:       scopes,
+		Request:      request.HTTPClient{},
+	}
+}
+
+// RandomString 生成 n 字节随机数据的 base64url 编码，用于 state、nonce 及 PKCE code_verifier
+func RandomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CodeChallenge 计算 PKCE S256 code_challenge
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Discover 获取 Provider 元数据
+func (client *Client) Discover(ctx context.Context) (*Provider, error) {
+	if provider, ok := cache.Get("oidc_provider_" + client.Issuer); ok {
+		res := provider.(Provider)
+		return &res, nil
+	}
+
+	var provider Provider
+	if err := client.getJSON(ctx, client.Issuer+"/.well-known/openid-configuration", &provider); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(provider.Issuer, "/") != client.Issuer {
+		return nil, ErrIssuerMismatch
+	}
+
+	cache.Set("oidc_provider_"+client.Issuer, provider, discoveryTTL)
+	return &provider, nil
+}
+
+// AuthURL 获取授权页面 URL
+func (client *Client) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	provider, err := client.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	base, err := url.Parse(provider.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := base.Query()
+	query.Set("client_id", client.ClientID)
+	query.Set("response_type", "code")
+	query.Set("scope", strings.Join(client.Scopes, " "))
+	query.Set("redirect_uri", client.Redirect)
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	base.RawQuery = query.Encode()
+
+	return base.String(), nil
+}
+
+// Exchange 使用授权码兑换令牌
+func (client *Client) Exchange(ctx context.Context, code, verifier string) (*Token, error) {
+	provider, err := client.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {client.Redirect},
+		"client_id":     {client.ClientID},
+		"client_secret": {client.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	strBody := body.Encode()
+
+	res := client.Request.Request(
+		"POST",
+		provider.TokenEndpoint,
+		ioutil.NopCloser(strings.NewReader(strBody)),
+		request.WithContext(ctx),
+		request.WithHeader(http.Header{
+			"Content-Type": {"application/x-www-form-urlencoded"},
+			"Accept":       {"application/json"},
+		}),
+		request.WithContentLength(int64(len(strBody))),
+	)
+	respBody, err := res.GetResponse()
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Response.StatusCode != 200 {
+		var errResp OAuthError
+		if err := json.Unmarshal([]byte(respBody), &errResp); err != nil || errResp.ErrorType == "" {
+			return nil, fmt.Errorf("令牌端点返回非正常HTTP状态%d", res.Response.StatusCode)
+		}
+		return nil, errResp
+	}
+
+	var token Token
+	if err := json.Unmarshal([]byte(respBody), &token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, ErrInvalidIDToken
+	}
+
+	return &token, nil
+}
+
+// UserInfo 从 userinfo 端点获取用户声明，并合并入 claims 中缺失的部分
+func (client *Client) UserInfo(ctx context.Context, accessToken string, claims *Claims) error {
+	provider, err := client.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	if provider.UserinfoEndpoint == "" {
+		return nil
+	}
+
+	var info map[string]interface{}
+	if err := client.getJSON(ctx, provider.UserinfoEndpoint, &info, request.WithHeader(http.Header{
+		"Authorization": {"Bearer " + accessToken},
+	})); err != nil {
+		return err
+	}
+
+	if sub, _ := info["sub"].(string); sub != claims.Subject {
+		return ErrSubjectMismatch
+	}
+
+	for k, v := range info {
+		if _, ok := claims.Raw[k]; !ok {
+			claims.Raw[k] = v
+		}
+	}
+	if claims.Email == "" {
+		claims.Email, _ = info["email"].(string)
+		claims.EmailVerified, _ = info["email_verified"].(bool)
+	}
+	if claims.Name == "" {
+		claims.Name, _ = info["name"].(string)
+	}
+	if claims.PreferredUsername == "" {
+		claims.PreferredUsername, _ = info["preferred_username"].(string)
+	}
+
+	return nil
+}
+
+// getJSON 发送 GET 请求并解析 JSON 响应
+func (client *Client) getJSON(ctx context.Context, target string, v interface{}, opts ...request.Option) error {
+	opts = append(opts, request.WithContext(ctx), request.WithHeader(http.Header{
+		"Accept": {"application/json"},
+	}))
+	res := client.Request.Request("GET", target, nil, opts...).CheckHTTPResponse(200)
+	respBody, err := res.GetResponse()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(respBody), v)
+}
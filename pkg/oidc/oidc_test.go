@@ -0,0 +1,246 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIdP 用于测试的本地身份提供方
+type testIdP struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	challenge string
+	claims    map[string]interface{}
+	userinfo  map[string]interface{}
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &testIdP{key: key, kid: "key1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Provider{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			UserinfoEndpoint:      idp.server.URL + "/userinfo",
+			JwksURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jsonWebKeySet{Keys: []jsonWebKey{{
+			Kty: "RSA",
+			Kid: idp.kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "code" || CodeChallenge(r.Form.Get("code_verifier")) != idp.challenge {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"授权码无效"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(Token{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IDToken:     idp.sign(idp.claims),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(401)
+			return
+		}
+		json.NewEncoder(w).Encode(idp.userinfo)
+	})
+	idp.server = httptest.NewServer(mux)
+	return idp
+}
+
+func (idp *testIdP) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": idp.kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *testIdP) validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            idp.server.URL,
+		"sub":            "user1",
+		"aud":            "client",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          "nonce",
+		"email":          "user1@example.com",
+		"email_verified": true,
+		"groups":         []string{"staff", "admins"},
+	}
+}
+
+func TestClient_AuthURL(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+	idp := newTestIdP(t)
+	defer idp.server.Close()
+
+	client := NewClient(idp.server.URL+"/", "client", "secret", "http://cloudreve.org/callback", []string{"openid", "email"})
+	res, err := client.AuthURL(context.Background(), "state", "nonce", "verifier")
+	asserts.NoError(err)
+
+	authURL, _ := url.Parse(res)
+	asserts.Equal("/authorize", authURL.Path)
+	asserts.Equal("client", authURL.Query().Get("client_id"))
+	asserts.Equal("openid email", authURL.Query().Get("scope"))
+	asserts.Equal("state", authURL.Query().Get("state"))
+	asserts.Equal("nonce", authURL.Query().Get("nonce"))
+	asserts.Equal(CodeChallenge("verifier"), authURL.Query().Get("code_challenge"))
+	asserts.Equal("S256", authURL.Query().Get("code_challenge_method"))
+
+	// 签发者不匹配
+	{
+		cache.Store = cache.NewMemoStore()
+		client := NewClient(idp.server.URL+"/other", "client", "secret", "", nil)
+		_, err := client.AuthURL(context.Background(), "state", "nonce", "verifier")
+		asserts.Error(err)
+	}
+}
+
+func TestClient_ExchangeAndVerify(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+	idp := newTestIdP(t)
+	defer idp.server.Close()
+	client := NewClient(idp.server.URL, "client", "secret", "http://cloudreve.org/callback", []string{"openid"})
+	verifier, err := RandomString(32)
+	asserts.NoError(err)
+	idp.challenge = CodeChallenge(verifier)
+	ctx := context.Background()
+
+	// 成功
+	{
+		idp.claims = idp.validClaims()
+		token, err := client.Exchange(ctx, "code", verifier)
+		asserts.NoError(err)
+		claims, err := client.Verify(ctx, token.IDToken, "nonce")
+		asserts.NoError(err)
+		asserts.Equal("user1", claims.Subject)
+		asserts.Equal("user1@example.com", claims.Email)
+		asserts.True(claims.EmailVerified)
+		asserts.Equal([]string{"staff", "admins"}, claims.Strings("groups"))
+		asserts.Empty(claims.Strings("not_exist"))
+	}
+
+	// code_verifier 错误
+	{
+		_, err := client.Exchange(ctx, "code", "wrong")
+		asserts.Error(err)
+		asserts.IsType(OAuthError{}, err)
+	}
+
+	// 校验失败
+	{
+		claims := idp.validClaims()
+		_, err := client.Verify(ctx, idp.sign(claims), "other")
+		asserts.Equal(ErrNonceMismatch, err)
+
+		claims["aud"] = []string{"other"}
+		_, err = client.Verify(ctx, idp.sign(claims), "nonce")
+		asserts.Equal(ErrAudienceMismatch, err)
+
+		claims = idp.validClaims()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		_, err = client.Verify(ctx, idp.sign(claims), "nonce")
+		asserts.Equal(ErrTokenExpired, err)
+
+		claims = idp.validClaims()
+		claims["iss"] = "http://evil.org"
+		_, err = client.Verify(ctx, idp.sign(claims), "nonce")
+		asserts.Equal(ErrIssuerMismatch, err)
+
+		// 篡改声明
+		parts := strings.Split(idp.sign(idp.validClaims()), ".")
+		payload, _ := json.Marshal(map[string]interface{}{"sub": "admin"})
+		parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+		_, err = client.Verify(ctx, strings.Join(parts, "."), "nonce")
+		asserts.Equal(ErrInvalidSignature, err)
+
+		// 不允许未签名的令牌
+		header, _ := json.Marshal(map[string]string{"alg": "none"})
+		parts[0] = base64.RawURLEncoding.EncodeToString(header)
+		_, err = client.Verify(ctx, strings.Join(parts, "."), "nonce")
+		asserts.Equal(ErrUnsupportedAlg, err)
+
+		_, err = client.Verify(ctx, "invalid", "nonce")
+		asserts.Equal(ErrInvalidIDToken, err)
+	}
+
+	// 公钥轮换
+	{
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		idp.key = key
+		idp.kid = "key2"
+		_, err := client.Verify(ctx, idp.sign(idp.validClaims()), "nonce")
+		asserts.NoError(err)
+	}
+}
+
+func TestClient_UserInfo(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+	idp := newTestIdP(t)
+	defer idp.server.Close()
+	client := NewClient(idp.server.URL, "client", "secret", "", nil)
+
+	// 成功
+	{
+		idp.userinfo = map[string]interface{}{
+			"sub":            "user1",
+			"email":          "user1@example.com",
+			"email_verified": true,
+			"groups":         "staff",
+		}
+		claims := &Claims{Subject: "user1", Raw: map[string]interface{}{"sub": "user1"}}
+		asserts.NoError(client.UserInfo(context.Background(), "access", claims))
+		asserts.Equal("user1@example.com", claims.Email)
+		asserts.True(claims.EmailVerified)
+		asserts.Equal([]string{"staff"}, claims.Strings("groups"))
+	}
+
+	// sub 不一致
+	{
+		idp.userinfo = map[string]interface{}{"sub": "user2", "email": "user2@example.com"}
+		claims := &Claims{Subject: "user1", Raw: map[string]interface{}{}}
+		asserts.Equal(ErrSubjectMismatch, client.UserInfo(context.Background(), "access", claims))
+		asserts.Empty(claims.Email)
+	}
+
+	// 访问令牌无效
+	{
+		claims := &Claims{Subject: "user1", Raw: map[string]interface{}{}}
+		asserts.Error(client.UserInfo(context.Background(), "wrong", claims))
+	}
+}
@@ -0,0 +1,142 @@
+package oidc
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	// ErrInvalidIDToken ID Token 格式错误
+	ErrInvalidIDToken = errors.New("ID Token 格式错误")
+	// ErrInvalidSignature ID Token 签名无效
+	ErrInvalidSignature = errors.New("ID Token 签名无效")
+	// ErrUnsupportedAlg 不支持的签名算法
+	ErrUnsupportedAlg = errors.New("不支持的签名算法")
+	// ErrKeyNotFound 未找到签名公钥
+	ErrKeyNotFound = errors.New("未找到签名公钥")
+	// ErrIssuerMismatch 签发者不匹配
+	ErrIssuerMismatch = errors.New("签发者不匹配")
+	// ErrAudienceMismatch 受众不包含当前客户端
+	ErrAudienceMismatch = errors.New("ID Token 受众不包含当前客户端")
+	// ErrTokenExpired ID Token 已过期
+	ErrTokenExpired = errors.New("ID Token 已过期")
+	// ErrNonceMismatch nonce 不匹配
+	ErrNonceMismatch = errors.New("nonce 不匹配")
+	// ErrSubjectMismatch 用户信息与 ID Token 的 sub 不一致
+	ErrSubjectMismatch = errors.New("用户信息与 ID Token 不一致")
+)
+
+// Provider OpenID Provider 元数据，见 OpenID Connect Discovery 1.0
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Token 令牌端点的响应
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// OAuthError 令牌端点的错误响应
+type OAuthError struct {
+	ErrorType        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Error 实现error接口
+func (err OAuthError) Error() string {
+	if err.ErrorDescription != "" {
+		return err.ErrorDescription
+	}
+	return err.ErrorType
+}
+
+// Claims ID Token 中的声明
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	Expiry            int64    `json:"exp"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+
+	// Raw 全部声明，用于读取自定义声明
+	Raw map[string]interface{} `json:"-"`
+}
+
+// Strings 以字符串列表形式读取声明 name，声明可为字符串或字符串数组
+func (claims *Claims) Strings(name string) []string {
+	switch value := claims.Raw[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		res := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// audience aud 声明，可为字符串或字符串数组
+type audience []string
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (aud *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*aud = multiple
+	return nil
+}
+
+func (aud audience) contains(value string) bool {
+	for _, v := range aud {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonWebKey JWKS 中的公钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jsonWebKeySet JWKS
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func init() {
+	gob.Register(Provider{})
+	gob.Register(jsonWebKeySet{})
+}
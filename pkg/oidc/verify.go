@@ -0,0 +1,199 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew 校验有效期时允许的时钟误差
+const clockSkew = time.Minute
+
+// Verify 校验 ID Token 的签名、签发者、受众、有效期及 nonce，返回其中的声明
+func (client *Client) Verify(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	provider, err := client.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	key, err := client.publicKey(ctx, provider, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if err := decodeSegment(parts[1], &claims.Raw); err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	if claims.Issuer != provider.Issuer {
+		return nil, ErrIssuerMismatch
+	}
+	if !claims.Audience.contains(client.ClientID) {
+		return nil, ErrAudienceMismatch
+	}
+	if time.Now().Add(-clockSkew).After(time.Unix(claims.Expiry, 0)) {
+		return nil, ErrTokenExpired
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	return claims, nil
+}
+
+// publicKey 获取 kid 对应的签名公钥，未找到时刷新一次 JWKS 以应对公钥轮换
+func (client *Client) publicKey(ctx context.Context, provider *Provider, kid string) (interface{}, error) {
+	cacheKey := "oidc_jwks_" + client.Issuer
+	for refreshed := false; ; refreshed = true {
+		var keySet jsonWebKeySet
+		if cached, ok := cache.Get(cacheKey); ok && !refreshed {
+			keySet = cached.(jsonWebKeySet)
+		} else {
+			if err := client.getJSON(ctx, provider.JwksURI, &keySet); err != nil {
+				return nil, err
+			}
+			cache.Set(cacheKey, keySet, discoveryTTL)
+		}
+
+		for _, key := range keySet.Keys {
+			// 未指定 kid 时使用第一个签名公钥
+			if (kid == "" || key.Kid == kid) && key.Use != "enc" {
+				return key.publicKey()
+			}
+		}
+
+		if refreshed {
+			return nil, ErrKeyNotFound
+		}
+	}
+}
+
+// publicKey 解析公钥
+func (key jsonWebKey) publicKey() (interface{}, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(key.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrUnsupportedAlg
+		}
+		x, err := decodeBigInt(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, ErrUnsupportedAlg
+}
+
+// verifySignature 校验签名，支持 RS256/384/512 及 ES256/384/512
+func verifySignature(alg string, key interface{}, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return ErrUnsupportedAlg
+	}
+	hasher := hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			return ErrUnsupportedAlg
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest, signature) != nil {
+			return ErrInvalidSignature
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || len(signature) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrInvalidSignature
+		}
+	default:
+		return ErrUnsupportedAlg
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
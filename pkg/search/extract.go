@@ -0,0 +1,275 @@
+package search
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// textExtensions 按纯文本提取正文的扩展名
+var textExtensions = map[string]bool{
+	"txt":      true,
+	"md":       true,
+	"markdown": true,
+	"csv":      true,
+	"log":      true,
+}
+
+// CanExtract 是否支持提取文件 name 的正文
+func CanExtract(name string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	return textExtensions[ext] || ext == "pdf"
+}
+
+// ExtractText 从文件内容中提取可供索引的正文，至多读取 limit 字节。
+// 不支持的文件类型返回空字符串
+func ExtractText(name string, file io.Reader, limit int64) (string, error) {
+	if !CanExtract(name) {
+		return "", nil
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(file, limit))
+	if err != nil {
+		return "", err
+	}
+
+	if strings.EqualFold(path.Ext(name), ".pdf") {
+		return extractPDF(content, limit), nil
+	}
+	return strings.ToValidUTF8(string(content), " "), nil
+}
+
+/* =================
+	 PDF 正文提取
+   =================
+*/
+
+// extractPDF 尽力提取 PDF 中各内容流的文本。仅支持未压缩及 FlateDecode
+// 压缩的流，使用自定义编码字体的文本无法正确提取。各内容流解压后的总大小
+// 不超过 limit 字节，超出部分被忽略
+func extractPDF(content []byte, limit int64) string {
+	var text strings.Builder
+	for len(content) > 0 && limit > 0 {
+		start := bytes.Index(content, []byte("stream"))
+		if start == -1 {
+			break
+		}
+
+		// 流字典位于 stream 关键字之前
+		dict := content[:start]
+		if dictStart := bytes.LastIndex(dict, []byte("obj")); dictStart != -1 {
+			dict = dict[dictStart:]
+		}
+
+		data := content[start+len("stream"):]
+		data = bytes.TrimPrefix(data, []byte("\r"))
+		data = bytes.TrimPrefix(data, []byte("\n"))
+		end := bytes.Index(data, []byte("endstream"))
+		if end == -1 {
+			break
+		}
+		content = data[end+len("endstream"):]
+		data = data[:end]
+
+		if bytes.Contains(dict, []byte("/Filter")) {
+			if !bytes.Contains(dict, []byte("/FlateDecode")) {
+				continue
+			}
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// 流可能被截断，保留已解压的部分
+			data, _ = ioutil.ReadAll(io.LimitReader(reader, limit))
+			reader.Close()
+		}
+		if int64(len(data)) > limit {
+			data = data[:limit]
+		}
+		limit -= int64(len(data))
+
+		extractPDFText(data, &text)
+	}
+
+	return text.String()
+}
+
+// extractPDFText 解析内容流，提取 BT/ET 文本块中文本显示操作的字符串
+func extractPDFText(stream []byte, text *strings.Builder) {
+	inText := false
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '%':
+			// 注释
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			str, next := readLiteralString(stream, i)
+			if inText {
+				text.WriteString(decodePDFString(str))
+			}
+			i = next
+		case c == '<' && i+1 < len(stream) && stream[i+1] == '<':
+			i += 2
+		case c == '<':
+			str, next := readHexString(stream, i)
+			if inText {
+				text.WriteString(decodePDFString(str))
+			}
+			i = next
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			start := i
+			for i < len(stream) && (stream[i] == '-' || stream[i] == '.' || (stream[i] >= '0' && stream[i] <= '9')) {
+				i++
+			}
+			// TJ 数组中较大的负间距通常表示单词间隔
+			if num, err := strconv.ParseFloat(string(stream[start:i]), 64); inText && err == nil && num < -200 {
+				text.WriteByte(' ')
+			}
+		case isPDFRegular(c):
+			start := i
+			for i < len(stream) && isPDFRegular(stream[i]) && stream[i] != '/' {
+				i++
+			}
+			if i == start {
+				i++
+			}
+			switch string(stream[start:i]) {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				text.WriteByte('\n')
+			case "Td", "TD", "T*", "Tm", "'", "\"":
+				if inText {
+					text.WriteByte(' ')
+				}
+			case "BI":
+				// 跳过内联图片数据
+				if end := bytes.Index(stream[i:], []byte("EI")); end != -1 {
+					i += end + 2
+				} else {
+					i = len(stream)
+				}
+			}
+		default:
+			i++
+		}
+	}
+}
+
+// readLiteralString 读取从 start 处开始的 (...) 字符串，返回字符串内容及其后的位置
+func readLiteralString(stream []byte, start int) ([]byte, int) {
+	var (
+		str   []byte
+		depth = 0
+		i     = start
+	)
+	for ; i < len(stream); i++ {
+		c := stream[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return str, i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(stream) {
+				return str, i
+			}
+			switch esc := stream[i]; esc {
+			case 'n':
+				str = append(str, '\n')
+			case 'r':
+				str = append(str, '\r')
+			case 't':
+				str = append(str, '\t')
+			case 'b':
+				str = append(str, '\b')
+			case 'f':
+				str = append(str, '\f')
+			case '\r', '\n':
+				// 续行
+			default:
+				if esc >= '0' && esc <= '7' {
+					end := i
+					for end < len(stream) && end < i+3 && stream[end] >= '0' && stream[end] <= '7' {
+						end++
+					}
+					value, _ := strconv.ParseUint(string(stream[i:end]), 8, 8)
+					str = append(str, byte(value))
+					i = end - 1
+				} else {
+					str = append(str, esc)
+				}
+			}
+			continue
+		}
+		str = append(str, c)
+	}
+	return str, i
+}
+
+// readHexString 读取从 start 处开始的 <...> 十六进制字符串
+func readHexString(stream []byte, start int) ([]byte, int) {
+	end := bytes.IndexByte(stream[start:], '>')
+	if end == -1 {
+		return nil, len(stream)
+	}
+
+	digits := make([]byte, 0, end)
+	for _, c := range stream[start+1 : start+end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	str := make([]byte, len(digits)/2)
+	for i := range str {
+		value, _ := strconv.ParseUint(string(digits[i*2:i*2+2]), 16, 8)
+		str[i] = byte(value)
+	}
+	return str, start + end + 1
+}
+
+// decodePDFString 解码文本字符串，带 BOM 的按 UTF-16BE 解码，其余按单字节编码解码
+func decodePDFString(str []byte) string {
+	if len(str) >= 2 && str[0] == 0xFE && str[1] == 0xFF {
+		units := make([]uint16, 0, len(str)/2)
+		for i := 2; i+1 < len(str); i += 2 {
+			units = append(units, uint16(str[i])<<8|uint16(str[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	runes := make([]rune, len(str))
+	for i, c := range str {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// isPDFRegular 是否为 PDF 的常规字符（非空白、非分隔符）
+func isPDFRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '%':
+		return false
+	}
+	return true
+}
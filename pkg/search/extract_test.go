@@ -0,0 +1,79 @@
+package search
+
+import (
+	"bytes"
+	"compress/zlib"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCanExtract(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(CanExtract("1.txt"))
+	asserts.True(CanExtract("README.MD"))
+	asserts.True(CanExtract("paper.pdf"))
+	asserts.False(CanExtract("photo.jpg"))
+	asserts.False(CanExtract("noext"))
+}
+
+func TestExtractText(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不支持的类型
+	{
+		text, err := ExtractText("1.jpg", strings.NewReader("content"), 100)
+		asserts.NoError(err)
+		asserts.Empty(text)
+	}
+
+	// 纯文本，超出限制的部分被忽略，无效的 UTF-8 字符被替换
+	{
+		text, err := ExtractText("1.md", strings.NewReader("# title\xff content"), 15)
+		asserts.NoError(err)
+		asserts.Equal("# title  conten", text)
+	}
+
+	// PDF，未压缩的流
+	{
+		pdf := "%PDF-1.4\n1 0 obj\n<< /Length 60 >>\nstream\n" +
+			"BT /F1 12 Tf 72 712 Td (Hello \\(PDF\\)) Tj T* [(Wor) -20 (ld) -300 (again)] TJ ET\n" +
+			"endstream\nendobj\n"
+		text, err := ExtractText("1.pdf", strings.NewReader(pdf), 1024)
+		asserts.NoError(err)
+		asserts.Contains(text, "Hello (PDF)")
+		asserts.Contains(text, "World again")
+		asserts.NotContains(text, "F1")
+	}
+
+	// PDF，FlateDecode 压缩的流及 UTF-16 十六进制字符串
+	{
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		writer.Write([]byte("BT <FEFF65874EF6> Tj ( \\101\\102C) Tj ET"))
+		writer.Close()
+		pdf := "%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\n" +
+			compressed.String() + "\nendstream\nendobj\n" +
+			"2 0 obj\n<< /Filter /DCTDecode >>\nstream\nBT (image) Tj ET\nendstream\nendobj\n"
+		text, err := ExtractText("1.PDF", strings.NewReader(pdf), 4096)
+		asserts.NoError(err)
+		asserts.Contains(text, "文件 ABC")
+		asserts.NotContains(text, "image")
+	}
+
+	// PDF，解压后的内容超出限制的部分被忽略
+	{
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		writer.Write([]byte("BT (first) Tj ET\n" + strings.Repeat(" ", 1<<20)))
+		writer.Close()
+		pdf := "%PDF-1.4\n1 0 obj\n<< /Filter /FlateDecode >>\nstream\n" +
+			compressed.String() + "\nendstream\nendobj\n" +
+			"2 0 obj\n<< /Length 20 >>\nstream\nBT (second) Tj ET\nendstream\nendobj\n"
+		asserts.True(compressed.Len() < 4096)
+		text, err := ExtractText("1.pdf", strings.NewReader(pdf), 4096)
+		asserts.NoError(err)
+		asserts.Contains(text, "first")
+		asserts.NotContains(text, "second")
+	}
+}
@@ -0,0 +1,428 @@
+package search
+
+import (
+	"encoding/gob"
+	"fmt"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// indexVersion 索引文件格式版本，格式不兼容时需重建索引
+const indexVersion = 2
+
+// Default 全局搜索索引
+var Default = NewIndex()
+
+// Document 被索引的文件
+type Document struct {
+	ID       uint
+	UID      uint
+	FolderID uint
+	// 所在目录的完整路径
+	Path      string
+	Name      string
+	Size      uint64
+	UpdatedAt time.Time
+	// 物理文件标识，引用同一物理文件的文件共用正文索引
+	Source string
+}
+
+// ext 文件扩展名（小写，不含 .）
+func (doc *Document) ext() string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(doc.Name), "."))
+}
+
+// Equal 两个文档的元数据是否一致
+func (doc *Document) Equal(other *Document) bool {
+	return doc.ID == other.ID && doc.UID == other.UID && doc.FolderID == other.FolderID &&
+		doc.Path == other.Path && doc.Name == other.Name && doc.Size == other.Size &&
+		doc.UpdatedAt.Equal(other.UpdatedAt) && doc.Source == other.Source
+}
+
+// indexFile 持久化到磁盘的索引内容，倒排表在加载时重新生成
+type indexFile struct {
+	Version  int
+	Docs     map[uint]*Document
+	Contents map[string][]string
+}
+
+// Index 保存于内存、定期持久化到磁盘的倒排索引。文件名直接以子串匹配，
+// 正文按物理文件建立倒排表
+type Index struct {
+	mu sync.RWMutex
+
+	docs     map[uint]*Document
+	userDocs map[uint]map[uint]struct{}
+	// 物理文件到引用它的文件
+	sourceDocs map[string]map[uint]struct{}
+	// 物理文件正文的词元
+	contents map[string][]string
+	// 词元到包含它的物理文件
+	postings map[string]map[string]struct{}
+
+	ready bool
+	dirty bool
+	path  string
+}
+
+// NewIndex 创建空索引
+func NewIndex() *Index {
+	index := &Index{}
+	index.reset()
+	return index
+}
+
+// SourceKey 根据存储策略ID与物理文件路径生成物理文件标识
+func SourceKey(policyID uint, sourceName string) string {
+	return fmt.Sprintf("%d:%s", policyID, sourceName)
+}
+
+// Init 从 path 加载全局索引，加载失败时返回错误，此时索引需要重建
+func Init(path string) error {
+	return Default.Load(path)
+}
+
+// Flush 将全局索引写入磁盘
+func Flush() {
+	if err := Default.Save(); err != nil {
+		util.Log().Warning("无法保存搜索索引, %s", err)
+	}
+}
+
+// Load 从磁盘加载索引，之后 Save 会写回同一位置。加载的索引可能落后于数据库，
+// 需与数据库同步后再调用 SetReady 标记为可用
+func (index *Index) Load(path string) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.path = path
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var data indexFile
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return err
+	}
+	if data.Version != indexVersion {
+		return fmt.Errorf("索引版本 %d 与当前版本 %d 不兼容", data.Version, indexVersion)
+	}
+
+	index.reset()
+	for source, tokens := range data.Contents {
+		index.setContent(source, tokens)
+	}
+	for _, doc := range data.Docs {
+		index.put(doc)
+	}
+	return nil
+}
+
+// Save 将有变动的索引写入磁盘
+func (index *Index) Save() error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if !index.dirty || index.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(index.path), 0700); err != nil {
+		return err
+	}
+
+	// 先写入临时文件，避免中途失败损坏原有索引
+	tmp := index.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(file).Encode(indexFile{
+		Version:  indexVersion,
+		Docs:     index.docs,
+		Contents: index.contents,
+	})
+	file.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, index.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	index.dirty = false
+	return nil
+}
+
+// Reset 清空索引并标记为未就绪，用于重建索引
+func (index *Index) Reset() {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.reset()
+	index.ready = false
+	index.dirty = true
+}
+
+// SetReady 标记索引已建立完毕，可用于搜索
+func (index *Index) SetReady() {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.ready = true
+}
+
+// Ready 索引是否可用于搜索
+func (index *Index) Ready() bool {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return index.ready
+}
+
+// Put 添加或更新文件的索引，正文沿用同一物理文件已有的正文索引
+func (index *Index) Put(doc Document) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.remove(doc.ID)
+	index.put(&doc)
+	index.dirty = true
+}
+
+// PutContent 更新物理文件的正文索引，没有文件引用该物理文件时忽略
+func (index *Index) PutContent(source, text string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if len(index.sourceDocs[source]) == 0 {
+		return
+	}
+	index.setContent(source, Tokenize(text))
+	index.dirty = true
+}
+
+// HasContent 物理文件是否已建立正文索引
+func (index *Index) HasContent(source string) bool {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	_, ok := index.contents[source]
+	return ok
+}
+
+// Delete 删除文件的索引，物理文件不再被引用时一并删除其正文索引
+func (index *Index) Delete(ids ...uint) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, id := range ids {
+		index.remove(id)
+	}
+	index.dirty = true
+}
+
+// IDs 返回所有已索引文件的ID
+func (index *Index) IDs() []uint {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	ids := make([]uint, 0, len(index.docs))
+	for id := range index.docs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Get 获取文件的索引
+func (index *Index) Get(id uint) (Document, bool) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	doc, ok := index.docs[id]
+	if !ok {
+		return Document{}, false
+	}
+	return *doc, true
+}
+
+// Search 在 uid 用户的文件中执行查询，按相关度排序后返回第 offset 条起
+// 至多 limit 条结果的文件ID及结果总数，limit 为 0 时返回全部结果
+func (index *Index) Search(uid uint, query *Query, offset, limit int) ([]uint, int) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	var (
+		exts    map[string]struct{}
+		folders map[uint]struct{}
+		terms   = make([]string, len(query.Terms))
+		tokens  = make([][]string, len(query.Terms))
+	)
+	if len(query.Exts) > 0 {
+		exts = make(map[string]struct{}, len(query.Exts))
+		for _, ext := range query.Exts {
+			exts[ext] = struct{}{}
+		}
+	}
+	if query.Folders != nil {
+		folders = make(map[uint]struct{}, len(query.Folders))
+		for _, id := range query.Folders {
+			folders[id] = struct{}{}
+		}
+	}
+	for i, term := range query.Terms {
+		terms[i] = strings.ToLower(term)
+		tokens[i] = queryTokens(term)
+	}
+
+	type hit struct {
+		doc   *Document
+		score int
+	}
+	hits := make([]hit, 0)
+	for id := range index.userDocs[uid] {
+		doc := index.docs[id]
+		if doc.Size < query.MinSize || doc.Size > query.MaxSize {
+			continue
+		}
+		if !query.After.IsZero() && doc.UpdatedAt.Before(query.After) {
+			continue
+		}
+		if !query.Before.IsZero() && !doc.UpdatedAt.Before(query.Before) {
+			continue
+		}
+		if exts != nil {
+			if _, ok := exts[doc.ext()]; !ok {
+				continue
+			}
+		}
+		if folders != nil {
+			if _, ok := folders[doc.FolderID]; !ok {
+				continue
+			}
+		}
+
+		// 所有关键词都需命中，文件名命中的权重高于路径或正文命中
+		score, matched := 0, true
+		name := strings.ToLower(doc.Name)
+		dir := strings.ToLower(doc.Path)
+		for i := range terms {
+			if strings.Contains(name, terms[i]) {
+				score += 2
+			} else if strings.Contains(dir, terms[i]) || index.contentContains(doc.Source, tokens[i]) {
+				score++
+			} else {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			hits = append(hits, hit{doc: doc, score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		if !hits[i].doc.UpdatedAt.Equal(hits[j].doc.UpdatedAt) {
+			return hits[i].doc.UpdatedAt.After(hits[j].doc.UpdatedAt)
+		}
+		return hits[i].doc.ID > hits[j].doc.ID
+	})
+
+	total := len(hits)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	ids := make([]uint, 0, end-offset)
+	for _, hit := range hits[offset:end] {
+		ids = append(ids, hit.doc.ID)
+	}
+	return ids, total
+}
+
+// contentContains 物理文件正文是否包含所有词元
+func (index *Index) contentContains(source string, tokens []string) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	for _, token := range tokens {
+		if _, ok := index.postings[token][source]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (index *Index) reset() {
+	index.docs = make(map[uint]*Document)
+	index.userDocs = make(map[uint]map[uint]struct{})
+	index.sourceDocs = make(map[string]map[uint]struct{})
+	index.contents = make(map[string][]string)
+	index.postings = make(map[string]map[string]struct{})
+}
+
+func (index *Index) put(doc *Document) {
+	index.docs[doc.ID] = doc
+	addToSet(index.userDocs, doc.UID, doc.ID)
+	if _, ok := index.sourceDocs[doc.Source]; !ok {
+		index.sourceDocs[doc.Source] = make(map[uint]struct{})
+	}
+	index.sourceDocs[doc.Source][doc.ID] = struct{}{}
+}
+
+func (index *Index) remove(id uint) {
+	doc, ok := index.docs[id]
+	if !ok {
+		return
+	}
+
+	delete(index.docs, id)
+	delete(index.userDocs[doc.UID], id)
+	if len(index.userDocs[doc.UID]) == 0 {
+		delete(index.userDocs, doc.UID)
+	}
+	delete(index.sourceDocs[doc.Source], id)
+	if len(index.sourceDocs[doc.Source]) == 0 {
+		delete(index.sourceDocs, doc.Source)
+		index.setContent(doc.Source, nil)
+	}
+}
+
+// setContent 替换物理文件的正文词元，tokens 为 nil 时删除正文索引
+func (index *Index) setContent(source string, tokens []string) {
+	for _, token := range index.contents[source] {
+		delete(index.postings[token], source)
+		if len(index.postings[token]) == 0 {
+			delete(index.postings, token)
+		}
+	}
+	delete(index.contents, source)
+
+	if tokens == nil {
+		return
+	}
+	index.contents[source] = tokens
+	for _, token := range tokens {
+		if _, ok := index.postings[token]; !ok {
+			index.postings[token] = make(map[string]struct{})
+		}
+		index.postings[token][source] = struct{}{}
+	}
+}
+
+func addToSet(sets map[uint]map[uint]struct{}, key, value uint) {
+	if _, ok := sets[key]; !ok {
+		sets[key] = make(map[uint]struct{})
+	}
+	sets[key][value] = struct{}{}
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testIndex() *Index {
+	index := NewIndex()
+	now := time.Now()
+	index.Put(Document{ID: 1, UID: 1, FolderID: 1, Path: "/", Name: "Report.pdf", Size: 2048, UpdatedAt: now, Source: "1:a"})
+	index.Put(Document{ID: 2, UID: 1, FolderID: 2, Path: "/Travel", Name: "notes.md", Size: 100, UpdatedAt: now.Add(-time.Hour), Source: "1:b"})
+	index.Put(Document{ID: 3, UID: 1, FolderID: 2, Path: "/Travel", Name: "photo.jpg", Size: 4096, UpdatedAt: now.Add(-48 * time.Hour), Source: "1:c"})
+	index.Put(Document{ID: 4, UID: 2, FolderID: 3, Path: "/", Name: "report.txt", Size: 10, UpdatedAt: now, Source: "1:d"})
+	index.PutContent("1:a", "quarterly revenue")
+	index.PutContent("1:b", "meeting notes about the quarterly report 文件系统")
+	return index
+}
+
+func TestIndex_Search(t *testing.T) {
+	asserts := assert.New(t)
+	index := testIndex()
+
+	// 文件名命中优先于正文命中，只返回当前用户的文件
+	{
+		ids, total := index.Search(1, &Query{Terms: []string{"report"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal(2, total)
+		asserts.Equal([]uint{1, 2}, ids)
+	}
+
+	// 所有关键词都需命中
+	{
+		ids, _ := index.Search(1, &Query{Terms: []string{"quarterly", "meeting"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{2}, ids)
+		ids, _ = index.Search(1, &Query{Terms: []string{"文件系统"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{2}, ids)
+		ids, _ = index.Search(1, &Query{Terms: []string{"系统文件"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Empty(ids)
+	}
+
+	// 所在目录路径命中
+	{
+		ids, _ := index.Search(1, &Query{Terms: []string{"travel"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{2, 3}, ids)
+		ids, _ = index.Search(1, &Query{Terms: []string{"travel", "photo"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{3}, ids)
+	}
+
+	// 过滤条件
+	{
+		ids, _ := index.Search(1, &Query{Exts: []string{"jpg", "md"}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{2, 3}, ids)
+		ids, _ = index.Search(1, &Query{MinSize: 1000, MaxSize: 3000}, 0, 0)
+		asserts.Equal([]uint{1}, ids)
+		ids, _ = index.Search(1, &Query{After: time.Now().Add(-24 * time.Hour), MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{1, 2}, ids)
+		ids, _ = index.Search(1, &Query{Before: time.Now().Add(-24 * time.Hour), MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{3}, ids)
+		ids, _ = index.Search(1, &Query{Folders: []uint{2}, MaxSize: ^uint64(0)}, 0, 0)
+		asserts.Equal([]uint{2, 3}, ids)
+	}
+
+	// 分页
+	{
+		ids, total := index.Search(1, &Query{MaxSize: ^uint64(0)}, 1, 1)
+		asserts.Equal(3, total)
+		asserts.Equal([]uint{2}, ids)
+		ids, total = index.Search(1, &Query{MaxSize: ^uint64(0)}, 5, 1)
+		asserts.Equal(3, total)
+		asserts.Empty(ids)
+	}
+}
+
+func TestIndex_PutAndDelete(t *testing.T) {
+	asserts := assert.New(t)
+	index := testIndex()
+
+	// 复制的文件沿用同一物理文件的正文索引
+	index.Put(Document{ID: 5, UID: 1, FolderID: 1, Name: "copy.md", Source: "1:b"})
+	ids, _ := index.Search(1, &Query{Terms: []string{"meeting"}, MaxSize: ^uint64(0)}, 0, 0)
+	asserts.Equal([]uint{2, 5}, ids)
+
+	// 重命名
+	index.Put(Document{ID: 2, UID: 1, FolderID: 2, Name: "renamed.md", Source: "1:b"})
+	doc, ok := index.Get(2)
+	asserts.True(ok)
+	asserts.Equal("renamed.md", doc.Name)
+
+	// 仍有文件引用时保留正文索引
+	index.Delete(2)
+	asserts.True(index.HasContent("1:b"))
+	_, ok = index.Get(2)
+	asserts.False(ok)
+
+	// 物理文件不再被引用时删除正文索引
+	index.Delete(5)
+	asserts.False(index.HasContent("1:b"))
+	_, ok = index.postings["meeting"]
+	asserts.False(ok)
+
+	// 没有文件引用的物理文件忽略正文
+	index.PutContent("1:x", "orphan")
+	asserts.False(index.HasContent("1:x"))
+	asserts.ElementsMatch([]uint{1, 3, 4}, index.IDs())
+}
+
+func TestDocument_Equal(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+	doc := Document{ID: 1, UID: 1, FolderID: 1, Path: "/", Name: "a.txt", UpdatedAt: now, Source: "1:a"}
+
+	// 时区不同的同一时刻
+	other := doc
+	other.UpdatedAt = now.UTC()
+	asserts.True(doc.Equal(&other))
+
+	// 所在目录路径变化
+	other.Path = "/sub"
+	asserts.False(doc.Equal(&other))
+}
+
+func TestIndex_SaveAndLoad(t *testing.T) {
+	asserts := assert.New(t)
+	dir, err := ioutil.TempDir("", "cloudreve_search")
+	asserts.NoError(err)
+	defer os.RemoveAll(dir)
+	indexPath := filepath.Join(dir, "sub", "search.index")
+
+	// 索引文件不存在
+	index := testIndex()
+	asserts.Error(index.Load(indexPath))
+	asserts.False(index.Ready())
+
+	// 保存后重新加载
+	index = testIndex()
+	index.path = indexPath
+	asserts.NoError(index.Save())
+	loaded := NewIndex()
+	asserts.NoError(loaded.Load(indexPath))
+	asserts.False(loaded.Ready())
+	asserts.Len(loaded.IDs(), 4)
+	ids, total := loaded.Search(1, &Query{Terms: []string{"quarterly"}, MaxSize: ^uint64(0)}, 0, 0)
+	asserts.Equal(2, total)
+	asserts.Equal([]uint{1, 2}, ids)
+
+	// 文件损坏
+	asserts.NoError(ioutil.WriteFile(indexPath, []byte("broken"), 0644))
+	asserts.Error(NewIndex().Load(indexPath))
+}
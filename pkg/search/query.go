@@ -0,0 +1,233 @@
+package search
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	// ErrEmptyQuery 查询条件为空
+	ErrEmptyQuery = errors.New("搜索条件不能为空")
+	// ErrInvalidSize 无效的文件大小条件
+	ErrInvalidSize = errors.New("无效的文件大小条件")
+	// ErrInvalidDate 无效的日期条件
+	ErrInvalidDate = errors.New("无效的日期条件，格式应为 2006-01-02")
+	// ErrUnknownType 未知的文件类型
+	ErrUnknownType = errors.New("未知的文件类型")
+)
+
+// TypeExtensions 各文件类型包含的扩展名
+var TypeExtensions = map[string][]string{
+	"image": {"bmp", "iff", "png", "gif", "jpg", "jpeg", "psd", "svg", "webp"},
+	"video": {"mp4", "flv", "avi", "wmv", "mkv", "rm", "rmvb", "mov", "ogv"},
+	"audio": {"mp3", "flac", "ape", "wav", "aac", "ogg", "midi", "mid"},
+	"doc":   {"txt", "md", "pdf", "doc", "docx", "ppt", "pptx", "xls", "xlsx", "pub"},
+}
+
+// dateLayout 日期条件的格式
+const dateLayout = "2006-01-02"
+
+// Query 搜索条件
+type Query struct {
+	// 关键词，文件名包含关键词，或正文包含关键词的所有词元时视为命中
+	Terms []string
+	// 限定的扩展名（小写，不含 .），为空时不限制
+	Exts []string
+	// 文件大小范围，包含边界
+	MinSize uint64
+	MaxSize uint64
+	// 修改时间范围，零值表示不限制
+	After  time.Time
+	Before time.Time
+	// 限定的目录路径
+	In string
+	// 限定的目录ID，由调用方根据 In 解析后填入，为 nil 时不限制
+	Folders []uint
+}
+
+// NewQuery 创建不包含任何条件的查询
+func NewQuery() *Query {
+	return &Query{MaxSize: math.MaxUint64}
+}
+
+// ParseQuery 解析查询语句。查询语句由空格分隔的关键词和过滤条件组成，
+// 包含空格的内容可使用双引号包裹。支持的过滤条件有 type:image|video|audio|doc、
+// ext:pdf,docx、size:>10M（也可使用 >=、<、<= 或 1M..5M 表示范围）、
+// after:2020-01-01、before:2020-02-01 以及 in:/path（包含子目录）
+func ParseQuery(raw string) (*Query, error) {
+	query := NewQuery()
+	for _, field := range splitFields(raw) {
+		sep := strings.Index(field, ":")
+		if sep <= 0 || sep == len(field)-1 {
+			query.Terms = append(query.Terms, field)
+			continue
+		}
+
+		value := field[sep+1:]
+		switch strings.ToLower(field[:sep]) {
+		case "type":
+			exts, ok := TypeExtensions[strings.ToLower(value)]
+			if !ok {
+				return nil, ErrUnknownType
+			}
+			query.Exts = append(query.Exts, exts...)
+		case "ext":
+			for _, ext := range strings.Split(value, ",") {
+				if ext = strings.ToLower(strings.TrimPrefix(ext, ".")); ext != "" {
+					query.Exts = append(query.Exts, ext)
+				}
+			}
+		case "size":
+			if err := query.parseSize(value); err != nil {
+				return nil, err
+			}
+		case "after":
+			date, err := time.ParseInLocation(dateLayout, value, time.Local)
+			if err != nil {
+				return nil, ErrInvalidDate
+			}
+			query.After = date
+		case "before":
+			date, err := time.ParseInLocation(dateLayout, value, time.Local)
+			if err != nil {
+				return nil, ErrInvalidDate
+			}
+			query.Before = date
+		case "in":
+			query.In = value
+		default:
+			query.Terms = append(query.Terms, field)
+		}
+	}
+
+	if query.IsEmpty() {
+		return nil, ErrEmptyQuery
+	}
+	return query, nil
+}
+
+// IsEmpty 查询是否不包含任何条件
+func (query *Query) IsEmpty() bool {
+	return len(query.Terms) == 0 && len(query.Exts) == 0 &&
+		query.MinSize == 0 && query.MaxSize == math.MaxUint64 &&
+		query.After.IsZero() && query.Before.IsZero() && query.In == ""
+}
+
+// parseSize 解析文件大小条件
+func (query *Query) parseSize(value string) error {
+	if bounds := strings.SplitN(value, "..", 2); len(bounds) == 2 {
+		min, err := parseSize(bounds[0])
+		if err != nil {
+			return err
+		}
+		max, err := parseSize(bounds[1])
+		if err != nil || max < min {
+			return ErrInvalidSize
+		}
+		query.MinSize, query.MaxSize = min, max
+		return nil
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(value, op) {
+			continue
+		}
+		size, err := parseSize(value[len(op):])
+		if err != nil {
+			return err
+		}
+		switch op {
+		case ">=":
+			query.MinSize = size
+		case "<=":
+			query.MaxSize = size
+		case ">":
+			if size == math.MaxUint64 {
+				return ErrInvalidSize
+			}
+			query.MinSize = size + 1
+		case "<":
+			if size == 0 {
+				return ErrInvalidSize
+			}
+			query.MaxSize = size - 1
+		}
+		return nil
+	}
+
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	query.MinSize, query.MaxSize = size, size
+	return nil
+}
+
+// parseSize 解析带单位的文件大小，如 512、1.5K、10MB
+func parseSize(value string) (uint64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	unitStart := strings.IndexFunc(value, unicode.IsLetter)
+	if unitStart == -1 {
+		unitStart = len(value)
+	}
+
+	num, err := strconv.ParseFloat(value[:unitStart], 64)
+	if err != nil || num < 0 {
+		return 0, ErrInvalidSize
+	}
+
+	var multiplier float64
+	switch strings.TrimSuffix(value[unitStart:], "B") {
+	case "":
+		multiplier = 1
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	case "T":
+		multiplier = 1 << 40
+	default:
+		return 0, ErrInvalidSize
+	}
+
+	size := num * multiplier
+	if size >= math.MaxUint64 {
+		return 0, ErrInvalidSize
+	}
+	return uint64(size), nil
+}
+
+// splitFields 按空格拆分查询语句，双引号包裹的内容视为一个整体
+func splitFields(raw string) []string {
+	var (
+		fields  = make([]string, 0)
+		current strings.Builder
+		quoted  bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			fields = append(fields, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return fields
+}
@@ -0,0 +1,79 @@
+package search
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 空查询
+	{
+		_, err := ParseQuery("  ")
+		asserts.Equal(ErrEmptyQuery, err)
+	}
+
+	// 关键词，引号包裹
+	{
+		query, err := ParseQuery(`report "annual summary" c:`)
+		asserts.NoError(err)
+		asserts.Equal([]string{"report", "annual summary", "c:"}, query.Terms)
+		asserts.Empty(query.Exts)
+		asserts.EqualValues(0, query.MinSize)
+		asserts.EqualValues(uint64(math.MaxUint64), query.MaxSize)
+	}
+
+	// 类型、扩展名
+	{
+		query, err := ParseQuery("type:audio ext:.PDF,md")
+		asserts.NoError(err)
+		asserts.Empty(query.Terms)
+		asserts.Contains(query.Exts, "mp3")
+		asserts.Contains(query.Exts, "pdf")
+		asserts.Contains(query.Exts, "md")
+
+		_, err = ParseQuery("type:unknown")
+		asserts.Equal(ErrUnknownType, err)
+	}
+
+	// 文件大小
+	{
+		query, err := ParseQuery("size:>1K")
+		asserts.NoError(err)
+		asserts.EqualValues(1025, query.MinSize)
+
+		query, err = ParseQuery("size:<=1.5MB")
+		asserts.NoError(err)
+		asserts.EqualValues(1572864, query.MaxSize)
+
+		query, err = ParseQuery("size:1K..2k")
+		asserts.NoError(err)
+		asserts.EqualValues(1024, query.MinSize)
+		asserts.EqualValues(2048, query.MaxSize)
+
+		query, err = ParseQuery("size:10")
+		asserts.NoError(err)
+		asserts.EqualValues(10, query.MinSize)
+		asserts.EqualValues(10, query.MaxSize)
+
+		for _, value := range []string{"size:<0", "size:2K..1K", "size:1X", "size:abc", "size:-1"} {
+			_, err = ParseQuery(value)
+			asserts.Equal(ErrInvalidSize, err, value)
+		}
+	}
+
+	// 日期、目录
+	{
+		query, err := ParseQuery(`after:2020-01-02 before:2020-02-01 in:"/my docs"`)
+		asserts.NoError(err)
+		asserts.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local), query.After)
+		asserts.Equal(time.Date(2020, 2, 1, 0, 0, 0, 0, time.Local), query.Before)
+		asserts.Equal("/my docs", query.In)
+
+		_, err = ParseQuery("after:yesterday")
+		asserts.Equal(ErrInvalidDate, err)
+	}
+}
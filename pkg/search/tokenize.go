@@ -0,0 +1,101 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxTokenLength 单个词元的最大长度，超出部分会被截断
+const maxTokenLength = 64
+
+// Tokenize 将文本切分为去重后的小写词元。字母、数字组成的连续片段作为一个词元，
+// 中日韩文字没有分隔符，连续片段会同时切分为单字及相邻两字组成的词元
+func Tokenize(text string) []string {
+	seen := make(map[string]struct{})
+	tokens := make([]string, 0)
+	add := func(token string) {
+		if _, ok := seen[token]; ok {
+			return
+		}
+		seen[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+
+	forEachSegment(text, func(segment []rune, cjk bool) {
+		if !cjk {
+			add(string(truncate(segment)))
+			return
+		}
+		for i := range segment {
+			add(string(segment[i]))
+			if i+1 < len(segment) {
+				add(string(segment[i : i+2]))
+			}
+		}
+	})
+
+	return tokens
+}
+
+// queryTokens 将查询词切分为词元，查询词命中要求文档包含所有词元。
+// 与 Tokenize 不同，多于一个字的中日韩片段只切分为两字词元，以近似短语匹配
+func queryTokens(term string) []string {
+	tokens := make([]string, 0)
+	forEachSegment(term, func(segment []rune, cjk bool) {
+		if !cjk || len(segment) == 1 {
+			tokens = append(tokens, string(truncate(segment)))
+			return
+		}
+		for i := 0; i+1 < len(segment); i++ {
+			tokens = append(tokens, string(segment[i:i+2]))
+		}
+	})
+
+	return tokens
+}
+
+// forEachSegment 将文本按字符类别拆分为连续片段，依次交给 fn 处理
+func forEachSegment(text string, fn func(segment []rune, cjk bool)) {
+	var (
+		segment []rune
+		cjk     bool
+	)
+	flush := func() {
+		if len(segment) > 0 {
+			fn(segment, cjk)
+			segment = nil
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			if !cjk {
+				flush()
+			}
+			cjk = true
+			segment = append(segment, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if cjk {
+				flush()
+			}
+			cjk = false
+			segment = append(segment, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// isCJK 字符是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func truncate(segment []rune) []rune {
+	if len(segment) > maxTokenLength {
+		return segment[:maxTokenLength]
+	}
+	return segment
+}
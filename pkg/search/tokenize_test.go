@@ -0,0 +1,35 @@
+package search
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 英文、数字
+	asserts.Equal([]string{"hello", "world", "2020"}, Tokenize("Hello, WORLD! hello 2020"))
+
+	// 中文
+	asserts.Equal([]string{"文", "文件", "件", "件系", "系", "系统", "统"}, Tokenize("文件系统"))
+
+	// 中英混合
+	asserts.Equal([]string{"cloudreve", "网", "网盘", "盘"}, Tokenize("Cloudreve网盘"))
+
+	// 超长词元截断
+	tokens := Tokenize(strings.Repeat("a", 100))
+	asserts.Len(tokens, 1)
+	asserts.Len(tokens[0], maxTokenLength)
+
+	// 空文本
+	asserts.Empty(Tokenize(" ,.!"))
+}
+
+func TestQueryTokens(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal([]string{"文件", "件系", "系统"}, queryTokens("文件系统"))
+	asserts.Equal([]string{"文"}, queryTokens("文"))
+	asserts.Equal([]string{"readme", "md"}, queryTokens("README.md"))
+}
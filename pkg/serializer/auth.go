@@ -4,15 +4,17 @@ import "encoding/json"
 
 // RequestRawSign 待签名的HTTP请求
 type RequestRawSign struct {
+	Method string
 	Path   string
 	Policy string
 	Body   string
 }
 
-// NewRequestSignString 返回JSON格式的待签名字符串
-// TODO 测试
-func NewRequestSignString(path, policy, body string) string {
+// NewRequestSignString 返回JSON格式的待签名字符串，签名同时覆盖请求方法，
+// 同一路径上不同方法的接口无法共用签名
+func NewRequestSignString(method, path, policy, body string) string {
 	req := RequestRawSign{
+		Method: method,
 		Path:   path,
 		Policy: policy,
 		Body:   body,
@@ -8,6 +8,7 @@ import (
 func TestNewRequestSignString(t *testing.T) {
 	asserts := assert.New(t)
 
-	sign := NewRequestSignString("1", "2", "3")
+	sign := NewRequestSignString("PUT", "1", "2", "3")
 	asserts.NotEmpty(sign)
+	asserts.NotEqual(sign, NewRequestSignString("POST", "1", "2", "3"))
 }
@@ -15,17 +15,22 @@ type SiteConfig struct {
 	HomepageViewMethod string `json:"home_view_method"`
 	ShareViewMethod    string `json:"share_view_method"`
 	Authn              bool   `json:"authn"`
+	OIDC               bool   `json:"oidc"`
 	User               User   `json:"user"`
 	UseReCaptcha       bool   `json:"captcha_IsUseReCaptcha"`
 	ReCaptchaKey       string `json:"captcha_ReCaptchaKey"`
 }
 
 type task struct {
-	Status     int    `json:"status"`
-	Type       int    `json:"type"`
-	CreateDate string `json:"create_date"`
-	Progress   int    `json:"progress"`
-	Error      string `json:"error"`
+	ID            uint   `json:"id"`
+	Status        int    `json:"status"`
+	Type          int    `json:"type"`
+	Priority      int    `json:"priority"`
+	CreateDate    string `json:"create_date"`
+	Progress      int    `json:"progress"`
+	ProcessedSize uint64 `json:"processed_size"`
+	TotalSize     uint64 `json:"total_size"`
+	Error         string `json:"error"`
 }
 
 // BuildTaskList 构建任务列表响应
@@ -33,11 +38,15 @@ func BuildTaskList(tasks []model.Task, total int) Response {
 	res := make([]task, 0, len(tasks))
 	for _, t := range tasks {
 		res = append(res, task{
-			Status:     t.Status,
-			Type:       t.Type,
-			CreateDate: t.CreatedAt.Format("2006-01-02 15:04:05"),
-			Progress:   t.Progress,
-			Error:      t.Error,
+			ID:            t.ID,
+			Status:        t.Status,
+			Type:          t.Type,
+			Priority:      t.Priority,
+			CreateDate:    t.CreatedAt.Format("2006-01-02 15:04:05"),
+			Progress:      t.Progress,
+			ProcessedSize: t.ProcessedSize,
+			TotalSize:     t.TotalSize,
+			Error:         t.Error,
 		})
 	}
 
@@ -75,6 +84,7 @@ func BuildSiteConfig(settings map[string]string, user *model.User) Response {
 			HomepageViewMethod: checkSettingValue(settings, "home_view_method"),
 			ShareViewMethod:    checkSettingValue(settings, "share_view_method"),
 			Authn:              model.IsTrueVal(checkSettingValue(settings, "authn_enabled")),
+			OIDC:               model.IsTrueVal(checkSettingValue(settings, "oidc_enabled")),
 			User:               userRes,
 			UseReCaptcha:       model.IsTrueVal(checkSettingValue(settings, "captcha_IsUseReCaptcha")),
 			ReCaptchaKey:       checkSettingValue(settings, "captcha_ReCaptchaKey"),
@@ -3,6 +3,7 @@ package serializer
 import (
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/hashid"
+	"strings"
 	"time"
 )
 
@@ -18,6 +19,34 @@ type Share struct {
 	Preview    bool          `json:"preview"`
 	Creator    *shareCreator `json:"creator,omitempty"`
 	Source     *shareSource  `json:"source,omitempty"`
+	Request    *shareRequest `json:"request,omitempty"`
+}
+
+// shareRequest 文件收集的上传限制
+type shareRequest struct {
+	MaxSize     uint64   `json:"max_size"`
+	AllowedExts []string `json:"allowed_exts"`
+	UploadQuota uint64   `json:"upload_quota"`
+	Uploaded    uint64   `json:"uploaded"`
+	Uploads     int      `json:"uploads"`
+}
+
+// buildShareRequest 构建文件收集的上传限制，非文件收集时返回空
+func buildShareRequest(share *model.Share) *shareRequest {
+	if !share.FileRequest {
+		return nil
+	}
+	exts := []string{}
+	if share.AllowedExts != "" {
+		exts = strings.Split(share.AllowedExts, ",")
+	}
+	return &shareRequest{
+		MaxSize:     share.MaxSize,
+		AllowedExts: exts,
+		UploadQuota: share.UploadQuota,
+		Uploaded:    share.Uploaded,
+		Uploads:     share.Uploads,
+	}
 }
 
 type shareCreator struct {
@@ -33,16 +62,17 @@ type shareSource struct {
 
 // myShareItem 我的分享列表条目
 type myShareItem struct {
-	Key             string       `json:"key"`
-	IsDir           bool         `json:"is_dir"`
-	Password        string       `json:"password"`
-	CreateDate      string       `json:"create_date,omitempty"`
-	Downloads       int          `json:"downloads"`
-	RemainDownloads int          `json:"remain_downloads"`
-	Views           int          `json:"views"`
-	Expire          int64        `json:"expire"`
-	Preview         bool         `json:"preview"`
-	Source          *shareSource `json:"source,omitempty"`
+	Key             string        `json:"key"`
+	IsDir           bool          `json:"is_dir"`
+	Password        string        `json:"password"`
+	CreateDate      string        `json:"create_date,omitempty"`
+	Downloads       int           `json:"downloads"`
+	RemainDownloads int           `json:"remain_downloads"`
+	Views           int           `json:"views"`
+	Expire          int64         `json:"expire"`
+	Preview         bool          `json:"preview"`
+	Source          *shareSource  `json:"source,omitempty"`
+	Request         *shareRequest `json:"request,omitempty"`
 }
 
 // BuildShareList 构建我的分享列表响应
@@ -60,6 +90,7 @@ func BuildShareList(shares []model.Share, total int) Response {
 			Preview:         shares[i].PreviewEnabled,
 			Expire:          -1,
 			RemainDownloads: shares[i].RemainDownloads,
+			Request:         buildShareRequest(&shares[i]),
 		}
 		if shares[i].Expires != nil {
 			item.Expire = shares[i].Expires.Unix() - now
@@ -110,6 +141,7 @@ func BuildShareResponse(share *model.Share, unlocked bool) Share {
 	resp.Downloads = share.Downloads
 	resp.Views = share.Views
 	resp.Preview = share.PreviewEnabled
+	resp.Request = buildShareRequest(share)
 
 	if share.Expires != nil {
 		resp.Expire = share.Expires.Unix() - time.Now().Unix()
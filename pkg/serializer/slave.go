@@ -1,5 +1,10 @@
 package serializer
 
+import (
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"time"
+)
+
 // RemoteDeleteRequest 远程策略删除接口请求正文
 type RemoteDeleteRequest struct {
 	Files []string `json:"files"`
@@ -10,3 +15,53 @@ type ListRequest struct {
 	Path      string `json:"path"`
 	Recursive bool   `json:"recursive"`
 }
+
+// RemoteUploadSessionRequest 远程策略创建分片上传会话请求正文
+type RemoteUploadSessionRequest struct {
+	Key       string       `json:"key"`
+	Name      string       `json:"name"`
+	MIMEType  string       `json:"mime_type"`
+	Size      uint64       `json:"size"`
+	ChunkSize uint64       `json:"chunk_size"`
+	TTL       int          `json:"ttl"`
+	Policy    UploadPolicy `json:"policy"`
+}
+
+// SlaveTaskRequest 远程策略创建从机任务请求正文
+type SlaveTaskRequest struct {
+	Type   int          `json:"type"`
+	Props  string       `json:"props"`
+	Policy UploadPolicy `json:"policy"`
+}
+
+// SlaveTaskStatus 从机任务的执行状态
+type SlaveTaskStatus struct {
+	Status        int               `json:"status"`
+	ProcessedSize uint64            `json:"processed_size"`
+	TotalSize     uint64            `json:"total_size"`
+	Error         string            `json:"error,omitempty"`
+	Objects       []response.Object `json:"objects,omitempty"`
+}
+
+// SlaveCompressFile 从机压缩任务中的单个文件
+type SlaveCompressFile struct {
+	Source    string    `json:"source"`
+	Name      string    `json:"name"`
+	Size      uint64    `json:"size"`
+	Modified  time.Time `json:"modified"`
+	Encrypted bool      `json:"encrypted,omitempty"`
+}
+
+// SlaveCompressProps 从机压缩任务属性
+type SlaveCompressProps struct {
+	Files []SlaveCompressFile `json:"files"`
+	Dst   string              `json:"dst"`
+}
+
+// SlaveDecompressProps 从机解压缩任务属性
+type SlaveDecompressProps struct {
+	Src       string `json:"src"`
+	Size      uint64 `json:"size"`
+	Dst       string `json:"dst"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
@@ -0,0 +1,36 @@
+package serializer
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+)
+
+// trash 回收站对象序列化器
+type trash struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	OriginPath string `json:"origin_path"`
+	Size       uint64 `json:"size"`
+	Date       string `json:"date"`
+}
+
+// BuildTrashList 构建回收站列表响应
+func BuildTrashList(trashes []model.Trash, total int) Response {
+	res := make([]trash, 0, len(trashes))
+	for _, t := range trashes {
+		res = append(res, trash{
+			ID:         hashid.HashID(t.ID, hashid.TrashID),
+			Name:       t.Name,
+			Type:       t.ObjectType,
+			OriginPath: t.OriginPath,
+			Size:       t.Size,
+			Date:       t.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total":   total,
+		"objects": res,
+	}}
+}
@@ -0,0 +1,16 @@
+package serializer
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildTrashList(t *testing.T) {
+	asserts := assert.New(t)
+	trashes := []model.Trash{{ObjectType: model.TrashFileType, Name: "a.txt"}}
+
+	res := BuildTrashList(trashes, 1)
+	asserts.Equal(1, res.Data.(map[string]interface{})["total"])
+	asserts.Len(res.Data.(map[string]interface{})["objects"], 1)
+}
@@ -14,6 +14,7 @@ type UploadPolicy struct {
 	MaxSize          uint64   `json:"max_size"`
 	AllowedExtension []string `json:"allowed_extension"`
 	CallbackURL      string   `json:"callback_url"`
+	Encrypt          bool     `json:"encrypt,omitempty"`
 }
 
 // UploadCredential 返回给客户端的上传凭证
@@ -38,6 +39,36 @@ type UploadSession struct {
 	SavePath    string
 }
 
+// ChunkUploadSession 分片上传会话
+type ChunkUploadSession struct {
+	Key         string
+	UID         uint
+	PolicyID    uint
+	VirtualPath string
+	Name        string
+	MIMEType    string
+	Size        uint64
+	ChunkSize   uint64
+	Policy      UploadPolicy // 从机端使用的上传策略
+	Operator    uint         // 上传至“与我共享”中的目录时，发起上传的用户ID
+}
+
+// UploadSessionResponse 创建分片上传会话的响应
+type UploadSessionResponse struct {
+	SessionID  string            `json:"session_id"`
+	ChunkSize  uint64            `json:"chunk_size"`
+	Expires    int64             `json:"expires"`
+	UploadURL  string            `json:"upload_url,omitempty"` // 从机直传地址
+	Credential *UploadCredential `json:"credential,omitempty"` // 从机直传凭证
+}
+
+// UploadSessionStatus 分片上传会话状态
+type UploadSessionStatus struct {
+	Size      uint64 `json:"size"`
+	ChunkSize uint64 `json:"chunk_size"`
+	Received  []uint `json:"received"`
+}
+
 // UploadCallback 上传回调正文
 type UploadCallback struct {
 	Name       string `json:"name"`
@@ -53,6 +84,7 @@ type QiniuCallbackFailed struct {
 
 func init() {
 	gob.Register(UploadSession{})
+	gob.Register(ChunkUploadSession{})
 }
 
 // DecodeUploadPolicy 反序列化Header中携带的上传策略
@@ -83,3 +115,22 @@ func (policy *UploadPolicy) EncodeUploadPolicy() (string, error) {
 	return res, nil
 
 }
+
+// ChunkCount 返回文件被分成的分片数量
+func (session *ChunkUploadSession) ChunkCount() uint {
+	if session.ChunkSize == 0 {
+		return 0
+	}
+	return uint((session.Size + session.ChunkSize - 1) / session.ChunkSize)
+}
+
+// ChunkLength 返回第 index 个分片的预期大小
+func (session *ChunkUploadSession) ChunkLength(index uint) uint64 {
+	if index >= session.ChunkCount() {
+		return 0
+	}
+	if index == session.ChunkCount()-1 {
+		return session.Size - uint64(index)*session.ChunkSize
+	}
+	return session.ChunkSize
+}
@@ -61,3 +61,22 @@ func TestUploadPolicy_EncodeUploadPolicy(t *testing.T) {
 	asserts.NoError(err)
 	asserts.NotEmpty(res)
 }
+
+func TestChunkUploadSession_ChunkCount(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.EqualValues(0, (&ChunkUploadSession{Size: 10}).ChunkCount())
+	asserts.EqualValues(0, (&ChunkUploadSession{Size: 0, ChunkSize: 4}).ChunkCount())
+	asserts.EqualValues(3, (&ChunkUploadSession{Size: 10, ChunkSize: 4}).ChunkCount())
+	asserts.EqualValues(2, (&ChunkUploadSession{Size: 8, ChunkSize: 4}).ChunkCount())
+}
+
+func TestChunkUploadSession_ChunkLength(t *testing.T) {
+	asserts := assert.New(t)
+	session := &ChunkUploadSession{Size: 10, ChunkSize: 4}
+
+	asserts.EqualValues(4, session.ChunkLength(0))
+	asserts.EqualValues(4, session.ChunkLength(1))
+	asserts.EqualValues(2, session.ChunkLength(2))
+	asserts.EqualValues(0, session.ChunkLength(3))
+}
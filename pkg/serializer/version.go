@@ -0,0 +1,27 @@
+package serializer
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+)
+
+// fileVersion 文件历史版本序列化器
+type fileVersion struct {
+	ID   string `json:"id"`
+	Size uint64 `json:"size"`
+	Date string `json:"date"`
+}
+
+// BuildFileVersionList 构建文件历史版本列表响应
+func BuildFileVersionList(versions []model.FileVersion) Response {
+	res := make([]fileVersion, 0, len(versions))
+	for _, v := range versions {
+		res = append(res, fileVersion{
+			ID:   hashid.HashID(v.ID, hashid.VersionID),
+			Size: v.Size,
+			Date: v.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return Response{Data: res}
+}
@@ -0,0 +1,15 @@
+package serializer
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildFileVersionList(t *testing.T) {
+	asserts := assert.New(t)
+	versions := []model.FileVersion{{Size: 1}, {Size: 2}}
+
+	res := BuildFileVersionList(versions)
+	asserts.Len(res.Data, 2)
+}
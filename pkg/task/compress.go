@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"os"
+	"path"
 )
 
 // CompressTask 文件压缩任务
@@ -81,7 +84,7 @@ func (job *CompressTask) GetError() *JobError {
 }
 
 // Do 开始执行任务
-func (job *CompressTask) Do() {
+func (job *CompressTask) Do(ctx context.Context) {
 	// 创建文件系统
 	fs, err := filesystem.NewFileSystem(job.User)
 	if err != nil {
@@ -92,8 +95,20 @@ func (job *CompressTask) Do() {
 	util.Log().Debug("开始压缩文件")
 	job.TaskModel.SetProgress(CompressingProgress)
 
+	// 压缩文件的目的存储端可执行任务，且待压缩文件均位于此存储端时，在存储端压缩
+	if handler, ok := slaveHandler(fs); ok {
+		files, err := fs.GetCompressFiles(ctx, job.TaskProps.Dirs, job.TaskProps.Files)
+		if err != nil {
+			job.SetErrorMsg(err.Error())
+			return
+		}
+		if isOnPolicy(files, job.User.Policy.ID) {
+			job.compressOnSlave(ctx, fs, handler, files)
+			return
+		}
+	}
+
 	// 开始压缩
-	ctx := context.Background()
 	zipFile, err := fs.Compress(ctx, job.TaskProps.Dirs, job.TaskProps.Files, false)
 	if err != nil {
 		job.SetErrorMsg(err.Error())
@@ -110,10 +125,77 @@ func (job *CompressTask) Do() {
 		job.SetErrorMsg(err.Error())
 		return
 	}
+	HookReplicate(ctx, fs)
 
 	job.removeZipFile()
 }
 
+// compressOnSlave 在存储端压缩给定文件，并将压缩文件添加到用户文件系统
+func (job *CompressTask) compressOnSlave(ctx context.Context, fs *filesystem.FileSystem, handler SlaveHandler, files []model.File) {
+	props := serializer.SlaveCompressProps{
+		Files: make([]serializer.SlaveCompressFile, 0, len(files)),
+		Dst: fs.GenerateSavePath(ctx, local.FileStream{
+			Name:        path.Base(job.TaskProps.Dst),
+			VirtualPath: path.Dir(job.TaskProps.Dst),
+		}),
+	}
+	for _, file := range files {
+		props.Files = append(props.Files, serializer.SlaveCompressFile{
+			Source:    file.SourceName,
+			Name:      path.Join(file.Position, file.Name),
+			Size:      file.Size,
+			Modified:  file.UpdatedAt,
+			Encrypted: file.Encrypted,
+		})
+	}
+
+	objects, err := runOnSlave(ctx, handler, CompressTaskType, props)
+	if err != nil {
+		// 任务已取消
+		if ctx.Err() != nil {
+			return
+		}
+		job.SetErrorMsg(err.Error())
+		return
+	}
+
+	// 添加压缩文件
+	job.TaskModel.SetProgress(InsertingProgress)
+	for i := range objects {
+		objects[i].Name = path.Base(job.TaskProps.Dst)
+		objects[i].RelativePath = objects[i].Name
+	}
+	fs.Use("BeforeAddFile", filesystem.HookValidateFile)
+	fs.Use("BeforeAddFile", filesystem.HookValidateCapacity)
+	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	added, failed, err := addObjects(ctx, fs, path.Dir(job.TaskProps.Dst), objects, true)
+	if len(failed) > 0 {
+		handler.Delete(context.Background(), failed)
+	}
+	if err != nil || len(added) == 0 {
+		if ctx.Err() == nil {
+			job.SetErrorMsg("无法添加压缩文件")
+		}
+		return
+	}
+
+	fs.SetTargetFile(&[]model.File{*added[0]})
+	HookReplicate(ctx, fs)
+}
+
+// isOnPolicy 返回文件是否均使用给定存储策略
+func isOnPolicy(files []model.File, policyID uint) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, file := range files {
+		if file.PolicyID != policyID {
+			return false
+		}
+	}
+	return true
+}
+
 // NewCompressTask 新建压缩任务
 func NewCompressTask(user *model.User, dst string, dirs, files []uint) (Job, error) {
 	newTask := &CompressTask{
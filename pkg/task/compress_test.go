@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/HFO4/cloudreve/models"
@@ -78,7 +79,7 @@ func TestCompressTask_Do(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1,
 			1))
 		mock.ExpectCommit()
-		task.Do()
+		task.Do(context.Background())
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotEmpty(task.GetError().Msg)
 	}
@@ -103,7 +104,7 @@ func TestCompressTask_Do(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1,
 			1))
 		mock.ExpectCommit()
-		task.Do()
+		task.Do(context.Background())
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotEmpty(task.GetError().Msg)
 	}
@@ -140,7 +141,7 @@ func TestCompressTask_Do(t *testing.T) {
 		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1,
 			1))
 		mock.ExpectCommit()
-		task.Do()
+		task.Do(context.Background())
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotEmpty(task.GetError().Msg)
 		asserts.True(util.IsEmpty("test/compress"))
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"path"
 )
 
 // DecompressTask 文件压缩任务
@@ -71,7 +74,7 @@ func (job *DecompressTask) GetError() *JobError {
 }
 
 // Do 开始执行任务
-func (job *DecompressTask) Do() {
+func (job *DecompressTask) Do(ctx context.Context) {
 	// 创建文件系统
 	fs, err := filesystem.NewFileSystem(job.User)
 	if err != nil {
@@ -80,7 +83,20 @@ func (job *DecompressTask) Do() {
 	}
 
 	job.TaskModel.SetProgress(DecompressingProgress)
-	err = fs.Decompress(context.Background(), job.TaskProps.Src, job.TaskProps.Dst)
+
+	// 压缩文件位于解压目的存储端，且存储端可执行任务时，在存储端解压
+	if err := fs.ResetFileIfNotExist(ctx, job.TaskProps.Src); err != nil {
+		job.SetErrorMsg("解压缩失败", err)
+		return
+	}
+	if fs.FileTarget[0].PolicyID == job.User.Policy.ID {
+		if handler, ok := slaveHandler(fs); ok {
+			job.decompressOnSlave(ctx, fs, handler)
+			return
+		}
+	}
+
+	err = fs.Decompress(ctx, job.TaskProps.Src, job.TaskProps.Dst)
 	if err != nil {
 		job.SetErrorMsg("解压缩失败", err)
 		return
@@ -88,6 +104,43 @@ func (job *DecompressTask) Do() {
 
 }
 
+// decompressOnSlave 在存储端解压缩文件，并将解压出的目录和文件添加到用户文件系统
+func (job *DecompressTask) decompressOnSlave(ctx context.Context, fs *filesystem.FileSystem, handler SlaveHandler) {
+	archive := fs.FileTarget[0]
+	props := serializer.SlaveDecompressProps{
+		Src:  archive.SourceName,
+		Size: archive.Size,
+		Dst: path.Join(
+			job.User.Policy.GeneratePath(job.User.ID, job.TaskProps.Dst),
+			"decompress_"+util.RandStringRunes(16),
+		),
+		Encrypted: archive.Encrypted,
+	}
+
+	objects, err := runOnSlave(ctx, handler, DecompressTaskType, props)
+	if err != nil {
+		// 任务已取消
+		if ctx.Err() != nil {
+			return
+		}
+		job.SetErrorMsg("解压缩失败", err)
+		return
+	}
+
+	// 添加解压出的目录和文件，未能添加的文件从存储端删除
+	job.TaskModel.SetProgress(InsertingProgress)
+	fs.Use("BeforeAddFile", filesystem.HookValidateFile)
+	fs.Use("BeforeAddFile", filesystem.HookValidateCapacity)
+	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	_, failed, err := addObjects(ctx, fs, job.TaskProps.Dst, objects, true)
+	if len(failed) > 0 {
+		handler.Delete(context.Background(), failed)
+	}
+	if err == filesystem.ErrInsufficientCapacity {
+		job.SetErrorMsg("容量不足", err)
+	}
+}
+
 // NewDecompressTask 新建压缩任务
 func NewDecompressTask(user *model.User, src, dst string) (Job, error) {
 	newTask := &DecompressTask{
@@ -0,0 +1,186 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/util"
+)
+
+// EncryptTask 加密存储策略下已有文件的任务
+type EncryptTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps EncryptProps
+	Err       *JobError
+}
+
+// EncryptProps 存储加密任务属性
+type EncryptProps struct {
+	PolicyID  uint   `json:"policy_id"` // 存储策略ID
+	LastID    uint   `json:"last_id"`   // 已处理的最后一个文件ID，任务恢复时由此继续
+	Encrypted int    `json:"encrypted"` // 已加密的文件数量
+	Failed    []uint `json:"failed"`    // 加密失败的文件ID
+}
+
+// Props 获取任务属性
+func (job *EncryptTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务类型
+func (job *EncryptTask) Type() int {
+	return EncryptTaskType
+}
+
+// Creator 获取创建者ID
+func (job *EncryptTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *EncryptTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *EncryptTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *EncryptTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *EncryptTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *EncryptTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *EncryptTask) Do(ctx context.Context) {
+
+	// 查找存储策略
+	policy, err := model.GetPolicyByID(job.TaskProps.PolicyID)
+	if err != nil {
+		job.SetErrorMsg("找不到存储策略", err)
+		return
+	}
+	if !policy.OptionsSerialized.Encrypt {
+		job.SetErrorMsg("存储策略未开启加密存储", nil)
+		return
+	}
+
+	// 创建文件系统
+	job.User.Policy = policy
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	// 已处理的物理文件，加密后的新物理文件在后续批次中仍会被列出，一并记录以跳过
+	handled := make(map[string]bool)
+	for {
+		// 目标存储策略ID为0，即列取此存储策略下的全部文件
+		files, err := model.GetFilesToMigrate(model.StorageScopePolicy, policy.ID, 0,
+			job.TaskProps.LastID, migrateBatchSize)
+		if err != nil {
+			job.SetErrorMsg("无法列取待加密文件", err)
+			return
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		// 共用物理文件的记录会随第一个文件一同加密
+		for i := range files {
+			// 任务已取消，已处理的进度保存在任务属性中，重试时继续
+			if ctx.Err() != nil {
+				return
+			}
+
+			source := files[i].SourceName
+			if !handled[source] {
+				encrypted, err := fs.EncryptFile(ctx, &files[i])
+				if err != nil {
+					util.Log().Warning("无法加密文件[%d]，%s", files[i].ID, err)
+					job.TaskProps.Failed = append(job.TaskProps.Failed, files[i].ID)
+				} else {
+					handled[source] = true
+					handled[files[i].SourceName] = true
+					if encrypted {
+						job.TaskProps.Encrypted++
+					}
+				}
+			}
+
+			// 记录进度，以便任务中断后继续
+			job.TaskProps.LastID = files[i].ID
+			job.TaskModel.SetProps(job.Props())
+			job.TaskModel.SetProgress(job.TaskProps.Encrypted)
+		}
+	}
+
+	if len(job.TaskProps.Failed) > 0 {
+		job.SetErrorMsg(fmt.Sprintf("%d 个文件加密失败", len(job.TaskProps.Failed)), nil)
+	}
+}
+
+// NewEncryptTask 新建存储加密任务
+func NewEncryptTask(user uint, policy uint) (Job, error) {
+	creator, err := model.GetActiveUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &EncryptTask{
+		User: &creator,
+		TaskProps: EncryptProps{
+			PolicyID: policy,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewEncryptTaskFromModel 从数据库记录中恢复存储加密任务
+func NewEncryptTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &EncryptTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,160 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncryptTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &EncryptTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(EncryptTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestEncryptTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &EncryptTask{
+		User: &model.User{},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: EncryptProps{
+			PolicyID: 64,
+		},
+	}
+
+	// 存储策略不存在
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotEmpty(task.Err.Error)
+		task.Err = nil
+	}
+
+	// 存储策略未开启加密
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(64, "local"))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("存储策略未开启加密存储", task.Err.Msg)
+		task.Err = nil
+	}
+
+	// 无法列取文件
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "options"}).AddRow(64, "local", `{"encrypt":true}`))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("无法列取待加密文件", task.Err.Msg)
+		task.Err = nil
+	}
+
+	// 文件加密失败，记录进度后继续
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "options"}).AddRow(64, "local", `{"encrypt":true}`))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(0, 0, 64).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).AddRow(5, 64, "TestEncryptTask_Do_notExist.txt"))
+		// 记录进度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)progress(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 从上次处理的文件继续列取
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(5, 0, 64).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(task.Err)
+		asserts.EqualValues(5, task.TaskProps.LastID)
+		asserts.Equal([]uint{5}, task.TaskProps.Failed)
+		asserts.Equal(0, task.TaskProps.Encrypted)
+	}
+}
+
+func TestNewEncryptTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewEncryptTask(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(job)
+		asserts.NoError(err)
+	}
+
+	// 用户不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		job, err := NewEncryptTask(1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewEncryptTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewEncryptTaskFromModel(&model.Task{Props: `{"policy_id":2,"last_id":5}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		if asserts.NoError(err) {
+			asserts.EqualValues(5, job.(*EncryptTask).TaskProps.LastID)
+			asserts.EqualValues(2, job.(*EncryptTask).TaskProps.PolicyID)
+		}
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewEncryptTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
@@ -5,4 +5,8 @@ import "errors"
 var (
 	// ErrUnknownTaskType 未知任务类型
 	ErrUnknownTaskType = errors.New("未知任务类型")
+	// ErrNotCancelable 任务已结束
+	ErrNotCancelable = errors.New("任务已结束，无法取消")
+	// ErrNotRetryable 任务未失败或未取消
+	ErrNotRetryable = errors.New("只能重试执行失败或已取消的任务")
 )
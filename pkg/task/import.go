@@ -7,6 +7,8 @@ import (
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"path"
 )
@@ -75,8 +77,7 @@ func (job *ImportTask) GetError() *JobError {
 }
 
 // Do 开始执行任务
-func (job *ImportTask) Do() {
-	ctx := context.Background()
+func (job *ImportTask) Do(ctx context.Context) {
 
 	// 查找存储策略
 	policy, err := model.GetPolicyByID(job.TaskProps.PolicyID)
@@ -99,29 +100,61 @@ func (job *ImportTask) Do() {
 	fs.Use("BeforeAddFile", filesystem.HookValidateCapacity)
 	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 
-	// 列取目录、对象
+	// 列取目录、对象，存储端可执行任务时在存储端列取
 	job.TaskModel.SetProgress(ListingProgress)
-	coxIgnoreConflict := context.WithValue(context.Background(), fsctx.IgnoreConflictCtx,
-		true)
-	objects, err := fs.Handler.List(ctx, job.TaskProps.Src, job.TaskProps.Recursive)
+	var objects []response.Object
+	if handler, ok := slaveHandler(fs); ok {
+		objects, err = runOnSlave(ctx, handler, ImportTaskType, serializer.ListRequest{
+			Path:      job.TaskProps.Src,
+			Recursive: job.TaskProps.Recursive,
+		})
+	} else {
+		objects, err = fs.Handler.List(ctx, job.TaskProps.Src, job.TaskProps.Recursive)
+	}
 	if err != nil {
+		// 任务已取消
+		if ctx.Err() != nil {
+			return
+		}
 		job.SetErrorMsg("无法列取文件", err)
 		return
 	}
 
+	// 导入的是存储端已有的文件，未经加密
 	job.TaskModel.SetProgress(InsertingProgress)
+	ctx = context.WithValue(ctx, fsctx.EncryptedCtx, false)
+	if _, _, err := addObjects(ctx, fs, job.TaskProps.Dst, objects, false); err == filesystem.ErrInsufficientCapacity {
+		job.SetErrorMsg("容量不足", err)
+	}
+}
+
+// addObjects 将存储端已有的目录和文件添加到用户文件系统的 dst 目录下，unique 为真时
+// 与已有文件同名的文件会被自动重命名。返回添加的文件及未能添加的文件的物理路径，
+// 容量不足或任务取消时，剩余的文件均视为未能添加
+func addObjects(ctx context.Context, fs *filesystem.FileSystem, dst string, objects []response.Object, unique bool) ([]*model.File, []string, error) {
+	var (
+		added  []*model.File
+		failed []string
+	)
+	coxIgnoreConflict := context.WithValue(ctx, fsctx.IgnoreConflictCtx,
+		true)
 
 	// 虚拟目录路径与folder对象ID的对应
 	pathCache := make(map[string]*model.Folder, len(objects))
 
 	// 插入目录记录到用户文件系统
 	for _, object := range objects {
+		// 任务已取消
+		if ctx.Err() != nil {
+			return added, fileSources(objects), ctx.Err()
+		}
+
 		if object.IsDir {
 			// 创建目录
-			virtualPath := path.Join(job.TaskProps.Dst, object.RelativePath)
+			virtualPath := path.Join(dst, object.RelativePath)
 			folder, err := fs.CreateDirectory(coxIgnoreConflict, virtualPath)
 			if err != nil {
-				util.Log().Warning("导入任务无法创建用户目录[%s], %s", virtualPath, err)
+				util.Log().Warning("无法创建用户目录[%s], %s", virtualPath, err)
 			} else if folder.ID > 0 {
 				pathCache[virtualPath] = folder
 			}
@@ -129,14 +162,25 @@ func (job *ImportTask) Do() {
 	}
 
 	// 插入文件记录到用户文件系统
-	for _, object := range objects {
+	for i, object := range objects {
+		// 任务已取消
+		if ctx.Err() != nil {
+			return added, append(failed, fileSources(objects[i:])...), ctx.Err()
+		}
+
 		if !object.IsDir {
 			// 创建文件信息
-			virtualPath := path.Dir(path.Join(job.TaskProps.Dst, object.RelativePath))
+			virtualPath := path.Dir(path.Join(dst, object.RelativePath))
+			fileName := object.Name
+			if unique {
+				if uniqueName, err := fs.GetUniqueFileName(ctx, fileName, virtualPath); err == nil {
+					fileName = uniqueName
+				}
+			}
 			fileHeader := local.FileStream{
 				Size:        object.Size,
 				VirtualPath: virtualPath,
-				Name:        object.Name,
+				Name:        fileName,
 			}
 			addFileCtx := context.WithValue(ctx, fsctx.FileHeaderCtx, fileHeader)
 			addFileCtx = context.WithValue(addFileCtx, fsctx.SavePathCtx, object.Source)
@@ -150,10 +194,11 @@ func (job *ImportTask) Do() {
 				if exist {
 					parentFolder = folder
 				} else {
-					folder, err := fs.CreateDirectory(context.Background(), virtualPath)
+					folder, err := fs.CreateDirectory(ctx, virtualPath)
 					if err != nil {
-						util.Log().Warning("导入任务无法创建用户目录[%s], %s",
+						util.Log().Warning("无法创建用户目录[%s], %s",
 							virtualPath, err)
+						failed = append(failed, object.Source)
 						continue
 					}
 					parentFolder = folder
@@ -161,18 +206,33 @@ func (job *ImportTask) Do() {
 			}
 
 			// 插入文件记录
-			_, err := fs.AddFile(addFileCtx, parentFolder)
+			file, err := fs.AddFile(addFileCtx, parentFolder)
 			if err != nil {
-				util.Log().Warning("导入任务无法创插入文件[%s], %s",
+				util.Log().Warning("无法插入文件[%s], %s",
 					object.RelativePath, err)
+				failed = append(failed, object.Source)
 				if err == filesystem.ErrInsufficientCapacity {
-					job.SetErrorMsg("容量不足", err)
-					return
+					return added, append(failed, fileSources(objects[i+1:])...), err
 				}
+				continue
 			}
+			added = append(added, file)
+
+		}
+	}
+
+	return added, failed, nil
+}
 
+// fileSources 返回对象中所有文件的物理路径
+func fileSources(objects []response.Object) []string {
+	sources := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if !object.IsDir {
+			sources = append(sources, object.Source)
 		}
 	}
+	return sources
 }
 
 // NewImportTask 新建导入任务
@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/HFO4/cloudreve/models"
@@ -79,7 +80,7 @@ func TestImportTask_Do(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		task.Do()
+		task.Do(context.Background())
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotEmpty(task.Err.Error)
 		task.Err = nil
@@ -94,7 +95,7 @@ func TestImportTask_Do(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		task.Do()
+		task.Do(context.Background())
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotEmpty(task.Err.Msg)
 		task.Err = nil
@@ -114,7 +115,7 @@ func TestImportTask_Do(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		task.Do()
+		task.Do(context.Background())
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Nil(task.Err)
 		task.Err = nil
@@ -147,7 +148,7 @@ func TestImportTask_Do(t *testing.T) {
 		// 创建文件时查找父目录，仍然不存在
 		mock.ExpectQuery("SELECT(.+)folders").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
-		task.Do()
+		task.Do(context.Background())
 
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Nil(task.Err)
@@ -183,7 +184,7 @@ func TestImportTask_Do(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
 		mock.ExpectCommit()
 
-		task.Do()
+		task.Do(context.Background())
 
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Nil(task.Err)
@@ -1,6 +1,7 @@
 package task
 
 import (
+	"context"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/util"
 )
@@ -15,6 +16,12 @@ const (
 	TransferTaskType
 	// ImportTaskType 导入任务
 	ImportTaskType
+	// MigrateTaskType 存储策略迁移任务
+	MigrateTaskType
+	// ReplicateTaskType 文件副本复制任务
+	ReplicateTaskType
+	// EncryptTaskType 存储加密任务
+	EncryptTaskType
 )
 
 // 任务状态
@@ -31,6 +38,16 @@ const (
 	Complete
 )
 
+// 任务优先级，数值越大越先执行
+const (
+	// LowPriority 低优先级
+	LowPriority = -1
+	// NormalPriority 默认优先级
+	NormalPriority = 0
+	// HighPriority 高优先级
+	HighPriority = 1
+)
+
 // 任务进度
 const (
 	// PendingProgress 等待中
@@ -56,7 +73,7 @@ type Job interface {
 	Props() string       // 返回序列化后的任务属性
 	Model() *model.Task  // 返回对应的数据库模型
 	SetStatus(int)       // 设定任务状态
-	Do()                 // 开始执行任务
+	Do(context.Context)  // 开始执行任务，ctx 取消后应尽快返回
 	SetError(*JobError)  // 设定任务失败信息
 	GetError() *JobError // 获取任务执行结果，返回nil表示成功完成执行
 }
@@ -76,11 +93,22 @@ func Record(job Job) (*model.Task, error) {
 		Progress: 0,
 		Error:    "",
 		Props:    job.Props(),
+		Priority: defaultPriority(job.Type()),
 	}
 	_, err := record.Create()
 	return &record, err
 }
 
+// defaultPriority 返回任务类型的默认优先级，管理员发起的批量任务低于用户发起的任务
+func defaultPriority(taskType int) int {
+	switch taskType {
+	case ImportTaskType, MigrateTaskType, ReplicateTaskType, EncryptTaskType:
+		return LowPriority
+	default:
+		return NormalPriority
+	}
+}
+
 // Resume 从数据库中恢复未完成任务
 func Resume() {
 	tasks := model.GetTasksByStatus(Queued, Processing)
@@ -100,6 +128,45 @@ func Resume() {
 	}
 }
 
+// Cancel 取消排队中或执行中的任务，任务不在任务池中时直接将记录设为已取消
+func Cancel(task *model.Task) error {
+	if task.Status != Queued && task.Status != Processing {
+		return ErrNotCancelable
+	}
+
+	if TaskPoll.Cancel(task.ID) {
+		return nil
+	}
+	return task.SetStatus(Canceled)
+}
+
+// Retry 重新提交执行失败或已取消的任务，可恢复的任务从上次记录的进度继续
+func Retry(task *model.Task) error {
+	if (task.Status != Error && task.Status != Canceled) || TaskPoll.IsRunning(task.ID) {
+		return ErrNotRetryable
+	}
+
+	job, err := GetJobFromModel(task)
+	if err != nil {
+		return err
+	}
+
+	if err := task.Reset(Queued); err != nil {
+		return err
+	}
+	TaskPoll.Submit(job)
+	return nil
+}
+
+// SetPriority 设定任务优先级，排队中的任务按新的优先级调度
+func SetPriority(task *model.Task, priority int) error {
+	if err := task.SetPriority(priority); err != nil {
+		return err
+	}
+	TaskPoll.SetPriority(task.ID, priority)
+	return nil
+}
+
 // GetJobFromModel 从数据库给定模型获取任务
 func GetJobFromModel(task *model.Task) (Job, error) {
 	switch task.Type {
@@ -111,6 +178,12 @@ func GetJobFromModel(task *model.Task) (Job, error) {
 		return NewTransferTaskFromModel(task)
 	case ImportTaskType:
 		return NewImportTaskFromModel(task)
+	case MigrateTaskType:
+		return NewMigrateTaskFromModel(task)
+	case ReplicateTaskType:
+		return NewReplicateTaskFromModel(task)
+	case EncryptTaskType:
+		return NewEncryptTaskFromModel(task)
 	default:
 		return nil, ErrUnknownTaskType
 	}
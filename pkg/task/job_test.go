@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -70,4 +71,124 @@ func TestGetJobFromModel(t *testing.T) {
 		asserts.Nil(job)
 		asserts.Error(err)
 	}
+	// MigrateTaskType
+	{
+		task := &model.Task{
+			Status: 0,
+			Type:   MigrateTaskType,
+		}
+		mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnError(errors.New("error"))
+		job, err := GetJobFromModel(task)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+	// EncryptTaskType
+	{
+		task := &model.Task{
+			Status: 0,
+			Type:   EncryptTaskType,
+		}
+		mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnError(errors.New("error"))
+		job, err := GetJobFromModel(task)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	asserts := assert.New(t)
+	origin := TaskPoll
+	defer func() { TaskPoll = origin }()
+	TaskPoll = NewPool(0, 0)
+
+	// 任务已结束
+	{
+		err := Cancel(&model.Task{Model: gorm.Model{ID: 1}, Status: Complete})
+		asserts.Equal(ErrNotCancelable, err)
+	}
+
+	// 任务在任务池中排队
+	{
+		job := &MockJob{TaskModel: &model.Task{Model: gorm.Model{ID: 1}}}
+		TaskPoll.Submit(job)
+		asserts.NoError(Cancel(&model.Task{Model: gorm.Model{ID: 1}, Status: Queued}))
+		asserts.False(TaskPoll.IsRunning(1))
+	}
+
+	// 任务不在任务池中，直接设为已取消
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(Cancel(&model.Task{Model: gorm.Model{ID: 2}, Status: Processing}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	asserts := assert.New(t)
+	origin := TaskPoll
+	defer func() { TaskPoll = origin }()
+	TaskPoll = NewPool(0, 0)
+
+	// 任务未失败
+	{
+		err := Retry(&model.Task{Model: gorm.Model{ID: 1}, Status: Complete})
+		asserts.Equal(ErrNotRetryable, err)
+	}
+
+	// 无法恢复任务
+	{
+		err := Retry(&model.Task{Model: gorm.Model{ID: 1}, Status: Error, Type: 233})
+		asserts.Equal(ErrUnknownTaskType, err)
+	}
+
+	// 成功
+	{
+		record := &model.Task{Model: gorm.Model{ID: 1}, Status: Canceled, Type: CompressTaskType, Props: "{}"}
+		mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(Retry(record))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(Queued, record.Status)
+		asserts.True(TaskPoll.IsRunning(1))
+	}
+
+	// 任务仍在任务池中
+	{
+		err := Retry(&model.Task{Model: gorm.Model{ID: 1}, Status: Error})
+		asserts.Equal(ErrNotRetryable, err)
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	asserts := assert.New(t)
+	origin := TaskPoll
+	defer func() { TaskPoll = origin }()
+	TaskPoll = NewPool(0, 0)
+
+	// 成功
+	{
+		record := &model.Task{Model: gorm.Model{ID: 1}}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)priority(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(SetPriority(record, HighPriority))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(HighPriority, record.Priority)
+	}
+
+	// 数据库错误
+	{
+		record := &model.Task{Model: gorm.Model{ID: 1}}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)priority(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(SetPriority(record, HighPriority))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
 }
@@ -0,0 +1,187 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/util"
+)
+
+// migrateBatchSize 每批次列取的待迁移文件数量
+const migrateBatchSize = 100
+
+// MigrateTask 存储策略迁移任务
+type MigrateTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps MigrateProps
+	Err       *JobError
+}
+
+// MigrateProps 存储策略迁移任务属性
+type MigrateProps struct {
+	Scope        string `json:"scope"`         // 迁移范围，user、group 或 policy
+	TargetID     uint   `json:"target_id"`     // 迁移范围对应的用户、用户组或存储策略ID
+	PolicyID     uint   `json:"policy_id"`     // 目标存储策略ID
+	DeleteSource bool   `json:"delete_source"` // 迁移后是否删除原文件
+	LastID       uint   `json:"last_id"`       // 已处理的最后一个文件ID，任务恢复时由此继续
+	Migrated     int    `json:"migrated"`      // 已迁移的文件数量
+	Failed       []uint `json:"failed"`        // 迁移失败的文件ID
+}
+
+// Props 获取任务属性
+func (job *MigrateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务类型
+func (job *MigrateTask) Type() int {
+	return MigrateTaskType
+}
+
+// Creator 获取创建者ID
+func (job *MigrateTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *MigrateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *MigrateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *MigrateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *MigrateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *MigrateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *MigrateTask) Do(ctx context.Context) {
+
+	// 查找目标存储策略
+	policy, err := model.GetPolicyByID(job.TaskProps.PolicyID)
+	if err != nil {
+		job.SetErrorMsg("找不到存储策略", err)
+		return
+	}
+
+	// 创建文件系统
+	job.User.Policy = policy
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	for {
+		files, err := model.GetFilesToMigrate(job.TaskProps.Scope, job.TaskProps.TargetID,
+			policy.ID, job.TaskProps.LastID, migrateBatchSize)
+		if err != nil {
+			job.SetErrorMsg("无法列取待迁移文件", err)
+			return
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		// 同一批次中共用物理文件的记录会随第一个文件一同迁移
+		migrated := make(map[string]bool, len(files))
+		for i := range files {
+			// 任务已取消，已处理的进度保存在任务属性中，重试时继续
+			if ctx.Err() != nil {
+				return
+			}
+
+			key := fmt.Sprintf("%d/%s", files[i].PolicyID, files[i].SourceName)
+			if !migrated[key] {
+				if err := fs.MigrateFile(ctx, &files[i], &policy, job.TaskProps.DeleteSource); err != nil {
+					util.Log().Warning("无法迁移文件[%d]，%s", files[i].ID, err)
+					job.TaskProps.Failed = append(job.TaskProps.Failed, files[i].ID)
+				} else {
+					migrated[key] = true
+				}
+			}
+			if migrated[key] {
+				job.TaskProps.Migrated++
+			}
+
+			// 记录进度，以便任务中断后继续
+			job.TaskProps.LastID = files[i].ID
+			job.TaskModel.SetProps(job.Props())
+			job.TaskModel.SetProgress(job.TaskProps.Migrated)
+		}
+	}
+
+	if len(job.TaskProps.Failed) > 0 {
+		job.SetErrorMsg(fmt.Sprintf("%d 个文件迁移失败", len(job.TaskProps.Failed)), nil)
+	}
+}
+
+// NewMigrateTask 新建存储策略迁移任务
+func NewMigrateTask(user uint, scope string, target, policy uint, deleteSource bool) (Job, error) {
+	creator, err := model.GetActiveUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &MigrateTask{
+		User: &creator,
+		TaskProps: MigrateProps{
+			Scope:        scope,
+			TargetID:     target,
+			PolicyID:     policy,
+			DeleteSource: deleteSource,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewMigrateTaskFromModel 从数据库记录中恢复存储策略迁移任务
+func NewMigrateTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &MigrateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -0,0 +1,181 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMigrateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(MigrateTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestMigrateTask_SetError(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{
+		User: &model.User{},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	task.SetErrorMsg("error", nil)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("error", task.GetError().Msg)
+}
+
+func TestMigrateTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{
+		User: &model.User{},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: MigrateProps{
+			Scope:    model.StorageScopeUser,
+			TargetID: 1,
+			PolicyID: 64,
+		},
+	}
+
+	// 存储策略不存在
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotEmpty(task.Err.Error)
+		task.Err = nil
+	}
+
+	// 无法列取文件
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(64, "local"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("无法列取待迁移文件", task.Err.Msg)
+		task.Err = nil
+	}
+
+	// 没有待迁移文件
+	{
+		cache.Deletes([]string{"64"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(64, "local"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(0, 64, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(task.Err)
+	}
+
+	// 文件迁移失败，记录进度后继续
+	{
+		cache.Deletes([]string{"64", "63"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(64, "local"))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(0, 64, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).AddRow(5, 63, "a.txt"))
+		// 原文件的存储策略不存在
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 记录进度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)props(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)progress(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 从上次处理的文件继续列取
+		mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(5, 64, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(task.Err)
+		asserts.EqualValues(5, task.TaskProps.LastID)
+		asserts.Equal([]uint{5}, task.TaskProps.Failed)
+		asserts.Equal(0, task.TaskProps.Migrated)
+	}
+}
+
+func TestNewMigrateTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		job, err := NewMigrateTask(1, model.StorageScopeUser, 1, 2, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NotNil(job)
+		asserts.NoError(err)
+	}
+
+	// 失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		job, err := NewMigrateTask(1, model.StorageScopeUser, 1, 2, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Nil(job)
+		asserts.Error(err)
+	}
+}
+
+func TestNewMigrateTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewMigrateTaskFromModel(&model.Task{Props: `{"last_id":5}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		if asserts.NoError(err) {
+			asserts.EqualValues(5, job.(*MigrateTask).TaskProps.LastID)
+		}
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewMigrateTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
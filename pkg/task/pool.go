@@ -1,59 +1,191 @@
 package task
 
 import (
+	"context"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/util"
+	"sync"
 )
 
 // TaskPoll 要使用的任务池
 var TaskPoll *Pool
 
-// Pool 带有最大配额的任务池
+// Pool 带有最大配额的任务池，排队中的任务按优先级及提交顺序执行
 type Pool struct {
+	lock sync.Mutex
+
 	// 容量
-	idleWorker chan int
+	maxWorker int
+	// 每个用户同时执行的最大任务数，0为不限制
+	maxWorkerPerUser int
+
+	running     int
+	userRunning map[uint]int
+
+	// 排队中的任务
+	queue []*poolEntry
+	// 按任务ID索引的排队中及执行中任务
+	entries map[uint]*poolEntry
+	seq     uint64
+}
+
+// poolEntry 任务池中的任务
+type poolEntry struct {
+	job      Job
+	id       uint
+	user     uint
+	priority int
+	seq      uint64
+	ctx      context.Context
+	cancel   context.CancelFunc
+	running  bool
 }
 
-// Add 增加可用Worker数量
-func (pool *Pool) Add(num int) {
-	for i := 0; i < num; i++ {
-		pool.idleWorker <- 1
+// NewPool 创建任务池
+func NewPool(maxWorker, maxWorkerPerUser int) *Pool {
+	return &Pool{
+		maxWorker:        maxWorker,
+		maxWorkerPerUser: maxWorkerPerUser,
+		userRunning:      make(map[uint]int),
+		entries:          make(map[uint]*poolEntry),
 	}
 }
 
-// ObtainWorker 阻塞直到获取新的Worker
-func (pool *Pool) ObtainWorker() Worker {
-	select {
-	case <-pool.idleWorker:
-		// 有空闲Worker名额时，返回新Worker
-		return &GeneralWorker{}
+// Submit 开始提交任务
+func (pool *Pool) Submit(job Job) {
+	entry := &poolEntry{job: job, user: job.Creator()}
+	if record := job.Model(); record != nil {
+		entry.id = record.ID
+		entry.priority = record.Priority
 	}
+	entry.ctx, entry.cancel = context.WithCancel(context.Background())
+
+	pool.lock.Lock()
+	pool.seq++
+	entry.seq = pool.seq
+	pool.queue = append(pool.queue, entry)
+	if entry.id > 0 {
+		pool.entries[entry.id] = entry
+	}
+	pool.lock.Unlock()
+
+	util.Log().Debug("等待获取Worker")
+	pool.schedule()
 }
 
-// FreeWorker 添加空闲Worker
-func (pool *Pool) FreeWorker() {
-	pool.Add(1)
+// schedule 在有空闲名额时按优先级启动排队中的任务
+func (pool *Pool) schedule() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	for pool.running < pool.maxWorker {
+		next := -1
+		for i, entry := range pool.queue {
+			if pool.maxWorkerPerUser > 0 && pool.userRunning[entry.user] >= pool.maxWorkerPerUser {
+				continue
+			}
+			if next < 0 || entry.priority > pool.queue[next].priority ||
+				(entry.priority == pool.queue[next].priority && entry.seq < pool.queue[next].seq) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return
+		}
+
+		entry := pool.queue[next]
+		pool.queue = append(pool.queue[:next], pool.queue[next+1:]...)
+		entry.running = true
+		pool.running++
+		pool.userRunning[entry.user]++
+		go pool.run(entry)
+	}
 }
 
-// Submit 开始提交任务
-func (pool *Pool) Submit(job Job) {
-	go func() {
-		util.Log().Debug("等待获取Worker")
-		worker := pool.ObtainWorker()
-		util.Log().Debug("获取到Worker")
-		worker.Do(job)
-		util.Log().Debug("释放Worker")
-		pool.FreeWorker()
-	}()
+// run 执行任务，结束后释放名额
+func (pool *Pool) run(entry *poolEntry) {
+	util.Log().Debug("获取到Worker")
+	worker := &GeneralWorker{}
+	worker.Do(entry.ctx, entry.job)
+	entry.cancel()
+
+	util.Log().Debug("释放Worker")
+	pool.lock.Lock()
+	pool.running--
+	pool.userRunning[entry.user]--
+	if pool.userRunning[entry.user] <= 0 {
+		delete(pool.userRunning, entry.user)
+	}
+	if entry.id > 0 && pool.entries[entry.id] == entry {
+		delete(pool.entries, entry.id)
+	}
+	pool.lock.Unlock()
+
+	pool.schedule()
+}
+
+// Cancel 取消任务池中的任务，排队中的任务直接设为已取消，执行中的任务
+// 在返回后设为已取消。任务不在任务池中时返回假
+func (pool *Pool) Cancel(id uint) bool {
+	pool.lock.Lock()
+	entry, ok := pool.entries[id]
+	if !ok {
+		pool.lock.Unlock()
+		return false
+	}
+
+	if entry.running {
+		pool.lock.Unlock()
+		entry.cancel()
+		return true
+	}
+
+	pool.removeQueued(entry)
+	delete(pool.entries, id)
+	pool.lock.Unlock()
+
+	entry.cancel()
+	entry.job.SetStatus(Canceled)
+	return true
+}
+
+// SetPriority 调整排队中任务的优先级，任务不在排队中时返回假
+func (pool *Pool) SetPriority(id uint, priority int) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	entry, ok := pool.entries[id]
+	if !ok || entry.running {
+		return false
+	}
+	entry.priority = priority
+	return true
+}
+
+// IsRunning 返回任务是否在任务池中排队或执行
+func (pool *Pool) IsRunning(id uint) bool {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	_, ok := pool.entries[id]
+	return ok
+}
+
+// removeQueued 从排队中移除任务，调用时须持有锁
+func (pool *Pool) removeQueued(target *poolEntry) {
+	for i, entry := range pool.queue {
+		if entry == target {
+			pool.queue = append(pool.queue[:i], pool.queue[i+1:]...)
+			return
+		}
+	}
 }
 
 // Init 初始化任务池
 func Init() {
 	maxWorker := model.GetIntSetting("max_worker_num", 10)
-	TaskPoll = &Pool{
-		idleWorker: make(chan int, maxWorker),
-	}
-	TaskPoll.Add(maxWorker)
+	maxWorkerPerUser := model.GetIntSetting("max_worker_num_per_user", 0)
+	TaskPoll = NewPool(maxWorker, maxWorkerPerUser)
 	util.Log().Info("初始化任务队列，WorkerNum = %d", maxWorker)
 
 	Resume()
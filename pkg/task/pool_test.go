@@ -8,6 +8,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 var mock sqlmock.Sqlmock
@@ -28,24 +29,127 @@ func TestMain(m *testing.M) {
 func TestInit(t *testing.T) {
 	asserts := assert.New(t)
 	cache.Set("setting_max_worker_num", "10", 0)
-	mock.ExpectQuery("SELECT(.+)").WithArgs(Queued).WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow(-1))
+	cache.Set("setting_max_worker_num_per_user", "2", 0)
+	mock.ExpectQuery("SELECT(.+)").WithArgs(Queued, Processing).WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow(-1))
 	Init()
 	asserts.NoError(mock.ExpectationsWereMet())
-	asserts.Len(TaskPoll.idleWorker, 10)
+	asserts.Equal(10, TaskPoll.maxWorker)
+	asserts.Equal(2, TaskPoll.maxWorkerPerUser)
+}
+
+// blockingJob 返回在 release 关闭前阻塞执行的任务，开始执行时向 started 发送任务ID
+func blockingJob(id, user uint, priority int, started chan uint, release chan struct{}) *MockJob {
+	job := &MockJob{
+		TaskModel: &model.Task{Model: gorm.Model{ID: id}, Priority: priority},
+		User:      user,
+	}
+	job.DoFunc = func() {
+		started <- id
+		select {
+		case <-release:
+		case <-job.ctx.Done():
+		}
+	}
+	return job
 }
 
 func TestPool_Submit(t *testing.T) {
 	asserts := assert.New(t)
-	pool := &Pool{
-		idleWorker: make(chan int, 1),
-	}
-	pool.Add(1)
+	pool := NewPool(1, 0)
+	done := make(chan struct{})
 	job := &MockJob{
 		DoFunc: func() {
-
+			close(done)
 		},
 	}
 	asserts.NotPanics(func() {
 		pool.Submit(job)
 	})
+	<-done
+}
+
+func TestPool_Priority(t *testing.T) {
+	asserts := assert.New(t)
+	pool := NewPool(1, 0)
+	started := make(chan uint, 4)
+	release := make(chan struct{})
+	defer close(release)
+
+	// 占用唯一的名额后，排队中的任务按优先级及提交顺序执行
+	pool.Submit(blockingJob(1, 1, NormalPriority, started, release))
+	asserts.EqualValues(1, <-started)
+	pool.Submit(blockingJob(2, 1, LowPriority, started, release))
+	pool.Submit(blockingJob(3, 1, NormalPriority, started, release))
+	pool.Submit(blockingJob(4, 1, NormalPriority, started, release))
+	asserts.True(pool.SetPriority(4, HighPriority))
+	asserts.False(pool.SetPriority(1, HighPriority))
+
+	asserts.True(pool.Cancel(1))
+	asserts.EqualValues(4, <-started)
+	asserts.True(pool.Cancel(4))
+	asserts.EqualValues(3, <-started)
+	asserts.True(pool.Cancel(3))
+	asserts.EqualValues(2, <-started)
+}
+
+func TestPool_MaxWorkerPerUser(t *testing.T) {
+	asserts := assert.New(t)
+	pool := NewPool(2, 1)
+	started := make(chan uint, 3)
+	release := make(chan struct{})
+	defer close(release)
+
+	// 用户1的第二个任务需等待，用户2的任务可直接执行
+	pool.Submit(blockingJob(1, 1, NormalPriority, started, release))
+	asserts.EqualValues(1, <-started)
+	pool.Submit(blockingJob(2, 1, HighPriority, started, release))
+	pool.Submit(blockingJob(3, 2, NormalPriority, started, release))
+	asserts.EqualValues(3, <-started)
+
+	asserts.True(pool.Cancel(1))
+	asserts.EqualValues(2, <-started)
+}
+
+func TestPool_Cancel(t *testing.T) {
+	asserts := assert.New(t)
+	pool := NewPool(1, 0)
+	started := make(chan uint, 2)
+	release := make(chan struct{})
+	defer close(release)
+
+	running := blockingJob(1, 1, NormalPriority, started, release)
+	queued := blockingJob(2, 1, NormalPriority, started, release)
+	pool.Submit(running)
+	asserts.EqualValues(1, <-started)
+	pool.Submit(queued)
+
+	// 取消排队中的任务
+	{
+		asserts.True(pool.IsRunning(2))
+		asserts.True(pool.Cancel(2))
+		asserts.Equal(Canceled, queued.Status)
+		asserts.False(pool.IsRunning(2))
+	}
+
+	// 取消执行中的任务
+	{
+		asserts.True(pool.Cancel(1))
+		for pool.IsRunning(1) {
+			time.Sleep(time.Millisecond)
+		}
+		asserts.Equal(Canceled, running.Status)
+	}
+
+	// 任务不存在
+	{
+		asserts.False(pool.Cancel(3))
+		asserts.False(pool.SetPriority(3, HighPriority))
+	}
+
+	// 已取消的任务不会执行
+	select {
+	case id := <-started:
+		asserts.Fail("任务不应被执行", id)
+	case <-time.After(10 * time.Millisecond):
+	}
 }
@@ -0,0 +1,64 @@
+package task
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"sync"
+	"time"
+)
+
+// progressSyncInterval 按字节计算的进度写入数据库的最小间隔
+const progressSyncInterval = time.Second
+
+// progress 记录任务按字节计算的进度，实现 filesystem.ProgressReporter
+type progress struct {
+	mu        sync.Mutex
+	task      *model.Task
+	processed uint64
+	total     uint64
+	dirty     bool
+	synced    time.Time
+}
+
+// newProgress 创建任务的进度记录器，task 为 nil 时只在内存中记录
+func newProgress(task *model.Task) *progress {
+	return &progress{task: task, synced: time.Now()}
+}
+
+// AddTotal 增加已知的待处理字节数
+func (p *progress) AddTotal(size uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += size
+	p.dirty = true
+	p.sync(false)
+}
+
+// AddProcessed 增加已处理的字节数
+func (p *progress) AddProcessed(size uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.processed += size
+	p.dirty = true
+	p.sync(false)
+}
+
+// Flush 将尚未写入的进度写入数据库
+func (p *progress) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sync(true)
+}
+
+// sync 将进度写入数据库，force 为假时限制写入频率
+func (p *progress) sync(force bool) {
+	if p.task == nil || !p.dirty {
+		return
+	}
+	if !force && time.Since(p.synced) < progressSyncInterval {
+		return
+	}
+
+	p.task.SetSizeProgress(p.processed, p.total)
+	p.dirty = false
+	p.synced = time.Now()
+}
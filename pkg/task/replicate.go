@@ -0,0 +1,140 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/util"
+)
+
+// ReplicateTask 文件副本复制任务，由系统发起
+type ReplicateTask struct {
+	TaskModel *model.Task
+	TaskProps ReplicateProps
+	Err       *JobError
+}
+
+// ReplicateProps 文件副本复制任务属性
+type ReplicateProps struct {
+	FileID uint `json:"file_id"` // 要复制的文件ID
+}
+
+// Props 获取任务属性
+func (job *ReplicateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务类型
+func (job *ReplicateTask) Type() int {
+	return ReplicateTaskType
+}
+
+// Creator 获取创建者ID，副本复制任务由系统发起
+func (job *ReplicateTask) Creator() uint {
+	return 0
+}
+
+// Model 获取任务的数据库模型
+func (job *ReplicateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *ReplicateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *ReplicateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *ReplicateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *ReplicateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *ReplicateTask) Do(ctx context.Context) {
+	files, err := model.GetFilesByIDs([]uint{job.TaskProps.FileID}, 0)
+	if err != nil || len(files) == 0 {
+		job.SetErrorMsg("文件不存在", err)
+		return
+	}
+
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	if err := fs.ReplicateFile(ctx, &files[0]); err != nil {
+		job.SetErrorMsg("无法复制文件副本", err)
+	}
+}
+
+// NewReplicateTask 新建文件副本复制任务
+func NewReplicateTask(fileID uint) (Job, error) {
+	newTask := &ReplicateTask{
+		TaskProps: ReplicateProps{
+			FileID: fileID,
+		},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewReplicateTaskFromModel 从数据库记录中恢复文件副本复制任务
+func NewReplicateTaskFromModel(task *model.Task) (Job, error) {
+	newTask := &ReplicateTask{
+		TaskModel: task,
+	}
+
+	err := json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
+
+// HookReplicate 文件上传或更新后，若存储策略设定了副本，提交副本复制任务。
+// 任务创建失败不影响上传结果
+func HookReplicate(ctx context.Context, fs *filesystem.FileSystem) error {
+	if len(fs.FileTarget) == 0 {
+		return nil
+	}
+
+	file := fs.FileTarget[0]
+	if file.GetPolicy().ReplicaPolicyID == 0 {
+		return nil
+	}
+
+	job, err := NewReplicateTask(file.ID)
+	if err != nil {
+		util.Log().Warning("无法创建副本复制任务，%s", err)
+		return nil
+	}
+	TaskPoll.Submit(job)
+	return nil
+}
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReplicateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ReplicateTask{}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(ReplicateTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestReplicateTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ReplicateTask{
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: ReplicateProps{FileID: 1},
+	}
+
+	// 文件不存在
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do(context.Background())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("文件不存在", task.Err.Msg)
+	}
+}
+
+func TestNewReplicateTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		job, err := NewReplicateTaskFromModel(&model.Task{Props: `{"file_id":2}`})
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*ReplicateTask).TaskProps.FileID)
+	}
+
+	// JSON解析失败
+	{
+		job, err := NewReplicateTaskFromModel(&model.Task{Props: "?"})
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
+
+func TestHookReplicate(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 没有目标文件
+	{
+		fs := &filesystem.FileSystem{}
+		asserts.NoError(HookReplicate(context.Background(), fs))
+	}
+
+	// 存储策略未设定副本
+	{
+		fs := &filesystem.FileSystem{
+			FileTarget: []model.File{{Policy: model.Policy{Model: gorm.Model{ID: 1}}}},
+		}
+		asserts.NoError(HookReplicate(context.Background(), fs))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
@@ -0,0 +1,98 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"time"
+)
+
+// slavePollInterval 查询从机任务状态的间隔
+var slavePollInterval = time.Second
+
+// slaveMaxFailures 连续查询从机任务状态失败的最大次数
+const slaveMaxFailures = 5
+
+// ErrSlaveTaskCanceled 从机上的任务被取消
+var ErrSlaveTaskCanceled = errors.New("从机上的任务已取消")
+
+// SlaveHandler 可在存储端执行后台任务的存储策略适配器
+type SlaveHandler interface {
+	// CreateTask 在存储端创建任务，返回存储端的任务ID
+	CreateTask(ctx context.Context, taskType int, props interface{}) (string, error)
+	// GetTask 获取存储端任务的执行状态
+	GetTask(ctx context.Context, id string) (*serializer.SlaveTaskStatus, error)
+	// DeleteTask 删除存储端的任务，未结束的任务会被取消
+	DeleteTask(ctx context.Context, id string) error
+	// Delete 删除存储端的文件
+	Delete(ctx context.Context, files []string) ([]string, error)
+}
+
+// slaveHandler 返回文件系统当前使用的适配器，适配器所在存储端无法执行任务时返回假
+func slaveHandler(fs *filesystem.FileSystem) (SlaveHandler, bool) {
+	handler, ok := fs.Handler.(SlaveHandler)
+	return handler, ok
+}
+
+// runOnSlave 在存储端执行任务并等待其结束，期间将存储端报告的进度计入 ctx 中的进度报告器。
+// ctx 取消时存储端的任务同时被取消
+func runOnSlave(ctx context.Context, handler SlaveHandler, taskType int, props interface{}) ([]response.Object, error) {
+	id, err := handler.CreateTask(ctx, taskType, props)
+	if err != nil {
+		return nil, err
+	}
+	defer handler.DeleteTask(context.Background(), id)
+
+	reporter, _ := ctx.Value(fsctx.ProgressCtx).(filesystem.ProgressReporter)
+	var processed, total uint64
+	failures := 0
+
+	ticker := time.NewTicker(slavePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		status, err := handler.GetTask(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			failures++
+			if failures >= slaveMaxFailures {
+				return nil, err
+			}
+			util.Log().Debug("无法获取从机任务[%s]状态，%s，重试(%d/%d)", id, err, failures, slaveMaxFailures)
+			continue
+		}
+		failures = 0
+
+		// 存储端报告的是累计进度，只计入增加的部分
+		if reporter != nil {
+			if status.TotalSize > total {
+				reporter.AddTotal(status.TotalSize - total)
+				total = status.TotalSize
+			}
+			if status.ProcessedSize > processed {
+				reporter.AddProcessed(status.ProcessedSize - processed)
+				processed = status.ProcessedSize
+			}
+		}
+
+		switch status.Status {
+		case Complete:
+			return status.Objects, nil
+		case Error:
+			return nil, errors.New(status.Error)
+		case Canceled:
+			return nil, ErrSlaveTaskCanceled
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type slaveHandlerMock struct {
+	mu        sync.Mutex
+	createErr error
+	statuses  []*serializer.SlaveTaskStatus
+	getErr    error
+	getCount  int
+	deleted   []string
+}
+
+func (m *slaveHandlerMock) CreateTask(ctx context.Context, taskType int, props interface{}) (string, error) {
+	if m.createErr != nil {
+		return "", m.createErr
+	}
+	return "id", nil
+}
+
+func (m *slaveHandlerMock) GetTask(ctx context.Context, id string) (*serializer.SlaveTaskStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.getCount++
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	status := m.statuses[0]
+	if len(m.statuses) > 1 {
+		m.statuses = m.statuses[1:]
+	}
+	return status, nil
+}
+
+func (m *slaveHandlerMock) DeleteTask(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+func (m *slaveHandlerMock) Delete(ctx context.Context, files []string) ([]string, error) {
+	return []string{}, nil
+}
+
+type slaveTestProgress struct {
+	total     uint64
+	processed uint64
+}
+
+func (p *slaveTestProgress) AddTotal(size uint64) {
+	p.total += size
+}
+
+func (p *slaveTestProgress) AddProcessed(size uint64) {
+	p.processed += size
+}
+
+func TestRunOnSlave(t *testing.T) {
+	asserts := assert.New(t)
+	interval := slavePollInterval
+	slavePollInterval = time.Millisecond
+	defer func() { slavePollInterval = interval }()
+
+	// 创建任务失败
+	{
+		handler := &slaveHandlerMock{createErr: errors.New("error")}
+		objects, err := runOnSlave(context.Background(), handler, ImportTaskType, nil)
+		asserts.Error(err)
+		asserts.Nil(objects)
+		asserts.Empty(handler.deleted)
+	}
+
+	// 成功，进度计入报告器
+	{
+		handler := &slaveHandlerMock{statuses: []*serializer.SlaveTaskStatus{
+			{Status: Processing, ProcessedSize: 1, TotalSize: 10},
+			{Status: Processing, ProcessedSize: 5, TotalSize: 10},
+			{Status: Complete, ProcessedSize: 10, TotalSize: 10, Objects: []response.Object{{Name: "1.txt"}}},
+		}}
+		reporter := &slaveTestProgress{}
+		ctx := context.WithValue(context.Background(), fsctx.ProgressCtx, reporter)
+		objects, err := runOnSlave(ctx, handler, ImportTaskType, nil)
+		asserts.NoError(err)
+		asserts.Len(objects, 1)
+		asserts.EqualValues(10, reporter.total)
+		asserts.EqualValues(10, reporter.processed)
+		asserts.Equal([]string{"id"}, handler.deleted)
+	}
+
+	// 从机任务失败
+	{
+		handler := &slaveHandlerMock{statuses: []*serializer.SlaveTaskStatus{
+			{Status: Error, Error: "error"},
+		}}
+		objects, err := runOnSlave(context.Background(), handler, ImportTaskType, nil)
+		asserts.EqualError(err, "error")
+		asserts.Nil(objects)
+		asserts.Equal([]string{"id"}, handler.deleted)
+	}
+
+	// 从机任务被取消
+	{
+		handler := &slaveHandlerMock{statuses: []*serializer.SlaveTaskStatus{
+			{Status: Canceled},
+		}}
+		_, err := runOnSlave(context.Background(), handler, ImportTaskType, nil)
+		asserts.Equal(ErrSlaveTaskCanceled, err)
+	}
+
+	// 连续查询失败
+	{
+		handler := &slaveHandlerMock{getErr: errors.New("error")}
+		_, err := runOnSlave(context.Background(), handler, ImportTaskType, nil)
+		asserts.EqualError(err, "error")
+		asserts.Equal(slaveMaxFailures, handler.getCount)
+		asserts.Equal([]string{"id"}, handler.deleted)
+	}
+
+	// 上下文取消
+	{
+		handler := &slaveHandlerMock{statuses: []*serializer.SlaveTaskStatus{
+			{Status: Processing},
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := runOnSlave(ctx, handler, ImportTaskType, nil)
+		asserts.Equal(context.Canceled, err)
+		asserts.Equal([]string{"id"}, handler.deleted)
+	}
+}
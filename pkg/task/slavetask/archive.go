@@ -0,0 +1,193 @@
+package slavetask
+
+import (
+	"archive/zip"
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"io"
+	"path"
+	"sync"
+	"time"
+)
+
+// countingReader 记录已读取的字节数
+type countingReader struct {
+	io.ReadCloser
+	n uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += uint64(n)
+	return n, err
+}
+
+// readerAt 将可随机读取的文件流包装为 io.ReaderAt，供读取压缩包使用
+type readerAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// sourceContext 在上下文中附加由主机告知的文件加密状态，供适配器读取文件时使用
+func sourceContext(ctx context.Context, encrypted bool) context.Context {
+	return context.WithValue(ctx, fsctx.FileModelCtx, model.File{Encrypted: encrypted})
+}
+
+// compress 将给定文件压缩后写入 Dst，压缩包内无法读取的文件会被跳过
+func (job *Task) compress(ctx context.Context) ([]response.Object, error) {
+	props := job.compressProps
+	pr, pw := io.Pipe()
+
+	go func() {
+		zipWriter := zip.NewWriter(pw)
+		for _, file := range props.Files {
+			if ctx.Err() != nil {
+				pw.CloseWithError(ctx.Err())
+				return
+			}
+
+			src, err := job.handler.Get(sourceContext(ctx, file.Encrypted), file.Source)
+			if err != nil {
+				util.Log().Warning("无法压缩文件%s，%s", file.Name, err)
+				continue
+			}
+
+			writer, err := zipWriter.CreateHeader(&zip.FileHeader{
+				Name:               file.Name,
+				Modified:           file.Modified,
+				UncompressedSize64: file.Size,
+				Method:             zip.Deflate,
+			})
+			if err == nil {
+				_, err = io.Copy(writer, filesystem.WithProgress(ctx, src, file.Size))
+			}
+			src.Close()
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(zipWriter.Close())
+	}()
+
+	reader := &countingReader{ReadCloser: pr}
+	if err := job.handler.Put(ctx, reader, props.Dst, 0); err != nil {
+		job.handler.Delete(context.Background(), []string{props.Dst})
+		return nil, serializer.NewError(serializer.CodeIOFailed, "无法写入压缩文件", err)
+	}
+
+	return []response.Object{{
+		Name:         path.Base(props.Dst),
+		RelativePath: path.Base(props.Dst),
+		Source:       props.Dst,
+		Size:         reader.n,
+		LastModify:   time.Now(),
+	}}, nil
+}
+
+// decompress 将压缩包 Src 解压至 Dst 目录，返回解压出的目录和文件，
+// 压缩包内无法解压的文件会被跳过
+func (job *Task) decompress(ctx context.Context) ([]response.Object, error) {
+	props := job.decompressProps
+	src, err := job.handler.Get(sourceContext(ctx, props.Encrypted), props.Src)
+	if err != nil {
+		return nil, serializer.NewError(serializer.CodeIOFailed, "无法打开压缩文件", err)
+	}
+	defer src.Close()
+
+	size, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, serializer.NewError(serializer.CodeIOFailed, "无法打开压缩文件", err)
+	}
+
+	archive, err := filesystem.OpenArchive(&readerAt{rs: src}, size)
+	if err != nil {
+		return nil, serializer.NewError(serializer.CodeIOFailed, "无法读取压缩文件", err)
+	}
+	defer archive.Close()
+
+	objects := make([]response.Object, 0)
+	for {
+		if ctx.Err() != nil {
+			job.removeObjects(objects)
+			return nil, ctx.Err()
+		}
+
+		entry, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			job.removeObjects(objects)
+			return nil, serializer.NewError(serializer.CodeIOFailed, "无法读取压缩文件", err)
+		}
+
+		rawPath := util.FormSlash(entry.Name)
+		savePath, err := filesystem.ArchiveSavePath(props.Dst, rawPath)
+		if err != nil {
+			job.removeObjects(objects)
+			return nil, serializer.NewError(serializer.CodeIOFailed, "压缩包内的文件路径不合法", err)
+		}
+
+		object := response.Object{
+			Name:         path.Base(savePath),
+			RelativePath: path.Clean(rawPath),
+			Source:       savePath,
+			IsDir:        entry.IsDir,
+			LastModify:   entry.Modified,
+		}
+		if object.IsDir {
+			objects = append(objects, object)
+			continue
+		}
+
+		fileStream, err := entry.Open()
+		if err != nil {
+			util.Log().Warning("无法打开压缩包内文件%s , %s , 跳过", rawPath, err)
+			continue
+		}
+
+		object.Size = entry.Size
+		err = job.handler.Put(ctx, filesystem.WithProgress(ctx, fileStream, object.Size), savePath, object.Size)
+		if err != nil {
+			util.Log().Warning("无法解压缩压缩包内文件%s , %s , 跳过", rawPath, err)
+			job.handler.Delete(context.Background(), []string{savePath})
+			continue
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+// removeObjects 删除已解压出的文件
+func (job *Task) removeObjects(objects []response.Object) {
+	files := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if !object.IsDir {
+			files = append(files, object.Source)
+		}
+	}
+	if len(files) > 0 {
+		job.handler.Delete(context.Background(), files)
+	}
+}
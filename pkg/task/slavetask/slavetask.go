@@ -0,0 +1,271 @@
+package slavetask
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/filesystem/response"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"sync"
+	"time"
+)
+
+// finishedTTL 已结束的任务未被主机删除时在从机上保留的时长
+const finishedTTL = time.Hour
+
+// ErrTaskNotExist 任务不存在
+var ErrTaskNotExist = errors.New("任务不存在")
+
+// Pool 从机执行任务使用的任务池
+var Pool *task.Pool
+
+var (
+	lock  sync.Mutex
+	tasks = make(map[string]*Task)
+)
+
+// Task 主机下发至从机，针对从机本地存储执行的任务
+type Task struct {
+	ID       string
+	TaskType int
+	props    string
+	handler  local.Driver
+
+	compressProps   serializer.SlaveCompressProps
+	decompressProps serializer.SlaveDecompressProps
+	importProps     serializer.ListRequest
+
+	mu        sync.Mutex
+	status    int
+	processed uint64
+	total     uint64
+	err       *task.JobError
+	objects   []response.Object
+	cancel    context.CancelFunc
+	canceled  bool
+}
+
+// Init 初始化从机任务池
+func Init() {
+	Pool = task.NewPool(conf.SlaveConfig.MaxWorkerNum, 0)
+	util.Log().Info("初始化从机任务队列，WorkerNum = %d", conf.SlaveConfig.MaxWorkerNum)
+}
+
+// NewTask 根据主机的请求创建从机任务
+func NewTask(req serializer.SlaveTaskRequest) (*Task, error) {
+	job := &Task{
+		ID:       util.RandStringRunes(32),
+		TaskType: req.Type,
+		props:    req.Props,
+		handler:  local.NewSlaveDriver(req.Policy),
+		status:   task.Queued,
+	}
+
+	var err error
+	switch req.Type {
+	case task.CompressTaskType:
+		err = json.Unmarshal([]byte(req.Props), &job.compressProps)
+	case task.DecompressTaskType:
+		err = json.Unmarshal([]byte(req.Props), &job.decompressProps)
+	case task.ImportTaskType:
+		err = json.Unmarshal([]byte(req.Props), &job.importProps)
+	default:
+		return nil, task.ErrUnknownTaskType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Submit 登记并提交从机任务，返回任务ID
+func Submit(job *Task) string {
+	lock.Lock()
+	tasks[job.ID] = job
+	lock.Unlock()
+
+	Pool.Submit(job)
+	return job.ID
+}
+
+// Get 根据ID获取从机任务
+func Get(id string) (*Task, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if job, ok := tasks[id]; ok {
+		return job, nil
+	}
+	return nil, ErrTaskNotExist
+}
+
+// Delete 删除从机任务，任务仍在排队或执行时将其取消
+func Delete(id string) error {
+	lock.Lock()
+	job, ok := tasks[id]
+	delete(tasks, id)
+	lock.Unlock()
+
+	if !ok {
+		return ErrTaskNotExist
+	}
+
+	job.mu.Lock()
+	job.canceled = true
+	if job.cancel != nil {
+		job.cancel()
+	}
+	job.mu.Unlock()
+	return nil
+}
+
+// Type 获取任务类型
+func (job *Task) Type() int {
+	return job.TaskType
+}
+
+// Creator 获取创建者ID，从机任务无创建者
+func (job *Task) Creator() uint {
+	return 0
+}
+
+// Props 获取任务属性
+func (job *Task) Props() string {
+	return job.props
+}
+
+// Model 获取任务的数据库模型，从机任务只记录在内存中
+func (job *Task) Model() *model.Task {
+	return nil
+}
+
+// SetStatus 设定状态，任务结束后一段时间内未被主机删除时自动清除
+func (job *Task) SetStatus(status int) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	if job.canceled && status != task.Processing {
+		status = task.Canceled
+	}
+	job.status = status
+
+	if status == task.Complete || status == task.Error || status == task.Canceled {
+		time.AfterFunc(finishedTTL, func() {
+			lock.Lock()
+			if tasks[job.ID] == job {
+				delete(tasks, job.ID)
+			}
+			lock.Unlock()
+		})
+	}
+}
+
+// SetError 设定任务失败信息
+func (job *Task) SetError(err *task.JobError) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.err = err
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *Task) SetErrorMsg(msg string, err error) {
+	jobErr := &task.JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *Task) GetError() *task.JobError {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.err
+}
+
+// AddTotal 增加已知的待处理字节数
+func (job *Task) AddTotal(size uint64) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.total += size
+}
+
+// AddProcessed 增加已处理的字节数
+func (job *Task) AddProcessed(size uint64) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.processed += size
+}
+
+// Status 返回任务的执行状态、进度及执行完成后产生的对象
+func (job *Task) Status() serializer.SlaveTaskStatus {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	status := serializer.SlaveTaskStatus{
+		Status:        job.status,
+		ProcessedSize: job.processed,
+		TotalSize:     job.total,
+	}
+	if job.err != nil {
+		status.Error = job.err.Msg
+		if job.err.Error != "" {
+			status.Error += "，" + job.err.Error
+		}
+	}
+	if job.status == task.Complete {
+		status.Objects = job.objects
+	}
+	return status
+}
+
+// Do 开始执行任务
+func (job *Task) Do(ctx context.Context) {
+	job.mu.Lock()
+	if job.canceled {
+		job.mu.Unlock()
+		return
+	}
+	ctx, job.cancel = context.WithCancel(ctx)
+	job.mu.Unlock()
+	defer job.cancel()
+
+	// 进度记录在任务中，供主机查询
+	ctx = context.WithValue(ctx, fsctx.ProgressCtx, job)
+
+	var (
+		objects []response.Object
+		err     error
+	)
+	switch job.TaskType {
+	case task.CompressTaskType:
+		objects, err = job.compress(ctx)
+	case task.DecompressTaskType:
+		objects, err = job.decompress(ctx)
+	case task.ImportTaskType:
+		objects, err = job.handler.List(ctx, job.importProps.Path, job.importProps.Recursive)
+		if err != nil {
+			err = serializer.NewError(serializer.CodeIOFailed, "无法列取文件", err)
+		}
+	}
+
+	if err != nil {
+		if appErr, ok := err.(serializer.AppError); ok {
+			job.SetErrorMsg(appErr.Msg, appErr.RawError)
+		} else {
+			job.SetErrorMsg(err.Error(), nil)
+		}
+		return
+	}
+
+	job.mu.Lock()
+	job.objects = objects
+	job.mu.Unlock()
+}
@@ -0,0 +1,157 @@
+package slavetask
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestTask(asserts *assert.Assertions, taskType int, props interface{}) *Task {
+	propsEncoded, err := json.Marshal(props)
+	asserts.NoError(err)
+	job, err := NewTask(serializer.SlaveTaskRequest{Type: taskType, Props: string(propsEncoded)})
+	asserts.NoError(err)
+	return job
+}
+
+func TestNewTask(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未知任务类型
+	{
+		job, err := NewTask(serializer.SlaveTaskRequest{Type: task.TransferTaskType, Props: "{}"})
+		asserts.Equal(task.ErrUnknownTaskType, err)
+		asserts.Nil(job)
+	}
+
+	// 属性无法解析
+	{
+		job, err := NewTask(serializer.SlaveTaskRequest{Type: task.CompressTaskType, Props: "?"})
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+
+	// 成功
+	{
+		job, err := NewTask(serializer.SlaveTaskRequest{Type: task.DecompressTaskType, Props: `{"src":"1.zip","dst":"/"}`})
+		asserts.NoError(err)
+		asserts.Len(job.ID, 32)
+		asserts.Equal(task.DecompressTaskType, job.Type())
+		asserts.Equal("1.zip", job.decompressProps.Src)
+		asserts.Equal(task.Queued, job.Status().Status)
+	}
+}
+
+func TestTask_CompressAndDecompress(t *testing.T) {
+	asserts := assert.New(t)
+	root := "slavetask_test"
+	defer os.RemoveAll(util.RelativePath(root))
+
+	asserts.NoError(os.MkdirAll(util.RelativePath(filepath.Join(root, "src")), 0744))
+	asserts.NoError(ioutil.WriteFile(util.RelativePath(filepath.Join(root, "src", "1.txt")), []byte("123"), 0644))
+
+	// 压缩
+	zipPath := root + "/archive.zip"
+	{
+		job := newTestTask(asserts, task.CompressTaskType, serializer.SlaveCompressProps{
+			Files: []serializer.SlaveCompressFile{
+				{Source: root + "/src/1.txt", Name: "dir/1.txt", Size: 3, Modified: time.Now()},
+				{Source: root + "/src/not_exist.txt", Name: "not_exist.txt", Size: 1},
+			},
+			Dst: zipPath,
+		})
+		job.SetStatus(task.Processing)
+		job.Do(context.Background())
+		job.SetStatus(task.Complete)
+
+		status := job.Status()
+		asserts.Empty(status.Error)
+		asserts.EqualValues(3, status.ProcessedSize)
+		asserts.Len(status.Objects, 1)
+		asserts.Equal("archive.zip", status.Objects[0].Name)
+		asserts.True(util.Exists(util.RelativePath(zipPath)))
+	}
+
+	// 解压缩
+	{
+		job := newTestTask(asserts, task.DecompressTaskType, serializer.SlaveDecompressProps{
+			Src: zipPath,
+			Dst: root + "/dst",
+		})
+		job.SetStatus(task.Processing)
+		job.Do(context.Background())
+		job.SetStatus(task.Complete)
+
+		status := job.Status()
+		asserts.Empty(status.Error)
+		asserts.Len(status.Objects, 1)
+		asserts.Equal("dir/1.txt", status.Objects[0].RelativePath)
+		asserts.Equal(root+"/dst/dir/1.txt", status.Objects[0].Source)
+		content, err := ioutil.ReadFile(util.RelativePath(filepath.Join(root, "dst", "dir", "1.txt")))
+		asserts.NoError(err)
+		asserts.Equal("123", string(content))
+	}
+
+	// 压缩包不存在
+	{
+		job := newTestTask(asserts, task.DecompressTaskType, serializer.SlaveDecompressProps{
+			Src: root + "/not_exist.zip",
+			Dst: root + "/dst",
+		})
+		job.Do(context.Background())
+		job.SetStatus(task.Error)
+
+		status := job.Status()
+		asserts.Contains(status.Error, "无法打开压缩文件")
+		asserts.Nil(status.Objects)
+	}
+
+	// 导入
+	{
+		job := newTestTask(asserts, task.ImportTaskType, serializer.ListRequest{
+			Path:      root + "/dst",
+			Recursive: true,
+		})
+		job.Do(context.Background())
+		job.SetStatus(task.Complete)
+		asserts.Len(job.Status().Objects, 2)
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	asserts := assert.New(t)
+	job := newTestTask(asserts, task.ImportTaskType, serializer.ListRequest{Path: "/"})
+
+	lock.Lock()
+	tasks[job.ID] = job
+	lock.Unlock()
+
+	// 获取
+	{
+		res, err := Get(job.ID)
+		asserts.NoError(err)
+		asserts.Equal(job, res)
+
+		_, err = Get("not_exist")
+		asserts.Equal(ErrTaskNotExist, err)
+	}
+
+	// 删除后任务被取消
+	{
+		asserts.NoError(Delete(job.ID))
+		_, err := Get(job.ID)
+		asserts.Equal(ErrTaskNotExist, err)
+		asserts.Equal(ErrTaskNotExist, Delete(job.ID))
+
+		job.SetStatus(task.Complete)
+		asserts.Equal(task.Canceled, job.Status().Status)
+	}
+}
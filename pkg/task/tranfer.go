@@ -77,7 +77,7 @@ func (job *TransferTask) GetError() *JobError {
 }
 
 // Do 开始执行任务
-func (job *TransferTask) Do() {
+func (job *TransferTask) Do(ctx context.Context) {
 	defer job.Recycle()
 
 	// 创建文件系统
@@ -88,10 +88,17 @@ func (job *TransferTask) Do() {
 	}
 
 	for index, file := range job.TaskProps.Src {
+		// 任务已取消
+		if ctx.Err() != nil {
+			return
+		}
+
 		job.TaskModel.SetProgress(index)
-		err = fs.UploadFromPath(context.Background(), file, path.Join(job.TaskProps.Dst, filepath.Base(file)))
+		err = fs.UploadFromPath(ctx, file, path.Join(job.TaskProps.Dst, filepath.Base(file)))
 		if err != nil {
 			job.SetErrorMsg("文件转存失败", err)
+		} else {
+			HookReplicate(context.Background(), fs)
 		}
 	}
 
@@ -1,21 +1,29 @@
 package task
 
-import "github.com/HFO4/cloudreve/pkg/util"
+import (
+	"context"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/util"
+)
 
 // Worker 处理任务的对象
 type Worker interface {
-	Do(Job) // 执行任务
+	Do(context.Context, Job) // 执行任务
 }
 
 // GeneralWorker 通用Worker
 type GeneralWorker struct {
 }
 
-// Do 执行任务
-func (worker *GeneralWorker) Do(job Job) {
+// Do 执行任务，ctx 取消后任务状态设为已取消
+func (worker *GeneralWorker) Do(ctx context.Context, job Job) {
 	util.Log().Debug("开始执行任务")
 	job.SetStatus(Processing)
 
+	// 记录按字节计算的进度
+	progress := newProgress(job.Model())
+	ctx = context.WithValue(ctx, fsctx.ProgressCtx, progress)
+
 	defer func() {
 		// 致命错误捕获
 		if err := recover(); err != nil {
@@ -26,7 +34,15 @@ func (worker *GeneralWorker) Do(job Job) {
 	}()
 
 	// 开始执行任务
-	job.Do()
+	job.Do(ctx)
+	progress.Flush()
+
+	// 任务被取消
+	if ctx.Err() != nil {
+		util.Log().Debug("任务已取消")
+		job.SetStatus(Canceled)
+		return
+	}
 
 	// 任务执行失败
 	if err := job.GetError(); err != nil {
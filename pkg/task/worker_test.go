@@ -1,15 +1,21 @@
 package task
 
 import (
+	"context"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 type MockJob struct {
-	Err    *JobError
-	Status int
-	DoFunc func()
+	Err       *JobError
+	Status    int
+	DoFunc    func()
+	TaskModel *model.Task
+	User      uint
+	ctx       context.Context
 }
 
 func (job *MockJob) Type() int {
@@ -17,7 +23,7 @@ func (job *MockJob) Type() int {
 }
 
 func (job *MockJob) Creator() uint {
-	panic("implement me")
+	return job.User
 }
 
 func (job *MockJob) Props() string {
@@ -25,14 +31,15 @@ func (job *MockJob) Props() string {
 }
 
 func (job *MockJob) Model() *model.Task {
-	panic("implement me")
+	return job.TaskModel
 }
 
 func (job *MockJob) SetStatus(status int) {
 	job.Status = status
 }
 
-func (job *MockJob) Do() {
+func (job *MockJob) Do(ctx context.Context) {
+	job.ctx = ctx
 	job.DoFunc()
 }
 
@@ -52,7 +59,7 @@ func TestGeneralWorker_Do(t *testing.T) {
 	{
 		job.DoFunc = func() {
 		}
-		worker.Do(job)
+		worker.Do(context.Background(), job)
 		asserts.Equal(Complete, job.Status)
 	}
 
@@ -62,7 +69,7 @@ func TestGeneralWorker_Do(t *testing.T) {
 		}
 		job.Status = Queued
 		job.Err = &JobError{Msg: "error"}
-		worker.Do(job)
+		worker.Do(context.Background(), job)
 		asserts.Equal(Error, job.Status)
 	}
 
@@ -73,8 +80,31 @@ func TestGeneralWorker_Do(t *testing.T) {
 		}
 		job.Status = Queued
 		job.Err = nil
-		worker.Do(job)
+		worker.Do(context.Background(), job)
 		asserts.Equal(Error, job.Status)
 	}
 
+	// 已取消，忽略错误
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		job.DoFunc = func() {
+			cancel()
+		}
+		job.Status = Queued
+		job.Err = &JobError{Msg: "error"}
+		worker.Do(ctx, job)
+		asserts.Equal(Canceled, job.Status)
+	}
+
+	// 上下文中有进度报告器
+	{
+		job.DoFunc = func() {
+			_, ok := job.ctx.Value(fsctx.ProgressCtx).(filesystem.ProgressReporter)
+			asserts.True(ok)
+		}
+		job.Status = Queued
+		job.Err = nil
+		worker.Do(context.Background(), job)
+		asserts.Equal(Complete, job.Status)
+	}
 }
@@ -88,11 +88,15 @@ func (image *Thumb) Save(path string) (err error) {
 	}
 	defer out.Close()
 
-	err = png.Encode(out, image.src)
-	return err
+	return image.Encode(out)
 
 }
 
+// Encode 将缩略图以 PNG 格式写入 w
+func (image *Thumb) Encode(w io.Writer) error {
+	return png.Encode(w, image.src)
+}
+
 // CreateAvatar 创建头像
 func (image *Thumb) CreateAvatar(uid uint, savePath string, s int, m int, l int) error {
 	// 生成头像缩略图
@@ -117,8 +117,25 @@ func walkFS(
 		depth = 0
 	}
 
-	dirs, _ := info.(*model.Folder).GetChildFolder()
-	files, _ := info.(*model.Folder).GetChildFiles()
+	var (
+		dirs  []model.Folder
+		files []model.File
+	)
+	folder := info.(*model.Folder)
+	if filesystem.IsSharedRoot(folder) {
+		// “与我共享”虚拟目录下为共享给用户的目录
+		dirs, _ = fs.SharedFolders()
+	} else {
+		dirs, _ = folder.GetChildFolder()
+		files, _ = folder.GetChildFiles()
+
+		// 用户根目录下显示“与我共享”虚拟目录
+		if fs.Root == nil && fs.Operator == nil && folder.ParentID == nil {
+			if shared, err := fs.SharedFolders(); err == nil && len(shared) > 0 {
+				dirs = append(dirs, *fs.SharedRoot())
+			}
+		}
+	}
 
 	for _, fileInfo := range files {
 		filename := path.Join(name, fileInfo.Name)
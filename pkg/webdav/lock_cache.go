@@ -0,0 +1,250 @@
+package webdav
+
+import (
+	"encoding/gob"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/cache"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"strings"
+	"time"
+)
+
+const (
+	// cacheLocksKey 用户锁表在缓存中的键前缀
+	cacheLocksKey = "webdav_locks_"
+	// cacheMutexKey 修改用户锁表时占用的互斥锁的键前缀
+	cacheMutexKey = "webdav_locks_mutex_"
+	// cacheMutexTTL 互斥锁的最长占用时间（秒），实例异常退出时其占用的互斥锁在此之后自动解除
+	cacheMutexTTL = 10
+)
+
+func init() {
+	gob.Register(map[string]cacheLock{})
+}
+
+// NewCacheLS 返回将 uid 用户的锁存储于缓存的 LockSystem，使用 Redis 缓存的多个实例共享锁
+func NewCacheLS(uid uint) LockSystem {
+	return &cacheLS{uid: uid}
+}
+
+// cacheLock 存储于缓存中的锁
+type cacheLock struct {
+	Root      string
+	ZeroDepth bool
+	OwnerXML  string
+	Duration  time.Duration
+	// 过期时间，零值表示永不过期
+	Expires time.Time
+	// 被请求占用的截止时间
+	HeldUntil time.Time
+}
+
+// held 锁是否正被请求占用
+func (lock *cacheLock) held(now time.Time) bool {
+	return now.Before(lock.HeldUntil)
+}
+
+// expired 锁是否已过期，与 memLS 相同，被占用的锁不会过期
+func (lock *cacheLock) expired(now time.Time) bool {
+	return !lock.Expires.IsZero() && !now.Before(lock.Expires) && !lock.held(now)
+}
+
+// cacheLS 基于缓存的 LockSystem。用户的所有锁以一个键存储，
+// 每次修改前通过 cache.Add 占用该用户的互斥锁
+type cacheLS struct {
+	uid uint
+}
+
+// update 占用互斥锁后读取用户的锁表并清理已过期的锁，由 fn 检查或修改后写回
+func (c *cacheLS) update(now time.Time, fn func(locks map[string]cacheLock) error) error {
+	mutex := fmt.Sprintf("%s%d", cacheMutexKey, c.uid)
+	deadline := time.Now().Add(cacheMutexTTL * time.Second)
+	for {
+		ok, err := cache.Add(mutex, 1, cacheMutexTTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() {
+		if err := cache.Deletes([]string{mutex}, ""); err != nil {
+			util.Log().Warning("无法释放WebDAV锁表的互斥锁, %s", err)
+		}
+	}()
+
+	key := fmt.Sprintf("%s%d", cacheLocksKey, c.uid)
+	locks := make(map[string]cacheLock)
+	if raw, ok := cache.Get(key); ok {
+		if stored, ok := raw.(map[string]cacheLock); ok {
+			for token, lock := range stored {
+				locks[token] = lock
+			}
+		}
+	}
+	for token, lock := range locks {
+		if lock.expired(now) {
+			delete(locks, token)
+		}
+	}
+
+	if err := fn(locks); err != nil {
+		return err
+	}
+	return cache.Set(key, locks, 0)
+}
+
+func (c *cacheLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	var held []string
+	err := c.update(now, func(locks map[string]cacheLock) error {
+		var t0, t1 string
+		if name0 != "" {
+			if t0 = cacheLookup(locks, now, slashClean(name0), conditions...); t0 == "" {
+				return ErrConfirmationFailed
+			}
+		}
+		if name1 != "" {
+			if t1 = cacheLookup(locks, now, slashClean(name1), conditions...); t1 == "" {
+				return ErrConfirmationFailed
+			}
+		}
+
+		// Don't hold the same lock twice.
+		if t1 == t0 {
+			t1 = ""
+		}
+
+		for _, token := range []string{t0, t1} {
+			if token == "" {
+				continue
+			}
+			lock := locks[token]
+			lock.HeldUntil = now.Add(lockHoldTimeout)
+			locks[token] = lock
+			held = append(held, token)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		err := c.update(time.Now(), func(locks map[string]cacheLock) error {
+			for _, token := range held {
+				if lock, ok := locks[token]; ok {
+					lock.HeldUntil = time.Time{}
+					locks[token] = lock
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			util.Log().Warning("无法解除WebDAV锁的占用, %s", err)
+		}
+	}, nil
+}
+
+// cacheLookup 与 memLS.lookup 相同，返回锁定 name 且与条件匹配、未被占用的锁的令牌，
+// 不存在时返回空字符串
+func cacheLookup(locks map[string]cacheLock, now time.Time, name string, conditions ...Condition) string {
+	// TODO: support Condition.Not and Condition.ETag.
+	for _, c := range conditions {
+		lock, ok := locks[c.Token]
+		if c.Token == "" || !ok || lock.held(now) {
+			continue
+		}
+		if name == lock.Root {
+			return c.Token
+		}
+		if lock.ZeroDepth {
+			continue
+		}
+		if lock.Root == "/" || strings.HasPrefix(name, lock.Root+"/") {
+			return c.Token
+		}
+	}
+	return ""
+}
+
+func (c *cacheLS) Create(now time.Time, details LockDetails) (string, error) {
+	details.Root = slashClean(details.Root)
+	token, err := nextLockToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = c.update(now, func(locks map[string]cacheLock) error {
+		existing := make([]model.WebdavLock, 0, len(locks))
+		for t, lock := range locks {
+			existing = append(existing, model.WebdavLock{Token: t, Root: lock.Root, ZeroDepth: lock.ZeroDepth})
+		}
+		if !canCreateAmong(existing, token, details.Root, details.ZeroDepth) {
+			return ErrLocked
+		}
+
+		lock := cacheLock{
+			Root:      details.Root,
+			ZeroDepth: details.ZeroDepth,
+			OwnerXML:  details.OwnerXML,
+			Duration:  details.Duration,
+		}
+		if expires := lockExpiry(now, details.Duration); expires != nil {
+			lock.Expires = *expires
+		}
+		locks[token] = lock
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (c *cacheLS) Refresh(now time.Time, token string, duration time.Duration) (LockDetails, error) {
+	var details LockDetails
+	err := c.update(now, func(locks map[string]cacheLock) error {
+		lock, ok := locks[token]
+		if !ok {
+			return ErrNoSuchLock
+		}
+		if lock.held(now) {
+			return ErrLocked
+		}
+
+		lock.Duration = duration
+		lock.Expires = time.Time{}
+		if expires := lockExpiry(now, duration); expires != nil {
+			lock.Expires = *expires
+		}
+		locks[token] = lock
+		details = LockDetails{
+			Root:      lock.Root,
+			Duration:  duration,
+			OwnerXML:  lock.OwnerXML,
+			ZeroDepth: lock.ZeroDepth,
+		}
+		return nil
+	})
+	return details, err
+}
+
+func (c *cacheLS) Unlock(now time.Time, token string) error {
+	return c.update(now, func(locks map[string]cacheLock) error {
+		lock, ok := locks[token]
+		if !ok {
+			return ErrNoSuchLock
+		}
+		if lock.held(now) {
+			return ErrLocked
+		}
+		delete(locks, token)
+		return nil
+	})
+}
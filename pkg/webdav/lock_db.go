@@ -0,0 +1,240 @@
+package webdav
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// lockHoldTimeout 请求占用锁的最长时间，实例异常退出时其占用的锁在此之后自动解除
+const lockHoldTimeout = time.Hour
+
+// NewDBLS 返回将 uid 用户的锁存储于数据库的 LockSystem，连接同一数据库的多个实例共享锁
+func NewDBLS(uid uint) LockSystem {
+	return &dbLS{uid: uid}
+}
+
+// dbLS 基于数据库的 LockSystem。占用、刷新、解锁均通过带条件的单条更新完成，
+// 创建锁时先写入再检查冲突，并发创建相互冲突的锁时至少有一方会失败
+type dbLS struct {
+	uid uint
+}
+
+// nextLockToken 生成新的锁令牌
+func nextLockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "opaquelocktoken:" + hex.EncodeToString(buf), nil
+}
+
+// collectExpiredLocks 清理已过期的锁，与 memLS.collectExpiredNodes 相同，被占用的锁不会过期
+func (d *dbLS) collectExpiredLocks(now time.Time) error {
+	return model.DeleteExpiredWebdavLocks(d.uid, now)
+}
+
+func (d *dbLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	if err := d.collectExpiredLocks(now); err != nil {
+		return nil, err
+	}
+
+	var t0, t1 string
+	if name0 != "" {
+		if t0 = d.lookup(now, slashClean(name0), conditions...); t0 == "" {
+			return nil, ErrConfirmationFailed
+		}
+	}
+	if name1 != "" {
+		if t1 = d.lookup(now, slashClean(name1), conditions...); t1 == "" {
+			return nil, ErrConfirmationFailed
+		}
+	}
+
+	// Don't hold the same lock twice.
+	if t1 == t0 {
+		t1 = ""
+	}
+
+	held := make([]string, 0, 2)
+	release := func() {
+		for _, token := range held {
+			if err := model.ReleaseWebdavLock(token, d.uid); err != nil {
+				util.Log().Warning("无法解除WebDAV锁[%s]的占用, %s", token, err)
+			}
+		}
+	}
+	for _, token := range []string{t0, t1} {
+		if token == "" {
+			continue
+		}
+		ok, err := model.HoldWebdavLock(token, d.uid, now, now.Add(lockHoldTimeout))
+		if err != nil || !ok {
+			// 锁已被其他请求占用
+			release()
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrConfirmationFailed
+		}
+		held = append(held, token)
+	}
+
+	return release, nil
+}
+
+// lookup 与 memLS.lookup 相同，返回锁定 name 且与条件匹配、未被占用的锁的令牌，
+// 不存在时返回空字符串
+func (d *dbLS) lookup(now time.Time, name string, conditions ...Condition) string {
+	// TODO: support Condition.Not and Condition.ETag.
+	for _, c := range conditions {
+		if c.Token == "" {
+			continue
+		}
+		lock, err := model.GetWebdavLockByToken(c.Token, d.uid)
+		if err != nil || lock.IsHeld(now) {
+			continue
+		}
+		if name == lock.Root {
+			return lock.Token
+		}
+		if lock.ZeroDepth {
+			continue
+		}
+		if lock.Root == "/" || strings.HasPrefix(name, lock.Root+"/") {
+			return lock.Token
+		}
+	}
+	return ""
+}
+
+func (d *dbLS) Create(now time.Time, details LockDetails) (string, error) {
+	if err := d.collectExpiredLocks(now); err != nil {
+		return "", err
+	}
+	details.Root = slashClean(details.Root)
+
+	token, err := nextLockToken()
+	if err != nil {
+		return "", err
+	}
+	lock := &model.WebdavLock{
+		Token:     token,
+		UserID:    d.uid,
+		Root:      details.Root,
+		ZeroDepth: details.ZeroDepth,
+		OwnerXML:  details.OwnerXML,
+		Duration:  int64(details.Duration),
+		ExpiresAt: lockExpiry(now, details.Duration),
+	}
+	if err := lock.Create(); err != nil {
+		return "", err
+	}
+
+	// 写入后再检查冲突，其他实例同时写入的冲突锁也能被发现
+	locks, err := model.GetWebdavLocksByUID(d.uid)
+	if err == nil && canCreateAmong(locks, token, details.Root, details.ZeroDepth) {
+		return token, nil
+	}
+	if _, deleteErr := model.DeleteWebdavLock(token, d.uid, now); deleteErr != nil {
+		util.Log().Warning("无法删除冲突的WebDAV锁[%s], %s", token, deleteErr)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "", ErrLocked
+}
+
+func (d *dbLS) Refresh(now time.Time, token string, duration time.Duration) (LockDetails, error) {
+	if err := d.collectExpiredLocks(now); err != nil {
+		return LockDetails{}, err
+	}
+
+	lock, err := d.get(now, token)
+	if err != nil {
+		return LockDetails{}, err
+	}
+	ok, err := model.RefreshWebdavLock(token, d.uid, now, int64(duration), lockExpiry(now, duration))
+	if err != nil {
+		return LockDetails{}, err
+	}
+	if !ok {
+		return LockDetails{}, ErrLocked
+	}
+
+	return LockDetails{
+		Root:      lock.Root,
+		Duration:  duration,
+		OwnerXML:  lock.OwnerXML,
+		ZeroDepth: lock.ZeroDepth,
+	}, nil
+}
+
+func (d *dbLS) Unlock(now time.Time, token string) error {
+	if err := d.collectExpiredLocks(now); err != nil {
+		return err
+	}
+
+	if _, err := d.get(now, token); err != nil {
+		return err
+	}
+	ok, err := model.DeleteWebdavLock(token, d.uid, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLocked
+	}
+	return nil
+}
+
+// get 获取未被占用的锁
+func (d *dbLS) get(now time.Time, token string) (*model.WebdavLock, error) {
+	lock, err := model.GetWebdavLockByToken(token, d.uid)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrNoSuchLock
+		}
+		return nil, err
+	}
+	if lock.IsHeld(now) {
+		return nil, ErrLocked
+	}
+	return lock, nil
+}
+
+// canCreateAmong 与 memLS.canCreate 相同，检查令牌为 token 的新锁是否与 locks 中的其他锁冲突
+func canCreateAmong(locks []model.WebdavLock, token, name string, zeroDepth bool) bool {
+	for _, lock := range locks {
+		if lock.Token == token {
+			continue
+		}
+		if lock.Root == name {
+			// The target node is already locked.
+			return false
+		}
+		if !zeroDepth && (name == "/" || strings.HasPrefix(lock.Root, name+"/")) {
+			// The requested lock depth is infinite, and a descendent of the
+			// target node is locked.
+			return false
+		}
+		if !lock.ZeroDepth && (lock.Root == "/" || strings.HasPrefix(name, lock.Root+"/")) {
+			// An ancestor of the target node is locked with infinite depth.
+			return false
+		}
+	}
+	return true
+}
+
+// lockExpiry 锁的过期时间，duration 为负数时永不过期
+func lockExpiry(now time.Time, duration time.Duration) *time.Time {
+	if duration < 0 {
+		return nil
+	}
+	expires := now.Add(duration)
+	return &expires
+}
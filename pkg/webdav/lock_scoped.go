@@ -0,0 +1,61 @@
+package webdav
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// scopedLS 将相对于 root 目录的路径转换为所有者文件系统内的绝对路径后交由 ls 处理，
+// 以 root 为根目录的 WebDAV 账户、挂载的共享目录与其他访问方式共用同一组锁
+type scopedLS struct {
+	ls   LockSystem
+	root string
+}
+
+// abs 转换为所有者文件系统内的绝对路径，空路径表示不指定
+func (s *scopedLS) abs(name string) string {
+	if name == "" {
+		return ""
+	}
+	return path.Join(s.root, slashClean(name))
+}
+
+// rel 转换为相对于 root 的路径，不在 root 之下时返回 false
+func (s *scopedLS) rel(name string) (string, bool) {
+	if name == s.root {
+		return "/", true
+	}
+	if strings.HasPrefix(name, s.root+"/") {
+		return strings.TrimPrefix(name, s.root), true
+	}
+	return "", false
+}
+
+func (s *scopedLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	return s.ls.Confirm(now, s.abs(name0), s.abs(name1), conditions...)
+}
+
+func (s *scopedLS) Create(now time.Time, details LockDetails) (string, error) {
+	details.Root = s.abs(details.Root)
+	return s.ls.Create(now, details)
+}
+
+func (s *scopedLS) Refresh(now time.Time, token string, duration time.Duration) (LockDetails, error) {
+	details, err := s.ls.Refresh(now, token, duration)
+	if err != nil {
+		return details, err
+	}
+
+	// 锁定的对象不在当前根目录下，视为不存在
+	root, ok := s.rel(details.Root)
+	if !ok {
+		return LockDetails{}, ErrNoSuchLock
+	}
+	details.Root = root
+	return details, nil
+}
+
+func (s *scopedLS) Unlock(now time.Time, token string) error {
+	return s.ls.Unlock(now, token)
+}
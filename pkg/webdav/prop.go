@@ -10,6 +10,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"net/http"
 	"strconv"
@@ -172,11 +173,9 @@ var liveProps = map[xml.Name]struct {
 //
 // Each Propstat has a unique status and each property name will only be part
 // of one Propstat element.
-func props(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo, pnames []xml.Name) ([]Propstat, error) {
+func props(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo, deadProps map[xml.Name]Property, pnames []xml.Name) ([]Propstat, error) {
 	isDir := fi.IsDir()
 
-	var deadProps map[xml.Name]Property
-
 	pstatOK := Propstat{Status: http.StatusOK}
 	pstatNotFound := Propstat{Status: http.StatusNotFound}
 	for _, pn := range pnames {
@@ -205,17 +204,18 @@ func props(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi Fil
 }
 
 // Propnames returns the property names defined for resource name.
-func propnames(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo) ([]xml.Name, error) {
+func propnames(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo, deadProps map[xml.Name]Property) ([]xml.Name, error) {
 	isDir := fi.IsDir()
 
-	var deadProps map[xml.Name]Property
-
 	pnames := make([]xml.Name, 0, len(liveProps)+len(deadProps))
 	for pn, prop := range liveProps {
 		if prop.findFn != nil && (prop.dir || !isDir) {
 			pnames = append(pnames, pn)
 		}
 	}
+	for pn := range deadProps {
+		pnames = append(pnames, pn)
+	}
 	return pnames, nil
 }
 
@@ -227,8 +227,8 @@ func propnames(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi
 // returned if they are named in 'include'.
 //
 // See http://www.webdav.org/specs/rfc4918.html#METHOD_PROPFIND
-func allprop(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, info FileInfo, include []xml.Name) ([]Propstat, error) {
-	pnames, err := propnames(ctx, fs, ls, info)
+func allprop(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, info FileInfo, deadProps map[xml.Name]Property, include []xml.Name) ([]Propstat, error) {
+	pnames, err := propnames(ctx, fs, ls, info, deadProps)
 	if err != nil {
 		return nil, err
 	}
@@ -242,12 +242,12 @@ func allprop(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, info
 			pnames = append(pnames, pn)
 		}
 	}
-	return props(ctx, fs, ls, info, pnames)
+	return props(ctx, fs, ls, info, deadProps, pnames)
 }
 
 // Patch patches the properties of resource name. The return values are
 // constrained in the same manner as DeadPropsHolder.Patch.
-func patch(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, name string, patches []Proppatch) ([]Propstat, error) {
+func patch(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo, patches []Proppatch) ([]Propstat, error) {
 	conflict := false
 loop:
 	for _, patch := range patches {
@@ -278,17 +278,138 @@ loop:
 		return makePropstats(pstatForbidden, pstatFailedDep), nil
 	}
 
-	// The file doesn't implement the optional DeadPropsHolder interface, so
-	// all patches are forbidden.
+	// 属性值过大时拒绝全部修改
+	tooLarge := false
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			if !patch.Remove && len(p.InnerXML) > maxDeadPropSize {
+				tooLarge = true
+			}
+		}
+	}
+	if tooLarge {
+		pstatTooLarge := Propstat{Status: http.StatusInsufficientStorage}
+		pstatFailedDep := Propstat{Status: StatusFailedDependency}
+		for _, patch := range patches {
+			for _, p := range patch.Props {
+				if !patch.Remove && len(p.InnerXML) > maxDeadPropSize {
+					pstatTooLarge.Props = append(pstatTooLarge.Props, Property{XMLName: p.XMLName})
+				} else {
+					pstatFailedDep.Props = append(pstatFailedDep.Props, Property{XMLName: p.XMLName})
+				}
+			}
+		}
+		return makePropstats(pstatTooLarge, pstatFailedDep), nil
+	}
+
+	// 同一属性被多次修改时以最后一次为准
+	var (
+		order   = make([]xml.Name, 0)
+		removed = make(map[xml.Name]bool)
+		latest  = make(map[xml.Name]Property)
+	)
 	pstat := Propstat{Status: http.StatusOK}
 	for _, patch := range patches {
 		for _, p := range patch.Props {
-			pstat.Props = append(pstat.Props, Property{XMLName: p.XMLName})
+			if _, ok := latest[p.XMLName]; !ok {
+				order = append(order, p.XMLName)
+				pstat.Props = append(pstat.Props, Property{XMLName: p.XMLName})
+			}
+			latest[p.XMLName] = p
+			removed[p.XMLName] = patch.Remove
 		}
 	}
+
+	var set, remove []model.WebdavProperty
+	for _, pn := range order {
+		prop := model.WebdavProperty{Space: pn.Space, Local: pn.Local}
+		if removed[pn] {
+			remove = append(remove, prop)
+			continue
+		}
+		prop.Lang = latest[pn].Lang
+		prop.InnerXML = string(latest[pn].InnerXML)
+		set = append(set, prop)
+	}
+
+	key, _ := deadPropsTarget(fi)
+	if err := model.PatchWebdavProperties(key.id, key.isDir, set, remove); err != nil {
+		return nil, err
+	}
 	return []Propstat{pstat}, nil
 }
 
+// maxDeadPropSize 单个自定义属性值的最大长度
+const maxDeadPropSize = 64 << 10
+
+// deadPropsKey 自定义属性所属的文件或目录
+type deadPropsKey struct {
+	id    uint
+	isDir bool
+}
+
+// deadPropsTarget 返回 fi 对应的属性所属对象及其父目录ID，根目录没有父目录
+func deadPropsTarget(fi FileInfo) (deadPropsKey, *uint) {
+	if folder, ok := fi.(*model.Folder); ok {
+		return deadPropsKey{id: folder.ID, isDir: true}, folder.ParentID
+	}
+	file := fi.(*model.File)
+	return deadPropsKey{id: file.ID}, &file.FolderID
+}
+
+// deadPropsLoader 在一次请求中按目录批量加载自定义属性，避免逐个文件查询
+type deadPropsLoader struct {
+	props  map[deadPropsKey]map[xml.Name]Property
+	loaded map[uint]bool
+}
+
+func newDeadPropsLoader() *deadPropsLoader {
+	return &deadPropsLoader{
+		props:  make(map[deadPropsKey]map[xml.Name]Property),
+		loaded: make(map[uint]bool),
+	}
+}
+
+// load 获取 fi 的自定义属性，首次访问某目录下的对象时加载该目录下所有对象的属性
+func (l *deadPropsLoader) load(fi FileInfo) (map[xml.Name]Property, error) {
+	key, parent := deadPropsTarget(fi)
+	if parent == nil {
+		if _, ok := l.props[key]; !ok {
+			props, err := model.GetWebdavPropertiesByObjectIDs([]uint{key.id}, key.isDir)
+			if err != nil {
+				return nil, err
+			}
+			l.add(props)
+			if _, ok := l.props[key]; !ok {
+				l.props[key] = nil
+			}
+		}
+	} else if !l.loaded[*parent] {
+		props, err := model.GetWebdavPropertiesInFolder(*parent)
+		if err != nil {
+			return nil, err
+		}
+		l.add(props)
+		l.loaded[*parent] = true
+	}
+	return l.props[key], nil
+}
+
+func (l *deadPropsLoader) add(props []model.WebdavProperty) {
+	for _, prop := range props {
+		key := deadPropsKey{id: prop.ObjectID, isDir: prop.IsDir}
+		if l.props[key] == nil {
+			l.props[key] = make(map[xml.Name]Property)
+		}
+		name := xml.Name{Space: prop.Space, Local: prop.Local}
+		l.props[key][name] = Property{
+			XMLName:  name,
+			Lang:     prop.Lang,
+			InnerXML: []byte(prop.InnerXML),
+		}
+	}
+}
+
 func escapeXML(s string) string {
 	for i := 0; i < len(s); i++ {
 		// As an optimization, if s contains only ASCII letters, digits or a
@@ -402,6 +523,10 @@ type ETager interface {
 }
 
 func findETag(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, reqPath string, fi FileInfo) (string, error) {
+	// 优先使用文件内容摘要
+	if file, ok := fi.(*model.File); ok && file.Hash != "" {
+		return fmt.Sprintf(`"%s"`, file.Hash), nil
+	}
 	return fmt.Sprintf(`"%x%x"`, fi.ModTime().UnixNano(), fi.GetSize()), nil
 }
 
@@ -10,15 +10,17 @@ import (
 	"errors"
 	"fmt"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
-	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/HFO4/cloudreve/pkg/task"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,27 +29,107 @@ type Handler struct {
 	Prefix string
 	// LockSystem is the lock management system.
 	LockSystem map[uint]LockSystem
+	// NewLockSystem 为用户创建 LockSystem，为空时使用 NewMemLS
+	NewLockSystem func(uid uint) LockSystem
 	// Logger is an optional error logger. If non-nil, it will be called
 	// for all HTTP requests.
 	Logger func(*http.Request, error)
+
+	mu sync.Mutex
 }
 
-func (h *Handler) stripPrefix(p string, uid uint) (string, int, error) {
-	if h.Prefix == "" {
-		return p, http.StatusOK, nil
+// lockSystem 获取用户的 LockSystem，不存在时新建
+func (h *Handler) lockSystem(uid uint) LockSystem {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ls, ok := h.LockSystem[uid]; ok {
+		return ls
 	}
-	prefix := h.Prefix
-	if r := strings.TrimPrefix(p, prefix); len(r) < len(p) {
-		if len(r) == 0 {
-			r = "/"
-		}
-		return util.RemoveSlash(r), http.StatusOK, nil
+
+	var ls LockSystem
+	if h.NewLockSystem != nil {
+		ls = h.NewLockSystem(uid)
+	} else {
+		ls = NewMemLS()
+	}
+	h.LockSystem[uid] = ls
+	return ls
+}
+
+type lockSystemKey struct{}
+
+// scopedLockSystem 获取文件系统对应的 LockSystem。锁以所有者文件系统内的绝对路径标识，
+// 根目录不同的 WebDAV 账户、挂载的共享目录访问同一对象时能够发现彼此的锁
+func (h *Handler) scopedLockSystem(fs *filesystem.FileSystem) (LockSystem, error) {
+	ls := h.lockSystem(fs.User.ID)
+	if fs.Root == nil {
+		return ls, nil
+	}
+
+	// fs.Root 的名称已被替换为根目录，需重新查找其完整路径
+	folders, err := model.GetFoldersByIDs([]uint{fs.Root.ID}, fs.User.ID)
+	if err != nil || len(folders) == 0 {
+		return nil, filesystem.ErrPathNotExist
+	}
+	if err := folders[0].TraceRoot(); err != nil {
+		return nil, err
+	}
+	root := path.Join(folders[0].Position, folders[0].Name)
+	if root == "/" {
+		return ls, nil
+	}
+	return &scopedLS{ls: ls, root: root}, nil
+}
+
+// requestLockSystem 获取请求所用的 LockSystem
+func requestLockSystem(r *http.Request) LockSystem {
+	return r.Context().Value(lockSystemKey{}).(LockSystem)
+}
+
+type prefixKey struct{}
+
+// WithPrefix 为请求指定URL前缀，用于覆盖 Handler.Prefix，如访问“与我共享”中的目录时
+func WithPrefix(r *http.Request, prefix string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), prefixKey{}, prefix))
+}
+
+// prefix 获取请求的URL前缀
+func (h *Handler) prefix(r *http.Request) string {
+	if prefix, ok := r.Context().Value(prefixKey{}).(string); ok {
+		return prefix
+	}
+	return h.Prefix
+}
+
+// stripPrefix 去除URL前缀，得到相对于账户根目录的路径。路径会被规范化，
+// 以免通过 .. 等方式访问根目录之外的文件
+func (h *Handler) stripPrefix(r *http.Request, p string) (string, int, error) {
+	prefix := h.prefix(r)
+	if prefix == "" {
+		return path.Clean("/" + p), http.StatusOK, nil
+	}
+	if r := strings.TrimPrefix(p, prefix); len(r) < len(p) && (r == "" || r[0] == '/') {
+		return path.Clean("/" + r), http.StatusOK, nil
 	}
 	return p, http.StatusNotFound, errPrefixMismatch
 }
 
+// IsWriteMethod 请求方法是否会修改文件或锁，只读账户不能使用这些方法
+func IsWriteMethod(method string) bool {
+	switch method {
+	case "PUT", "DELETE", "MKCOL", "COPY", "MOVE", "PROPPATCH", "LOCK", "UNLOCK":
+		return true
+	}
+	return false
+}
+
 // isPathExist 路径是否存在
 func isPathExist(ctx context.Context, fs *filesystem.FileSystem, path string) (bool, FileInfo) {
+	// “与我共享”虚拟目录
+	if fs.Root == nil && fs.Operator == nil && path == filesystem.SharedRootPath {
+		return true, fs.SharedRoot()
+	}
+
 	// 尝试目录
 	if ok, folder := fs.IsPathExist(path); ok {
 		return ok, folder
@@ -60,13 +142,13 @@ func isPathExist(ctx context.Context, fs *filesystem.FileSystem, path string) (b
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) {
 	status, err := http.StatusBadRequest, errUnsupportedMethod
+	var ls LockSystem
 	if h.LockSystem == nil {
 		status, err = http.StatusInternalServerError, errNoLockSystem
+	} else if ls, err = h.scopedLockSystem(fs); err != nil {
+		status = http.StatusInternalServerError
 	} else {
-		// 检查并新建LockSystem
-		if _, ok := h.LockSystem[fs.User.ID]; !ok {
-			h.LockSystem[fs.User.ID] = NewMemLS()
-		}
+		r = r.WithContext(context.WithValue(r.Context(), lockSystemKey{}, ls))
 		switch r.Method {
 		case "OPTIONS":
 			status, err = h.handleOptions(w, r, fs)
@@ -103,8 +185,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesyst
 }
 
 // OK
-func (h *Handler) lock(now time.Time, root string, fs *filesystem.FileSystem) (token string, status int, err error) {
-	token, err = h.LockSystem[fs.User.ID].Create(now, LockDetails{
+func (h *Handler) lock(r *http.Request, now time.Time, root string) (token string, status int, err error) {
+	token, err = requestLockSystem(r).Create(now, LockDetails{
 		Root:      root,
 		Duration:  infiniteTimeout,
 		ZeroDepth: true,
@@ -129,16 +211,16 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string, fs *filesystem.
 		// locks are unlocked at the end of the HTTP request.
 		now, srcToken, dstToken := time.Now(), "", ""
 		if src != "" {
-			srcToken, status, err = h.lock(now, src, fs)
+			srcToken, status, err = h.lock(r, now, src)
 			if err != nil {
 				return nil, status, err
 			}
 		}
 		if dst != "" {
-			dstToken, status, err = h.lock(now, dst, fs)
+			dstToken, status, err = h.lock(r, now, dst)
 			if err != nil {
 				if srcToken != "" {
-					h.LockSystem[fs.User.ID].Unlock(now, srcToken)
+					requestLockSystem(r).Unlock(now, srcToken)
 				}
 				return nil, status, err
 			}
@@ -146,10 +228,10 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string, fs *filesystem.
 
 		return func() {
 			if dstToken != "" {
-				h.LockSystem[fs.User.ID].Unlock(now, dstToken)
+				requestLockSystem(r).Unlock(now, dstToken)
 			}
 			if srcToken != "" {
-				h.LockSystem[fs.User.ID].Unlock(now, srcToken)
+				requestLockSystem(r).Unlock(now, srcToken)
 			}
 		}, 0, nil
 	}
@@ -171,12 +253,12 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string, fs *filesystem.
 			//if u.Host != r.Host {
 			//	continue
 			//}
-			lsrc, status, err = h.stripPrefix(u.Path, fs.User.ID)
+			lsrc, status, err = h.stripPrefix(r, u.Path)
 			if err != nil {
 				return nil, status, err
 			}
 		}
-		release, err = h.LockSystem[fs.User.ID].Confirm(
+		release, err = requestLockSystem(r).Confirm(
 			time.Now(),
 			lsrc,
 			dst,
@@ -199,7 +281,7 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string, fs *filesystem.
 
 //OK
 func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -224,7 +306,7 @@ func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request, fs *file
 func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
 	defer fs.Recycle()
 
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -238,6 +320,9 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *
 	fs.SetTargetFile(&[]model.File{*file})
 
 	rs, err := fs.Preview(ctx, 0, false)
+	if r.Method == "GET" {
+		audit.RecordContext(ctx, fs.User, audit.ActionDownload, reqPath, err)
+	}
 	if err != nil {
 		if err == filesystem.ErrObjectNotExist {
 			return http.StatusNotFound, err
@@ -245,7 +330,7 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *
 		return http.StatusInternalServerError, err
 	}
 
-	etag, err := findETag(ctx, fs, h.LockSystem[fs.User.ID], reqPath, &fs.FileTarget[0])
+	etag, err := findETag(ctx, fs, requestLockSystem(r), reqPath, &fs.FileTarget[0])
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -267,7 +352,7 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *
 func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
 	defer fs.Recycle()
 
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -279,17 +364,17 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	ctx := r.Context()
 
-	// 尝试作为文件删除
+	// 尝试作为文件移入回收站
 	if ok, file := fs.IsFileExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false); err != nil {
+		if err := fs.Trash(ctx, []uint{}, []uint{file.ID}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
 	}
 
-	// 尝试作为目录删除
+	// 尝试作为目录移入回收站
 	if ok, folder := fs.IsPathExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{folder.ID}, []uint{}, false); err != nil {
+		if err := fs.Trash(ctx, []uint{folder.ID}, []uint{}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -300,7 +385,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 // OK
 func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -333,27 +418,43 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 	exist, originFile := fs.IsFileExist(reqPath)
 	if exist {
 		// 已存在，为更新操作
+		if fs.IsVersionEnabled() {
+			// 保留历史版本，新内容写入新的物理文件，并计入完整容量
+			originFile.SourceName = fs.GenerateVersionSavePath(ctx, fileData)
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+			fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+			fs.Use("AfterUpload", filesystem.HookSaveFileVersion)
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterUpload", task.HookReplicate)
+			fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+			fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+			fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+		} else {
+			// 检查此文件是否有软链接
+			fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
+			if err == nil && len(fileList) == 0 {
+				// 如果包含软连接，应重新生成新文件副本，并更新source_name
+				originFile.SourceName = fs.GenerateSavePath(ctx, fileData)
+				fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+				fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+				fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+			}
 
-		// 检查此文件是否有软链接
-		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
-		if err == nil && len(fileList) == 0 {
-			// 如果包含软连接，应重新生成新文件副本，并更新source_name
-			originFile.SourceName = fs.GenerateSavePath(ctx, fileData)
-			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
+			fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+			fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+			fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterUpload", task.HookReplicate)
+			fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+			fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+			fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 		}
-
-		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-		fs.Use("BeforeUpload", filesystem.HookValidateFile)
-		fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
-		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
-		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
-		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 		ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
 	} else {
 		// 给文件系统分配钩子
@@ -362,6 +463,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
 		fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+		fs.Use("AfterUpload", task.HookReplicate)
 		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
 		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 		fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
@@ -373,7 +475,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		return http.StatusMethodNotAllowed, err
 	}
 
-	etag, err := findETag(ctx, fs, h.LockSystem[fs.User.ID], reqPath, &fs.FileTarget[0])
+	etag, err := findETag(ctx, fs, requestLockSystem(r), reqPath, &fs.FileTarget[0])
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -385,7 +487,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
 	defer fs.Recycle()
 
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -427,12 +529,12 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request, fs *fil
 	//	return http.StatusBadGateway, errInvalidDestination
 	//}
 
-	src, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	src, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
 
-	dst, status, err := h.stripPrefix(u.Path, fs.User.ID)
+	dst, status, err := h.stripPrefix(r, u.Path)
 	if err != nil {
 		return status, err
 	}
@@ -524,7 +626,7 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 		if token == "" {
 			return http.StatusBadRequest, errInvalidLockToken
 		}
-		ld, err = h.LockSystem[fs.User.ID].Refresh(now, token, duration)
+		ld, err = requestLockSystem(r).Refresh(now, token, duration)
 		if err != nil {
 			if err == ErrNoSuchLock {
 				return http.StatusPreconditionFailed, err
@@ -544,7 +646,7 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 				return http.StatusBadRequest, errInvalidDepth
 			}
 		}
-		reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+		reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 		if err != nil {
 			return status, err
 		}
@@ -554,7 +656,7 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 			OwnerXML:  li.Owner.InnerXML,
 			ZeroDepth: depth == 0,
 		}
-		token, err = h.LockSystem[fs.User.ID].Create(now, ld)
+		token, err = requestLockSystem(r).Create(now, ld)
 		if err != nil {
 			if err == ErrLocked {
 				return StatusLocked, err
@@ -563,7 +665,7 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 		}
 		defer func() {
 			if retErr != nil {
-				h.LockSystem[fs.User.ID].Unlock(now, token)
+				requestLockSystem(r).Unlock(now, token)
 			}
 		}()
 
@@ -606,7 +708,7 @@ func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request, fs *files
 	}
 	t = t[1 : len(t)-1]
 
-	switch err = h.LockSystem[fs.User.ID].Unlock(time.Now(), t); err {
+	switch err = requestLockSystem(r).Unlock(time.Now(), t); err {
 	case nil:
 		return http.StatusNoContent, err
 	case ErrForbidden:
@@ -624,7 +726,7 @@ func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request, fs *files
 func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
 	defer fs.Recycle()
 
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -647,15 +749,20 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, fs *fil
 	}
 
 	mw := multistatusWriter{w: w}
+	loader := newDeadPropsLoader()
 
 	walkFn := func(reqPath string, info FileInfo, err error) error {
 
+		if err != nil {
+			return err
+		}
+		deadProps, err := loader.load(info)
 		if err != nil {
 			return err
 		}
 		var pstats []Propstat
 		if pf.Propname != nil {
-			pnames, err := propnames(ctx, fs, h.LockSystem[fs.User.ID], info)
+			pnames, err := propnames(ctx, fs, requestLockSystem(r), info, deadProps)
 			if err != nil {
 				return err
 			}
@@ -665,14 +772,14 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, fs *fil
 			}
 			pstats = append(pstats, pstat)
 		} else if pf.Allprop != nil {
-			pstats, err = allprop(ctx, fs, h.LockSystem[fs.User.ID], info, pf.Prop)
+			pstats, err = allprop(ctx, fs, requestLockSystem(r), info, deadProps, pf.Prop)
 		} else {
-			pstats, err = props(ctx, fs, h.LockSystem[fs.User.ID], info, pf.Prop)
+			pstats, err = props(ctx, fs, requestLockSystem(r), info, deadProps, pf.Prop)
 		}
 		if err != nil {
 			return err
 		}
-		href := path.Join(h.Prefix, reqPath)
+		href := path.Join(h.prefix(r), reqPath)
 		if href != "/" && info.IsDir() {
 			href += "/"
 		}
@@ -693,7 +800,7 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, fs *fil
 func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
 	defer fs.Recycle()
 
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	reqPath, status, err := h.stripPrefix(r, r.URL.Path)
 	if err != nil {
 		return status, err
 	}
@@ -705,14 +812,15 @@ func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request, fs *fi
 
 	ctx := r.Context()
 
-	if exist, _ := isPathExist(ctx, fs, reqPath); !exist {
+	exist, fi := isPathExist(ctx, fs, reqPath)
+	if !exist {
 		return http.StatusNotFound, nil
 	}
 	patches, status, err := readProppatch(r.Body)
 	if err != nil {
 		return status, err
 	}
-	pstats, err := patch(ctx, fs, h.LockSystem[fs.User.ID], reqPath, patches)
+	pstats, err := patch(ctx, fs, requestLockSystem(r), fi, patches)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
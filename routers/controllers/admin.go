@@ -1,13 +1,17 @@
 package controllers
 
 import (
+	"fmt"
 	"github.com/HFO4/cloudreve/pkg/aria2"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/email"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/service/admin"
 	"github.com/gin-gonic/gin"
 	"io"
+	"strings"
 )
 
 // AdminSummary 获取管理站点概况
@@ -35,6 +39,7 @@ func AdminChangeSetting(c *gin.Context) {
 	var service admin.BatchSettingChangeService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Change()
+		audit.RecordResponse(c, audit.ActionAdminSetting, strings.Join(service.Keys(), ", "), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -71,6 +76,8 @@ func AdminReloadService(c *gin.Context) {
 		email.Init()
 	case "aria2":
 		aria2.Init(true)
+	case "search":
+		go filesystem.RebuildSearchIndex()
 	}
 
 	c.JSON(200, serializer.Response{})
@@ -131,11 +138,23 @@ func AdminTestSlave(c *gin.Context) {
 	}
 }
 
+// AdminTestSFTP 测试SFTP服务器连接
+func AdminTestSFTP(c *gin.Context) {
+	var service admin.SFTPTestService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Test()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminAddPolicy 新建存储策略
 func AdminAddPolicy(c *gin.Context) {
 	var service admin.AddPolicyService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Add()
+		audit.RecordResponse(c, audit.ActionAdminPolicySave, service.Policy.Name, res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -147,6 +166,7 @@ func AdminAddCORS(c *gin.Context) {
 	var service admin.PolicyService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.AddCORS()
+		audit.RecordResponse(c, audit.ActionAdminPolicySave, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -158,6 +178,7 @@ func AdminAddSCF(c *gin.Context) {
 	var service admin.PolicyService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.AddSCF()
+		audit.RecordResponse(c, audit.ActionAdminPolicySave, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -191,6 +212,7 @@ func AdminDeletePolicy(c *gin.Context) {
 	var service admin.PolicyService
 	if err := c.ShouldBindUri(&service); err == nil {
 		res := service.Delete()
+		audit.RecordResponse(c, audit.ActionAdminPolicyDelete, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -213,6 +235,7 @@ func AdminAddGroup(c *gin.Context) {
 	var service admin.AddGroupService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Add()
+		audit.RecordResponse(c, audit.ActionAdminGroupSave, service.Group.Name, res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -224,6 +247,7 @@ func AdminDeleteGroup(c *gin.Context) {
 	var service admin.GroupService
 	if err := c.ShouldBindUri(&service); err == nil {
 		res := service.Delete()
+		audit.RecordResponse(c, audit.ActionAdminGroupDelete, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -257,6 +281,7 @@ func AdminAddUser(c *gin.Context) {
 	var service admin.AddUserService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Add()
+		audit.RecordResponse(c, audit.ActionAdminUserSave, service.User.Email, res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -279,6 +304,7 @@ func AdminDeleteUser(c *gin.Context) {
 	var service admin.UserBatchService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Delete()
+		audit.RecordResponse(c, audit.ActionAdminUserDelete, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -290,6 +316,30 @@ func AdminBanUser(c *gin.Context) {
 	var service admin.UserService
 	if err := c.ShouldBindUri(&service); err == nil {
 		res := service.Ban()
+		audit.RecordResponse(c, audit.ActionAdminUserBan, fmt.Sprint(service.ID), res)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListLockedUser 列出被锁定的用户
+func AdminListLockedUser(c *gin.Context) {
+	var service admin.NoParamService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.LockedUsers()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminUnlockUser 解除用户的登录锁定
+func AdminUnlockUser(c *gin.Context) {
+	var service admin.UserService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Unlock()
+		audit.RecordResponse(c, audit.ActionAdminUserUnlock, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -331,6 +381,7 @@ func AdminDeleteFile(c *gin.Context) {
 	var service admin.FileBatchService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Delete(c)
+		audit.RecordResponse(c, audit.ActionAdminFileDelete, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -353,6 +404,7 @@ func AdminDeleteShare(c *gin.Context) {
 	var service admin.ShareBatchService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Delete(c)
+		audit.RecordResponse(c, audit.ActionAdminShareDelete, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -375,6 +427,7 @@ func AdminDeleteDownload(c *gin.Context) {
 	var service admin.TaskBatchService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Delete(c)
+		audit.RecordResponse(c, audit.ActionAdminDownloadDelete, fmt.Sprint(service.ID), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -397,6 +450,43 @@ func AdminDeleteTask(c *gin.Context) {
 	var service admin.TaskBatchService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.DeleteGeneral(c)
+		audit.RecordResponse(c, audit.ActionAdminTaskDelete, fmt.Sprint(service.ID), res)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCancelTask 批量取消任务
+func AdminCancelTask(c *gin.Context) {
+	var service admin.TaskBatchService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Cancel(c)
+		audit.RecordResponse(c, audit.ActionAdminTaskCancel, fmt.Sprint(service.ID), res)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminRetryTask 批量重试任务
+func AdminRetryTask(c *gin.Context) {
+	var service admin.TaskBatchService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Retry(c)
+		audit.RecordResponse(c, audit.ActionAdminTaskRetry, fmt.Sprint(service.ID), res)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminSetTaskPriority 设定任务优先级
+func AdminSetTaskPriority(c *gin.Context) {
+	var service admin.TaskPriorityService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Set(c)
+		audit.RecordResponse(c, audit.ActionAdminTaskPriority, fmt.Sprintf("%d -> %d", service.ID, service.Priority), res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -408,12 +498,61 @@ func AdminCreateImportTask(c *gin.Context) {
 	var service admin.ImportTaskService
 	if err := c.ShouldBindJSON(&service); err == nil {
 		res := service.Create(c, CurrentUser(c))
+		audit.RecordResponse(c, audit.ActionAdminImport, service.Src+" -> "+service.Dst, res)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
 
+// AdminCreateMigrateTask 新建存储策略迁移任务
+func AdminCreateMigrateTask(c *gin.Context) {
+	var service admin.MigrateTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		audit.RecordResponse(c, audit.ActionAdminMigrate, fmt.Sprintf("%s %d -> %d", service.Scope, service.TargetID, service.PolicyID), res)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateEncryptTask 新建存储加密任务
+func AdminCreateEncryptTask(c *gin.Context) {
+	var service admin.EncryptTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		audit.RecordResponse(c, audit.ActionAdminEncrypt, fmt.Sprintf("%d", service.PolicyID), res)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListAuditLog 列出审计日志
+func AdminListAuditLog(c *gin.Context) {
+	var service admin.AuditLogListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.AuditLogs()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminExportAuditLog 导出审计日志
+func AdminExportAuditLog(c *gin.Context) {
+	var service admin.AuditLogFilter
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Export(c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListFolders 列出用户或外部文件系统目录
 func AdminListFolders(c *gin.Context) {
 	var service admin.ListFolderService
@@ -424,3 +563,40 @@ func AdminListFolders(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// AdminStorageTop 获取容量使用排行
+func AdminStorageTop(c *gin.Context) {
+	var service admin.StorageTopService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Top()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminStorageGrowth 获取容量增长统计
+func AdminStorageGrowth(c *gin.Context) {
+	var service admin.StorageGrowthService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Growth()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminStorageUser 获取用户容量使用明细
+func AdminStorageUser(c *gin.Context) {
+	var service admin.StorageUserService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Breakdown()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/HFO4/cloudreve/service/callback"
+	"github.com/HFO4/cloudreve/service/user"
 	"github.com/gin-gonic/gin"
 )
 
@@ -118,3 +119,24 @@ func S3Callback(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// OIDCCallback OpenID Connect 授权回调，登录成功后跳转至首页
+func OIDCCallback(c *gin.Context) {
+	var service user.OIDCCallbackService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Callback(c)
+		if res.Code == 0 {
+			c.Redirect(302, "/#/home")
+			return
+		}
+
+		redirect, _ := url.Parse("/login")
+		queries := redirect.Query()
+		queries.Add("code", strconv.Itoa(res.Code))
+		queries.Add("msg", res.Msg)
+		redirect.RawQuery = queries.Encode()
+		c.Redirect(302, "/#"+redirect.String())
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -4,12 +4,12 @@ import "C"
 import (
 	"context"
 	"fmt"
-	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
 	"github.com/HFO4/cloudreve/service/explorer"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -69,6 +69,21 @@ func Decompress(c *gin.Context) {
 	}
 }
 
+// ListArchive 列出压缩包内容
+func ListArchive(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ItemArchiveListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.ListArchive(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AnonymousGetContent 匿名获取文件资源
 func AnonymousGetContent(c *gin.Context) {
 	// 创建上下文
@@ -275,13 +290,6 @@ func FileUploadStream(c *gin.Context) {
 		return
 	}
 
-	// 非可用策略时拒绝上传
-	if user, ok := c.Get("user"); ok && !user.(*model.User).Policy.IsTransitUpload(fileSize) {
-		request.BlackHole(c.Request.Body)
-		c.JSON(200, serializer.Err(serializer.CodePolicyNotAllowed, "当前存储策略无法使用", nil))
-		return
-	}
-
 	// 解码文件名和路径
 	fileName, err := url.QueryUnescape(c.Request.Header.Get("X-FileName"))
 	filePath, err := url.QueryUnescape(c.Request.Header.Get("X-Path"))
@@ -297,6 +305,21 @@ func FileUploadStream(c *gin.Context) {
 		return
 	}
 
+	// 上传至“与我共享”中的目录时，使用目录所有者的容量及存储策略
+	filePath, err = fs.EnterSharedPath(filePath, true)
+	if err != nil {
+		request.BlackHole(c.Request.Body)
+		c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err))
+		return
+	}
+
+	// 非可用策略时拒绝上传
+	if !fs.User.Policy.IsTransitUpload(fileSize) {
+		request.BlackHole(c.Request.Body)
+		c.JSON(200, serializer.Err(serializer.CodePolicyNotAllowed, "当前存储策略无法使用", nil))
+		return
+	}
+
 	fileName, _ = fs.GetUniqueFileName(ctx, fileName, filePath)
 	fileData := local.FileStream{
 		MIMEType:    c.Request.Header.Get("Content-Type"),
@@ -312,6 +335,7 @@ func FileUploadStream(c *gin.Context) {
 	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
 	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", task.HookReplicate)
 	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
 	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
 	fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
@@ -329,6 +353,82 @@ func FileUploadStream(c *gin.Context) {
 	})
 }
 
+// CreateUploadSession 创建分片上传会话
+func CreateUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetUploadSession 获取分片上传会话已接收的分片
+func GetUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Status(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UploadChunk 上传分片
+func UploadChunk(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadChunkService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Upload(ctx, c)
+		c.JSON(200, res)
+	} else {
+		request.BlackHole(c.Request.Body)
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CompleteUploadSession 合并分片并完成上传
+func CompleteUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Complete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteUploadSession 取消分片上传会话
+func DeleteUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // GetUploadCredential 获取上传凭证
 func GetUploadCredential(c *gin.Context) {
 	// 创建上下文
@@ -347,7 +447,12 @@ func GetUploadCredential(c *gin.Context) {
 // SearchFile 搜索文件
 func SearchFile(c *gin.Context) {
 	var service explorer.ItemSearchService
-	if err := c.ShouldBindUri(&service); err == nil {
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindQuery(&service); err == nil {
 		res := service.Search(c)
 		c.JSON(200, res)
 	} else {
@@ -3,11 +3,14 @@ package controllers
 import (
 	"context"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/HFO4/cloudreve/service/share"
 	"github.com/gin-gonic/gin"
+	"net/url"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -217,3 +220,99 @@ func GetUserShare(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// CreateInternalShare 将目录共享给站内用户或用户组
+func CreateInternalShare(c *gin.Context) {
+	var service share.InternalShareCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListInternalShare 列出我创建的站内共享
+func ListInternalShare(c *gin.Context) {
+	var service share.InternalShareListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListInternalShareGroups 列出可共享的用户组
+func ListInternalShareGroups(c *gin.Context) {
+	var service share.InternalShareListService
+	res := service.Groups(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// UpdateInternalShare 更新站内共享权限
+func UpdateInternalShare(c *gin.Context) {
+	var service share.InternalShareUpdateService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Update(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteInternalShare 取消站内共享
+func DeleteInternalShare(c *gin.Context) {
+	var service share.InternalShareService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UploadToShare 向文件收集上传文件
+func UploadToShare(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 取得文件大小及文件名
+	fileSize, err := strconv.ParseUint(c.Request.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+	fileName, err := url.QueryUnescape(c.Request.Header.Get("X-FileName"))
+	if err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	service := share.UploadService{
+		Name:     fileName,
+		Size:     fileSize,
+		MIMEType: c.Request.Header.Get("Content-Type"),
+	}
+	res := service.Upload(ctx, c, c.Request.Body)
+	if res.Code != 0 {
+		request.BlackHole(c.Request.Body)
+	}
+	c.JSON(200, res)
+}
+
+// GetShareStats 获取分享访问统计
+func GetShareStats(c *gin.Context) {
+	var service share.ShareStatsService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Stats(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -24,6 +24,7 @@ func SiteConfig(c *gin.Context) {
 		"home_view_method",
 		"share_view_method",
 		"authn_enabled",
+		"oidc_enabled",
 		"captcha_IsUseReCaptcha",
 		"captcha_ReCaptchaKey",
 	)
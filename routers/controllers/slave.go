@@ -5,6 +5,7 @@ import (
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/service/admin"
 	"github.com/HFO4/cloudreve/service/explorer"
@@ -26,7 +27,6 @@ func SlaveUpload(c *gin.Context) {
 		c.JSON(200, serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err))
 		return
 	}
-	fs.Handler = local.Driver{}
 
 	// 从请求中取得上传策略
 	uploadPolicyRaw := c.GetHeader("X-Policy")
@@ -42,6 +42,7 @@ func SlaveUpload(c *gin.Context) {
 		return
 	}
 	ctx = context.WithValue(ctx, fsctx.UploadPolicyCtx, *uploadPolicy)
+	fs.Handler = local.NewSlaveDriver(*uploadPolicy)
 
 	// 取得文件大小
 	fileSize, err := strconv.ParseUint(c.Request.Header.Get("Content-Length"), 10, 64)
@@ -82,6 +83,88 @@ func SlaveUpload(c *gin.Context) {
 	})
 }
 
+// SlaveCreateUploadSession 从机创建分片上传会话
+func SlaveCreateUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveUploadSessionCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SlaveGetUploadSession 从机获取分片上传会话已接收的分片
+func SlaveGetUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveUploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Status(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SlaveUploadChunk 从机上传分片
+func SlaveUploadChunk(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveUploadChunkService
+	if err := c.ShouldBindUri(&service); err != nil {
+		request.BlackHole(c.Request.Body)
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+	if err := c.ShouldBindQuery(&service); err != nil {
+		request.BlackHole(c.Request.Body)
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	res := service.Upload(ctx, c)
+	c.JSON(200, res)
+}
+
+// SlaveCompleteUploadSession 从机合并分片并完成上传
+func SlaveCompleteUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveUploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Complete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SlaveDeleteUploadSession 从机删除分片上传会话
+func SlaveDeleteUploadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.SlaveUploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // SlaveDownload 从机文件下载,此请求返回的HTTP状态码不全为200
 func SlaveDownload(c *gin.Context) {
 	// 创建上下文
@@ -169,3 +252,36 @@ func SlaveList(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// SlaveCreateTask 从机创建后台任务
+func SlaveCreateTask(c *gin.Context) {
+	var service explorer.SlaveTaskCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SlaveGetTask 从机获取后台任务状态
+func SlaveGetTask(c *gin.Context) {
+	var service explorer.SlaveTaskService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Status(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SlaveDeleteTask 从机删除后台任务
+func SlaveDeleteTask(c *gin.Context) {
+	var service explorer.SlaveTaskService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
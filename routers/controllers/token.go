@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"github.com/HFO4/cloudreve/service/setting"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAPITokens 列出 API 令牌
+func GetAPITokens(c *gin.Context) {
+	var service setting.APITokenListService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Tokens(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateAPIToken 创建 API 令牌
+func CreateAPIToken(c *gin.Context) {
+	var service setting.APITokenCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteAPIToken 吊销 API 令牌
+func DeleteAPIToken(c *gin.Context) {
+	var service setting.APITokenService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
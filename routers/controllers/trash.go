@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"github.com/HFO4/cloudreve/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash 列出回收站中的对象
+func ListTrash(c *gin.Context) {
+	var service explorer.TrashListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreTrash 恢复回收站中的对象
+func RestoreTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TrashItemService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PurgeTrash 彻底删除回收站中的对象
+func PurgeTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TrashItemService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Purge(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// EmptyTrash 清空回收站
+func EmptyTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res := explorer.EmptyTrash(ctx, c)
+	c.JSON(200, res)
+}
@@ -157,6 +157,17 @@ func UserLogin(c *gin.Context) {
 	}
 }
 
+// StartOIDCLogin 开始 OpenID Connect 登录
+func StartOIDCLogin(c *gin.Context) {
+	var service user.OIDCLoginService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Start(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // UserRegister 用户注册
 func UserRegister(c *gin.Context) {
 	var service user.UserRegisterService
@@ -232,6 +243,13 @@ func UserStorage(c *gin.Context) {
 	c.JSON(200, res)
 }
 
+// UserStorageUsage 按文件类型获取容量使用
+func UserStorageUsage(c *gin.Context) {
+	var service user.StorageUsageService
+	res := service.Types(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
 // UserTasks 获取任务队列
 func UserTasks(c *gin.Context) {
 	var service user.SettingListService
@@ -243,6 +261,28 @@ func UserTasks(c *gin.Context) {
 	}
 }
 
+// UserCancelTask 取消任务
+func UserCancelTask(c *gin.Context) {
+	var service user.TaskService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Cancel(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// UserRetryTask 重试任务
+func UserRetryTask(c *gin.Context) {
+	var service user.TaskService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Retry(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // UserSetting 获取用户设定
 func UserSetting(c *gin.Context) {
 	var service user.SettingService
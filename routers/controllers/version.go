@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"github.com/HFO4/cloudreve/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListFileVersions 列出文件的历史版本
+func ListFileVersions(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.ListVersions(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateVersionDownloadSession 创建文件历史版本下载会话
+func CreateVersionDownloadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.CreateDownloadSession(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreFileVersion 将文件恢复至历史版本
+func RestoreFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteFileVersions 删除文件的历史版本
+func DeleteFileVersions(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionDeleteService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
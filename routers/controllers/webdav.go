@@ -1,12 +1,18 @@
 package controllers
 
 import (
+	"context"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/conf"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/HFO4/cloudreve/pkg/webdav"
 	"github.com/HFO4/cloudreve/service/setting"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"path"
+	"strings"
 )
 
 var handler *webdav.Handler
@@ -15,6 +21,16 @@ func init() {
 	handler = &webdav.Handler{
 		Prefix:     "/dav",
 		LockSystem: make(map[uint]webdav.LockSystem),
+		NewLockSystem: func(uid uint) webdav.LockSystem {
+			// 多实例部署时锁需存储于数据库或共用的 Redis 缓存
+			switch conf.WebDAVConfig.LockStore {
+			case "database":
+				return webdav.NewDBLS(uid)
+			case "cache":
+				return webdav.NewCacheLS(uid)
+			}
+			return webdav.NewMemLS()
+		},
 	}
 }
 
@@ -29,16 +45,56 @@ func ServeWebDAV(c *gin.Context) {
 	if webdavCtx, ok := c.Get("webdav"); ok {
 		application := webdavCtx.(*model.Webdav)
 
-		// 重定根目录
-		if application.Root != "/" {
-			if exist, root := fs.IsPathExist(application.Root); exist {
-				root.Position = ""
-				root.Name = "/"
-				fs.Root = root
+		// 只读账户不能修改文件
+		if application.Readonly && webdav.IsWriteMethod(c.Request.Method) {
+			fs.Recycle()
+			c.Status(http.StatusForbidden)
+			return
+		}
+
+		// 重定根目录，根目录不存在时拒绝访问
+		if application.Root != "" && application.Root != "/" {
+			exist, root := fs.IsPathExist(application.Root)
+			if !exist {
+				fs.Recycle()
+				c.Status(http.StatusNotFound)
+				return
+			}
+			root.Position = ""
+			root.Name = "/"
+			fs.Root = root
+		}
+
+		if err := application.Touch(); err != nil {
+			util.Log().Debug("无法更新WebDAV账户[%d]的最后使用时间，%s", application.ID, err)
+		}
+	}
+
+	// 访问“与我共享”中的目录时，以共享目录作为根目录
+	if fs.Root == nil {
+		reqPath := path.Clean("/" + strings.TrimPrefix(c.Request.URL.Path, handler.Prefix))
+		if reqPath == filesystem.SharedRootPath && webdav.IsWriteMethod(c.Request.Method) {
+			fs.Recycle()
+			c.Status(http.StatusForbidden)
+			return
+		}
+		if reqPath != filesystem.SharedRootPath && filesystem.IsSharedPath(reqPath) {
+			prefix, err := fs.MountShared(reqPath, webdav.IsWriteMethod(c.Request.Method))
+			if err != nil {
+				fs.Recycle()
+				if err == filesystem.ErrSharedReadOnly {
+					c.Status(http.StatusForbidden)
+				} else {
+					c.Status(http.StatusNotFound)
+				}
+				return
 			}
+			c.Request = webdav.WithPrefix(c.Request, handler.Prefix+prefix)
 		}
 	}
 
+	// 文件系统操作需从请求上下文中获取客户端信息用于审计日志
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), fsctx.GinCtx, c))
 	handler.ServeHTTP(c.Writer, c.Request, fs)
 }
 
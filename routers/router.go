@@ -3,6 +3,7 @@ package routers
 import (
 	"github.com/HFO4/cloudreve/bootstrap"
 	"github.com/HFO4/cloudreve/middleware"
+	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/conf"
 	"github.com/HFO4/cloudreve/pkg/hashid"
 	"github.com/HFO4/cloudreve/pkg/util"
@@ -41,16 +42,26 @@ func InitSlaveRouter() *gin.Engine {
 		v3.POST("ping", controllers.SlavePing)
 		// 上传
 		v3.POST("upload", controllers.SlaveUpload)
+		// 分片上传会话
+		v3.POST("upload/session", controllers.SlaveCreateUploadSession)
+		v3.GET("upload/session/:sessionId", controllers.SlaveGetUploadSession)
+		v3.PUT("upload/session/:sessionId", controllers.SlaveUploadChunk)
+		v3.POST("upload/session/:sessionId", controllers.SlaveCompleteUploadSession)
+		v3.DELETE("upload/session/:sessionId", controllers.SlaveDeleteUploadSession)
 		// 下载
-		v3.GET("download/:speed/:path/:name", controllers.SlaveDownload)
+		v3.GET("download/:speed/:encrypted/:path/:name", controllers.SlaveDownload)
 		// 预览 / 外链
-		v3.GET("source/:speed/:path/:name", controllers.SlavePreview)
+		v3.GET("source/:speed/:encrypted/:path/:name", controllers.SlavePreview)
 		// 缩略图
-		v3.GET("thumb/:path", controllers.SlaveThumb)
+		v3.GET("thumb/:encrypted/:path", controllers.SlaveThumb)
 		// 删除文件
 		v3.POST("delete", controllers.SlaveDelete)
 		// 列出文件
 		v3.POST("list", controllers.SlaveList)
+		// 后台任务
+		v3.POST("task", controllers.SlaveCreateTask)
+		v3.GET("task/:id", controllers.SlaveGetTask)
+		v3.DELETE("task/:id", controllers.SlaveDeleteTask)
 	}
 	return r
 }
@@ -149,6 +160,11 @@ func InitMasterRouter() *gin.Engine {
 				middleware.IsFunctionEnabled("authn_enabled"),
 				controllers.FinishLoginAuthn,
 			)
+			// OpenID Connect 登录
+			user.GET("oidc",
+				middleware.IsFunctionEnabled("oidc_enabled"),
+				controllers.StartOIDCLogin,
+			)
 			// 获取用户主页展示用分享
 			user.GET("profile/:id",
 				middleware.HashID(hashid.UserID),
@@ -217,6 +233,12 @@ func InitMasterRouter() *gin.Engine {
 					controllers.OneDriveOAuth,
 				)
 			}
+			// OpenID Connect 授权回调
+			callback.GET(
+				"oidc",
+				middleware.IsFunctionEnabled("oidc_enabled"),
+				controllers.OIDCCallback,
+			)
 			// 腾讯云COS策略上传回调
 			callback.GET(
 				"cos/:key",
@@ -239,6 +261,7 @@ func InitMasterRouter() *gin.Engine {
 			// 创建文件下载会话
 			share.PUT("download/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDownload,
 			)
@@ -246,6 +269,7 @@ func InitMasterRouter() *gin.Engine {
 			share.GET("preview/:id",
 				middleware.CSRFCheck(),
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShare,
@@ -253,6 +277,7 @@ func InitMasterRouter() *gin.Engine {
 			// 取得Office文档预览地址
 			share.GET("doc/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDocPreview,
@@ -260,31 +285,42 @@ func InitMasterRouter() *gin.Engine {
 			// 获取文本文件内容
 			share.GET("content/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShareText,
 			)
 			// 分享目录列文件
 			share.GET("list/:id/*path",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				controllers.ListSharedFolder,
 			)
 			// 归档打包下载
 			share.POST("archive/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				middleware.BeforeShareDownload(),
 				controllers.ArchiveShare,
 			)
 			// 获取README文本文件内容
 			share.GET("readme/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				controllers.PreviewShareReadme,
 			)
 			// 获取缩略图
 			share.GET("thumb/:id/:file",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareCanBrowse(),
 				middleware.ShareCanPreview(),
 				controllers.ShareThumb,
 			)
+			// 向文件收集上传文件
+			share.POST("upload/:id",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareIsFileRequest(),
+				controllers.UploadToShare,
+			)
 			// 搜索公共分享
 			v3.Group("share").GET("search", controllers.SearchShare)
 		}
@@ -293,8 +329,11 @@ func InitMasterRouter() *gin.Engine {
 		auth := v3.Group("")
 		auth.Use(middleware.AuthRequired())
 		{
+			// 使用 API 令牌访问时，修改文件的接口需要写入权限
+			write := middleware.TokenScope(model.ScopeFileWrite)
+
 			// 管理
-			admin := auth.Group("admin", middleware.IsAdmin())
+			admin := auth.Group("admin", middleware.IsAdmin(), middleware.TokenScope(model.ScopeAdmin))
 			{
 				// 获取站点概况
 				admin.GET("summary", controllers.AdminSummary)
@@ -327,6 +366,8 @@ func InitMasterRouter() *gin.Engine {
 					policy.POST("test/path", controllers.AdminTestPath)
 					// 测试从机通信
 					policy.POST("test/slave", controllers.AdminTestSlave)
+					// 测试SFTP连接
+					policy.POST("test/sftp", controllers.AdminTestSFTP)
 					// 创建存储策略
 					policy.POST("", controllers.AdminAddPolicy)
 					// 创建跨域策略
@@ -366,6 +407,10 @@ func InitMasterRouter() *gin.Engine {
 					user.POST("delete", controllers.AdminDeleteUser)
 					// 封禁/解封用户
 					user.PATCH("ban/:id", controllers.AdminBanUser)
+					// 列出登录失败次数过多被锁定的用户
+					user.POST("locked", controllers.AdminListLockedUser)
+					// 解除登录锁定
+					user.PATCH("unlock/:id", controllers.AdminUnlockUser)
 				}
 
 				file := admin.Group("file")
@@ -403,8 +448,36 @@ func InitMasterRouter() *gin.Engine {
 					task.POST("list", controllers.AdminListTask)
 					// 删除
 					task.POST("delete", controllers.AdminDeleteTask)
+					// 取消
+					task.POST("cancel", controllers.AdminCancelTask)
+					// 重试
+					task.POST("retry", controllers.AdminRetryTask)
+					// 设定优先级
+					task.PATCH("priority", controllers.AdminSetTaskPriority)
 					// 新建文件导入任务
 					task.POST("import", controllers.AdminCreateImportTask)
+					// 新建存储策略迁移任务
+					task.POST("migrate", controllers.AdminCreateMigrateTask)
+					// 新建存储加密任务
+					task.POST("encrypt", controllers.AdminCreateEncryptTask)
+				}
+
+				audit := admin.Group("audit")
+				{
+					// 列出审计日志
+					audit.POST("list", controllers.AdminListAuditLog)
+					// 导出审计日志
+					audit.POST("export", controllers.AdminExportAuditLog)
+				}
+
+				storage := admin.Group("storage")
+				{
+					// 容量使用排行
+					storage.GET("top", controllers.AdminStorageTop)
+					// 容量增长统计
+					storage.GET("growth", controllers.AdminStorageGrowth)
+					// 用户容量使用明细
+					storage.GET("user/:id", controllers.AdminStorageUser)
 				}
 
 			}
@@ -416,11 +489,14 @@ func InitMasterRouter() *gin.Engine {
 				user.GET("me", controllers.UserMe)
 				// 存储信息
 				user.GET("storage", controllers.UserStorage)
+				// 按文件类型统计的容量使用
+				user.GET("storage/usage", controllers.UserStorageUsage)
 				// 退出登录
 				user.DELETE("session", controllers.UserSignOut)
 
 				// WebAuthn 注册相关
 				authn := user.Group("authn",
+					middleware.SessionRequired(),
 					middleware.IsFunctionEnabled("authn_enabled"))
 				{
 					authn.PUT("", controllers.StartRegAuthn)
@@ -428,10 +504,14 @@ func InitMasterRouter() *gin.Engine {
 				}
 
 				// 用户设置
-				setting := user.Group("setting")
+				setting := user.Group("setting", middleware.SessionRequired())
 				{
 					// 任务队列
 					setting.GET("tasks", controllers.UserTasks)
+					// 取消任务
+					setting.POST("tasks/:id/cancel", controllers.UserCancelTask)
+					// 重试任务
+					setting.POST("tasks/:id/retry", controllers.UserRetryTask)
 					// 获取当前用户设定
 					setting.GET("", controllers.UserSetting)
 					// 从文件上传头像
@@ -442,20 +522,36 @@ func InitMasterRouter() *gin.Engine {
 					setting.PATCH(":option", controllers.UpdateOption)
 					// 获得二步验证初始化信息
 					setting.GET("2fa", controllers.UserInit2FA)
+					// 列出 API 令牌
+					setting.GET("tokens", controllers.GetAPITokens)
+					// 创建 API 令牌
+					setting.POST("tokens", controllers.CreateAPIToken)
+					// 吊销 API 令牌
+					setting.DELETE("tokens/:id", controllers.DeleteAPIToken)
 				}
 			}
 
 			// 文件
-			file := auth.Group("file", middleware.HashID(hashid.FileID))
+			file := auth.Group("file", middleware.HashID(hashid.FileID), middleware.TokenScope(model.ScopeFileRead))
 			{
 				// 文件上传
-				file.POST("upload", controllers.FileUploadStream)
+				file.POST("upload", write, controllers.FileUploadStream)
 				// 获取上传凭证
-				file.GET("upload/credential", controllers.GetUploadCredential)
+				file.GET("upload/credential", write, controllers.GetUploadCredential)
+				// 创建分片上传会话
+				file.POST("upload/session", write, controllers.CreateUploadSession)
+				// 获取分片上传会话已接收的分片
+				file.GET("upload/session/:sessionId", write, controllers.GetUploadSession)
+				// 上传分片
+				file.PUT("upload/session/:sessionId/:index", write, controllers.UploadChunk)
+				// 合并分片并完成上传
+				file.POST("upload/session/:sessionId", write, controllers.CompleteUploadSession)
+				// 取消分片上传会话
+				file.DELETE("upload/session/:sessionId", write, controllers.DeleteUploadSession)
 				// 更新文件
-				file.PUT("update/:id", controllers.PutContent)
+				file.PUT("update/:id", write, controllers.PutContent)
 				// 创建空白文件
-				file.POST("create", controllers.CreateFile)
+				file.POST("create", write, controllers.CreateFile)
 				// 创建文件下载会话
 				file.PUT("download/:id", controllers.CreateDownloadSession)
 				// 预览文件
@@ -471,24 +567,34 @@ func InitMasterRouter() *gin.Engine {
 				// 打包要下载的文件
 				file.POST("archive", controllers.Archive)
 				// 创建文件压缩任务
-				file.POST("compress", controllers.Compress)
+				file.POST("compress", write, controllers.Compress)
 				// 创建文件解压缩任务
-				file.POST("decompress", controllers.Decompress)
+				file.POST("decompress", write, controllers.Decompress)
+				// 列出压缩包内容
+				file.GET("decompress/entries", controllers.ListArchive)
 				// 创建文件解压缩任务
 				file.GET("search/:type/:keywords", controllers.SearchFile)
+				// 列出文件历史版本
+				file.GET("version/:id", controllers.ListFileVersions)
+				// 创建历史版本下载会话
+				file.PUT("version/:id/:version/download", controllers.CreateVersionDownloadSession)
+				// 恢复至历史版本
+				file.PATCH("version/:id/:version", write, controllers.RestoreFileVersion)
+				// 删除历史版本
+				file.DELETE("version/:id", write, controllers.DeleteFileVersions)
 			}
 
 			// 离线下载任务
-			aria2 := auth.Group("aria2")
+			aria2 := auth.Group("aria2", middleware.TokenScope(model.ScopeFileRead))
 			{
 				// 创建URL下载任务
-				aria2.POST("url", controllers.AddAria2URL)
+				aria2.POST("url", write, controllers.AddAria2URL)
 				// 创建种子下载任务
-				aria2.POST("torrent/:id", middleware.HashID(hashid.FileID), controllers.AddAria2Torrent)
+				aria2.POST("torrent/:id", write, middleware.HashID(hashid.FileID), controllers.AddAria2Torrent)
 				// 重新选择要下载的文件
-				aria2.PUT("select/:gid", controllers.SelectAria2File)
+				aria2.PUT("select/:gid", write, controllers.SelectAria2File)
 				// 取消下载任务
-				aria2.DELETE("task/:gid", controllers.CancelAria2Download)
+				aria2.DELETE("task/:gid", write, controllers.CancelAria2Download)
 				// 获取正在下载中的任务
 				aria2.GET("downloading", controllers.ListDownloading)
 				// 获取已完成的任务
@@ -496,16 +602,16 @@ func InitMasterRouter() *gin.Engine {
 			}
 
 			// 目录
-			directory := auth.Group("directory")
+			directory := auth.Group("directory", middleware.TokenScope(model.ScopeFileRead))
 			{
 				// 创建目录
-				directory.PUT("", controllers.CreateDirectory)
+				directory.PUT("", write, controllers.CreateDirectory)
 				// 列出目录下内容
 				directory.GET("*path", controllers.ListDirectory)
 			}
 
 			// 对象，文件和目录的抽象
-			object := auth.Group("object")
+			object := auth.Group("object", write)
 			{
 				// 删除对象
 				object.DELETE("", controllers.Delete)
@@ -517,13 +623,28 @@ func InitMasterRouter() *gin.Engine {
 				object.POST("rename", controllers.Rename)
 			}
 
+			// 回收站
+			trash := auth.Group("trash", middleware.TokenScope(model.ScopeFileRead))
+			{
+				// 列出回收站中的对象
+				trash.GET("", controllers.ListTrash)
+				// 恢复对象
+				trash.PATCH("", write, controllers.RestoreTrash)
+				// 彻底删除对象
+				trash.DELETE("", write, controllers.PurgeTrash)
+				// 清空回收站
+				trash.DELETE("all", write, controllers.EmptyTrash)
+			}
+
 			// 分享
-			share := auth.Group("share")
+			share := auth.Group("share", middleware.TokenScope(model.ScopeShare))
 			{
 				// 创建新分享
 				share.POST("", controllers.CreateShare)
 				// 列出我的分享
 				share.GET("", controllers.ListShare)
+				// 分享访问统计
+				share.GET("stats/:id", controllers.GetShareStats)
 				// 更新分享属性
 				share.PATCH(":id",
 					middleware.ShareAvailable(),
@@ -536,8 +657,23 @@ func InitMasterRouter() *gin.Engine {
 				)
 			}
 
+			// 站内共享
+			internalShare := auth.Group("internal_share", middleware.TokenScope(model.ScopeShare))
+			{
+				// 列出我创建的站内共享
+				internalShare.GET("", controllers.ListInternalShare)
+				// 将目录共享给用户或用户组
+				internalShare.POST("", controllers.CreateInternalShare)
+				// 列出可共享的用户组
+				internalShare.GET("groups", controllers.ListInternalShareGroups)
+				// 更新共享权限
+				internalShare.PATCH(":id", controllers.UpdateInternalShare)
+				// 取消共享
+				internalShare.DELETE(":id", controllers.DeleteInternalShare)
+			}
+
 			// 用户标签
-			tag := auth.Group("tag")
+			tag := auth.Group("tag", write)
 			{
 				// 创建文件分类标签
 				tag.POST("filter", controllers.CreateFilterTag)
@@ -548,7 +684,7 @@ func InitMasterRouter() *gin.Engine {
 			}
 
 			// WebDAV管理相关
-			webdav := auth.Group("webdav")
+			webdav := auth.Group("webdav", middleware.SessionRequired())
 			{
 				// 获取账号信息
 				webdav.GET("accounts", controllers.GetWebDAVAccounts)
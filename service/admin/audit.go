@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"encoding/csv"
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// AuditLogFilter 审计日志筛选条件
+type AuditLogFilter struct {
+	OrderBy    string            `json:"order_by"`
+	Conditions map[string]string `json:"conditions"`
+	Searches   map[string]string `json:"searches"`
+	After      *time.Time        `json:"after"`
+	Before     *time.Time        `json:"before"`
+}
+
+// AuditLogListService 审计日志列表服务
+type AuditLogListService struct {
+	AuditLogFilter
+	Page     int `json:"page" binding:"min=1,required"`
+	PageSize int `json:"page_size" binding:"min=1,required"`
+}
+
+// auditLogColumns 可用于排序、筛选及搜索的审计日志字段
+var auditLogColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"user_id":    true,
+	"actor":      true,
+	"ip":         true,
+	"user_agent": true,
+	"action":     true,
+	"target":     true,
+	"result":     true,
+	"detail":     true,
+}
+
+// query 构建筛选审计日志的查询，字段不在 auditLogColumns 中时返回错误
+func (service *AuditLogFilter) query() (*gorm.DB, error) {
+	tx := model.DB.Model(&model.AuditLog{})
+	if service.OrderBy != "" {
+		order := strings.Fields(strings.ToLower(service.OrderBy))
+		if len(order) == 0 || len(order) > 2 || !auditLogColumns[order[0]] ||
+			(len(order) == 2 && order[1] != "asc" && order[1] != "desc") {
+			return nil, fmt.Errorf("不支持的排序方式 %q", service.OrderBy)
+		}
+		tx = tx.Order(strings.Join(order, " "))
+	} else {
+		tx = tx.Order("id desc")
+	}
+
+	for k, v := range service.Conditions {
+		if !auditLogColumns[k] {
+			return nil, fmt.Errorf("不支持的筛选字段 %q", k)
+		}
+		tx = tx.Where(k+" = ?", v)
+	}
+
+	if len(service.Searches) > 0 {
+		var (
+			search []string
+			args   []interface{}
+		)
+		for k, v := range service.Searches {
+			if !auditLogColumns[k] {
+				return nil, fmt.Errorf("不支持的搜索字段 %q", k)
+			}
+			search = append(search, k+" like ?")
+			args = append(args, "%"+v+"%")
+		}
+		tx = tx.Where(strings.Join(search, " OR "), args...)
+	}
+
+	// 时间范围
+	if service.After != nil {
+		tx = tx.Where("created_at >= ?", *service.After)
+	}
+	if service.Before != nil {
+		tx = tx.Where("created_at < ?", *service.Before)
+	}
+
+	return tx, nil
+}
+
+// AuditLogs 列出审计日志
+func (service *AuditLogListService) AuditLogs() serializer.Response {
+	var res []model.AuditLog
+	total := 0
+
+	tx, err := service.query()
+	if err != nil {
+		return serializer.ParamErr(err.Error(), nil)
+	}
+
+	// 计算总数用于分页
+	tx.Count(&total)
+
+	// 查询记录
+	if err := tx.Limit(service.PageSize).Offset((service.Page - 1) * service.PageSize).Find(&res).Error; err != nil {
+		return serializer.DBErr("无法列出审计日志", err)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
+
+// Export 将符合条件的审计日志导出为 CSV
+func (service *AuditLogFilter) Export(c *gin.Context) serializer.Response {
+	tx, err := service.query()
+	if err != nil {
+		return serializer.ParamErr(err.Error(), nil)
+	}
+	rows, err := tx.Rows()
+	if err != nil {
+		return serializer.DBErr("无法列出审计日志", err)
+	}
+	defer rows.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"audit_%s.csv\"", time.Now().Format("20060102150405")))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+
+	// 写入 BOM，便于表格软件识别编码
+	c.Writer.WriteString("\xEF\xBB\xBF")
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"ID", "时间", "用户ID", "操作者", "IP", "User-Agent", "操作", "对象", "结果", "详情"})
+
+	for rows.Next() {
+		var log model.AuditLog
+		if err := model.DB.ScanRows(rows, &log); err != nil {
+			util.Log().Warning("无法读取审计日志, %s", err)
+			break
+		}
+		writer.Write([]string{
+			fmt.Sprint(log.ID),
+			log.CreatedAt.Format(time.RFC3339),
+			fmt.Sprint(log.UserID),
+			csvCell(log.Actor),
+			log.IP,
+			csvCell(log.UserAgent),
+			log.Action,
+			csvCell(log.Target),
+			log.Result,
+			csvCell(log.Detail),
+		})
+	}
+
+	writer.Flush()
+	return serializer.Response{}
+}
+
+// csvCell 转义以公式字符开头的单元格，避免导出的内容在表格软件中被当作公式执行
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
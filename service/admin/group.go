@@ -50,6 +50,9 @@ func (service *GroupService) Delete() serializer.Response {
 
 	model.DB.Delete(&group)
 
+	// 删除共享给此用户组的站内共享
+	model.DB.Where("target_group_id = ?", group.ID).Delete(&model.InternalShare{})
+
 	return serializer.Response{}
 }
 
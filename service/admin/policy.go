@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
@@ -16,10 +17,13 @@ import (
 	"github.com/HFO4/cloudreve/pkg/auth"
 	"github.com/HFO4/cloudreve/pkg/cache"
 	"github.com/HFO4/cloudreve/pkg/conf"
+	"github.com/HFO4/cloudreve/pkg/encrypt"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/cos"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/onedrive"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/oss"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/s3"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/sftp"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/webdav"
 	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
@@ -27,9 +31,14 @@ import (
 	cossdk "github.com/tencentyun/cos-go-sdk-v5"
 )
 
-// PathTestService 本地路径测试服务
+// PathTestService 存储路径测试服务，Type 为 webdav 时测试WebDAV服务器上的路径，
+// 否则测试本机路径
 type PathTestService struct {
-	Path string `json:"path" binding:"required"`
+	Path      string `json:"path" binding:"required"`
+	Type      string `json:"type"`
+	Server    string `json:"server"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
 }
 
 // SlaveTestService 从机测试服务
@@ -38,6 +47,11 @@ type SlaveTestService struct {
 	Server string `json:"server" binding:"required"`
 }
 
+// SFTPTestService SFTP连接测试服务
+type SFTPTestService struct {
+	Policy model.Policy `json:"policy" binding:"required"`
+}
+
 // SlavePingService 从机相应ping
 type SlavePingService struct {
 	Callback string `json:"callback" binding:"required"`
@@ -86,6 +100,17 @@ func (service *PolicyService) Delete() serializer.Response {
 		return serializer.ParamErr(fmt.Sprintf("有 %d 个用户组绑定了此存储策略，请先解除绑定", len(groups)), nil)
 	}
 
+	// 检查是否被用作副本存储策略
+	total = 0
+	model.DB.Model(&model.Policy{}).Where("replica_policy_id = ?", service.ID).Count(&total)
+	if total > 0 {
+		return serializer.ParamErr(fmt.Sprintf("有 %d 个存储策略将此策略用作副本，请先解除设定", total), nil)
+	}
+	model.DB.Model(&model.FileReplica{}).Where("replica_policy_id = ?", service.ID).Count(&total)
+	if total > 0 {
+		return serializer.ParamErr(fmt.Sprintf("有 %d 个文件副本仍在使用此存储策略，请先删除这些文件", total), nil)
+	}
+
 	model.DB.Delete(&policy)
 	policy.ClearCache()
 
@@ -251,12 +276,50 @@ func (service *SlaveTestService) Test() serializer.Response {
 	return serializer.Response{}
 }
 
+// Test 测试SFTP服务器连接及存储根目录
+func (service *SFTPTestService) Test() serializer.Response {
+	if err := sftp.TestConnection(&service.Policy); err != nil {
+		return serializer.ParamErr("无法连接到SFTP服务器，"+err.Error(), nil)
+	}
+
+	return serializer.Response{}
+}
+
 // Add 添加存储策略
 func (service *AddPolicyService) Add() serializer.Response {
 	if service.Policy.Type != "local" && service.Policy.Type != "remote" {
 		service.Policy.DirNameRule = strings.TrimPrefix(service.Policy.DirNameRule, "/")
 	}
 
+	// 检查加密存储设定
+	if service.Policy.OptionsSerialized.Encrypt {
+		if service.Policy.Type != "local" && service.Policy.Type != "remote" {
+			return serializer.ParamErr("仅本机及从机存储策略支持加密存储", nil)
+		}
+		if service.Policy.Type == "local" {
+			if _, err := encrypt.MasterKey(); err != nil {
+				return serializer.ParamErr("无法开启加密存储，"+err.Error(), err)
+			}
+		}
+	}
+
+	// 检查SFTP服务器公钥
+	if service.Policy.Type == "sftp" {
+		if _, err := sftp.ParseHostKey(service.Policy.OptionsSerialized.HostKey); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	}
+
+	// 检查副本存储策略
+	if service.Policy.ReplicaPolicyID != 0 {
+		if service.Policy.ReplicaPolicyID == service.Policy.ID {
+			return serializer.ParamErr("副本存储策略不能为自身", nil)
+		}
+		if _, err := model.GetPolicyByID(service.Policy.ReplicaPolicyID); err != nil {
+			return serializer.ParamErr("副本存储策略不存在", err)
+		}
+	}
+
 	if service.Policy.ID > 0 {
 		if err := model.DB.Save(&service.Policy).Error; err != nil {
 			return serializer.ParamErr("存储策略保存失败", err)
@@ -277,6 +340,11 @@ func (service *PathTestService) Test() serializer.Response {
 	policy := model.Policy{DirNameRule: service.Path}
 	path := policy.GeneratePath(1, "/My File")
 	path = filepath.Join(path, "test.txt")
+
+	if service.Type == "webdav" {
+		return service.testWebDAV(filepath.ToSlash(path))
+	}
+
 	file, err := util.CreatNestedFile(util.RelativePath(path))
 	if err != nil {
 		return serializer.ParamErr(fmt.Sprintf("无法创建路径 %s , %s", path, err.Error()), nil)
@@ -288,6 +356,28 @@ func (service *PathTestService) Test() serializer.Response {
 	return serializer.Response{}
 }
 
+// testWebDAV 在WebDAV服务器上创建并删除测试文件
+func (service *PathTestService) testWebDAV(path string) serializer.Response {
+	handler := webdav.Driver{
+		Policy: &model.Policy{
+			Server:    service.Server,
+			AccessKey: service.AccessKey,
+			SecretKey: service.SecretKey,
+		},
+		HTTPClient: request.HTTPClient{},
+	}
+
+	ctx := context.Background()
+	if err := handler.Put(ctx, ioutil.NopCloser(strings.NewReader("")), path, 0); err != nil {
+		return serializer.ParamErr(fmt.Sprintf("无法创建路径 %s , %s", path, err.Error()), nil)
+	}
+	if _, err := handler.Delete(ctx, []string{path}); err != nil {
+		return serializer.ParamErr(fmt.Sprintf("无法删除测试文件 %s , %s", path, err.Error()), nil)
+	}
+
+	return serializer.Response{}
+}
+
 // Policies 列出存储策略
 func (service *AdminListService) Policies() serializer.Response {
 	var res []model.Policy
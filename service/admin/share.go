@@ -18,6 +18,9 @@ func (service *ShareBatchService) Delete(c *gin.Context) serializer.Response {
 	if err := model.DB.Where("id in (?)", service.ID).Delete(&model.Share{}).Error; err != nil {
 		return serializer.DBErr("无法删除分享", err)
 	}
+	if err := model.DeleteShareAccesses(service.ID); err != nil {
+		return serializer.DBErr("无法删除分享访问记录", err)
+	}
 	return serializer.Response{}
 }
 
@@ -5,6 +5,7 @@ import (
 	"github.com/HFO4/cloudreve/pkg/cache"
 	"github.com/HFO4/cloudreve/pkg/conf"
 	"github.com/HFO4/cloudreve/pkg/email"
+	"github.com/HFO4/cloudreve/pkg/hashid"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"time"
 )
@@ -18,6 +19,15 @@ type BatchSettingChangeService struct {
 	Options []SettingChangeService `json:"options"`
 }
 
+// Keys 待更改的设置项名称
+func (service *BatchSettingChangeService) Keys() []string {
+	keys := make([]string, 0, len(service.Options))
+	for _, setting := range service.Options {
+		keys = append(keys, setting.Key)
+	}
+	return keys
+}
+
 // SettingChangeService  设定更改服务
 type SettingChangeService struct {
 	Key   string `json:"key" binding:"required"`
@@ -74,10 +84,13 @@ func (service *NoParamService) Summary() serializer.Response {
 	files := make([]int, total)
 	users := make([]int, total)
 	shares := make([]int, total)
+	shareViews := make([]int, total)
+	shareDownloads := make([]int, total)
 	date := make([]string, total)
 
 	toRound := time.Now()
 	timeBase := time.Date(toRound.Year(), toRound.Month(), toRound.Day()+1, 0, 0, 0, 0, toRound.Location())
+	accessCounts := model.CountShareAccessesByDay(0, timeBase.Add(-time.Duration(total)*time.Hour*24))
 	for day := range files {
 		start := timeBase.Add(-time.Duration(total-day) * time.Hour * 24)
 		end := timeBase.Add(-time.Duration(total-day-1) * time.Hour * 24)
@@ -85,6 +98,8 @@ func (service *NoParamService) Summary() serializer.Response {
 		model.DB.Model(&model.User{}).Where("created_at BETWEEN ? AND ?", start, end).Count(&users[day])
 		model.DB.Model(&model.File{}).Where("created_at BETWEEN ? AND ?", start, end).Count(&files[day])
 		model.DB.Model(&model.Share{}).Where("created_at BETWEEN ? AND ?", start, end).Count(&shares[day])
+		shareViews[day] = accessCounts[start.Format("2006-01-02")][model.ShareAccessView]
+		shareDownloads[day] = accessCounts[start.Format("2006-01-02")][model.ShareAccessDownload]
 	}
 
 	// 统计总数
@@ -97,6 +112,24 @@ func (service *NoParamService) Summary() serializer.Response {
 	model.DB.Model(&model.Share{}).Where("password = ?", "").Count(&publicShareTotal)
 	model.DB.Model(&model.Share{}).Where("password <> ?", "").Count(&secretShareTotal)
 
+	// 分享访问统计
+	var shareTotal struct {
+		Views     int
+		Downloads int
+	}
+	model.DB.Model(&model.Share{}).Select("sum(views) as views, sum(downloads) as downloads").Scan(&shareTotal)
+	var hotShares []model.Share
+	model.DB.Order("downloads desc").Limit(5).Find(&hotShares)
+	topShares := make([]map[string]interface{}, 0, len(hotShares))
+	for _, share := range hotShares {
+		topShares = append(topShares, map[string]interface{}{
+			"key":       hashid.HashID(share.ID, hashid.ShareID),
+			"name":      share.SourceName,
+			"views":     share.Views,
+			"downloads": share.Downloads,
+		})
+	}
+
 	// 获取版本信息
 	versions := map[string]string{
 		"backend": conf.BackendVersion,
@@ -111,12 +144,17 @@ func (service *NoParamService) Summary() serializer.Response {
 			"files":            files,
 			"users":            users,
 			"shares":           shares,
+			"shareViews":       shareViews,
+			"shareDownloads":   shareDownloads,
 			"version":          versions,
 			"siteURL":          model.GetSettingByName("siteURL"),
 			"fileTotal":        fileTotal,
 			"userTotal":        userTotal,
 			"publicShareTotal": publicShareTotal,
 			"secretShareTotal": secretShareTotal,
+			"shareViewTotal":   shareTotal.Views,
+			"shareDownTotal":   shareTotal.Downloads,
+			"topShares":        topShares,
 		},
 	}
 }
@@ -0,0 +1,162 @@
+package admin
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"time"
+)
+
+// StorageTopService 容量使用排行服务
+type StorageTopService struct {
+	Scope string `form:"scope" binding:"required,eq=user|eq=group|eq=policy|eq=type"`
+	Limit int    `form:"limit" binding:"min=0,max=100"`
+}
+
+// StorageGrowthService 容量增长统计服务
+type StorageGrowthService struct {
+	Scope string `form:"scope" binding:"required,eq=site|eq=user|eq=group|eq=policy|eq=type"`
+	ID    uint   `form:"id"`
+	Type  string `form:"type"`
+	Days  int    `form:"days" binding:"min=0,max=365"`
+}
+
+// StorageUserService 用户容量使用明细服务
+type StorageUserService struct {
+	ID     uint   `uri:"id" binding:"required"`
+	Folder string `form:"folder"`
+}
+
+// Top 列出最近一次快照中容量使用最多的用户、用户组、存储策略或文件类型
+func (service *StorageTopService) Top() serializer.Response {
+	limit := service.Limit
+	if limit == 0 {
+		limit = 10
+	}
+
+	snapshots, err := model.LatestStorageSnapshots(service.Scope, limit)
+	if err != nil {
+		return serializer.DBErr("无法读取容量快照", err)
+	}
+
+	var snapshotAt *time.Time
+	if len(snapshots) > 0 {
+		snapshotAt = &snapshots[0].CreatedAt
+	}
+
+	items := make([]model.StorageUsage, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		item := model.StorageUsage{
+			TargetID: snapshot.TargetID,
+			Type:     snapshot.Type,
+			Size:     snapshot.Size,
+			Files:    snapshot.Files,
+		}
+		switch service.Scope {
+		case model.StorageScopeUser:
+			if user, err := model.GetUserByID(snapshot.TargetID); err == nil {
+				item.Name = user.Email
+			}
+		case model.StorageScopeGroup:
+			if group, err := model.GetGroupByID(snapshot.TargetID); err == nil {
+				item.Name = group.Name
+			}
+		case model.StorageScopePolicy:
+			if policy, err := model.GetPolicyByID(snapshot.TargetID); err == nil {
+				item.Name = policy.Name
+			}
+		}
+		items = append(items, item)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"items":       items,
+		"snapshot_at": snapshotAt,
+	}}
+}
+
+// Growth 列出容量使用随时间的变化
+func (service *StorageGrowthService) Growth() serializer.Response {
+	days := service.Days
+	if days == 0 {
+		days = 30
+	}
+
+	typ := ""
+	if service.Scope == model.StorageScopeType {
+		typ = service.Type
+		if typ == "" {
+			return serializer.ParamErr("需指定文件类型", nil)
+		}
+	}
+
+	snapshots, err := model.ListStorageSnapshots(service.Scope, service.ID, typ, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return serializer.DBErr("无法读取容量快照", err)
+	}
+
+	date := make([]string, 0, len(snapshots))
+	size := make([]uint64, 0, len(snapshots))
+	files := make([]int, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		date = append(date, snapshot.CreatedAt.Format("2006-01-02 15:04"))
+		size = append(size, snapshot.Size)
+		files = append(files, snapshot.Files)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"date":  date,
+		"size":  size,
+		"files": files,
+	}}
+}
+
+// Breakdown 统计用户各目录及各文件类型的容量使用，未指定目录时统计根目录
+func (service *StorageUserService) Breakdown() serializer.Response {
+	user, err := model.GetUserByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "用户不存在", err)
+	}
+
+	var folderID uint
+	if service.Folder != "" {
+		folderID, err = hashid.DecodeHashID(service.Folder, hashid.FolderID)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "目录不存在", err)
+		}
+	} else {
+		root, err := user.Root()
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "目录不存在", err)
+		}
+		folderID = root.ID
+	}
+
+	folders, err := model.StorageUsageByFolder(user.ID, folderID)
+	if err != nil {
+		return serializer.DBErr("无法统计容量使用", err)
+	}
+	types, err := model.StorageUsageByType(user.ID, search.TypeExtensions)
+	if err != nil {
+		return serializer.DBErr("无法统计容量使用", err)
+	}
+
+	folderItems := make([]map[string]interface{}, 0, len(folders))
+	for _, folder := range folders {
+		folderItems = append(folderItems, map[string]interface{}{
+			"id":    hashid.HashID(folder.TargetID, hashid.FolderID),
+			"name":  folder.Name,
+			"size":  folder.Size,
+			"files": folder.Files,
+			// 直接位于所统计目录下的文件
+			"self": folder.TargetID == folderID,
+		})
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"storage": user.Storage,
+		"folders": folderItems,
+		"types":   types,
+	}}
+}
@@ -13,6 +13,12 @@ type TaskBatchService struct {
 	ID []uint `json:"id" binding:"min=1"`
 }
 
+// TaskPriorityService 任务优先级设定服务
+type TaskPriorityService struct {
+	ID       uint `json:"id" binding:"required"`
+	Priority int  `json:"priority" binding:"min=-1,max=1"`
+}
+
 // ImportTaskService 导入任务
 type ImportTaskService struct {
 	UID       uint   `json:"uid" binding:"required"`
@@ -22,6 +28,19 @@ type ImportTaskService struct {
 	Recursive bool   `json:"recursive"`
 }
 
+// MigrateTaskService 存储策略迁移任务
+type MigrateTaskService struct {
+	Scope        string `json:"scope" binding:"required,eq=user|eq=group|eq=policy"`
+	TargetID     uint   `json:"target_id" binding:"required"`
+	PolicyID     uint   `json:"policy_id" binding:"required"`
+	DeleteSource bool   `json:"delete_source"`
+}
+
+// EncryptTaskService 存储加密任务
+type EncryptTaskService struct {
+	PolicyID uint `json:"policy_id" binding:"required"`
+}
+
 // Create 新建导入任务
 func (service *ImportTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
 	// 创建任务
@@ -33,6 +52,43 @@ func (service *ImportTaskService) Create(c *gin.Context, user *model.User) seria
 	return serializer.Response{}
 }
 
+// Create 新建存储策略迁移任务
+func (service *MigrateTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if service.Scope == model.StorageScopePolicy && service.TargetID == service.PolicyID {
+		return serializer.ParamErr("目标存储策略与原存储策略相同", nil)
+	}
+	if _, err := model.GetPolicyByID(service.PolicyID); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "存储策略不存在", err)
+	}
+
+	// 创建任务
+	job, err := task.NewMigrateTask(user.ID, service.Scope, service.TargetID, service.PolicyID, service.DeleteSource)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任务创建失败", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
+// Create 新建存储加密任务
+func (service *EncryptTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	policy, err := model.GetPolicyByID(service.PolicyID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "存储策略不存在", err)
+	}
+	if !policy.OptionsSerialized.Encrypt {
+		return serializer.ParamErr("存储策略未开启加密存储", nil)
+	}
+
+	// 创建任务
+	job, err := task.NewEncryptTask(user.ID, service.PolicyID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "任务创建失败", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
 // Delete 删除任务
 func (service *TaskBatchService) Delete(c *gin.Context) serializer.Response {
 	if err := model.DB.Where("id in (?)", service.ID).Delete(&model.Download{}).Error; err != nil {
@@ -49,6 +105,46 @@ func (service *TaskBatchService) DeleteGeneral(c *gin.Context) serializer.Respon
 	return serializer.Response{}
 }
 
+// Cancel 批量取消常规任务
+func (service *TaskBatchService) Cancel(c *gin.Context) serializer.Response {
+	for _, id := range service.ID {
+		record, err := model.GetTasksByID(id)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "任务不存在", err)
+		}
+		if err := task.Cancel(record); err != nil {
+			return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+		}
+	}
+	return serializer.Response{}
+}
+
+// Retry 批量重试常规任务
+func (service *TaskBatchService) Retry(c *gin.Context) serializer.Response {
+	for _, id := range service.ID {
+		record, err := model.GetTasksByID(id)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "任务不存在", err)
+		}
+		if err := task.Retry(record); err != nil {
+			return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+		}
+	}
+	return serializer.Response{}
+}
+
+// Set 设定常规任务优先级
+func (service *TaskPriorityService) Set(c *gin.Context) serializer.Response {
+	record, err := model.GetTasksByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "任务不存在", err)
+	}
+	if err := task.SetPriority(record, service.Priority); err != nil {
+		return serializer.DBErr("无法设定任务优先级", err)
+	}
+	return serializer.Response{}
+}
+
 // Tasks 列出常规任务
 func (service *AdminListService) Tasks() serializer.Response {
 	var res []model.Task
@@ -4,6 +4,7 @@ import (
 	"context"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/lockout"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"strings"
 )
@@ -44,6 +45,40 @@ func (service *UserService) Ban() serializer.Response {
 	return serializer.Response{Data: user.Status}
 }
 
+// Unlock 解除用户因登录失败次数过多导致的锁定
+func (service *UserService) Unlock() serializer.Response {
+	lockout.Unlock(service.ID)
+	return serializer.Response{}
+}
+
+// LockedUsers 列出因登录失败次数过多被锁定的用户
+func (service *NoParamService) LockedUsers() serializer.Response {
+	locked := lockout.LockedUsers()
+	if len(locked) == 0 {
+		return serializer.Response{Data: []interface{}{}}
+	}
+
+	ids := make([]uint, 0, len(locked))
+	for uid := range locked {
+		ids = append(ids, uid)
+	}
+
+	var users []model.User
+	if err := model.DB.Where("id in (?)", ids).Find(&users).Error; err != nil {
+		return serializer.DBErr("无法列出被锁定的用户", err)
+	}
+
+	res := make([]map[string]interface{}, 0, len(users))
+	for _, user := range users {
+		res = append(res, map[string]interface{}{
+			"user":         user,
+			"locked_until": locked[user.ID],
+		})
+	}
+
+	return serializer.Response{Data: res}
+}
+
 // Delete 删除用户
 func (service *UserBatchService) Delete() serializer.Response {
 	for _, uid := range service.ID {
@@ -60,11 +95,12 @@ func (service *UserBatchService) Delete() serializer.Response {
 		// 删除与此用户相关的所有资源
 
 		fs, err := filesystem.NewFileSystem(&user)
-		// 删除所有文件
+		// 删除回收站及所有文件
 		root, err := fs.User.Root()
 		if err != nil {
 			return serializer.Err(serializer.CodeNotFound, "无法找到用户根目录", err)
 		}
+		fs.EmptyTrash(context.Background())
 		fs.Delete(context.Background(), []uint{root.ID}, []uint{}, false)
 
 		// 删除相关任务
@@ -77,6 +113,21 @@ func (service *UserBatchService) Delete() serializer.Response {
 		// 删除WebDAV账号
 		model.DB.Where("user_id = ?", uid).Delete(&model.Webdav{})
 
+		// 删除 API 令牌
+		model.DB.Unscoped().Where("user_id = ?", uid).Delete(&model.APIToken{})
+
+		// 删除 OpenID Connect 身份关联
+		model.DB.Unscoped().Where("user_id = ?", uid).Delete(&model.OIDCIdentity{})
+
+		// 删除 LDAP 身份关联
+		model.DB.Unscoped().Where("user_id = ?", uid).Delete(&model.LDAPIdentity{})
+
+		// 删除分享的访问记录
+		model.DeleteShareAccessesOfUser(uid)
+
+		// 删除站内共享
+		model.DB.Where("user_id = ? or target_user_id = ?", uid, uid).Delete(&model.InternalShare{})
+
 		// 删除此用户
 		model.DB.Unscoped().Delete(user)
 
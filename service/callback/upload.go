@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/cos"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
@@ -12,6 +13,7 @@ import (
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/s3"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-gonic/gin"
 )
@@ -181,6 +183,10 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 		}
 	}
 
+	// 复制到副本存储策略
+	fs.SetTargetFile(&[]model.File{*file})
+	task.HookReplicate(ctx, fs)
+
 	return serializer.Response{
 		Code: 0,
 	}
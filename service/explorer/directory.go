@@ -59,8 +59,14 @@ func (service *DirectoryService) CreateDirectory(c *gin.Context) serializer.Resp
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// 位于“与我共享”中时切换至目录所有者
+	dirPath, err := fs.EnterSharedPath(service.Path, true)
+	if err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+
 	// 创建目录
-	_, err = fs.CreateDirectory(ctx, service.Path)
+	_, err = fs.CreateDirectory(ctx, dirPath)
 	if err != nil {
 		return serializer.Err(serializer.CodeCreateFolderFailed, err.Error(), err)
 	}
@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/cache"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"io/ioutil"
@@ -47,11 +49,13 @@ type SlaveDownloadService struct {
 	PathEncoded string `uri:"path" binding:"required"`
 	Name        string `uri:"name" binding:"required"`
 	Speed       int    `uri:"speed" binding:"min=0"`
+	Encrypted   bool   `uri:"encrypted"`
 }
 
 // SlaveFileService 从机单文件文件相关服务
 type SlaveFileService struct {
 	PathEncoded string `uri:"path" binding:"required"`
+	Encrypted   bool   `uri:"encrypted"`
 }
 
 // SlaveFilesService 从机多文件相关服务
@@ -81,6 +85,7 @@ func (service *SingleFileService) Create(c *gin.Context) serializer.Response {
 	// 给文件系统分配钩子
 	fs.Use("BeforeUpload", filesystem.HookValidateFile)
 	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", task.HookReplicate)
 
 	// 上传空文件
 	err = fs.Upload(ctx, local.FileStream{
@@ -259,6 +264,7 @@ func (service *FileIDService) CreateDownloadSession(ctx context.Context, c *gin.
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	audit.Record(c, fs.User, audit.ActionDownload, path.Join(fs.FileTarget[0].Position, fs.FileTarget[0].Name), nil)
 
 	return serializer.Response{
 		Code: 0,
@@ -393,33 +399,53 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 
 	// 取得现有文件
 	fileID, _ := c.Get("object_id")
+	if err := fs.EnterSharedObjects(nil, []uint{fileID.(uint)}, true); err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
 	originFile, _ := model.GetFilesByIDs([]uint{fileID.(uint)}, fs.User.ID)
 	if len(originFile) == 0 {
 		return serializer.Err(404, "文件不存在", nil)
 	}
 	fileData.Name = originFile[0].Name
 
-	// 检查此文件是否有软链接
-	fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
-	if err == nil && len(fileList) == 0 {
-		// 如果包含软连接，应重新生成新文件副本，并更新source_name
-		originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, fileData)
-		fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-		fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-		fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-	}
+	if fs.IsVersionEnabled() {
+		// 保留历史版本，新内容写入新的物理文件，并计入完整容量
+		originFile[0].SourceName = fs.GenerateVersionSavePath(uploadCtx, fileData)
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+		fs.Use("AfterUpload", filesystem.HookSaveFileVersion)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		fs.Use("AfterUpload", task.HookReplicate)
+		fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+		fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+	} else {
+		// 检查此文件是否有软链接
+		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
+		if err == nil && len(fileList) == 0 {
+			// 如果包含软连接，应重新生成新文件副本，并更新source_name
+			originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, fileData)
+			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+		}
 
-	// 给文件系统分配钩子
-	fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-	fs.Use("BeforeUpload", filesystem.HookValidateFile)
-	fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
-	fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-	fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
-	fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-	fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-	fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
-	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+		// 给文件系统分配钩子
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookChangeCapacity)
+		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+		fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		fs.Use("AfterUpload", task.HookReplicate)
+		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+		fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	}
 
 	// 执行上传
 	uploadCtx = context.WithValue(uploadCtx, fsctx.FileModelCtx, originFile[0])
@@ -452,6 +478,7 @@ func (service *SlaveDownloadService) ServeFile(ctx context.Context, c *gin.Conte
 	file := model.File{
 		Name:       service.Name,
 		SourceName: string(fileSource),
+		Encrypted:  service.Encrypted,
 		Policy: model.Policy{
 			Model: gorm.Model{ID: 1},
 			Type:  "local",
@@ -522,7 +549,7 @@ func (service *SlaveFileService) Thumb(ctx context.Context, c *gin.Context) seri
 	if err != nil {
 		return serializer.ParamErr("无法解析的文件地址", err)
 	}
-	fs.FileTarget = []model.File{{SourceName: string(fileSource), PicInfo: "1,1"}}
+	fs.FileTarget = []model.File{{SourceName: string(fileSource), PicInfo: "1,1", Encrypted: service.Encrypted}}
 
 	// 获取缩略图
 	resp, err := fs.GetThumb(ctx, 0)
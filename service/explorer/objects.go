@@ -59,6 +59,11 @@ type ItemDecompressService struct {
 	Dst string `json:"dst" binding:"required,min=1,max=65535"`
 }
 
+// ItemArchiveListService 列出压缩包内容服务
+type ItemArchiveListService struct {
+	Src string `form:"src" binding:"required,min=1,max=65535"`
+}
+
 // Raw 批量解码HashID，获取原始ID
 func (service *ItemIDService) Raw() *ItemService {
 	if service.Source != nil {
@@ -116,9 +121,9 @@ func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) seria
 		return serializer.Err(serializer.CodeParamErr, "文件太大", nil)
 	}
 
-	// 必须是zip压缩包
-	if !strings.HasSuffix(file.Name, ".zip") {
-		return serializer.Err(serializer.CodeParamErr, "只能解压 ZIP 格式的压缩文件", nil)
+	// 必须是支持的压缩包格式
+	if !filesystem.IsArchive(file.Name) {
+		return serializer.Err(serializer.CodeParamErr, "不支持此格式的压缩文件", nil)
 	}
 
 	// 创建任务
@@ -132,6 +137,54 @@ func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) seria
 
 }
 
+// ListArchive 列出压缩包内的目录和文件
+func (service *ItemArchiveListService) ListArchive(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 检查用户组权限
+	if !fs.User.Group.OptionsSerialized.ArchiveTask {
+		return serializer.Err(serializer.CodeGroupNotAllowed, "当前用户组无法进行此操作", nil)
+	}
+
+	// 压缩包是否存在
+	exist, file := fs.IsFileExist(service.Src)
+	if !exist {
+		return serializer.Err(serializer.CodeNotFound, "文件不存在", nil)
+	}
+
+	// 文件尺寸限制，列取在请求中同步进行，用户组未限制大小时使用站点设置的上限
+	maxSize := fs.User.Group.OptionsSerialized.DecompressSize
+	if maxSize == 0 {
+		maxSize = uint64(model.GetIntSetting("archive_list_max_size", 1073741824))
+	}
+	if file.Size > maxSize {
+		return serializer.Err(serializer.CodeParamErr, "文件太大", nil)
+	}
+
+	// 必须是支持的压缩包格式
+	if !filesystem.IsArchive(file.Name) {
+		return serializer.Err(serializer.CodeParamErr, "不支持此格式的压缩文件", nil)
+	}
+
+	fs.SetTargetFile(&[]model.File{*file})
+	entries, truncated, err := fs.ListArchive(ctx, service.Src, model.GetIntSetting("archive_list_max_entries", 1000))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "无法读取压缩文件", err)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"entries":   entries,
+			"truncated": truncated,
+		},
+	}
+}
+
 // CreateCompressTask 创建文件压缩任务
 func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serializer.Response {
 	// 创建文件系统
@@ -227,6 +280,9 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 	// 开始压缩
 	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	items := service.Raw()
+	if err := fs.EnterSharedObjects(items.Dirs, items.Items, false); err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
 	zipFile, err := fs.Compress(ctx, items.Dirs, items.Items, true)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, "无法创建压缩文件", err)
@@ -258,7 +314,7 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 	}
 }
 
-// Delete 删除对象
+// Delete 删除对象，对象会先被移入回收站
 func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
 	// 创建文件系统
 	fs, err := filesystem.NewFileSystemFromContext(c)
@@ -267,9 +323,13 @@ func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serial
 	}
 	defer fs.Recycle()
 
-	// 删除对象
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	// 将对象移入回收站
 	items := service.Raw()
-	err = fs.Delete(ctx, items.Dirs, items.Items, false)
+	if err := fs.EnterSharedObjects(items.Dirs, items.Items, true); err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+	err = fs.Trash(ctx, items.Dirs, items.Items)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -289,9 +349,16 @@ func (service *ItemMoveService) Move(ctx context.Context, c *gin.Context) serial
 	}
 	defer fs.Recycle()
 
-	// 移动对象
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	// 切换至共享目录所有者
 	items := service.Src.Raw()
-	err = fs.Move(ctx, items.Dirs, items.Items, service.SrcDir, service.Dst)
+	srcDir, dst, err := service.enterShared(fs, items, true)
+	if err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+
+	// 移动对象
+	err = fs.Move(ctx, items.Dirs, items.Items, srcDir, dst)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -302,6 +369,27 @@ func (service *ItemMoveService) Move(ctx context.Context, c *gin.Context) serial
 
 }
 
+// enterShared 源目录或目标目录位于“与我共享”中时，将文件系统切换至目录所有者，
+// 返回所有者文件系统中的源目录及目标目录路径
+func (service *ItemMoveService) enterShared(fs *filesystem.FileSystem, items *ItemService, writeSrc bool) (string, string, error) {
+	if filesystem.IsSharedPath(service.SrcDir) != filesystem.IsSharedPath(service.Dst) {
+		return "", "", filesystem.ErrSharedOwnerMismatch
+	}
+
+	if err := fs.EnterSharedObjects(items.Dirs, items.Items, writeSrc); err != nil {
+		return "", "", err
+	}
+	srcDir, err := fs.EnterSharedPath(service.SrcDir, writeSrc)
+	if err != nil {
+		return "", "", err
+	}
+	dst, err := fs.EnterSharedPath(service.Dst, true)
+	if err != nil {
+		return "", "", err
+	}
+	return srcDir, dst, nil
+}
+
 // Copy 复制对象
 func (service *ItemMoveService) Copy(ctx context.Context, c *gin.Context) serializer.Response {
 	// 复制操作只能对一个目录或文件对象进行操作
@@ -316,8 +404,16 @@ func (service *ItemMoveService) Copy(ctx context.Context, c *gin.Context) serial
 	}
 	defer fs.Recycle()
 
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	// 切换至共享目录所有者，复制时源对象只需可读
+	items := service.Src.Raw()
+	srcDir, dst, err := service.enterShared(fs, items, false)
+	if err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+
 	// 复制对象
-	err = fs.Copy(ctx, service.Src.Raw().Dirs, service.Src.Raw().Items, service.SrcDir, service.Dst)
+	err = fs.Copy(ctx, items.Dirs, items.Items, srcDir, dst)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -342,7 +438,11 @@ func (service *ItemRenameService) Rename(ctx context.Context, c *gin.Context) se
 	}
 	defer fs.Recycle()
 
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	// 重命名对象
+	if err := fs.EnterSharedObjects(service.Src.Raw().Dirs, service.Src.Raw().Items, true); err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
 	err = fs.Rename(ctx, service.Src.Raw().Dirs, service.Src.Raw().Items, service.NewName)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
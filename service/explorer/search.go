@@ -5,6 +5,7 @@ import (
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/search"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/gin-gonic/gin"
 	"strings"
@@ -14,8 +15,13 @@ import (
 type ItemSearchService struct {
 	Type     string `uri:"type" binding:"required"`
 	Keywords string `uri:"keywords" binding:"required"`
+	Page     int    `form:"page" binding:"min=0"`
+	PageSize int    `form:"page_size" binding:"min=0,max=200"`
 }
 
+// defaultSearchPageSize 分页搜索时默认的每页结果数
+const defaultSearchPageSize = 50
+
 // Search 执行搜索
 func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	// 创建文件系统
@@ -27,15 +33,26 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 
 	switch service.Type {
 	case "keywords":
-		return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
-	case "image":
-		return service.SearchKeywords(c, fs, "%.bmp", "%.flac", "%.iff", "%.png", "%.gif", "%.jpg", "%.jpge", "%.psd", "%.svg", "%.webp")
-	case "video":
-		return service.SearchKeywords(c, fs, "%.mp4", "%.flv", "%.avi", "%.wmv", "%.mkv", "%.rm", "%.rmvb", "%.mov", "%.ogv")
-	case "audio":
-		return service.SearchKeywords(c, fs, "%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid")
-	case "doc":
-		return service.SearchKeywords(c, fs, "%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub")
+		if !search.Default.Ready() {
+			return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
+		}
+		query, err := search.ParseQuery(service.Keywords)
+		if err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+		return service.SearchIndex(c, fs, query)
+	case "image", "video", "audio", "doc":
+		exts := search.TypeExtensions[service.Type]
+		if !search.Default.Ready() {
+			patterns := make([]interface{}, len(exts))
+			for i, ext := range exts {
+				patterns[i] = "%." + ext
+			}
+			return service.SearchKeywords(c, fs, patterns...)
+		}
+		query := search.NewQuery()
+		query.Exts = exts
+		return service.SearchIndex(c, fs, query)
 	case "tag":
 		if tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID); err == nil {
 			if tag, err := model.GetTagsByID(tid, fs.User.ID); err == nil {
@@ -55,6 +72,27 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	}
 }
 
+// SearchIndex 使用搜索索引搜索文件，指定页码时分页返回结果
+func (service *ItemSearchService) SearchIndex(c *gin.Context, fs *filesystem.FileSystem, query *search.Query) serializer.Response {
+	if service.PageSize == 0 {
+		service.PageSize = defaultSearchPageSize
+	}
+
+	objects, total, err := fs.SearchIndex(context.Background(), query, service.Page, service.PageSize)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+			"total":   total,
+		},
+	}
+}
+
 // SearchKeywords 根据关键字搜索文件
 func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.FileSystem, keywords ...interface{}) serializer.Response {
 	// 上下文
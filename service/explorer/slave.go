@@ -0,0 +1,54 @@
+package explorer
+
+import (
+	"encoding/json"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task/slavetask"
+	"github.com/gin-gonic/gin"
+)
+
+// SlaveTaskCreateService 从机创建后台任务服务
+type SlaveTaskCreateService struct {
+	Type   int                     `json:"type"`
+	Props  string                  `json:"props" binding:"required"`
+	Policy serializer.UploadPolicy `json:"policy"`
+}
+
+// SlaveTaskService 从机后台任务服务
+type SlaveTaskService struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+// Create 创建并提交从机任务
+func (service *SlaveTaskCreateService) Create(c *gin.Context) serializer.Response {
+	job, err := slavetask.NewTask(serializer.SlaveTaskRequest{
+		Type:   service.Type,
+		Props:  service.Props,
+		Policy: service.Policy,
+	})
+	if err != nil {
+		return serializer.ParamErr("无法创建任务", err)
+	}
+
+	return serializer.Response{Data: slavetask.Submit(job)}
+}
+
+// Status 获取从机任务的执行状态
+func (service *SlaveTaskService) Status(c *gin.Context) serializer.Response {
+	job, err := slavetask.Get(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	// 将Data字段写为字符串方便主控端解析
+	res, _ := json.Marshal(job.Status())
+	return serializer.Response{Data: string(res)}
+}
+
+// Delete 删除从机任务，任务未结束时将其取消
+func (service *SlaveTaskService) Delete(c *gin.Context) serializer.Response {
+	if err := slavetask.Delete(service.ID); err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+	return serializer.Response{}
+}
@@ -0,0 +1,90 @@
+package explorer
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// TrashListService 回收站列表服务
+type TrashListService struct {
+	Page int `form:"page" binding:"required,min=1"`
+}
+
+// TrashItemService 回收站对象批量操作服务，字段值为HashID
+type TrashItemService struct {
+	Items []string `json:"items" binding:"required,min=1"`
+}
+
+// List 列出回收站中的对象
+func (service *TrashListService) List(c *gin.Context, user *model.User) serializer.Response {
+	trashes, total := model.ListTrash(user.ID, service.Page, 50, "created_at desc")
+	return serializer.BuildTrashList(trashes, total)
+}
+
+// Raw 获取回收站记录的原始ID
+func (service *TrashItemService) Raw() []uint {
+	ids := make([]uint, 0, len(service.Items))
+	for _, item := range service.Items {
+		id, err := hashid.DecodeHashID(item, hashid.TrashID)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Restore 将回收站中的对象恢复至原位置
+func (service *TrashItemService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.RestoreTrash(ctx, service.Raw()); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Purge 彻底删除回收站中的对象
+func (service *TrashItemService) Purge(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.PurgeTrash(ctx, service.Raw()); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// EmptyTrash 清空回收站
+func EmptyTrash(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.EmptyTrash(ctx); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
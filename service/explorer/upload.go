@@ -2,9 +2,13 @@ package explorer
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/request"
 	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
 	"github.com/gin-gonic/gin"
 )
 
@@ -24,6 +28,12 @@ func (service *UploadCredentialService) Get(ctx context.Context, c *gin.Context)
 		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
 	}
 
+	// 上传至“与我共享”中的目录时，使用目录所有者的容量及存储策略
+	service.Path, err = fs.EnterSharedPath(service.Path, true)
+	if err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+
 	// 存储策略是否一致
 	if service.Type != "" {
 		if service.Type != fs.User.Policy.Type {
@@ -42,3 +52,333 @@ func (service *UploadCredentialService) Get(ctx context.Context, c *gin.Context)
 		Data: credential,
 	}
 }
+
+// UploadSessionCreateService 创建分片上传会话服务
+type UploadSessionCreateService struct {
+	Path     string `json:"path" binding:"required"`
+	Size     uint64 `json:"size" binding:"min=0"`
+	Name     string `json:"name" binding:"required"`
+	MIMEType string `json:"mime_type"`
+}
+
+// UploadSessionService 分片上传会话服务
+type UploadSessionService struct {
+	ID string `uri:"sessionId" binding:"required"`
+}
+
+// UploadChunkService 上传分片服务
+type UploadChunkService struct {
+	ID    string `uri:"sessionId" binding:"required"`
+	Index uint   `uri:"index" binding:"min=0"`
+}
+
+// Create 创建分片上传会话
+func (service *UploadSessionCreateService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 上传至“与我共享”中的目录时，使用目录所有者的容量及存储策略
+	service.Path, err = fs.EnterSharedPath(service.Path, true)
+	if err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+
+	// 重名时自动重命名
+	fileName, err := fs.GetUniqueFileName(ctx, service.Name, service.Path)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFolderFailed, err.Error(), err)
+	}
+	file := local.FileStream{
+		Size:        service.Size,
+		VirtualPath: service.Path,
+		Name:        fileName,
+		MIMEType:    service.MIMEType,
+	}
+
+	// 预先检查文件及用户容量，实际容量在合并分片时扣除
+	ctx = context.WithValue(ctx, fsctx.FileHeaderCtx, file)
+	if err := filesystem.HookValidateFile(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+	if err := filesystem.HookValidateCapacityWithoutIncrease(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	res, err := fs.CreateUploadSession(ctx, file)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: res,
+	}
+}
+
+// Status 获取分片上传会话已接收的分片
+func (service *UploadSessionService) Status(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	session, err := fs.GetUploadSession(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	status, err := fs.GetUploadSessionStatus(ctx, session)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: status,
+	}
+}
+
+// Complete 合并分片并完成上传
+func (service *UploadSessionService) Complete(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	session, err := fs.GetUploadSession(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	// 给文件系统分配钩子
+	fs.Use("BeforeUpload", filesystem.HookValidateFile)
+	fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", task.HookReplicate)
+	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+
+	// 执行上传
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.CompleteUploadSession(ctx, session); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// Delete 取消分片上传会话
+func (service *UploadSessionService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	session, err := fs.GetUploadSession(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := fs.CancelUploadSession(ctx, session); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// Upload 上传单个分片
+func (service *UploadChunkService) Upload(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	session, err := fs.GetUploadSession(service.ID)
+	if err != nil {
+		request.BlackHole(c.Request.Body)
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := fs.UploadChunk(ctx, session, service.Index, c.Request.Body); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// SlaveUploadSessionCreateService 从机创建分片上传会话服务
+type SlaveUploadSessionCreateService struct {
+	Key       string                  `json:"key" binding:"required,alphanum"`
+	Name      string                  `json:"name" binding:"required"`
+	MIMEType  string                  `json:"mime_type"`
+	Size      uint64                  `json:"size"`
+	ChunkSize uint64                  `json:"chunk_size" binding:"required"`
+	TTL       int                     `json:"ttl" binding:"required"`
+	Policy    serializer.UploadPolicy `json:"policy"`
+}
+
+// SlaveUploadSessionService 从机分片上传会话服务
+type SlaveUploadSessionService struct {
+	ID string `uri:"sessionId" binding:"required"`
+}
+
+// SlaveUploadChunkService 从机上传分片服务
+type SlaveUploadChunkService struct {
+	ID    string `uri:"sessionId" binding:"required"`
+	Index uint   `form:"chunk"`
+}
+
+// Create 在从机创建分片上传会话
+func (service *SlaveUploadSessionCreateService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建匿名文件系统
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 预先检查文件
+	ctx = context.WithValue(ctx, fsctx.UploadPolicyCtx, service.Policy)
+	ctx = context.WithValue(ctx, fsctx.FileHeaderCtx, local.FileStream{
+		Size:     service.Size,
+		Name:     service.Name,
+		MIMEType: service.MIMEType,
+	})
+	if err := filesystem.HookSlaveUploadValidate(ctx, fs); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	// 从机不执行定时任务，创建新会话时顺便清理过期的分片
+	filesystem.CollectChunkSessions()
+
+	session := &serializer.ChunkUploadSession{
+		Key:       service.Key,
+		Name:      service.Name,
+		MIMEType:  service.MIMEType,
+		Size:      service.Size,
+		ChunkSize: service.ChunkSize,
+		Policy:    service.Policy,
+	}
+	if err := filesystem.CreateChunkSession(session, service.TTL); err != nil {
+		return serializer.Err(serializer.CodeCacheOperation, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// Status 获取从机分片上传会话已接收的分片
+func (service *SlaveUploadSessionService) Status(ctx context.Context, c *gin.Context) serializer.Response {
+	session, err := filesystem.GetChunkSession(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	received, err := filesystem.ReceivedChunks(session)
+	if err != nil {
+		return serializer.Err(serializer.CodeIOFailed, err.Error(), err)
+	}
+
+	res, _ := json.Marshal(serializer.UploadSessionStatus{
+		Size:      session.Size,
+		ChunkSize: session.ChunkSize,
+		Received:  received,
+	})
+	return serializer.Response{Data: string(res)}
+}
+
+// Complete 合并从机上的分片并完成上传
+func (service *SlaveUploadSessionService) Complete(ctx context.Context, c *gin.Context) serializer.Response {
+	session, err := filesystem.GetChunkSession(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	chunks, err := filesystem.OpenChunks(session)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	// 创建匿名文件系统
+	fs, err := filesystem.NewAnonymousFileSystem()
+	if err != nil {
+		chunks.Close()
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	fs.Handler = local.NewSlaveDriver(session.Policy)
+
+	// 给文件系统分配钩子
+	fs.Use("BeforeUpload", filesystem.HookSlaveUploadValidate)
+	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+	fs.Use("AfterUpload", filesystem.SlaveAfterUpload)
+	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+
+	// 执行上传
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	ctx = context.WithValue(ctx, fsctx.UploadPolicyCtx, session.Policy)
+	err = fs.Upload(ctx, local.FileStream{
+		File:     chunks,
+		Size:     session.Size,
+		Name:     session.Name,
+		MIMEType: session.MIMEType,
+	})
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	filesystem.DeleteChunkSession(session.Key)
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// Delete 删除从机上的分片上传会话
+func (service *SlaveUploadSessionService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	if _, err := filesystem.GetChunkSession(service.ID); err != nil {
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	filesystem.DeleteChunkSession(service.ID)
+	return serializer.Response{
+		Code: 0,
+	}
+}
+
+// Upload 向从机上传单个分片
+func (service *SlaveUploadChunkService) Upload(ctx context.Context, c *gin.Context) serializer.Response {
+	session, err := filesystem.GetChunkSession(service.ID)
+	if err != nil {
+		request.BlackHole(c.Request.Body)
+		return serializer.Err(serializer.CodeNotFound, err.Error(), err)
+	}
+
+	if err := filesystem.SaveChunk(session, service.Index, c.Request.Body); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+	}
+}
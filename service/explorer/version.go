@@ -0,0 +1,119 @@
+package explorer
+
+import (
+	"context"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FileVersionService 文件单个历史版本服务
+type FileVersionService struct {
+	Version string `uri:"version" binding:"required"`
+}
+
+// FileVersionDeleteService 文件历史版本删除服务
+type FileVersionDeleteService struct {
+	Versions []string `json:"versions" binding:"required,min=1"`
+}
+
+// ListVersions 列出文件的历史版本
+func (service *FileIDService) ListVersions(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+
+	versions, err := fs.ListFileVersions(ctx, objectID.(uint))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.BuildFileVersionList(versions)
+}
+
+// CreateDownloadSession 创建历史版本的下载会话，获取下载URL
+func (service *FileVersionService) CreateDownloadSession(ctx context.Context, c *gin.Context) serializer.Response {
+	versionID, err := hashid.DecodeHashID(service.Version, hashid.VersionID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "历史版本不存在", err)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+
+	// 获取下载地址
+	downloadURL, err := fs.GetVersionDownloadURL(ctx, objectID.(uint), versionID, "download_timeout")
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: downloadURL,
+	}
+}
+
+// Restore 将文件恢复至此历史版本
+func (service *FileVersionService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	versionID, err := hashid.DecodeHashID(service.Version, hashid.VersionID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "历史版本不存在", err)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+
+	if err := fs.RestoreFileVersion(ctx, objectID.(uint), versionID); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Delete 删除文件的历史版本
+func (service *FileVersionDeleteService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	versionIDs := make([]uint, 0, len(service.Versions))
+	for _, version := range service.Versions {
+		id, err := hashid.DecodeHashID(version, hashid.VersionID)
+		if err == nil {
+			versionIDs = append(versionIDs, id)
+		}
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+
+	// 获取对象id
+	objectID, _ := c.Get("object_id")
+
+	if err := fs.DeleteFileVersions(ctx, objectID.(uint), versionIDs); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
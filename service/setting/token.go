@@ -0,0 +1,95 @@
+package setting
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+	"strings"
+	"time"
+)
+
+// APITokenListService API 令牌列表服务
+type APITokenListService struct {
+}
+
+// APITokenService API 令牌管理服务
+type APITokenService struct {
+	ID uint `uri:"id" binding:"required,min=1"`
+}
+
+// APITokenCreateService API 令牌创建服务
+type APITokenCreateService struct {
+	Name   string   `json:"name" binding:"required,min=1,max=255"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// 有效期（秒），为 0 表示永不过期
+	Expires int64 `json:"expires" binding:"min=0"`
+}
+
+// Create 创建 API 令牌，令牌明文只在创建时返回一次
+func (service *APITokenCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	for _, scope := range service.Scopes {
+		if !model.IsValidScope(scope) {
+			return serializer.ParamErr("未知的权限范围 "+scope, nil)
+		}
+		if scope == model.ScopeAdmin && user.Group.ID != 1 && user.ID != 1 {
+			return serializer.Err(serializer.CodeAdminRequired, "非管理员无法创建管理权限的令牌", nil)
+		}
+	}
+
+	token := model.APIToken{
+		Name:   service.Name,
+		UserID: user.ID,
+		Scopes: strings.Join(service.Scopes, ","),
+	}
+	if service.Expires > 0 {
+		expires := time.Now().Add(time.Duration(service.Expires) * time.Second)
+		token.ExpiresAt = &expires
+	}
+
+	plain, err := token.Generate()
+	if err != nil {
+		return serializer.Err(serializer.CodeEncryptError, "无法生成令牌", err)
+	}
+
+	if _, err := token.Create(); err != nil {
+		return serializer.DBErr("创建失败", err)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"id":         token.ID,
+			"name":       token.Name,
+			"token":      plain,
+			"scopes":     token.ScopeList(),
+			"expires_at": token.ExpiresAt,
+			"created_at": token.CreatedAt,
+		},
+	}
+}
+
+// Delete 吊销 API 令牌
+func (service *APITokenService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	model.DeleteAPITokenByID(service.ID, user.ID)
+	return serializer.Response{}
+}
+
+// Tokens 列出 API 令牌
+func (service *APITokenListService) Tokens(c *gin.Context, user *model.User) serializer.Response {
+	tokens := model.ListAPITokens(user.ID)
+
+	res := make([]map[string]interface{}, 0, len(tokens))
+	for _, token := range tokens {
+		res = append(res, map[string]interface{}{
+			"id":           token.ID,
+			"name":         token.Name,
+			"scopes":       token.ScopeList(),
+			"expires_at":   token.ExpiresAt,
+			"last_used_at": token.LastUsedAt,
+			"created_at":   token.CreatedAt,
+		})
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"tokens": res,
+	}}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-gonic/gin"
+	"path"
 )
 
 // WebDAVListService WebDAV 列表服务
@@ -18,8 +19,9 @@ type WebDAVAccountService struct {
 
 // WebDAVAccountCreateService WebDAV 账号创建服务
 type WebDAVAccountCreateService struct {
-	Path string `json:"path" binding:"required,min=1,max=65535"`
-	Name string `json:"name" binding:"required,min=1,max=255"`
+	Path     string `json:"path" binding:"required,min=1,max=65535"`
+	Name     string `json:"name" binding:"required,min=1,max=255"`
+	Readonly bool   `json:"readonly"`
 }
 
 // WebDAVMountCreateService WebDAV 挂载创建服务
@@ -34,7 +36,8 @@ func (service *WebDAVAccountCreateService) Create(c *gin.Context, user *model.Us
 		Name:     service.Name,
 		Password: util.RandStringRunes(32),
 		UserID:   user.ID,
-		Root:     service.Path,
+		Root:     path.Clean("/" + service.Path),
+		Readonly: service.Readonly,
 	}
 
 	if _, err := account.Create(); err != nil {
@@ -45,6 +48,7 @@ func (service *WebDAVAccountCreateService) Create(c *gin.Context, user *model.Us
 		Data: map[string]interface{}{
 			"id":         account.ID,
 			"password":   account.Password,
+			"readonly":   account.Readonly,
 			"created_at": account.CreatedAt,
 		},
 	}
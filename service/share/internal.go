@@ -0,0 +1,209 @@
+package share
+
+import (
+	"fmt"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// InternalShareCreateService 站内共享创建服务
+type InternalShareCreateService struct {
+	SourceID string `json:"id" binding:"required"`
+	// 共享给的用户Email，与 Group 二选一
+	User     string `json:"user" binding:"omitempty,email"`
+	Group    uint   `json:"group"`
+	Writable bool   `json:"writable"`
+}
+
+// InternalShareListService 站内共享列表服务
+type InternalShareListService struct {
+	SourceID string `form:"id"`
+}
+
+// InternalShareService 站内共享管理服务
+type InternalShareService struct {
+	ID uint `uri:"id" binding:"required,min=1"`
+}
+
+// InternalShareUpdateService 站内共享更新服务
+type InternalShareUpdateService struct {
+	ID       uint `uri:"id" binding:"required,min=1"`
+	Writable bool `json:"writable"`
+}
+
+// Create 将目录共享给指定用户或用户组，已存在相同共享时更新其写入权限
+func (service *InternalShareCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if !user.Group.ShareEnabled {
+		return serializer.Err(serializer.CodeNoPermissionErr, "您无权共享目录", nil)
+	}
+	if (service.User == "") == (service.Group == 0) {
+		return serializer.ParamErr("需指定共享的用户或用户组其中之一", nil)
+	}
+
+	// 目录是否存在
+	folderID, err := hashid.DecodeHashID(service.SourceID, hashid.FolderID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "目录不存在", nil)
+	}
+	folders, err := model.GetFoldersByIDs([]uint{folderID}, user.ID)
+	if err != nil || len(folders) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "目录不存在", nil)
+	}
+	if folders[0].ParentID == nil {
+		return serializer.ParamErr("无法共享根目录", nil)
+	}
+
+	share := model.InternalShare{
+		UserID:   user.ID,
+		FolderID: folderID,
+		Writable: service.Writable,
+	}
+	target := folders[0].Name + " -> "
+	if service.User != "" {
+		targetUser, err := model.GetUserByEmail(service.User)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "用户不存在", err)
+		}
+		if targetUser.ID == user.ID {
+			return serializer.ParamErr("无法共享给自己", nil)
+		}
+		share.TargetUserID = targetUser.ID
+		target += targetUser.Email
+	} else {
+		// 游客用户组不能作为共享对象
+		group, err := model.GetGroupByID(service.Group)
+		if err != nil || group.ID == 3 {
+			return serializer.Err(serializer.CodeNotFound, "用户组不存在", err)
+		}
+		share.TargetGroupID = group.ID
+		target += group.Name
+	}
+
+	// 已存在相同共享时只更新写入权限
+	var existed model.InternalShare
+	if model.DB.Where(
+		"user_id = ? and folder_id = ? and target_user_id = ? and target_group_id = ?",
+		user.ID, folderID, share.TargetUserID, share.TargetGroupID,
+	).First(&existed).Error == nil {
+		err = existed.Update(map[string]interface{}{"writable": service.Writable})
+		share = existed
+		share.Writable = service.Writable
+	} else {
+		_, err = share.Create()
+	}
+	audit.Record(c, user, audit.ActionInternalShareCreate, target, err)
+	if err != nil {
+		return serializer.DBErr("共享创建失败", err)
+	}
+
+	return serializer.Response{
+		Data: internalShareResponse(&share),
+	}
+}
+
+// List 列出用户创建的站内共享，指定目录时只列出此目录的共享
+func (service *InternalShareListService) List(c *gin.Context, user *model.User) serializer.Response {
+	var folderID uint
+	if service.SourceID != "" {
+		id, err := hashid.DecodeHashID(service.SourceID, hashid.FolderID)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "目录不存在", nil)
+		}
+		folderID = id
+	}
+
+	shares, err := model.ListInternalShares(user.ID, folderID)
+	if err != nil {
+		return serializer.DBErr("无法列出共享", err)
+	}
+
+	res := make([]map[string]interface{}, 0, len(shares))
+	for i := 0; i < len(shares); i++ {
+		res = append(res, internalShareResponse(&shares[i]))
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"shares": res,
+	}}
+}
+
+// Groups 列出可作为共享对象的用户组
+func (service *InternalShareListService) Groups(c *gin.Context, user *model.User) serializer.Response {
+	var groups []model.Group
+	if err := model.DB.Where("id <> ?", 3).Find(&groups).Error; err != nil {
+		return serializer.DBErr("无法列出用户组", err)
+	}
+
+	res := make([]map[string]interface{}, 0, len(groups))
+	for _, group := range groups {
+		res = append(res, map[string]interface{}{
+			"id":   group.ID,
+			"name": group.Name,
+		})
+	}
+
+	return serializer.Response{Data: res}
+}
+
+// Update 更新站内共享的写入权限
+func (service *InternalShareUpdateService) Update(c *gin.Context, user *model.User) serializer.Response {
+	share, err := model.GetInternalShareByID(service.ID, user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "共享不存在", err)
+	}
+
+	err = share.Update(map[string]interface{}{"writable": service.Writable})
+	audit.Record(c, user, audit.ActionInternalShareUpdate, fmt.Sprintf("%d writable=%t", share.ID, service.Writable), err)
+	if err != nil {
+		return serializer.DBErr("共享更新失败", err)
+	}
+
+	share.Writable = service.Writable
+	return serializer.Response{
+		Data: internalShareResponse(share),
+	}
+}
+
+// Delete 取消站内共享
+func (service *InternalShareService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	share, err := model.GetInternalShareByID(service.ID, user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "共享不存在", err)
+	}
+
+	err = share.Delete()
+	audit.Record(c, user, audit.ActionInternalShareDelete, fmt.Sprintf("%d", share.ID), err)
+	if err != nil {
+		return serializer.DBErr("共享删除失败", err)
+	}
+
+	return serializer.Response{}
+}
+
+// internalShareResponse 序列化站内共享
+func internalShareResponse(share *model.InternalShare) map[string]interface{} {
+	res := map[string]interface{}{
+		"id":         share.ID,
+		"folder":     hashid.HashID(share.FolderID, hashid.FolderID),
+		"writable":   share.Writable,
+		"created_at": share.CreatedAt,
+	}
+	if share.TargetUserID != 0 {
+		if target, err := model.GetUserByID(share.TargetUserID); err == nil {
+			res["user"] = map[string]interface{}{
+				"id":    hashid.HashID(target.ID, hashid.UserID),
+				"email": target.Email,
+				"nick":  target.Nick,
+			}
+		}
+	} else if group, err := model.GetGroupByID(share.TargetGroupID); err == nil {
+		res["group"] = map[string]interface{}{
+			"id":   group.ID,
+			"name": group.Name,
+		}
+	}
+	return res
+}
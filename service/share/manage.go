@@ -2,10 +2,12 @@ package share
 
 import (
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/hashid"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/gin-gonic/gin"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -17,6 +19,12 @@ type ShareCreateService struct {
 	RemainDownloads int    `json:"downloads"`
 	Expire          int    `json:"expire"`
 	Preview         bool   `json:"preview"`
+
+	// 文件收集，只能用于目录
+	FileRequest bool     `json:"file_request"`
+	MaxSize     uint64   `json:"max_size"`
+	AllowedExts []string `json:"allowed_exts"`
+	UploadQuota uint64   `json:"upload_quota"`
 }
 
 // ShareUpdateService 分享更新服务
@@ -32,7 +40,9 @@ func (service *Service) Delete(c *gin.Context, user *model.User) serializer.Resp
 		return serializer.Err(serializer.CodeNotFound, "分享不存在", nil)
 	}
 
-	if err := share.Delete(); err != nil {
+	err := share.Delete()
+	audit.Record(c, user, audit.ActionShareDelete, c.Param("id")+" "+share.SourceName, err)
+	if err != nil {
 		return serializer.Err(serializer.CodeDBError, "分享删除失败", err)
 	}
 
@@ -43,16 +53,19 @@ func (service *Service) Delete(c *gin.Context, user *model.User) serializer.Resp
 func (service *ShareUpdateService) Update(c *gin.Context) serializer.Response {
 	shareCtx, _ := c.Get("share")
 	share := shareCtx.(*model.Share)
+	target := c.Param("id") + " " + service.Prop
 
 	switch service.Prop {
 	case "password":
 		err := share.Update(map[string]interface{}{"password": service.Value})
+		audit.Record(c, nil, audit.ActionShareUpdate, target, err)
 		if err != nil {
 			return serializer.Err(serializer.CodeDBError, "无法更新分享密码", err)
 		}
 	case "preview_enabled":
 		value := service.Value == "true"
 		err := share.Update(map[string]interface{}{"preview_enabled": value})
+		audit.Record(c, nil, audit.ActionShareUpdate, target, err)
 		if err != nil {
 			return serializer.Err(serializer.CodeDBError, "无法更新分享属性", err)
 		}
@@ -75,6 +88,10 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeNoPermissionErr, "您无权创建分享链接", nil)
 	}
 
+	if service.FileRequest && !service.IsDir {
+		return serializer.ParamErr("文件收集只能用于目录", nil)
+	}
+
 	// 源对象真实ID
 	var (
 		sourceID   uint
@@ -121,8 +138,26 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		SourceName:      sourceName,
 	}
 
-	// 如果开启了自动过期
-	if service.RemainDownloads > 0 {
+	// 文件收集不限制下载次数，只使用过期时间
+	if service.FileRequest {
+		exts := make([]string, 0, len(service.AllowedExts))
+		for _, ext := range service.AllowedExts {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext != "" && !strings.Contains(ext, ",") {
+				exts = append(exts, ext)
+			}
+		}
+		newShare.FileRequest = true
+		newShare.PreviewEnabled = false
+		newShare.MaxSize = service.MaxSize
+		newShare.AllowedExts = strings.Join(exts, ",")
+		newShare.UploadQuota = service.UploadQuota
+		if service.Expire > 0 {
+			expires := time.Now().Add(time.Duration(service.Expire) * time.Second)
+			newShare.Expires = &expires
+		}
+	} else if service.RemainDownloads > 0 {
+		// 如果开启了自动过期
 		expires := time.Now().Add(time.Duration(service.Expire) * time.Second)
 		newShare.RemainDownloads = service.RemainDownloads
 		newShare.Expires = &expires
@@ -131,11 +166,13 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 	// 创建分享
 	id, err := newShare.Create()
 	if err != nil {
+		audit.Record(c, user, audit.ActionShareCreate, sourceName, err)
 		return serializer.Err(serializer.CodeDBError, "分享链接创建失败", err)
 	}
 
 	// 获取分享的唯一id
 	uid := hashid.HashID(id, hashid.ShareID)
+	audit.Record(c, user, audit.ActionShareCreate, uid+" "+sourceName, nil)
 	// 最终得到分享链接
 	siteURL := model.GetSiteURL()
 	sharePath, _ := url.Parse("/#/s/" + uid)
@@ -0,0 +1,100 @@
+package share
+
+import (
+	"context"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/email"
+	"github.com/HFO4/cloudreve/pkg/filesystem"
+	"github.com/HFO4/cloudreve/pkg/filesystem/driver/local"
+	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/gin-gonic/gin"
+	"io"
+	"path"
+)
+
+// UploadService 向文件收集上传文件的服务
+type UploadService struct {
+	Name     string
+	Size     uint64
+	MIMEType string
+}
+
+// Upload 将文件上传至文件收集的源目录，容量及存储策略计入分享创建者
+func (service *UploadService) Upload(ctx context.Context, c *gin.Context, file io.ReadCloser) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+	userCtx, _ := c.Get("user")
+	visitor := userCtx.(*model.User)
+
+	if service.Name == "" || path.Base(service.Name) != service.Name {
+		return serializer.ParamErr("无效的文件名", nil)
+	}
+	if err := share.CanUpload(service.Name, service.Size); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), nil)
+	}
+
+	// 以分享创建者的身份上传
+	owner := share.Creator()
+	fs, err := filesystem.NewFileSystem(owner)
+	if err != nil {
+		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
+	}
+	defer fs.Recycle()
+	fs.Operator = visitor
+
+	// 非可用策略时拒绝上传
+	if !fs.User.Policy.IsTransitUpload(service.Size) {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "当前存储策略无法使用", nil)
+	}
+
+	// 源目录路径
+	folder := share.SourceFolder()
+	if err := folder.TraceRoot(); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "目录不存在", err)
+	}
+	dirPath := path.Join(folder.Position, folder.Name)
+
+	// 重名时自动重命名，不覆盖已有文件
+	fileName, err := fs.GetUniqueFileName(ctx, service.Name, dirPath)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+	fileData := local.FileStream{
+		MIMEType:    service.MIMEType,
+		File:        file,
+		Size:        service.Size,
+		Name:        fileName,
+		VirtualPath: dirPath,
+	}
+
+	// 给文件系统分配钩子
+	fs.Use("BeforeUpload", filesystem.HookValidateFile)
+	fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+	fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
+	fs.Use("AfterUploadCanceled", filesystem.HookGiveBackCapacity)
+	fs.Use("AfterUpload", filesystem.GenericAfterUpload)
+	fs.Use("AfterUpload", task.HookReplicate)
+	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
+	fs.Use("AfterValidateFailed", filesystem.HookGiveBackCapacity)
+	fs.Use("AfterUploadFailed", filesystem.HookGiveBackCapacity)
+
+	// 执行上传
+	uploadCtx := context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Upload(uploadCtx, fileData); err != nil {
+		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
+	}
+	share.Received(service.Size)
+
+	// 通知分享创建者
+	title, body := email.NewFileRequestEmail(owner.Nick, share.SourceName, fileName, service.Size, dirPath)
+	if err := email.Send(owner.Email, title, body); err != nil {
+		util.Log().Warning("无法发送文件收集通知邮件，%s", err)
+	}
+
+	return serializer.Response{
+		Data: fileName,
+	}
+}
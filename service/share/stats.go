@@ -0,0 +1,79 @@
+package share
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// ShareStatsService 分享访问统计服务
+type ShareStatsService struct {
+	// 统计的天数，默认为 30 天
+	Days int `form:"days" binding:"min=0,max=90"`
+	// 访问记录的页码
+	Page int `form:"page" binding:"min=0"`
+}
+
+// Stats 获取分享的每日访问统计、下载最多的文件及访问记录
+func (service *ShareStatsService) Stats(c *gin.Context, user *model.User) serializer.Response {
+	share := model.GetShareByHashID(c.Param("id"))
+	if share == nil || share.UserID != user.ID {
+		return serializer.Err(serializer.CodeNotFound, "分享不存在", nil)
+	}
+
+	total := service.Days
+	if total == 0 {
+		total = 30
+	}
+	page := service.Page
+	if page == 0 {
+		page = 1
+	}
+
+	// 统计每日访问
+	views := make([]int, total)
+	downloads := make([]int, total)
+	date := make([]string, total)
+	toRound := time.Now()
+	timeBase := time.Date(toRound.Year(), toRound.Month(), toRound.Day()+1, 0, 0, 0, 0, toRound.Location())
+	counts := model.CountShareAccessesByDay(share.ID, timeBase.Add(-time.Duration(total)*time.Hour*24))
+	for day := range date {
+		start := timeBase.Add(-time.Duration(total-day) * time.Hour * 24)
+		date[day] = start.Format("1月2日")
+		views[day] = counts[start.Format("2006-01-02")][model.ShareAccessView]
+		downloads[day] = counts[start.Format("2006-01-02")][model.ShareAccessDownload]
+	}
+
+	// 访问记录
+	accesses, accessTotal := model.ListShareAccesses(share.ID, page, 20)
+	records := make([]map[string]interface{}, 0, len(accesses))
+	for _, access := range accesses {
+		record := map[string]interface{}{
+			"action":     access.Action,
+			"ip":         access.IP,
+			"user_agent": access.UserAgent,
+			"referer":    access.Referer,
+			"path":       access.Path,
+			"date":       access.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if access.UserID != 0 {
+			record["user"] = hashid.HashID(access.UserID, hashid.UserID)
+		}
+		records = append(records, record)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"date":        date,
+			"views":       views,
+			"downloads":   downloads,
+			"viewTotal":   share.Views,
+			"downTotal":   share.Downloads,
+			"topFiles":    model.TopShareFiles(share.ID, 10),
+			"records":     records,
+			"recordTotal": accessTotal,
+		},
+	}
+}
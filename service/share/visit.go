@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/filesystem"
 	"github.com/HFO4/cloudreve/pkg/filesystem/fsctx"
 	"github.com/HFO4/cloudreve/pkg/hashid"
@@ -134,6 +135,9 @@ func (service *ShareGetService) Get(c *gin.Context) serializer.Response {
 
 	if unlocked {
 		share.Viewed()
+		userCtx, _ := c.Get("user")
+		user, _ := userCtx.(*model.User)
+		share.RecordAccess(c, user, model.ShareAccessView, "")
 	}
 
 	return serializer.Response{
@@ -180,6 +184,8 @@ func (service *Service) CreateDownloadSession(c *gin.Context) serializer.Respons
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	audit.Record(c, user, audit.ActionDownload, "share:"+hashid.HashID(share.ID, hashid.ShareID)+" "+path.Join(fs.FileTarget[0].Position, fs.FileTarget[0].Name), nil)
+	share.RecordAccess(c, user, model.ShareAccessDownload, service.accessPath(share))
 
 	return serializer.Response{
 		Code: 0,
@@ -202,7 +208,9 @@ func (service *Service) PreviewContent(ctx context.Context, c *gin.Context, isTe
 	}
 	subService := explorer.FileIDService{}
 
-	return subService.PreviewContent(ctx, c, isText)
+	res := subService.PreviewContent(ctx, c, isText)
+	service.recordDownload(c, share, res)
+	return res
 }
 
 // CreateDocPreviewSession 创建Office预览会话，返回预览地址
@@ -220,7 +228,27 @@ func (service *Service) CreateDocPreviewSession(c *gin.Context) serializer.Respo
 	}
 	subService := explorer.FileIDService{}
 
-	return subService.CreateDocPreviewSession(ctx, c)
+	res := subService.CreateDocPreviewSession(ctx, c)
+	service.recordDownload(c, share, res)
+	return res
+}
+
+// accessPath 返回访问的文件相对于分享的路径，用于访问统计
+func (service *Service) accessPath(share *model.Share) string {
+	if share.IsDir {
+		return path.Clean("/" + service.Path)
+	}
+	return "/" + share.SourceName
+}
+
+// recordDownload 文件预览成功或重定向至文件源时记录为一次下载
+func (service *Service) recordDownload(c *gin.Context, share *model.Share, res serializer.Response) {
+	if res.Code != 0 && res.Code != -301 {
+		return
+	}
+	userCtx, _ := c.Get("user")
+	user, _ := userCtx.(*model.User)
+	share.RecordAccess(c, user, model.ShareAccessDownload, service.accessPath(share))
 }
 
 // List 列出分享的目录下的对象
@@ -366,5 +394,9 @@ func (service *ArchiveService) Archive(c *gin.Context) serializer.Response {
 		Items: service.Items,
 	}
 
-	return subService.Archive(ctx, c)
+	res := subService.Archive(ctx, c)
+	if res.Code == 0 {
+		share.RecordAccess(c, user, model.ShareAccessDownload, path.Clean("/"+service.Path))
+	}
+	return res
 }
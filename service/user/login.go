@@ -1,17 +1,22 @@
 package user
 
 import (
+	"errors"
 	"fmt"
 	"github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
 	"github.com/HFO4/cloudreve/pkg/cache"
 	"github.com/HFO4/cloudreve/pkg/email"
 	"github.com/HFO4/cloudreve/pkg/hashid"
+	"github.com/HFO4/cloudreve/pkg/ldap"
+	"github.com/HFO4/cloudreve/pkg/lockout"
 	"github.com/HFO4/cloudreve/pkg/recaptcha"
 	"github.com/HFO4/cloudreve/pkg/serializer"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/mojocn/base64Captcha"
 	"github.com/pquerna/otp/totp"
+	"math"
 	"net/url"
 	"strings"
 	"time"
@@ -117,6 +122,15 @@ func (service *UserResetEmailService) Reset(c *gin.Context) serializer.Response
 	return serializer.Response{}
 }
 
+// loginLockedErr 登录被锁定时的响应
+func loginLockedErr(remain time.Duration) serializer.Response {
+	return serializer.Err(
+		serializer.CodeLoginLocked,
+		fmt.Sprintf("登录失败次数过多，请 %d 秒后再试", int(math.Ceil(remain.Seconds()))),
+		nil,
+	)
+}
+
 // Login 二步验证继续登录
 func (service *Enable2FA) Login(c *gin.Context) serializer.Response {
 	if uid, ok := util.GetSession(c, "2fa_user_id").(uint); ok {
@@ -126,8 +140,15 @@ func (service *Enable2FA) Login(c *gin.Context) serializer.Response {
 			return serializer.Err(serializer.CodeNotFound, "用户不存在", nil)
 		}
 
+		// 登录失败次数过多时拒绝验证
+		if remain := lockout.Check(expectedUser.ID, c.ClientIP()); remain > 0 {
+			return loginLockedErr(remain)
+		}
+
 		// 验证二步验证代码
 		if !totp.Validate(service.Code, expectedUser.TwoFactor) {
+			lockout.Fail(expectedUser.ID, c.ClientIP())
+			audit.Record(c, &expectedUser, audit.ActionLogin, expectedUser.Email, errors.New("二步验证代码不正确"))
 			return serializer.ParamErr("验证代码不正确", nil)
 		}
 
@@ -136,6 +157,8 @@ func (service *Enable2FA) Login(c *gin.Context) serializer.Response {
 		util.SetSession(c, map[string]interface{}{
 			"user_id": expectedUser.ID,
 		})
+		lockout.Success(expectedUser.ID)
+		audit.Record(c, &expectedUser, audit.ActionLogin, expectedUser.Email, nil)
 
 		return serializer.BuildUserResponse(expectedUser)
 	}
@@ -169,17 +192,53 @@ func (service *UserLoginService) Login(c *gin.Context) serializer.Response {
 		}
 	}
 
+	// 登录失败次数过多时拒绝登录，不再校验密码
+	ip := c.ClientIP()
+	var uid uint
+	if err == nil {
+		uid = expectedUser.ID
+	}
+	if remain := lockout.Check(uid, ip); remain > 0 {
+		audit.Record(c, nil, audit.ActionLogin, service.UserName, errors.New("登录失败次数过多，已被锁定"))
+		return loginLockedErr(remain)
+	}
+
+	// 启用 LDAP 时优先使用目录校验，目录中的新用户会被自动创建
+	ldapAuthenticated := false
+	if ldap.Enabled() {
+		user, ldapErr := ldap.Authenticate(service.UserName, service.Password)
+		switch ldapErr {
+		case nil:
+			expectedUser, err = *user, nil
+			ldapAuthenticated = true
+		case ldap.ErrNotLDAPUser:
+			// 继续使用本地密码校验
+		default:
+			lockout.Fail(uid, ip)
+			audit.Record(c, nil, audit.ActionLogin, service.UserName, ldapErr)
+			return serializer.Err(401, "用户邮箱或密码错误", nil)
+		}
+	}
+
 	// 一系列校验
 	if err != nil {
+		lockout.Fail(0, ip)
+		audit.Record(c, nil, audit.ActionLogin, service.UserName, errors.New("用户不存在"))
 		return serializer.Err(401, "用户邮箱或密码错误", err)
 	}
-	if authOK, _ := expectedUser.CheckPassword(service.Password); !authOK {
-		return serializer.Err(401, "用户邮箱或密码错误", nil)
+	if !ldapAuthenticated {
+		if authOK, _ := expectedUser.CheckPassword(service.Password); !authOK {
+			lockout.Fail(expectedUser.ID, ip)
+			audit.Record(c, &expectedUser, audit.ActionLogin, service.UserName, errors.New("密码错误"))
+			return serializer.Err(401, "用户邮箱或密码错误", nil)
+		}
 	}
 	if expectedUser.Status == model.Baned || expectedUser.Status == model.OveruseBaned {
+		audit.Record(c, &expectedUser, audit.ActionLogin, service.UserName, errors.New("账号已被封禁"))
 		return serializer.Err(403, "该账号已被封禁", nil)
 	}
 	if expectedUser.Status == model.NotActivicated {
+		audit.Record(c, &expectedUser, audit.ActionLogin, service.UserName, errors.New("账号未激活"))
 		return serializer.Err(403, "该账号未激活", nil)
 	}
 
@@ -195,6 +254,8 @@ func (service *UserLoginService) Login(c *gin.Context) serializer.Response {
 	util.SetSession(c, map[string]interface{}{
 		"user_id": expectedUser.ID,
 	})
+	lockout.Success(expectedUser.ID)
+	audit.Record(c, &expectedUser, audit.ActionLogin, service.UserName, nil)
 
 	return serializer.BuildUserResponse(expectedUser)
 
@@ -0,0 +1,196 @@
+package user
+
+import (
+	"errors"
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/audit"
+	"github.com/HFO4/cloudreve/pkg/oidc"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"net/url"
+	"strings"
+)
+
+// OIDCLoginService 发起 OpenID Connect 登录的服务
+type OIDCLoginService struct {
+}
+
+// OIDCCallbackService OpenID Connect 授权回调服务
+type OIDCCallbackService struct {
+	Code     string `form:"code"`
+	State    string `form:"state"`
+	Error    string `form:"error"`
+	ErrorMsg string `form:"error_description"`
+}
+
+// newOIDCClient 根据站点设置创建 OpenID Connect 客户端
+func newOIDCClient() *oidc.Client {
+	options := model.GetSettingByNames("oidc_issuer", "oidc_client_id", "oidc_client_secret", "oidc_scopes")
+	callback, _ := url.Parse("/api/v3/callback/oidc")
+	return oidc.NewClient(
+		options["oidc_issuer"],
+		options["oidc_client_id"],
+		options["oidc_client_secret"],
+		model.GetSiteURL().ResolveReference(callback).String(),
+		strings.Fields(options["oidc_scopes"]),
+	)
+}
+
+// Start 生成授权页面地址，并将 state、nonce 及 PKCE code_verifier 保存在会话中
+func (service *OIDCLoginService) Start(c *gin.Context) serializer.Response {
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := oidc.RandomString(32)
+		if err != nil {
+			return serializer.Err(serializer.CodeEncryptError, "无法生成登录会话", err)
+		}
+		secrets[i] = secret
+	}
+
+	authURL, err := newOIDCClient().AuthURL(c, secrets[0], secrets[1], secrets[2])
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "无法获取身份提供方信息", err)
+	}
+
+	util.SetSession(c, map[string]interface{}{
+		"oidc_state":    secrets[0],
+		"oidc_nonce":    secrets[1],
+		"oidc_verifier": secrets[2],
+	})
+
+	return serializer.Response{Data: authURL}
+}
+
+// Callback 完成授权码流程，登录或创建对应的用户
+func (service *OIDCCallbackService) Callback(c *gin.Context) serializer.Response {
+	if service.Error != "" {
+		return serializer.ParamErr(service.ErrorMsg, errors.New(service.Error))
+	}
+
+	state, _ := util.GetSession(c, "oidc_state").(string)
+	nonce, _ := util.GetSession(c, "oidc_nonce").(string)
+	verifier, _ := util.GetSession(c, "oidc_verifier").(string)
+	util.DeleteSession(c, "oidc_state")
+	util.DeleteSession(c, "oidc_nonce")
+	util.DeleteSession(c, "oidc_verifier")
+	if state == "" || state != service.State {
+		return serializer.Err(serializer.CodeNotFound, "登录会话不存在或已过期，请重试", nil)
+	}
+
+	client := newOIDCClient()
+	token, err := client.Exchange(c, service.Code, verifier)
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "无法获取令牌", err)
+	}
+
+	claims, err := client.Verify(c, token.IDToken, nonce)
+	if err != nil {
+		return serializer.Err(serializer.CodeCheckLogin, "ID Token 校验失败", err)
+	}
+
+	// ID Token 中未包含邮箱时，尝试从 userinfo 端点获取
+	if claims.Email == "" && token.AccessToken != "" {
+		if err := client.UserInfo(c, token.AccessToken, claims); err != nil {
+			util.Log().Warning("无法获取 OpenID Connect 用户信息, %s", err)
+		}
+	}
+
+	user, err := oidcUser(client.Issuer, claims)
+	if err != nil {
+		audit.Record(c, nil, audit.ActionLogin, claims.Email, err)
+		return serializer.Err(serializer.CodeCheckLogin, err.Error(), nil)
+	}
+
+	if user.Status == model.Baned || user.Status == model.OveruseBaned {
+		audit.Record(c, user, audit.ActionLogin, user.Email, errors.New("账号已被封禁"))
+		return serializer.Err(403, "该账号已被封禁", nil)
+	}
+	if user.Status == model.NotActivicated {
+		audit.Record(c, user, audit.ActionLogin, user.Email, errors.New("账号未激活"))
+		return serializer.Err(403, "该账号未激活", nil)
+	}
+
+	util.SetSession(c, map[string]interface{}{
+		"user_id": user.ID,
+	})
+	audit.Record(c, user, audit.ActionLogin, user.Email, nil)
+
+	return serializer.BuildUserResponse(*user)
+}
+
+// oidcUser 查找 claims 对应的用户，按需关联已有用户或创建新用户，并同步用户组
+func oidcUser(issuer string, claims *oidc.Claims) (*model.User, error) {
+	group, mapped := oidcGroup(claims)
+
+	// 已关联的用户
+	identity, err := model.GetOIDCIdentity(issuer, claims.Subject)
+	if err == nil {
+		user, err := model.GetUserByID(identity.UserID)
+		if err == nil {
+			if mapped && user.GroupID != group {
+				if err := user.Update(map[string]interface{}{"group_id": group}); err != nil {
+					return nil, err
+				}
+				user.GroupID = group
+			}
+			return &user, nil
+		}
+
+		// 用户已被删除，清理失效的关联
+		if !gorm.IsRecordNotFoundError(err) {
+			return nil, err
+		}
+		identity.Delete()
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, errors.New("身份提供方未返回邮箱")
+	}
+
+	// 通过已验证的邮箱关联已有用户
+	user, err := model.GetUserByEmail(claims.Email)
+	if err == nil {
+		if !claims.EmailVerified {
+			return nil, errors.New("邮箱已被其他账号使用，且身份提供方未验证该邮箱")
+		}
+		if mapped && user.GroupID != group {
+			if err := user.Update(map[string]interface{}{"group_id": group}); err != nil {
+				return nil, err
+			}
+			user.GroupID = group
+		}
+	} else {
+		if !model.IsTrueVal(model.GetSettingByName("oidc_auto_register")) {
+			return nil, errors.New("用户不存在")
+		}
+		if !mapped {
+			group = uint(model.GetIntSetting("oidc_default_group", 2))
+		}
+		created, err := model.CreateExternalUser(claims.Email, group, claims.PreferredUsername, claims.Name)
+		if err != nil {
+			return nil, err
+		}
+		user = *created
+	}
+
+	identity = &model.OIDCIdentity{UserID: user.ID, Issuer: issuer, Subject: claims.Subject}
+	if _, err := identity.Create(); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// oidcGroup 根据用户组映射规则计算用户组，返回是否有规则匹配
+func oidcGroup(claims *oidc.Claims) (uint, bool) {
+	return model.MatchGroupMapping(
+		model.GetSettingByName("oidc_group_mapping"),
+		"claim",
+		claims.Strings(model.GetSettingByName("oidc_group_claim")),
+		false,
+	)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	model "github.com/HFO4/cloudreve/models"
 	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/HFO4/cloudreve/pkg/task"
 	"github.com/HFO4/cloudreve/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/pquerna/otp/totp"
@@ -23,6 +24,11 @@ type SettingListService struct {
 	Page int `form:"page" binding:"required,min=1"`
 }
 
+// TaskService 用户任务操作服务
+type TaskService struct {
+	ID uint `uri:"id" binding:"required,min=1"`
+}
+
 // AvatarService 头像服务
 type AvatarService struct {
 	Size string `uri:"size" binding:"required,eq=l|eq=m|eq=s"`
@@ -239,6 +245,32 @@ func (service *SettingListService) ListTasks(c *gin.Context, user *model.User) s
 	return serializer.BuildTaskList(tasks, total)
 }
 
+// Cancel 取消用户的任务
+func (service *TaskService) Cancel(c *gin.Context, user *model.User) serializer.Response {
+	record, err := model.GetTaskByIDAndUser(service.ID, user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "任务不存在", err)
+	}
+
+	if err := task.Cancel(record); err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+	return serializer.Response{}
+}
+
+// Retry 重试用户的任务
+func (service *TaskService) Retry(c *gin.Context, user *model.User) serializer.Response {
+	record, err := model.GetTaskByIDAndUser(service.ID, user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "任务不存在", err)
+	}
+
+	if err := task.Retry(record); err != nil {
+		return serializer.Err(serializer.CodeNoPermissionErr, err.Error(), err)
+	}
+	return serializer.Response{}
+}
+
 // Settings 获取用户设定
 func (service *SettingService) Settings(c *gin.Context, user *model.User) serializer.Response {
 	return serializer.Response{
@@ -0,0 +1,25 @@
+package user
+
+import (
+	model "github.com/HFO4/cloudreve/models"
+	"github.com/HFO4/cloudreve/pkg/search"
+	"github.com/HFO4/cloudreve/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// StorageUsageService 用户容量使用统计服务
+type StorageUsageService struct {
+}
+
+// Types 按文件类型统计用户的容量使用
+func (service *StorageUsageService) Types(c *gin.Context, user *model.User) serializer.Response {
+	types, err := model.StorageUsageByType(user.ID, search.TypeExtensions)
+	if err != nil {
+		return serializer.DBErr("无法统计容量使用", err)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"used":  user.Storage,
+		"types": types,
+	}}
+}